@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,6 +22,47 @@ var (
 	version = "0.1.0"
 )
 
+// stringSliceFlag 可重複指定的字串旗標 (例如 -allow-tool a -allow-tool b)
+type stringSliceFlag []string
+
+func (f *stringSliceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringSliceFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runOptions 收集 run 子命令的所有參數
+type runOptions struct {
+	prompt      string
+	maxLoops    int
+	timeout     time.Duration
+	workDir     string
+	silent      bool
+	permissions *ghcopilot.PermissionPolicy
+	protect     []string // 額外的受保護路徑
+	noProtect   bool     // 停用受保護路徑檢查
+	changeScope *ghcopilot.ChangeScopePolicy
+	approve     bool // 每個迴圈結束後由操作者審核
+	hooks       []ghcopilot.ShellHook
+	logLevel    slog.Level // run 目錄日誌檔的等級
+	logConsole  slog.Level // stderr 日誌的等級 (-silent 時預設只顯示警告)
+	logFormat   ghcopilot.LogFormat
+	metricsAddr string                 // Prometheus 指標的 HTTP 位址
+	metricsFile string                 // node_exporter textfile collector 的輸出檔
+	noTrace     bool                   // 停用追蹤
+	otlpEnd     string                 // OTLP/HTTP collector 位址
+	tui         bool                   // 全螢幕終端機介面
+	junitPath   string                 // JUnit XML 輸出檔
+	sarifPath   string                 // SARIF 輸出檔
+	chaos       *ghcopilot.ChaosConfig // 混沌測試的故障注入
+	noContract  bool                   // 不附加 JSON 狀態區塊規格
+	noSDKTools  bool                   // 不在 SDK 會話註冊 agent 工具
+	noSDK       bool                   // 只使用 Copilot CLI，不啟動 SDK 執行器
+}
+
 func main() {
 	// 定義子命令
 	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
@@ -24,6 +71,47 @@ func main() {
 	runTimeout := runCmd.Duration("timeout", 5*time.Minute, "總執行逾時")
 	runWorkDir := runCmd.String("workdir", ".", "工作目錄")
 	runSilent := runCmd.Bool("silent", false, "靜默模式")
+	runPreset := runCmd.String("permissions", string(ghcopilot.PresetFull), "權限預設 ("+strings.Join(ghcopilot.PermissionPresetNames(), "|")+")")
+	runSafe := runCmd.Bool("safe", false, "安全模式 (等同 -permissions safe，禁止破壞性殼層指令)")
+	var runAllowTools, runDenyTools, runAddDirs stringSliceFlag
+	runCmd.Var(&runAllowTools, "allow-tool", "允許的工具 (可重複，例如 'shell(git status)'，指定後停用 --allow-all-tools)")
+	runCmd.Var(&runDenyTools, "deny-tool", "禁止的工具 (可重複，優先於允許)")
+	runCmd.Var(&runAddDirs, "add-dir", "額外允許存取的目錄 (可重複)")
+	var runAllowCommands, runAllowPaths stringSliceFlag
+	runCmd.Var(&runAllowCommands, "allow-command", "SDK 模式允許的殼層指令正規表示式 (可重複，例如 '^go (build|test|vet)\\b')")
+	runCmd.Var(&runAllowPaths, "allow-path", "SDK 模式允許寫入的路徑 glob (可重複，相對工作目錄，支援 **)")
+	runDenyNetwork := runCmd.Bool("deny-network", false, "SDK 模式拒絕 URL 請求與常見的網路指令 (curl、git push、go get 等)")
+	runMaxFileSize := runCmd.Int64("max-file-size", 0, "SDK 模式單次寫入檔案的大小上限 (位元組，0 表示不限制)")
+	runEscalate := runCmd.Bool("escalate-permissions", false, "SDK 權限請求不符合策略時詢問操作者，而不是直接拒絕")
+	var runProtect stringSliceFlag
+	runCmd.Var(&runProtect, "protect", "額外的受保護路徑 glob (可重複，預設已保護 go.mod、CI 與既有測試)")
+	runNoProtect := runCmd.Bool("no-protect", false, "停用受保護路徑檢查")
+	runMaxFiles := runCmd.Int("max-files", 0, "單一迴圈最多變更的檔案數 (0 表示不限制)")
+	runMaxAdded := runCmd.Int("max-added", 0, "單一迴圈最多新增行數 (0 表示不限制)")
+	runMaxRemoved := runCmd.Int("max-removed", 0, "單一迴圈最多刪除行數 (0 表示不限制)")
+	runMaxRunAdded := runCmd.Int("max-run-added", 0, "整次執行最多新增行數 (0 表示不限制)")
+	runMaxRunRemoved := runCmd.Int("max-run-removed", 0, "整次執行最多刪除行數 (0 表示不限制)")
+	var runScope stringSliceFlag
+	runCmd.Var(&runScope, "scope", "允許變更的路徑前綴 (可重複)")
+	runRevertOnScope := runCmd.Bool("revert-on-scope", false, "超出變更範圍時還原該迴圈的變更")
+	runApprove := runCmd.Bool("approve", false, "每個迴圈結束後顯示變更摘要，由操作者接受、拒絕、回饋或停止")
+	var runHooks, runStrictHooks stringSliceFlag
+	runCmd.Var(&runHooks, "hook", "殼層 hook point=command (可重複，point: pre_loop|post_loop|on_complete|on_breaker_open)")
+	runCmd.Var(&runStrictHooks, "hook-strict", "失敗時否決迴圈 (pre_loop) 或標記迴圈失敗 (post_loop) 的殼層 hook (可重複)")
+	runHookTimeout := runCmd.Duration("hook-timeout", ghcopilot.DefaultHookTimeout, "殼層 hook 逾時")
+	runLogLevel := runCmd.String("log-level", "info", "日誌等級 (debug|info|warn|error，RALPH_DEBUG=1 時預設 debug)")
+	runLogFormat := runCmd.String("log-format", "text", "日誌格式 (text|json)")
+	runNoContract := runCmd.Bool("no-status-contract", false, "不在 prompt 附加 JSON 狀態區塊規格，也不驗證狀態區塊 (沿用完成關鍵字判斷)")
+	runNoSDKTools := runCmd.Bool("no-sdk-tools", false, "不在 SDK 會話註冊 report_status、run_verification、read_loop_history 工具")
+	runNoSDK := runCmd.Bool("no-sdk", false, "只使用 Copilot CLI 執行，不啟動 SDK 執行器 (不能與 SDK 權限策略旗標併用)")
+	runNoTrace := runCmd.Bool("no-trace", false, "停用追蹤 (預設寫入 run 目錄的 traces.jsonl 並將 TRACEPARENT 傳給 Copilot CLI)")
+	runOTLPEndpoint := runCmd.String("otlp-endpoint", "", "同時將追蹤送往 OTLP/HTTP collector (例如 http://localhost:4318)")
+	runMetricsAddr := runCmd.String("metrics-addr", "", "以 HTTP 輸出 Prometheus 指標的位址 (例如 :9464，路徑 /metrics)")
+	runTUI := runCmd.Bool("tui", false, "全螢幕終端機介面 (輸出、迴圈歷史、熔斷器訊號與 p/s/f/a/r 按鍵；非終端機時改用逐行輸出)")
+	runMetricsFile := runCmd.String("metrics-file", "", "每個迴圈結束後寫入指標的檔案 (供 node_exporter textfile collector，副檔名 .prom)")
+	runJUnit := runCmd.String("junit", "", "結束後將每個迴圈寫成 JUnit XML testcase (驗證失敗或熔斷時為 failure)")
+	runSARIF := runCmd.String("sarif", "", "結束後將最後一次驗證留下的診斷寫成 SARIF")
+	runChaos := runCmd.String("chaos", "", "混沌測試：注入故障 (例如 reset=0.3,timeout=0.1,hang=5s,seed=42 或 script=reset;exit;none)")
 
 	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
 	statusWorkDir := statusCmd.String("workdir", ".", "工作目錄")
@@ -31,9 +119,71 @@ func main() {
 	resetCmd := flag.NewFlagSet("reset", flag.ExitOnError)
 	resetWorkDir := resetCmd.String("workdir", ".", "工作目錄")
 
+	ctlCmd := flag.NewFlagSet("ctl", flag.ExitOnError)
+	ctlWorkDir := ctlCmd.String("workdir", ".", "執行中 run 的工作目錄")
+
 	watchCmd := flag.NewFlagSet("watch", flag.ExitOnError)
 	watchWorkDir := watchCmd.String("workdir", ".", "工作目錄")
 	watchInterval := watchCmd.Duration("interval", 5*time.Second, "檢查間隔")
+	watchTUI := watchCmd.Bool("tui", false, "以全螢幕終端機介面追蹤最新的 run (非終端機時改用逐行輸出)")
+	watchHTTP := watchCmd.String("http", "", "改為在此位址提供網頁儀表板 (例如 :8080 或 127.0.0.1:8080)")
+
+	reportCmd := flag.NewFlagSet("report", flag.ExitOnError)
+	reportWorkDir := reportCmd.String("workdir", ".", "工作目錄 (讀取其中 .ralph-loop 的紀錄)")
+	reportRun := reportCmd.String("run", "latest", "run ID (預設為最新的 run)")
+	reportFormat := reportCmd.String("format", "md", "報告格式 (md|html)")
+	reportOutput := reportCmd.String("o", "", "輸出檔案 (預設輸出到 stdout)")
+
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+	historyWorkDir := historyCmd.String("workdir", ".", "工作目錄 (讀取其中 .ralph-loop 的紀錄)")
+	historyRun := historyCmd.String("run", "", "只列出此 run 的迴圈")
+	historyLoops := historyCmd.Bool("loops", false, "列出迴圈而非 run")
+	historyStatus := historyCmd.String("status", "", "run 狀態 (running|completed|stopped|cancelled|failed)，列出迴圈時為迴圈結果 (continue|complete|failed|stopped)")
+	historyModel := historyCmd.String("model", "", "模型名稱 (部分比對)")
+	historyExitReason := historyCmd.String("exit-reason", "", "退出理由 (部分比對)")
+	historySince := historyCmd.String("since", "", "開始時間不早於 (YYYY-MM-DD 或 RFC 3339)")
+	historyUntil := historyCmd.String("until", "", "開始時間不晚於 (YYYY-MM-DD 或 RFC 3339)")
+	historyContains := historyCmd.String("contains", "", "prompt、輸出或錯誤訊息包含的文字")
+	historyLimit := historyCmd.Int("limit", 0, "最多列出幾筆 (0 為不限)")
+	historyOutput := historyCmd.String("output", "text", "輸出格式 (text|json)")
+
+	historyShowCmd := flag.NewFlagSet("history show", flag.ExitOnError)
+	historyShowWorkDir := historyShowCmd.String("workdir", ".", "工作目錄 (讀取其中 .ralph-loop 的紀錄)")
+	historyShowOutput := historyShowCmd.String("output", "text", "輸出格式 (text|json)")
+
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	diffWorkDir := diffCmd.String("workdir", ".", "工作目錄 (讀取其中 .ralph-loop 的紀錄)")
+	diffOutput := diffCmd.String("output", "text", "輸出格式 (text|json)")
+
+	replayCmd := flag.NewFlagSet("replay", flag.ExitOnError)
+	replayWorkDir := replayCmd.String("workdir", ".", "工作目錄 (讀取其中 .ralph-loop 的紀錄)")
+	replayOutput := replayCmd.String("output", "text", "輸出格式 (text|json)")
+	replayCheck := replayCmd.Bool("check", false, "結果與原本的執行不同時以退出碼 1 結束")
+
+	statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
+	statsWorkDir := statsCmd.String("workdir", ".", "工作目錄 (讀取其中 .ralph-loop 的紀錄)")
+	statsSince := statsCmd.String("since", "", "只統計此時間之後開始的 run (YYYY-MM-DD 或 RFC 3339)")
+	statsUntil := statsCmd.String("until", "", "只統計此時間之前開始的 run (YYYY-MM-DD 或 RFC 3339)")
+	statsModel := statsCmd.String("model", "", "只統計此模型 (部分比對)")
+	statsOutput := statsCmd.String("output", "text", "輸出格式 (text|json|csv)")
+
+	benchCmd := flag.NewFlagSet("bench", flag.ExitOnError)
+	benchFixtures := benchCmd.String("fixtures", "testdata/bench", "題目目錄 (每個子目錄包含 fixture.json 與 repo/)")
+	benchFixture := benchCmd.String("fixture", "", "只執行這些題目 (逗號分隔)")
+	benchExecutor := benchCmd.String("executor", ghcopilot.BenchExecutorReal, "執行器 (real|fake|replay)")
+	benchReplay := benchCmd.String("replay", "", "replay 執行器回放的基準測試結果 (先前 -output json 的輸出)")
+	benchModel := benchCmd.String("model", "", "AI 模型 (預設: 與 run 相同)")
+	benchRequestCost := benchCmd.Float64("request-cost", 0.04, "每次執行器呼叫的估算費用 (USD)")
+	benchTimeout := benchCmd.Duration("timeout", 10*time.Minute, "每個題目的逾時 (0 表示不限制)")
+	benchOutput := benchCmd.String("output", "text", "輸出格式 (text|json)")
+
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	serveAddr := serveCmd.String("addr", "127.0.0.1:8787", "HTTP API 監聽位址")
+	serveWorkDir := serveCmd.String("workdir", ".", "預設工作目錄 (相對的 work_dir 以此為基準)")
+	serveToken := serveCmd.String("token", os.Getenv("RALPH_API_TOKEN"), "API token (預設讀取 RALPH_API_TOKEN；空值不需認證)")
+	serveShutdown := serveCmd.Duration("shutdown-timeout", 2*time.Minute, "關閉時等待 run 結束目前迴圈的時間，逾時後取消")
+	serveLogLevel := serveCmd.String("log-level", "info", "日誌等級 (debug|info|warn|error)")
+	serveLogFormat := serveCmd.String("log-format", "text", "日誌格式 (text|json)")
 
 	// 檢查參數
 	if len(os.Args) < 2 {
@@ -49,7 +199,124 @@ func main() {
 			runCmd.Usage()
 			os.Exit(1)
 		}
-		cmdRun(*runPrompt, *runMaxLoops, *runTimeout, *runWorkDir, *runSilent)
+		preset := ghcopilot.PermissionPreset(*runPreset)
+		if *runSafe {
+			presetSet := false
+			runCmd.Visit(func(f *flag.Flag) { presetSet = presetSet || f.Name == "permissions" })
+			if presetSet && preset != ghcopilot.PresetSafe {
+				fmt.Printf("錯誤: -safe 與 -permissions %s 衝突 (-safe 等同 -permissions safe)\n", preset)
+				os.Exit(1)
+			}
+			preset = ghcopilot.PresetSafe
+		}
+		policy, err := ghcopilot.NewPermissionPolicy(preset)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		policy.AllowTools(runAllowTools...).DenyTools(runDenyTools...).AddDirs(runAddDirs...)
+		policy.AllowedCommands = runAllowCommands
+		policy.AllowedPaths = runAllowPaths
+		policy.DenyNetwork = *runDenyNetwork
+		policy.MaxFileBytes = *runMaxFileSize
+		policy.Escalate = *runEscalate
+		if err := policy.Validate(); err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		// CLI 只接受 --allow-tool 等參數，這些限制只有 SDK 會話能執行
+		if *runNoSDK && policy.RequiresSDK() {
+			fmt.Println("錯誤: -allow-command、-allow-path、-deny-network、-max-file-size 與 -escalate-permissions 只在 SDK 模式生效，不能與 -no-sdk 併用")
+			os.Exit(1)
+		}
+		// 全螢幕介面佔用 stdin，無法逐一詢問權限請求
+		if *runTUI && *runEscalate {
+			fmt.Println("錯誤: -escalate-permissions 需要在終端機詢問操作者，不能與 -tui 併用")
+			os.Exit(1)
+		}
+
+		var hooks []ghcopilot.ShellHook
+		for _, group := range []struct {
+			specs   []string
+			enforce bool
+		}{{runHooks, false}, {runStrictHooks, true}} {
+			for _, spec := range group.specs {
+				hook, err := ghcopilot.ParseShellHook(spec, group.enforce)
+				if err != nil {
+					fmt.Printf("錯誤: %v\n", err)
+					os.Exit(1)
+				}
+				hook.Timeout = *runHookTimeout
+				hooks = append(hooks, hook)
+			}
+		}
+
+		// 日誌寫入 stderr 與 run 目錄，stdout 保留給執行輸出
+		levelSet := false
+		runCmd.Visit(func(f *flag.Flag) { levelSet = levelSet || f.Name == "log-level" })
+		if !levelSet && os.Getenv("RALPH_DEBUG") == "1" {
+			*runLogLevel = "debug"
+		}
+		logLevel, err := ghcopilot.ParseLogLevel(*runLogLevel)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		logFormat, err := ghcopilot.ParseLogFormat(*runLogFormat)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		logConsole := logLevel
+		if *runSilent && !levelSet && logConsole < slog.LevelWarn {
+			logConsole = slog.LevelWarn
+		}
+
+		var chaos *ghcopilot.ChaosConfig
+		if *runChaos != "" {
+			if chaos, err = ghcopilot.ParseChaosSpec(*runChaos); err != nil {
+				fmt.Printf("錯誤: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		scope := &ghcopilot.ChangeScopePolicy{
+			MaxFilesPerLoop:        *runMaxFiles,
+			MaxLinesAddedPerLoop:   *runMaxAdded,
+			MaxLinesRemovedPerLoop: *runMaxRemoved,
+			MaxLinesAddedPerRun:    *runMaxRunAdded,
+			MaxLinesRemovedPerRun:  *runMaxRunRemoved,
+			AllowedPathPrefixes:    runScope,
+			RevertOnViolation:      *runRevertOnScope,
+		}
+
+		cmdRun(runOptions{
+			prompt:      *runPrompt,
+			maxLoops:    *runMaxLoops,
+			timeout:     *runTimeout,
+			workDir:     *runWorkDir,
+			silent:      *runSilent,
+			permissions: policy,
+			protect:     runProtect,
+			noProtect:   *runNoProtect,
+			changeScope: scope,
+			approve:     *runApprove,
+			hooks:       hooks,
+			logLevel:    logLevel,
+			logConsole:  logConsole,
+			logFormat:   logFormat,
+			metricsAddr: *runMetricsAddr,
+			metricsFile: *runMetricsFile,
+			noTrace:     *runNoTrace,
+			otlpEnd:     *runOTLPEndpoint,
+			tui:         *runTUI,
+			junitPath:   *runJUnit,
+			chaos:       chaos,
+			noContract:  *runNoContract,
+			noSDKTools:  *runNoSDKTools,
+			noSDK:       *runNoSDK,
+			sarifPath:   *runSARIF,
+		})
 
 	case "status":
 		statusCmd.Parse(os.Args[2:])
@@ -59,9 +326,149 @@ func main() {
 		resetCmd.Parse(os.Args[2:])
 		cmdReset(*resetWorkDir)
 
+	case "ctl":
+		ctlCmd.Parse(os.Args[2:])
+		cmdCtl(*ctlWorkDir, ctlCmd.Args())
+
 	case "watch":
 		watchCmd.Parse(os.Args[2:])
-		cmdWatch(*watchWorkDir, *watchInterval)
+		switch {
+		case *watchHTTP != "":
+			cmdWatchHTTP(*watchHTTP, *watchWorkDir)
+		case *watchTUI:
+			cmdWatchTUI(*watchWorkDir)
+		default:
+			cmdWatch(*watchWorkDir, *watchInterval)
+		}
+
+	case "report":
+		reportCmd.Parse(os.Args[2:])
+		format, err := ghcopilot.ParseReportFormat(*reportFormat)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		cmdReport(*reportWorkDir, *reportRun, format, *reportOutput)
+
+	case "history":
+		if len(os.Args) > 2 && os.Args[2] == "show" {
+			// 迴圈 ID 前後都可以放選項
+			historyShowCmd.Parse(os.Args[3:])
+			if historyShowCmd.NArg() == 0 {
+				fmt.Println("錯誤: 需要迴圈 ID 或 <run-id>/<迴圈編號>")
+				os.Exit(1)
+			}
+			ref := historyShowCmd.Arg(0)
+			historyShowCmd.Parse(historyShowCmd.Args()[1:])
+			cmdHistoryShow(*historyShowWorkDir, ref, *historyShowOutput)
+			break
+		}
+
+		historyCmd.Parse(os.Args[2:])
+		filter := ghcopilot.HistoryFilter{
+			RunID:      *historyRun,
+			Status:     *historyStatus,
+			Model:      *historyModel,
+			ExitReason: *historyExitReason,
+			Contains:   *historyContains,
+		}
+		var err error
+		if *historySince != "" {
+			if filter.Since, err = ghcopilot.ParseHistoryTime(*historySince, false); err != nil {
+				fmt.Printf("錯誤: -since: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *historyUntil != "" {
+			if filter.Until, err = ghcopilot.ParseHistoryTime(*historyUntil, true); err != nil {
+				fmt.Printf("錯誤: -until: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		cmdHistory(*historyWorkDir, filter, *historyLoops || *historyRun != "", *historyLimit, *historyOutput)
+
+	case "diff":
+		// 迴圈 ID 前後都可以放選項
+		var refs []string
+		args := os.Args[2:]
+		for {
+			diffCmd.Parse(args)
+			if diffCmd.NArg() == 0 {
+				break
+			}
+			refs = append(refs, diffCmd.Arg(0))
+			args = diffCmd.Args()[1:]
+		}
+		if len(refs) != 2 {
+			fmt.Println("錯誤: 需要兩個迴圈 (迴圈 ID 或 <run-id>/<迴圈編號>)")
+			os.Exit(1)
+		}
+		cmdDiff(*diffWorkDir, refs[0], refs[1], *diffOutput)
+
+	case "replay":
+		// run ID 前後都可以放選項
+		var runID string
+		args := os.Args[2:]
+		for {
+			replayCmd.Parse(args)
+			if replayCmd.NArg() == 0 {
+				break
+			}
+			runID = replayCmd.Arg(0)
+			args = replayCmd.Args()[1:]
+		}
+		cmdReplay(*replayWorkDir, runID, *replayOutput, *replayCheck)
+
+	case "stats":
+		statsCmd.Parse(os.Args[2:])
+		filter := ghcopilot.HistoryFilter{Model: *statsModel}
+		var err error
+		if *statsSince != "" {
+			if filter.Since, err = ghcopilot.ParseHistoryTime(*statsSince, false); err != nil {
+				fmt.Printf("錯誤: -since: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *statsUntil != "" {
+			if filter.Until, err = ghcopilot.ParseHistoryTime(*statsUntil, true); err != nil {
+				fmt.Printf("錯誤: -until: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		cmdStats(*statsWorkDir, filter, *statsOutput)
+
+	case "bench":
+		benchCmd.Parse(os.Args[2:])
+		opts := ghcopilot.BenchOptions{
+			Executor:    *benchExecutor,
+			Model:       *benchModel,
+			Timeout:     *benchTimeout,
+			RequestCost: *benchRequestCost,
+		}
+		if *benchReplay != "" {
+			previous, err := ghcopilot.ReadBenchReport(*benchReplay)
+			if err != nil {
+				fmt.Printf("錯誤: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Replay = previous
+		}
+		cmdBench(*benchFixtures, *benchFixture, opts, *benchOutput)
+
+	case "serve":
+		serveCmd.Parse(os.Args[2:])
+		logLevel, err := ghcopilot.ParseLogLevel(*serveLogLevel)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		logFormat, err := ghcopilot.ParseLogFormat(*serveLogFormat)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		logger := slog.New(ghcopilot.NewLogHandler(os.Stderr, logLevel, logFormat))
+		cmdServe(*serveAddr, *serveWorkDir, *serveToken, *serveShutdown, logger)
 
 	case "version":
 		fmt.Printf("Ralph Loop v%s\n", version)
@@ -86,7 +493,15 @@ func printUsage() {
   run       啟動自動迴圈執行
   status    查看當前狀態
   reset     重置熔斷器
+  ctl       控制執行中的 run (status|pause|resume|stop|max-loops N|feedback "..."|reset-breaker)
   watch     監控模式 (持續顯示狀態)
+  report    產生 run 的 Markdown/HTML 報告
+  history   列出與篩選歷史 run 與迴圈 (history show <loop-id> 查看單一迴圈)
+  diff      比較兩個迴圈的輸出、狀態、分數、錯誤與檔案變更
+  replay    以錄製的模型回應重新執行 run 的分析、熔斷與退出邏輯
+  stats     彙整所有 run 的成功率、迴圈數、耗時與熔斷頻率 (依模型、執行器與日期)
+  bench     在暫存目錄中對題目專案執行迴圈，回報通過率、迴圈數、耗時與成本
+  serve     以 HTTP/JSON API 提交與監控 run (daemon 模式)
   version   顯示版本資訊
   help      顯示此幫助訊息
 
@@ -94,43 +509,237 @@ func printUsage() {
   # 啟動自動迴圈
   ralph-loop run -prompt "修正所有編譯錯誤" -max-loops 20
 
+  # 以安全權限執行 (禁止 rm、git push 等指令)
+  ralph-loop run -prompt "修正所有編譯錯誤" -safe
+
+  # 只允許特定工具
+  ralph-loop run -prompt "整理文件" -permissions edit-only -add-dir ../docs
+
+  # 限制每輪變更範圍，超出時還原
+  ralph-loop run -prompt "重構 parser" -max-files 5 -max-added 200 -scope internal/parser -revert-on-scope
+
+  # SDK 模式只允許 go 指令與寫入 internal/，其他權限請求詢問操作者 (SDK 無法啟動時結束，不改用 CLI)
+  ralph-loop run -prompt "修正所有編譯錯誤" -allow-command '^go ' -allow-path 'internal/**' -deny-network -escalate-permissions
+
+  # 只使用 Copilot CLI
+  ralph-loop run -prompt "修正所有編譯錯誤" -no-sdk
+
+  # 每個迴圈結束後人工審核
+  ralph-loop run -prompt "修正所有編譯錯誤" -approve
+
+  # 每輪結束後執行格式化與靜態檢查，失敗時要求 AI 修正
+  ralph-loop run -prompt "重構 parser" -hook post_loop="gofmt -w ." -hook-strict post_loop="go vet ./..."
+
+  # 混沌測試：注入連線重置與逾時，檢查重試與熔斷行為
+  ralph-loop run -prompt "修正所有編譯錯誤" -chaos reset=0.3,timeout=0.1,hang=5s,seed=42
+
+  # 在 CI 中輸出 JUnit 與 SARIF
+  ralph-loop run -prompt "修正所有編譯錯誤" -hook-strict post_loop="go vet ./..." -junit ralph.xml -sarif ralph.sarif
+
+  # 控制執行中的 run (在迴圈之間生效)
+  ralph-loop ctl pause
+  ralph-loop ctl feedback "先修正 parser 的測試"
+  ralph-loop ctl resume
+  ralph-loop ctl stop          # 目前迴圈結束後停止
+  ralph-loop ctl max-loops 30
+
   # 查看狀態
   ralph-loop status
 
   # 監控模式
   ralph-loop watch -interval 3s
+  ralph-loop watch -http 127.0.0.1:8080   # 網頁儀表板
+  ralph-loop watch -tui                   # 全螢幕追蹤最新的 run
+
+  # 查詢歷史
+  ralph-loop history -status failed -since 2026-01-01
+  ralph-loop history -run <run-id>
+  ralph-loop history -loops -contains "nil pointer" -output json
+  ralph-loop history show <run-id>/2
+  ralph-loop diff <run-id>/4 <run-id>/5
+
+  # 以錄製的回應重現 run（修改 ResponseAnalyzer 後檢查結果是否改變）
+  ralph-loop replay <run-id>
+  ralph-loop replay <run-id> -check -output json
+
+  # 跨 run 統計
+  ralph-loop stats -since 2026-01-01
+  ralph-loop stats -output csv > stats.csv
+
+  # 基準測試（fake 執行器套用參考修正，用於驗證題目；replay 回放先前的結果）
+  ralph-loop bench -output json > bench.json
+  ralph-loop bench -executor fake -fixture calc-off-by-one
+  ralph-loop bench -executor replay -replay bench.json
+
+  # 產生執行報告
+  ralph-loop report > report.md
+  ralph-loop report -run run-20260101-120000-1a2b -format html -o report.html
 
   # 重置熔斷器
   ralph-loop reset
 
+  # 啟動 API 服務 (以 RALPH_API_TOKEN 認證)
+  RALPH_API_TOKEN=secret ralph-loop serve -addr 127.0.0.1:8787
+  curl -H "Authorization: Bearer secret" -d '{"prompt":"修正所有編譯錯誤","max_loops":20}' http://127.0.0.1:8787/api/v1/runs
+
 更多資訊請參考: https://github.com/cy540/ralph-loop
 `, version)
 }
 
-func cmdRun(prompt string, maxLoops int, timeout time.Duration, workDir string, silent bool) {
+func cmdRun(opts runOptions) {
 	fmt.Println("========================================")
 	fmt.Println("  Ralph Loop - 自動程式碼迭代系統")
 	fmt.Println("========================================")
-	fmt.Printf("提示: %s\n", prompt)
-	fmt.Printf("最大迴圈: %d\n", maxLoops)
-	fmt.Printf("逾時: %v\n", timeout)
-	fmt.Printf("工作目錄: %s\n", workDir)
+	fmt.Printf("提示: %s\n", opts.prompt)
+	fmt.Printf("最大迴圈: %d\n", opts.maxLoops)
+	fmt.Printf("逾時: %v\n", opts.timeout)
+	fmt.Printf("工作目錄: %s\n", opts.workDir)
+	fmt.Printf("權限: %s\n", opts.permissions.Summary())
+	switch {
+	case opts.noSDK:
+		fmt.Println("執行器: CLI")
+	case opts.permissions.RequiresSDK():
+		fmt.Println("執行器: SDK (權限策略需要，不改用 CLI)")
+	default:
+		fmt.Println("執行器: SDK (無法啟動或失敗時改用 CLI)")
+	}
+	if opts.noProtect {
+		fmt.Println("受保護路徑: 停用")
+	} else {
+		fmt.Printf("受保護路徑: %s\n", strings.Join(append(append([]string{}, ghcopilot.DefaultProtectedPaths...), opts.protect...), ", "))
+	}
+	if opts.changeScope.Enabled() {
+		fmt.Printf("變更範圍: 檔案<=%d 新增<=%d 刪除<=%d 累計新增<=%d 累計刪除<=%d 路徑=%v 還原=%v\n",
+			opts.changeScope.MaxFilesPerLoop, opts.changeScope.MaxLinesAddedPerLoop, opts.changeScope.MaxLinesRemovedPerLoop,
+			opts.changeScope.MaxLinesAddedPerRun, opts.changeScope.MaxLinesRemovedPerRun,
+			opts.changeScope.AllowedPathPrefixes, opts.changeScope.RevertOnViolation)
+	}
+	if opts.approve {
+		fmt.Println("人工審核: 啟用")
+	}
+	for _, hook := range opts.hooks {
+		fmt.Printf("Hook: %s → %s (強制=%v)\n", hook.Point, hook.Command, hook.Enforce)
+	}
 	fmt.Println("----------------------------------------")
 
+	// 全螢幕介面：日誌、迴圈進度與審核都改由介面顯示，結束後再輸出摘要
+	var ui *ghcopilot.TerminalUI
+	if opts.tui {
+		if ghcopilot.TerminalUIAvailable(os.Stdin, os.Stdout) {
+			ui = ghcopilot.NewTerminalUI(os.Stdout, nil)
+		} else {
+			fmt.Println("⚠️  stdin/stdout 不是可控制的終端機，改用逐行輸出")
+		}
+	}
+
 	// 建立配置
 	config := ghcopilot.DefaultClientConfig()
-	config.WorkDir = workDir
-	config.Silent = silent
+	config.WorkDir = opts.workDir
+	config.Silent = opts.silent
+	config.Permissions = opts.permissions
+	if opts.noProtect {
+		config.ProtectedPaths = nil
+	} else {
+		config.ProtectedPaths = append(config.ProtectedPaths, opts.protect...)
+	}
+	if opts.changeScope.Enabled() {
+		config.ChangeScope = opts.changeScope
+	}
+	config.ShellHooks = opts.hooks
+	config.Logger = slog.New(ghcopilot.NewLogHandler(os.Stderr, opts.logConsole, opts.logFormat))
+	if opts.approve {
+		config.Approver = ghcopilot.NewTerminalApprover(os.Stdin, os.Stdout)
+	}
+	if opts.permissions.Escalate {
+		config.PermissionEscalator = ghcopilot.NewTerminalApprover(os.Stdin, os.Stdout)
+	}
+	if ui != nil {
+		config.Logger = slog.New(ghcopilot.NewLogHandler(ui.LogWriter(), opts.logConsole, opts.logFormat))
+		config.ProgressOutput = io.Discard
+		if opts.approve {
+			config.Approver = ui
+		}
+	}
+	config.LogLevel = opts.logLevel
+	config.LogFormat = opts.logFormat
+	config.EnableTracing = !opts.noTrace
+	config.OTLPEndpoint = opts.otlpEnd
 	config.CLIMaxRetries = 3
 	config.CircuitBreakerThreshold = 3
 	config.SameErrorThreshold = 5
+	config.Chaos = opts.chaos
+	config.StatusContract = !opts.noContract
+	config.SDKTools = !opts.noSDKTools
+	config.EnableSDK = !opts.noSDK
+	config.RequireSDK = opts.permissions.RequiresSDK()
 
 	// 建立客戶端
 	client := ghcopilot.NewRalphLoopClientWithConfig(config)
 	defer client.Close()
 
+	// 啟動 SDK 執行器：迴圈在 SDK 會話中執行，權限請求交給權限引擎
+	if config.EnableSDK {
+		if err := client.StartSDKExecutor(context.Background()); err != nil {
+			if config.RequireSDK {
+				fmt.Printf("錯誤: 無法啟動 SDK 執行器，權限策略無法在 CLI 模式執行: %v\n", err)
+				client.Close()
+				os.Exit(1)
+			}
+			fmt.Printf("⚠️  SDK 執行器未啟動，改用 CLI: %v\n", err)
+		}
+	}
+
+	// 即時顯示執行輸出
+	if ui != nil {
+		client.Subscribe(ui.Handle)
+		ui.SetControl(ghcopilot.LocalControl(client.Controller()))
+	} else if !opts.silent {
+		client.Subscribe(ghcopilot.NewConsoleRenderer(os.Stdout).Handle)
+	}
+	fmt.Printf("執行 ID: %s\n", client.RunID())
+	if dir := client.RunDir(); dir != "" {
+		fmt.Printf("執行紀錄: %s\n", filepath.Join(dir, ghcopilot.JournalFileName))
+		fmt.Printf("執行日誌: %s\n", filepath.Join(dir, ghcopilot.RunLogFileName))
+		fmt.Printf("執行器錄製: %s\n", filepath.Join(dir, ghcopilot.TranscriptFileName))
+		if !opts.noTrace {
+			fmt.Printf("追蹤: %s\n", filepath.Join(dir, ghcopilot.TraceFileName))
+		}
+	}
+
+	// 輸出 Prometheus 指標
+	if opts.metricsAddr != "" {
+		if server, err := ghcopilot.NewMetricsServer(opts.metricsAddr, client.Metrics().Registry()); err != nil {
+			fmt.Printf("⚠️  指標服務未啟用: %v\n", err)
+		} else {
+			defer server.Close()
+			fmt.Printf("指標: http://%s/metrics\n", server.Addr())
+		}
+	}
+	if opts.metricsFile != "" {
+		writeMetrics := func() {
+			if err := client.Metrics().Registry().WriteTextfile(opts.metricsFile); err != nil {
+				client.Logger().Warn("無法寫入指標檔", "file", opts.metricsFile, "error", err)
+			}
+		}
+		client.Subscribe(func(event ghcopilot.LoopEvent) {
+			if event.Type == ghcopilot.EventLoopFinished {
+				writeMetrics()
+			}
+		})
+		defer writeMetrics()
+		fmt.Printf("指標檔: %s\n", opts.metricsFile)
+	}
+
+	// 啟動控制通道（ralph-loop ctl）
+	if server, err := ghcopilot.NewControlServer(ghcopilot.ControlSocketPath(opts.workDir), client.Controller()); err != nil {
+		fmt.Printf("⚠️  控制通道未啟用: %v\n", err)
+	} else {
+		defer server.Close()
+		fmt.Printf("控制通道: %s\n", server.Path())
+	}
+
 	// 建立 context 與取消機制
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
 	defer cancel()
 
 	// 處理中斷信號
@@ -138,6 +747,9 @@ func cmdRun(prompt string, maxLoops int, timeout time.Duration, workDir string,
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
+		if ui != nil {
+			ui.Stop()
+		}
 		fmt.Println("\n收到中斷信號，正在停止...")
 		cancel()
 	}()
@@ -147,7 +759,16 @@ func cmdRun(prompt string, maxLoops int, timeout time.Duration, workDir string,
 
 	// 執行迴圈（顯示進度）
 	fmt.Println("⏳ 正在初始化 Copilot CLI...")
-	results, err := client.ExecuteUntilCompletion(ctx, prompt, maxLoops)
+	if ui != nil {
+		if err := ui.Start(os.Stdin); err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	results, err := client.ExecuteUntilCompletion(ctx, opts.prompt, opts.maxLoops)
+	if ui != nil {
+		ui.Stop()
+	}
 
 	// 顯示結果摘要
 	fmt.Println()
@@ -165,6 +786,9 @@ func cmdRun(prompt string, maxLoops int, timeout time.Duration, workDir string,
 	// 顯示狀態
 	status := client.GetStatus()
 	fmt.Printf("熔斷器狀態: %s\n", status.CircuitBreakerState)
+	if chaos := client.Chaos(); chaos != nil {
+		fmt.Printf("混沌注入: %s\n", chaos.Stats())
+	}
 
 	// 顯示每個迴圈的簡要
 	if len(results) > 0 {
@@ -179,9 +803,44 @@ func cmdRun(prompt string, maxLoops int, timeout time.Duration, workDir string,
 		}
 	}
 
+	// CI 產出物（未完成時也要輸出，讓 CI 顯示失敗的迴圈）
+	history := client.GetHistory()
+	if opts.junitPath != "" {
+		writeArtifact("JUnit", opts.junitPath, func(w io.Writer) error {
+			return ghcopilot.WriteJUnitReport(w, client.RunID(), history)
+		})
+	}
+	if opts.sarifPath != "" {
+		writeArtifact("SARIF", opts.sarifPath, func(w io.Writer) error {
+			return ghcopilot.WriteSARIFReport(w, opts.workDir, history)
+		})
+	}
+
 	fmt.Println("========================================")
 }
 
+// writeArtifact 建立檔案並以 write 寫入內容
+func writeArtifact(kind, path string, write func(w io.Writer) error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Printf("⚠️  無法寫入 %s: %v\n", kind, err)
+		return
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("⚠️  無法寫入 %s: %v\n", kind, err)
+		return
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("⚠️  無法寫入 %s: %v\n", kind, err)
+		return
+	}
+	fmt.Printf("%s: %s\n", kind, path)
+}
+
 func cmdStatus(workDir string) {
 	config := ghcopilot.DefaultClientConfig()
 	config.WorkDir = workDir
@@ -229,6 +888,33 @@ func cmdReset(workDir string) {
 	fmt.Println("熔斷器已重置")
 }
 
+func cmdCtl(workDir string, args []string) {
+	if len(args) == 0 {
+		fmt.Printf("錯誤: 需要指定控制指令 (%s)\n", strings.Join(ghcopilot.ControlCommands, "|"))
+		os.Exit(1)
+	}
+
+	req := ghcopilot.ControlRequest{Command: args[0], Arg: strings.Join(args[1:], " ")}
+	resp, err := ghcopilot.SendControlCommand(ghcopilot.ControlSocketPath(workDir), req)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	if !resp.OK {
+		fmt.Printf("錯誤: %s\n", resp.Error)
+		os.Exit(1)
+	}
+
+	state := resp.State
+	fmt.Printf("已暫停: %v\n", state.Paused)
+	fmt.Printf("要求停止: %v\n", state.StopRequested)
+	fmt.Printf("迴圈: %d/%d\n", state.LoopsCompleted, state.MaxLoops)
+	fmt.Printf("待注入回饋: %d\n", state.PendingFeedback)
+	if state.BreakerReset {
+		fmt.Println("熔斷器: 將在下一個迴圈前重置")
+	}
+}
+
 func cmdWatch(workDir string, interval time.Duration) {
 	config := ghcopilot.DefaultClientConfig()
 	config.WorkDir = workDir
@@ -275,8 +961,13 @@ func cmdWatch(workDir string, interval time.Duration) {
 
 			if status.Summary != nil {
 				fmt.Println()
-				for k, v := range status.Summary {
-					fmt.Printf("  %s: %v\n", k, v)
+				keys := make([]string, 0, len(status.Summary))
+				for k := range status.Summary {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					fmt.Printf("  %s: %v\n", k, status.Summary[k])
 				}
 			}
 			fmt.Println("----------------------------------------")
@@ -284,3 +975,440 @@ func cmdWatch(workDir string, interval time.Duration) {
 		}
 	}
 }
+
+// recordConfig 傳回讀取紀錄用的配置（SaveDir 與 RunsDir 以工作目錄為基準，與 run 寫入的位置相同）
+func recordConfig(workDir string) *ghcopilot.ClientConfig {
+	config := ghcopilot.DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = ghcopilot.ResolveWorkDirPath(workDir, config.SaveDir)
+	config.RunsDir = ghcopilot.ResolveWorkDirPath(workDir, config.RunsDir)
+	return config
+}
+
+func cmdWatchHTTP(addr, workDir string) {
+	runsDir := recordConfig(workDir).RunsDir
+	server := ghcopilot.NewDashboardServer(runsDir)
+	server.SetLogger(nil)
+	if err := server.Listen(addr); err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("========================================")
+	fmt.Println("  Ralph Loop 網頁儀表板")
+	fmt.Println("========================================")
+	fmt.Printf("網址: http://%s/\n", server.Addr())
+	fmt.Printf("執行紀錄: %s\n", runsDir)
+	fmt.Println("按 Ctrl+C 停止")
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("關閉時發生錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("\n監控已停止")
+}
+
+func cmdWatchTUI(workDir string) {
+	runsDir := recordConfig(workDir).RunsDir
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 非終端機時逐行輸出執行紀錄
+	handle := ghcopilot.NewConsoleRenderer(os.Stdout).Handle
+	var ui *ghcopilot.TerminalUI
+	if ghcopilot.TerminalUIAvailable(os.Stdin, os.Stdout) {
+		ui = ghcopilot.NewTerminalUI(os.Stdout, ghcopilot.SocketControl(ghcopilot.ControlSocketPath(workDir)))
+		if err := ui.Start(os.Stdin); err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		defer ui.Stop()
+		handle = ui.Handle
+	} else {
+		fmt.Printf("追蹤 %s 中最新的 run (按 Ctrl+C 停止)\n", runsDir)
+	}
+
+	// 追蹤最新的 run，出現更新的 run 時切換（ctx 結束時一併停止追蹤）
+	current := ""
+	stopFollow := func() {}
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		if runs, err := ghcopilot.ListJournalRuns(runsDir); err == nil && len(runs) > 0 && runs[0].ID != current {
+			stopFollow()
+			current = runs[0].ID
+			if ui != nil {
+				ui.Reset()
+			} else {
+				fmt.Printf("== %s ==\n", current)
+			}
+
+			followCtx, cancel := context.WithCancel(ctx)
+			stopFollow = cancel
+			path := filepath.Join(runsDir, current, ghcopilot.JournalFileName)
+			go ghcopilot.FollowJournal(followCtx, path, 200*time.Millisecond, handle)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if ui != nil {
+				ui.Stop()
+			}
+			fmt.Println("監控已停止")
+			return
+		}
+	}
+}
+
+func cmdReport(workDir, runID string, format ghcopilot.ReportFormat, output string) {
+	config := recordConfig(workDir)
+	if runID == "" || runID == "latest" {
+		runs, err := ghcopilot.ListJournalRuns(config.RunsDir)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		if len(runs) == 0 {
+			fmt.Printf("錯誤: %s 中沒有執行紀錄\n", config.RunsDir)
+			os.Exit(1)
+		}
+		runID = runs[0].ID
+	}
+
+	report, err := ghcopilot.LoadRunReport(filepath.Join(config.RunsDir, runID))
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := report.Write(out, format); err != nil {
+		fmt.Printf("錯誤: 無法輸出報告: %v\n", err)
+		os.Exit(1)
+	}
+	if output != "" {
+		fmt.Printf("✅ 報告已寫入 %s\n", output)
+	}
+}
+
+func cmdHistory(workDir string, filter ghcopilot.HistoryFilter, loops bool, limit int, output string) {
+	if output != "text" && output != "json" {
+		fmt.Printf("錯誤: 未知的輸出格式 %q (可用: text, json)\n", output)
+		os.Exit(1)
+	}
+	if err := filter.Validate(loops); err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	config := recordConfig(workDir)
+	history, err := ghcopilot.LoadHistory(config.SaveDir, config.RunsDir)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !loops {
+		runs := history.FilterRuns(filter)
+		if limit > 0 && len(runs) > limit {
+			runs = runs[:limit]
+		}
+		if output == "json" {
+			writeJSONOutput(runs)
+			return
+		}
+		if len(runs) == 0 {
+			fmt.Println("沒有符合條件的 run")
+			return
+		}
+		ghcopilot.WriteHistoryRuns(os.Stdout, runs)
+		return
+	}
+
+	matched := history.FilterLoops(filter)
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+	if output == "json" {
+		rows := make([]ghcopilot.HistoryLoop, 0, len(matched))
+		for _, execCtx := range matched {
+			rows = append(rows, ghcopilot.NewHistoryLoop(execCtx))
+		}
+		writeJSONOutput(rows)
+		return
+	}
+	if len(matched) == 0 {
+		fmt.Println("沒有符合條件的迴圈")
+		return
+	}
+	ghcopilot.WriteHistoryLoops(os.Stdout, matched)
+}
+
+func cmdHistoryShow(workDir, ref, output string) {
+	if output != "text" && output != "json" {
+		fmt.Printf("錯誤: 未知的輸出格式 %q (可用: text, json)\n", output)
+		os.Exit(1)
+	}
+
+	config := recordConfig(workDir)
+	history, err := ghcopilot.LoadHistory(config.SaveDir, config.RunsDir)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	execCtx, err := history.FindLoop(ref)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		writeJSONOutput(execCtx)
+		return
+	}
+	ghcopilot.WriteHistoryLoop(os.Stdout, execCtx)
+}
+
+func cmdDiff(workDir, refA, refB, output string) {
+	if output != "text" && output != "json" {
+		fmt.Printf("錯誤: 未知的輸出格式 %q (可用: text, json)\n", output)
+		os.Exit(1)
+	}
+
+	config := recordConfig(workDir)
+	history, err := ghcopilot.LoadHistory(config.SaveDir, config.RunsDir)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	before, err := history.FindLoop(refA)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	after, err := history.FindLoop(refB)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	comparison := ghcopilot.CompareLoops(before, after)
+	if output == "json" {
+		writeJSONOutput(comparison)
+		return
+	}
+	comparison.WriteText(os.Stdout)
+}
+
+func cmdReplay(workDir, runID, output string, check bool) {
+	if output != "text" && output != "json" {
+		fmt.Printf("錯誤: 未知的輸出格式 %q (可用: text, json)\n", output)
+		os.Exit(1)
+	}
+
+	config := recordConfig(workDir)
+	if runID == "" {
+		runs, err := ghcopilot.ListJournalRuns(config.RunsDir)
+		if err != nil {
+			fmt.Printf("錯誤: %v\n", err)
+			os.Exit(1)
+		}
+		for _, run := range runs {
+			if _, err := os.Stat(filepath.Join(config.RunsDir, run.ID, ghcopilot.TranscriptFileName)); err == nil {
+				runID = run.ID
+				break
+			}
+		}
+		if runID == "" {
+			fmt.Printf("錯誤: %s 中沒有錄製的 run\n", config.RunsDir)
+			os.Exit(1)
+		}
+	}
+
+	history, err := ghcopilot.LoadHistory(config.SaveDir, config.RunsDir)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	var original []*ghcopilot.ExecutionContext
+	for _, run := range history.FilterRuns(ghcopilot.HistoryFilter{RunID: runID}) {
+		original = run.Loops
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	report, err := ghcopilot.ReplayRun(ctx, filepath.Join(config.RunsDir, runID), original)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		writeJSONOutput(report)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if check && report.Diverged() {
+		os.Exit(1)
+	}
+}
+
+func cmdStats(workDir string, filter ghcopilot.HistoryFilter, output string) {
+	if output != "text" && output != "json" && output != "csv" {
+		fmt.Printf("錯誤: 未知的輸出格式 %q (可用: text, json, csv)\n", output)
+		os.Exit(1)
+	}
+
+	config := recordConfig(workDir)
+	history, err := ghcopilot.LoadHistory(config.SaveDir, config.RunsDir)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	stats := ghcopilot.ComputeStats(history.FilterRuns(filter))
+
+	switch output {
+	case "json":
+		writeJSONOutput(stats)
+	case "csv":
+		if err := stats.WriteCSV(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "錯誤: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		if stats.Overall.Runs == 0 {
+			fmt.Println("沒有符合條件的 run")
+			return
+		}
+		stats.WriteText(os.Stdout)
+	}
+}
+
+func cmdBench(dir, only string, opts ghcopilot.BenchOptions, output string) {
+	if output != "text" && output != "json" {
+		fmt.Printf("錯誤: 未知的輸出格式 %q (可用: text, json)\n", output)
+		os.Exit(1)
+	}
+
+	fixtures, err := ghcopilot.LoadBenchFixtures(dir)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	if only != "" {
+		wanted := map[string]bool{}
+		for _, name := range strings.Split(only, ",") {
+			wanted[strings.TrimSpace(name)] = true
+		}
+		var selected []ghcopilot.BenchFixture
+		for _, fixture := range fixtures {
+			if wanted[fixture.Name] {
+				selected = append(selected, fixture)
+				delete(wanted, fixture.Name)
+			}
+		}
+		for name := range wanted {
+			fmt.Printf("錯誤: 找不到題目 %s\n", name)
+			os.Exit(1)
+		}
+		fixtures = selected
+	}
+	if len(fixtures) == 0 {
+		fmt.Printf("錯誤: %s 中沒有題目\n", dir)
+		os.Exit(1)
+	}
+
+	// 進度輸出到 stderr，JSON 結果可直接重導向
+	opts.Progress = os.Stderr
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	report, err := ghcopilot.RunBench(ctx, fixtures, opts)
+	if err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == "json" {
+		writeJSONOutput(report)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+}
+
+// writeJSONOutput 將結果以縮排的 JSON 輸出到 stdout
+func writeJSONOutput(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "錯誤: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func cmdServe(addr, workDir, token string, shutdownTimeout time.Duration, logger *slog.Logger) {
+	manager := ghcopilot.NewRunManager(workDir, func() *ghcopilot.ClientConfig {
+		config := ghcopilot.DefaultClientConfig()
+		config.Logger = logger
+		config.CLIMaxRetries = 3
+		config.CircuitBreakerThreshold = 3
+		config.SameErrorThreshold = 5
+		return config
+	})
+	manager.SetLogger(logger.With("component", "run_manager"))
+
+	server := ghcopilot.NewAPIServer(manager, token)
+	server.SetLogger(logger.With("component", "api_server"))
+	if err := server.Listen(addr); err != nil {
+		fmt.Printf("錯誤: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("========================================")
+	fmt.Println("  Ralph Loop API 服務")
+	fmt.Println("========================================")
+	fmt.Printf("位址: http://%s/api/v1\n", server.Addr())
+	fmt.Printf("工作目錄: %s\n", workDir)
+	if token == "" {
+		fmt.Println("⚠️  未設定 token，任何能連線的人都可以提交 run")
+	} else {
+		fmt.Println("認證: Bearer token")
+	}
+	fmt.Println("按 Ctrl+C 停止 (執行中的 run 會在目前迴圈結束後停止)")
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+	fmt.Println("\n收到中斷信號，正在關閉...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// 再次中斷時立即取消所有 run
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("關閉時發生錯誤: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("API 服務已停止")
+}
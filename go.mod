@@ -3,6 +3,6 @@ module github.com/cy540/ralph-loop
 go 1.24.5
 
 require (
-	github.com/github/copilot-sdk/go v0.1.15-preview.0.0.20260121003103-2415f6f3b828 // indirect
-	github.com/google/jsonschema-go v0.4.2 // indirect
+	github.com/github/copilot-sdk/go v0.1.15-preview.0.0.20260121003103-2415f6f3b828
+	github.com/google/jsonschema-go v0.4.2
 )
@@ -0,0 +1,377 @@
+package ghcopilot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIServer 以 HTTP/JSON 提供 run 的提交、查詢、事件串流與控制（ralph-loop serve）
+//
+// 端點：
+//
+//	GET    /api/v1/health                   健康檢查（不需認證）
+//	GET    /api/v1/runs                     列出 run
+//	POST   /api/v1/runs                     提交 run（RunRequest）
+//	GET    /api/v1/runs/{id}                run 狀態
+//	DELETE /api/v1/runs/{id}                立即取消 run
+//	GET    /api/v1/runs/{id}/history        迴圈執行歷史
+//	GET    /api/v1/runs/{id}/events         以 Server-Sent Events 串流事件（支援 Last-Event-ID）
+//	POST   /api/v1/runs/{id}/{command}      控制指令（pause、resume、stop、max-loops、feedback、reset-breaker）
+//	POST   /api/v1/breaker/reset            重置工作目錄的熔斷器（{"work_dir": "..."}）
+//
+// 設定 token 時，除健康檢查外都需要 "Authorization: Bearer <token>"；
+// 瀏覽器的 EventSource 無法設定標頭，可改用 ?access_token=<token>。
+type APIServer struct {
+	manager *RunManager
+	token   string
+	logger  *slog.Logger
+	mux     *http.ServeMux
+
+	server   *http.Server
+	listener net.Listener
+	serveErr chan error
+
+	closing   chan struct{} // 關閉時通知事件串流結束
+	closeOnce sync.Once
+}
+
+// apiError 是錯誤回應的內容
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// NewAPIServer 建立 API 服務（token 為空時不需認證）
+func NewAPIServer(manager *RunManager, token string) *APIServer {
+	s := &APIServer{
+		manager: manager,
+		token:   token,
+		logger:  defaultLogger(),
+		mux:     http.NewServeMux(),
+		closing: make(chan struct{}),
+	}
+
+	s.mux.HandleFunc("GET /api/v1/health", s.handleHealth)
+	s.mux.HandleFunc("GET /api/v1/runs", s.authorized(s.handleListRuns))
+	s.mux.HandleFunc("POST /api/v1/runs", s.authorized(s.handleSubmitRun))
+	s.mux.HandleFunc("GET /api/v1/runs/{id}", s.authorized(s.handleGetRun))
+	s.mux.HandleFunc("DELETE /api/v1/runs/{id}", s.authorized(s.handleCancelRun))
+	s.mux.HandleFunc("GET /api/v1/runs/{id}/history", s.authorized(s.handleRunHistory))
+	s.mux.HandleFunc("GET /api/v1/runs/{id}/events", s.authorized(s.handleRunEvents))
+	s.mux.HandleFunc("POST /api/v1/runs/{id}/{command}", s.authorized(s.handleControlRun))
+	s.mux.HandleFunc("POST /api/v1/breaker/reset", s.authorized(s.handleResetBreaker))
+	return s
+}
+
+// SetLogger 設定日誌輸出（nil 表示不輸出）
+func (s *APIServer) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	s.logger = logger
+}
+
+// Handler 傳回 HTTP handler（可掛載到其他服務或用於測試）
+func (s *APIServer) Handler() http.Handler {
+	return s.mux
+}
+
+// Listen 在 addr（例如 "127.0.0.1:8787"）上開始服務
+func (s *APIServer) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("無法監聽 API 位址: %w", err)
+	}
+
+	s.listener = listener
+	s.server = &http.Server{Handler: s.mux, ReadHeaderTimeout: 5 * time.Second}
+	s.serveErr = make(chan error, 1)
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.serveErr <- err
+		}
+		close(s.serveErr)
+	}()
+	return nil
+}
+
+// Addr 傳回實際監聽的位址
+func (s *APIServer) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown 優雅關閉：停止接受請求、結束事件串流，並等待所有 run 在目前迴圈結束後停止
+//
+// ctx 到期時取消仍在執行的 run。
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	var firstErr error
+	if s.server != nil {
+		if err := s.server.Shutdown(ctx); err != nil {
+			firstErr = err
+		}
+		if err := <-s.serveErr; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := s.manager.Shutdown(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// authorized 在設定 token 時檢查請求的認證
+func (s *APIServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.logger.Debug("API 請求", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+		if s.token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" {
+				token = r.URL.Query().Get("access_token")
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				s.logger.Warn("API 認證失敗", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ralph-loop"`)
+				writeAPIError(w, http.StatusUnauthorized, "missing or invalid token")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (s *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+func (s *APIServer) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.manager.List())
+}
+
+func (s *APIServer) handleSubmitRun(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run, err := s.manager.Submit(req)
+	switch {
+	case errors.Is(err, ErrWorkDirBusy):
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, ErrManagerShutdown):
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/runs/"+run.ID())
+	writeJSON(w, http.StatusCreated, run.Info())
+}
+
+func (s *APIServer) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	if run := s.lookupRun(w, r); run != nil {
+		writeJSON(w, http.StatusOK, run.Info())
+	}
+}
+
+func (s *APIServer) handleCancelRun(w http.ResponseWriter, r *http.Request) {
+	run := s.lookupRun(w, r)
+	if run == nil {
+		return
+	}
+	run.Cancel()
+	<-run.Done()
+	writeJSON(w, http.StatusOK, run.Info())
+}
+
+func (s *APIServer) handleRunHistory(w http.ResponseWriter, r *http.Request) {
+	if run := s.lookupRun(w, r); run != nil {
+		writeJSON(w, http.StatusOK, run.History())
+	}
+}
+
+// handleControlRun 將控制指令套用到 run（與 ralph-loop ctl 相同）
+func (s *APIServer) handleControlRun(w http.ResponseWriter, r *http.Request) {
+	run := s.lookupRun(w, r)
+	if run == nil {
+		return
+	}
+
+	var body struct {
+		Arg interface{} `json:"arg"`
+	}
+	if err := decodeJSONBody(r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req := ControlRequest{Command: r.PathValue("command")}
+	if body.Arg != nil {
+		req.Arg = fmt.Sprint(body.Arg)
+	}
+
+	select {
+	case <-run.Done():
+		writeAPIError(w, http.StatusConflict, fmt.Sprintf("run %s is not running", run.ID()))
+		return
+	default:
+	}
+
+	resp := ControlResponse{OK: true}
+	if err := ApplyControlRequest(run.Client().Controller(), req); err != nil {
+		resp = ControlResponse{Error: err.Error()}
+	}
+	resp.State = run.Client().Controller().State()
+
+	status := http.StatusOK
+	if !resp.OK {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
+
+func (s *APIServer) handleResetBreaker(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		WorkDir string `json:"work_dir"`
+	}
+	if err := decodeJSONBody(r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	runID, err := s.manager.ResetBreaker(body.WorkDir)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := map[string]interface{}{"work_dir": s.manager.resolveWorkDir(body.WorkDir)}
+	if runID != "" {
+		resp["run_id"] = runID
+		resp["pending"] = true // 在該 run 的下一個迴圈前套用
+	} else {
+		resp["breaker_state"] = StateClosed
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleRunEvents 以 Server-Sent Events 串流 run 的事件
+//
+// 先重播緩衝中的事件（或 Last-Event-ID 之後的事件），再即時推送；
+// run 結束後送出 "end" 事件並關閉連線。每個事件的 id 為其序號。
+func (s *APIServer) handleRunEvents(w http.ResponseWriter, r *http.Request) {
+	run := s.lookupRun(w, r)
+	if run == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	cursor := 0
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("since")
+	}
+	if lastID != "" {
+		n, err := strconv.Atoi(lastID)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid event id %q", lastID))
+			return
+		}
+		cursor = n + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		events, next, changed, finished := run.EventsSince(cursor)
+		for i, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", next-len(events)+i, event.Type, data)
+		}
+		cursor = next
+		if finished {
+			data, _ := json.Marshal(run.Info())
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// lookupRun 取得路徑中的 run，找不到時寫入 404
+func (s *APIServer) lookupRun(w http.ResponseWriter, r *http.Request) *ManagedRun {
+	run, err := s.manager.Get(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return nil
+	}
+	return run
+}
+
+// decodeJSONBody 解析 JSON 請求內容（空內容視為零值）
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
@@ -0,0 +1,280 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAPIServer 建立使用假 copilot 的 API 服務
+func newTestAPIServer(t *testing.T, token string) (*APIServer, *httptest.Server, string) {
+	t.Helper()
+	workDir := t.TempDir()
+	manager := NewRunManager(workDir, func() *ClientConfig {
+		config := DefaultClientConfig()
+		config.EnableSDK = false
+		config.ProtectedPaths = nil
+		return config
+	})
+	manager.SetLogger(nil)
+
+	api := NewAPIServer(manager, token)
+	api.SetLogger(nil)
+	server := httptest.NewServer(api.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = api.Shutdown(ctx)
+	})
+	return api, server, workDir
+}
+
+// apiRequest 送出請求並解析 JSON 回應
+func apiRequest(t *testing.T, method, url, token string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s 失敗: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("無法解析 %s %s 的回應: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitForRun 等待 run 離開執行中狀態
+func waitForRun(t *testing.T, baseURL, token, id string) RunInfo {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		var info RunInfo
+		apiRequest(t, "GET", baseURL+"/api/v1/runs/"+id, token, nil, &info)
+		if info.Status != RunStatusRunning {
+			return info
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("run %s 未在時限內結束", id)
+	return RunInfo{}
+}
+
+// TestAPIServerRunLifecycle 測試提交、查詢、歷史與事件重播
+func TestAPIServerRunLifecycle(t *testing.T) {
+	installFakeCopilot(t, `echo "EXIT_SIGNAL: true"
+echo "任務完成"
+`)
+	_, server, workDir := newTestAPIServer(t, "")
+
+	var info RunInfo
+	status := apiRequest(t, "POST", server.URL+"/api/v1/runs", "", RunRequest{Prompt: "修正測試", MaxLoops: 3}, &info)
+	if status != http.StatusCreated || info.ID == "" || info.WorkDir != workDir {
+		t.Fatalf("提交應成功: %d %+v", status, info)
+	}
+
+	info = waitForRun(t, server.URL, "", info.ID)
+	if info.Status != RunStatusCompleted || len(info.Loops) != 1 || info.FinishedAt == nil {
+		t.Fatalf("run 應在 1 輪後完成: %+v", info)
+	}
+	if info.RunDir != filepath.Join(workDir, ".ralph-loop", "runs", info.ID) {
+		t.Errorf("執行紀錄應寫入工作目錄: %s", info.RunDir)
+	}
+	if _, err := os.Stat(filepath.Join(info.RunDir, JournalFileName)); err != nil {
+		t.Errorf("應寫入執行紀錄: %v", err)
+	}
+
+	var list []RunInfo
+	apiRequest(t, "GET", server.URL+"/api/v1/runs", "", nil, &list)
+	if len(list) != 1 || list[0].ID != info.ID {
+		t.Errorf("列表應包含 run: %+v", list)
+	}
+
+	var history []*ExecutionContext
+	apiRequest(t, "GET", server.URL+"/api/v1/runs/"+info.ID+"/history", "", nil, &history)
+	if len(history) != 1 || history[0].ShouldContinue {
+		t.Errorf("歷史應包含完成的迴圈: %+v", history)
+	}
+
+	// 已結束的 run：重播所有事件後送出 end
+	resp, err := http.Get(server.URL + "/api/v1/runs/" + info.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("應為 SSE: %s", resp.Header.Get("Content-Type"))
+	}
+	var types []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			types = append(types, name)
+		}
+	}
+	joined := strings.Join(types, ",")
+	if !strings.HasPrefix(joined, string(EventLoopStarted)) || !strings.Contains(joined, string(EventLoopFinished)) || !strings.HasSuffix(joined, "end") {
+		t.Errorf("事件串流應包含迴圈事件並以 end 結束: %v", types)
+	}
+
+	if status := apiRequest(t, "GET", server.URL+"/api/v1/runs/missing", "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("不存在的 run 應回傳 404，但為 %d", status)
+	}
+	if status := apiRequest(t, "POST", server.URL+"/api/v1/runs", "", RunRequest{}, nil); status != http.StatusBadRequest {
+		t.Errorf("缺少 prompt 應回傳 400，但為 %d", status)
+	}
+}
+
+// TestAPIServerAuth 測試 token 認證
+func TestAPIServerAuth(t *testing.T) {
+	_, server, _ := newTestAPIServer(t, "secret")
+
+	if status := apiRequest(t, "GET", server.URL+"/api/v1/health", "", nil, nil); status != http.StatusOK {
+		t.Errorf("健康檢查不需認證，但為 %d", status)
+	}
+	if status := apiRequest(t, "GET", server.URL+"/api/v1/runs", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("缺少 token 應回傳 401，但為 %d", status)
+	}
+	if status := apiRequest(t, "GET", server.URL+"/api/v1/runs", "wrong", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("錯誤的 token 應回傳 401，但為 %d", status)
+	}
+	if status := apiRequest(t, "GET", server.URL+"/api/v1/runs", "secret", nil, nil); status != http.StatusOK {
+		t.Errorf("正確的 token 應通過，但為 %d", status)
+	}
+	if status := apiRequest(t, "GET", server.URL+"/api/v1/runs?access_token=secret", "", nil, nil); status != http.StatusOK {
+		t.Errorf("查詢參數的 token 應通過，但為 %d", status)
+	}
+}
+
+// TestAPIServerControlAndConflict 測試控制執行中的 run 與同目錄的衝突
+func TestAPIServerControlAndConflict(t *testing.T) {
+	installFakeCopilot(t, `sleep 0.3
+echo "working"
+`)
+	_, server, _ := newTestAPIServer(t, "")
+
+	var info RunInfo
+	apiRequest(t, "POST", server.URL+"/api/v1/runs", "", RunRequest{Prompt: "長任務", MaxLoops: 20}, &info)
+
+	if status := apiRequest(t, "POST", server.URL+"/api/v1/runs", "", RunRequest{Prompt: "另一個任務"}, nil); status != http.StatusConflict {
+		t.Errorf("同一工作目錄的第二個 run 應回傳 409，但為 %d", status)
+	}
+
+	var resp ControlResponse
+	if status := apiRequest(t, "POST", server.URL+"/api/v1/runs/"+info.ID+"/max-loops", "", map[string]interface{}{"arg": 5}, &resp); status != http.StatusOK || resp.State.MaxLoops != 5 {
+		t.Errorf("max-loops 應套用: %d %+v", status, resp)
+	}
+	if status := apiRequest(t, "POST", server.URL+"/api/v1/runs/"+info.ID+"/explode", "", nil, &resp); status != http.StatusBadRequest || resp.OK {
+		t.Errorf("未知指令應回傳 400: %d %+v", status, resp)
+	}
+	if status := apiRequest(t, "POST", server.URL+"/api/v1/runs/"+info.ID+"/stop", "", nil, &resp); status != http.StatusOK || !resp.State.StopRequested {
+		t.Errorf("stop 應套用: %d %+v", status, resp)
+	}
+
+	info = waitForRun(t, server.URL, "", info.ID)
+	if info.Status != RunStatusStopped || len(info.Loops) == 0 {
+		t.Errorf("run 應在目前迴圈結束後停止: %+v", info)
+	}
+	if status := apiRequest(t, "POST", server.URL+"/api/v1/runs/"+info.ID+"/pause", "", nil, nil); status != http.StatusConflict {
+		t.Errorf("已結束的 run 不應接受控制指令，但為 %d", status)
+	}
+}
+
+// TestAPIServerCancel 測試取消 run 會中斷正在執行的迴圈
+func TestAPIServerCancel(t *testing.T) {
+	installFakeCopilot(t, `sleep 5
+echo "working"
+`)
+	_, server, _ := newTestAPIServer(t, "")
+
+	var info RunInfo
+	apiRequest(t, "POST", server.URL+"/api/v1/runs", "", RunRequest{Prompt: "長任務"}, &info)
+
+	start := time.Now()
+	apiRequest(t, "DELETE", server.URL+"/api/v1/runs/"+info.ID, "", nil, &info)
+	if info.Status != RunStatusCancelled {
+		t.Errorf("run 應為 cancelled: %+v", info)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Error("取消應中斷正在執行的迴圈")
+	}
+}
+
+// TestAPIServerShutdown 測試優雅關閉等待 run 停止並拒絕新的 run
+func TestAPIServerShutdown(t *testing.T) {
+	installFakeCopilot(t, `sleep 0.2
+echo "working"
+`)
+	api, server, _ := newTestAPIServer(t, "")
+
+	var info RunInfo
+	apiRequest(t, "POST", server.URL+"/api/v1/runs", "", RunRequest{Prompt: "長任務", MaxLoops: 50}, &info)
+	run, err := api.manager.Get(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 事件串流在關閉時結束
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		resp, err := http.Get(server.URL + "/api/v1/runs/" + info.ID + "/events")
+		if err == nil {
+			_, _ = bufio.NewReader(resp.Body).ReadString(0)
+			resp.Body.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := api.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown 失敗: %v", err)
+	}
+
+	if got := run.Info(); got.Status != RunStatusStopped || len(got.Loops) >= 50 {
+		t.Errorf("run 應在目前迴圈結束後停止: %+v", got)
+	}
+	if status := apiRequest(t, "POST", server.URL+"/api/v1/runs", "", RunRequest{Prompt: "新任務"}, nil); status != http.StatusServiceUnavailable {
+		t.Errorf("關閉後應拒絕新的 run，但為 %d", status)
+	}
+	select {
+	case <-streamDone:
+	case <-time.After(5 * time.Second):
+		t.Error("事件串流應在關閉時結束")
+	}
+}
+
+// TestAPIServerResetBreaker 測試重置閒置工作目錄的熔斷器
+func TestAPIServerResetBreaker(t *testing.T) {
+	_, server, workDir := newTestAPIServer(t, "")
+
+	var resp map[string]interface{}
+	status := apiRequest(t, "POST", server.URL+"/api/v1/breaker/reset", "", map[string]string{"work_dir": "."}, &resp)
+	if status != http.StatusOK || resp["work_dir"] != workDir || resp["breaker_state"] != string(StateClosed) {
+		t.Errorf("應重置熔斷器: %d %v", status, resp)
+	}
+}
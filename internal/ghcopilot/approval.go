@@ -0,0 +1,226 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ApprovalAction 代表操作者對一個迴圈的決定
+type ApprovalAction string
+
+const (
+	// ApprovalAccept 接受本輪變更並繼續
+	ApprovalAccept ApprovalAction = "accept"
+	// ApprovalReject 拒絕本輪變更（還原工作目錄）並繼續
+	ApprovalReject ApprovalAction = "reject"
+	// ApprovalStop 停止整個執行
+	ApprovalStop ApprovalAction = "stop"
+)
+
+// ApprovalDecision 代表操作者的決定與回饋
+type ApprovalDecision struct {
+	Action   ApprovalAction `json:"action"`             // 決定
+	Feedback string         `json:"feedback,omitempty"` // 注入下一次 prompt 的回饋
+	Reverted bool           `json:"reverted,omitempty"` // 拒絕時變更是否已還原
+}
+
+// ApprovalRequest 是迴圈結束後交給操作者審核的資訊
+type ApprovalRequest struct {
+	LoopIndex        int          // 迴圈索引
+	Changes          []FileChange // 本輪保留下來的檔案變更
+	Stats            ChangeStats  // 變更統計
+	StructuredStatus *LoopStatus  // 結構化狀態（AI 未輸出時為 nil）
+	CompletionScore  int          // 完成分數
+	ShouldContinue   bool         // 分析結果是否建議繼續
+	ExitReason       string       // 退出理由（如有）
+	Errors           []string     // 本輪的錯誤與策略違規
+}
+
+// Approver 在每個迴圈結束後詢問操作者是否接受
+type Approver interface {
+	Approve(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error)
+}
+
+// ApproverFunc 讓一般函式實作 Approver
+type ApproverFunc func(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error)
+
+// Approve 呼叫函式本身
+func (f ApproverFunc) Approve(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+	return f(ctx, req)
+}
+
+// TerminalApprover 透過終端機與操作者互動
+type TerminalApprover struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// 輸入由背景 goroutine 逐行讀取，讓等待輸入時可以被 context 取消
+	readOnce sync.Once
+	lines    chan terminalLine
+}
+
+// terminalLine 是背景讀取的一行輸入
+type terminalLine struct {
+	text string
+	err  error
+}
+
+// NewTerminalApprover 建立終端機審核器
+func NewTerminalApprover(in io.Reader, out io.Writer) *TerminalApprover {
+	return &TerminalApprover{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// readLine 讀取一行輸入，context 取消時立即返回
+//
+// 被取消時背景讀取仍在等待，讀到的下一行會交給下一次 readLine。
+// 輸入結束後一律傳回 io.EOF。
+func (a *TerminalApprover) readLine(ctx context.Context) (string, error) {
+	a.readOnce.Do(func() {
+		a.lines = make(chan terminalLine)
+		go func() {
+			defer close(a.lines)
+			for {
+				text, err := a.in.ReadString('\n')
+				a.lines <- terminalLine{text: text, err: err}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+
+	select {
+	case line, ok := <-a.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line.text, line.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Approve 顯示迴圈摘要並讀取操作者的決定
+//
+// 可用指令：a 接受、r 拒絕並還原、e 編輯回饋、s 停止。
+// 回饋可以先以 e 輸入，再搭配接受或拒絕送出。
+// 輸入結束（EOF）時視為停止；context 取消時傳回其錯誤。
+func (a *TerminalApprover) Approve(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+	fmt.Fprint(a.out, FormatApprovalRequest(req))
+
+	var feedback string
+	for {
+		if err := ctx.Err(); err != nil {
+			return ApprovalDecision{}, err
+		}
+
+		fmt.Fprint(a.out, "[a]接受 [r]拒絕並還原 [e]編輯回饋 [s]停止 > ")
+		line, err := a.readLine(ctx)
+		if err != nil && line == "" {
+			if err == io.EOF {
+				return ApprovalDecision{Action: ApprovalStop, Feedback: feedback}, nil
+			}
+			return ApprovalDecision{}, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "a", "accept", "":
+			return ApprovalDecision{Action: ApprovalAccept, Feedback: feedback}, nil
+		case "r", "reject":
+			return ApprovalDecision{Action: ApprovalReject, Feedback: feedback}, nil
+		case "s", "stop", "q":
+			return ApprovalDecision{Action: ApprovalStop, Feedback: feedback}, nil
+		case "e", "edit":
+			fmt.Fprint(a.out, "回饋 (單行，空白清除) > ")
+			text, err := a.readLine(ctx)
+			if err != nil && err != io.EOF {
+				return ApprovalDecision{}, err
+			}
+			feedback = strings.TrimSpace(text)
+		default:
+			fmt.Fprintln(a.out, "無效的選項")
+		}
+	}
+}
+
+// ApprovePermission 詢問操作者是否允許權限策略無法決定的 SDK 權限請求（預設拒絕）
+func (a *TerminalApprover) ApprovePermission(ctx context.Context, req PermissionRequest, reason string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(a.out, "\n權限請求 %s: %s\n理由: %s\n允許? [y/N] > ", req.Kind, req.Target(), reason)
+	line, err := a.readLine(ctx)
+	if err != nil && line == "" {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// FormatApprovalRequest 產生給操作者閱讀的迴圈摘要
+func FormatApprovalRequest(req *ApprovalRequest) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n---------- 迴圈 %d 審核 ----------\n", req.LoopIndex+1)
+
+	fmt.Fprintf(&sb, "變更: %d 個檔案, +%d -%d\n", req.Stats.FilesChanged, req.Stats.LinesAdded, req.Stats.LinesRemoved)
+	for _, change := range req.Changes {
+		fmt.Fprintf(&sb, "  %-8s %s (+%d -%d)\n", change.Kind, change.Path, change.LinesAdded, change.LinesRemoved)
+	}
+
+	if req.StructuredStatus != nil {
+		fmt.Fprintf(&sb, "狀態: %s, EXIT_SIGNAL=%v", req.StructuredStatus.Status, req.StructuredStatus.ExitSignal)
+		if req.StructuredStatus.TasksDone != "" {
+			fmt.Fprintf(&sb, ", 任務 %s", req.StructuredStatus.TasksDone)
+		}
+		sb.WriteString("\n")
+	} else {
+		sb.WriteString("狀態: (無結構化輸出)\n")
+	}
+
+	fmt.Fprintf(&sb, "完成分數: %d\n", req.CompletionScore)
+	if req.ShouldContinue {
+		sb.WriteString("分析: 建議繼續\n")
+	} else {
+		fmt.Fprintf(&sb, "分析: 建議結束 (%s)\n", req.ExitReason)
+	}
+
+	for _, e := range req.Errors {
+		fmt.Fprintf(&sb, "錯誤: %s\n", e)
+	}
+	return sb.String()
+}
+
+// FormatFeedbackNote 產生注入下一次 prompt 的操作者回饋
+func FormatFeedbackNote(decision ApprovalDecision) string {
+	var sb strings.Builder
+	if decision.Action == ApprovalReject {
+		if decision.Reverted {
+			sb.WriteString("操作者拒絕了上一輪的變更，這些變更已被還原。")
+		} else {
+			sb.WriteString("操作者拒絕了上一輪的變更。")
+		}
+	}
+	if decision.Feedback != "" {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("操作者回饋：")
+		sb.WriteString(decision.Feedback)
+	}
+	return sb.String()
+}
@@ -0,0 +1,175 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTerminalApproverActions 測試終端機審核的各種輸入
+func TestTerminalApproverActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		action   ApprovalAction
+		feedback string
+	}{
+		{"接受", "a\n", ApprovalAccept, ""},
+		{"預設接受", "\n", ApprovalAccept, ""},
+		{"拒絕", "r\n", ApprovalReject, ""},
+		{"停止", "s\n", ApprovalStop, ""},
+		{"EOF 停止", "", ApprovalStop, ""},
+		{"編輯後接受", "e\n請補上錯誤處理\na\n", ApprovalAccept, "請補上錯誤處理"},
+		{"無效選項後拒絕", "x\ne\n不要改 API\nreject\n", ApprovalReject, "不要改 API"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			approver := NewTerminalApprover(strings.NewReader(tt.input), &out)
+
+			decision, err := approver.Approve(context.Background(), &ApprovalRequest{})
+			if err != nil {
+				t.Fatalf("Approve 失敗: %v", err)
+			}
+			if decision.Action != tt.action || decision.Feedback != tt.feedback {
+				t.Errorf("應為 %s/%q，但為 %s/%q", tt.action, tt.feedback, decision.Action, decision.Feedback)
+			}
+		})
+	}
+}
+
+// TestTerminalApproverCancel 測試等待輸入時可被 context 取消
+func TestTerminalApproverCancel(t *testing.T) {
+	in, w := io.Pipe()
+	defer w.Close()
+	approver := NewTerminalApprover(in, io.Discard)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := approver.Approve(ctx, &ApprovalRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("逾時應傳回 context 錯誤，但為 %v", err)
+	}
+
+	// 取消前未讀到的輸入留給下一次審核
+	go w.Write([]byte("r\n"))
+	decision, err := approver.Approve(context.Background(), &ApprovalRequest{})
+	if err != nil || decision.Action != ApprovalReject {
+		t.Errorf("應讀到下一行輸入，但為 %v, %v", decision, err)
+	}
+}
+
+// TestFormatApprovalRequest 測試審核摘要
+func TestFormatApprovalRequest(t *testing.T) {
+	changes := []FileChange{{Path: "main.go", Kind: FileModified, LinesAdded: 3, LinesRemoved: 1}}
+	text := FormatApprovalRequest(&ApprovalRequest{
+		LoopIndex:        1,
+		Changes:          changes,
+		Stats:            SummarizeChanges(changes),
+		StructuredStatus: &LoopStatus{Status: "CONTINUE", TasksDone: "2/5"},
+		CompletionScore:  10,
+		ShouldContinue:   true,
+		Errors:           []string{"protected path violation"},
+	})
+
+	for _, want := range []string{"迴圈 2 審核", "1 個檔案, +3 -1", "main.go (+3 -1)", "任務 2/5", "建議繼續", "protected path violation"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("摘要應包含 %q:\n%s", want, text)
+		}
+	}
+}
+
+// TestFormatFeedbackNote 測試回饋說明
+func TestFormatFeedbackNote(t *testing.T) {
+	if FormatFeedbackNote(ApprovalDecision{Action: ApprovalAccept}) != "" {
+		t.Error("沒有回饋的接受不應產生說明")
+	}
+
+	note := FormatFeedbackNote(ApprovalDecision{Action: ApprovalReject, Reverted: true, Feedback: "改用表格測試"})
+	if !strings.Contains(note, "已被還原") || !strings.Contains(note, "改用表格測試") {
+		t.Errorf("說明內容不正確: %s", note)
+	}
+}
+
+// TestClientApprovalRejectRevertsAndInjectsFeedback 測試拒絕時還原變更並將回饋注入下一輪
+func TestClientApprovalRejectRevertsAndInjectsFeedback(t *testing.T) {
+	workDir := t.TempDir()
+	installFakeCopilot(t, `echo "$2" > last_prompt.txt
+echo "edit" >> work.txt
+echo "working"
+`)
+
+	var requests []*ApprovalRequest
+	decisions := []ApprovalDecision{
+		{Action: ApprovalReject, Feedback: "不要新增 work.txt"},
+		{Action: ApprovalAccept},
+	}
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithProtectedPaths().
+		WithApprover(ApproverFunc(func(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+			requests = append(requests, req)
+			return decisions[len(requests)-1], nil
+		})).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	client.ExecuteLoop(t.Context(), "任務")
+
+	if len(requests) != 1 || len(requests[0].Changes) != 2 {
+		t.Fatalf("審核請求應包含 2 個變更: %+v", requests)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "work.txt")); !os.IsNotExist(err) {
+		t.Error("拒絕後 work.txt 應被還原")
+	}
+
+	first := client.GetHistory()[0]
+	if !first.Failed || first.UserFeedback != "不要新增 work.txt" {
+		t.Errorf("拒絕的迴圈應標記失敗並記錄回饋: failed=%v feedback=%q", first.Failed, first.UserFeedback)
+	}
+	if decision, ok := first.Metadata["approval"].(ApprovalDecision); !ok || !decision.Reverted {
+		t.Errorf("應記錄已還原的審核決定: %+v", first.Metadata["approval"])
+	}
+
+	client.ExecuteLoop(t.Context(), "任務")
+	prompt, _ := os.ReadFile(filepath.Join(workDir, "last_prompt.txt"))
+	if !strings.Contains(string(prompt), "操作者回饋：不要新增 work.txt") {
+		t.Errorf("下一輪 prompt 應包含回饋: %s", prompt)
+	}
+}
+
+// TestClientApprovalStop 測試操作者停止執行
+func TestClientApprovalStop(t *testing.T) {
+	workDir := t.TempDir()
+	installFakeCopilot(t, `echo "working"
+`)
+
+	calls := 0
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithApprover(ApproverFunc(func(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+			calls++
+			return ApprovalDecision{Action: ApprovalStop}, nil
+		})).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	results, err := client.ExecuteUntilCompletion(t.Context(), "任務", 5)
+	if err == nil || !strings.Contains(err.Error(), "stopped by operator") {
+		t.Errorf("應傳回操作者停止的錯誤，但為 %v", err)
+	}
+	if calls != 1 || len(results) != 1 || !results[0].Stopped {
+		t.Errorf("應在第一輪後停止: calls=%d results=%d", calls, len(results))
+	}
+	if results[0].ExitReason != "stopped by operator" {
+		t.Errorf("退出理由不正確: %s", results[0].ExitReason)
+	}
+}
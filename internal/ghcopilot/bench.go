@@ -0,0 +1,411 @@
+package ghcopilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BenchFixtureFileName 基準測試題目的設定檔名
+const BenchFixtureFileName = "fixture.json"
+
+// 基準測試題目目錄中的子目錄
+const (
+	benchRepoDir     = "repo"     // 複製到暫存目錄的專案
+	benchSolutionDir = "solution" // fake 執行器套用的修正
+)
+
+// 基準測試使用的執行器
+const (
+	BenchExecutorReal   = "real"   // copilot SDK/CLI
+	BenchExecutorFake   = "fake"   // 套用題目的參考修正（驗證題目與流程本身）
+	BenchExecutorReplay = "replay" // 回放先前基準測試錄製的回應（不重現檔案變更，只比較迴圈決策）
+)
+
+// 題目未指定時的預設值
+const (
+	DefaultBenchVerify   = "go test ./..."
+	DefaultBenchMaxLoops = 5
+)
+
+// BenchFixture 是一個基準測試題目
+//
+// 題目目錄包含 fixture.json、repo/（有問題的專案，例如測試失敗的 Go 模組）
+// 與選用的 solution/（參考修正，fake 執行器會覆蓋到工作目錄）。
+type BenchFixture struct {
+	Name        string `json:"-"` // 目錄名稱
+	Dir         string `json:"-"`
+	Description string `json:"description,omitempty"`
+	Prompt      string `json:"prompt"`
+	Verify      string `json:"verify,omitempty"`    // 迴圈結束後的驗證指令 (預設: DefaultBenchVerify)
+	MaxLoops    int    `json:"max_loops,omitempty"` // (預設: DefaultBenchMaxLoops)
+}
+
+// LoadBenchFixtures 載入目錄中的所有題目（依名稱排序）
+func LoadBenchFixtures(dir string) ([]BenchFixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取題目目錄: %w", err)
+	}
+
+	var fixtures []BenchFixture
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		fixtureDir := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(fixtureDir, BenchFixtureFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("題目 %s: %w", entry.Name(), err)
+		}
+
+		fixture := BenchFixture{Name: entry.Name(), Dir: fixtureDir}
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("題目 %s: 設定格式錯誤: %w", entry.Name(), err)
+		}
+		if strings.TrimSpace(fixture.Prompt) == "" {
+			return nil, fmt.Errorf("題目 %s: 缺少 prompt", entry.Name())
+		}
+		if info, err := os.Stat(filepath.Join(fixtureDir, benchRepoDir)); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("題目 %s: 缺少 %s/ 目錄", entry.Name(), benchRepoDir)
+		}
+		if fixture.Verify == "" {
+			fixture.Verify = DefaultBenchVerify
+		}
+		if fixture.MaxLoops <= 0 {
+			fixture.MaxLoops = DefaultBenchMaxLoops
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].Name < fixtures[j].Name })
+	return fixtures, nil
+}
+
+// SolutionExecutor 是 fake 執行器：每次呼叫都把參考修正覆蓋到工作目錄並回報完成
+//
+// 沒有參考修正時不修改任何檔案，也不回報完成。
+type SolutionExecutor struct {
+	solutionDir string
+	workDir     string
+}
+
+// NewSolutionExecutor 建立套用 solutionDir 到 workDir 的執行器
+func NewSolutionExecutor(solutionDir, workDir string) *SolutionExecutor {
+	return &SolutionExecutor{solutionDir: solutionDir, workDir: workDir}
+}
+
+// ExecutePrompt 套用參考修正
+func (e *SolutionExecutor) ExecutePrompt(ctx context.Context, prompt string) (*ExecutionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	result := &ExecutionResult{Command: "fake:solution", Success: true}
+
+	files, err := overlayDir(e.workDir, e.solutionDir)
+	switch {
+	case os.IsNotExist(err):
+		result.Stdout = "沒有參考修正，未修改任何檔案。\n"
+	case err != nil:
+		return nil, err
+	default:
+		result.Stdout = fmt.Sprintf("套用參考修正: %s\n全部完成 done\n", strings.Join(files, ", "))
+	}
+	result.ExecutionTime = time.Since(start)
+	return result, nil
+}
+
+// overlayDir 將 src 中的檔案複製到 dst（覆蓋同名檔案），傳回複製的相對路徑
+func overlayDir(dst, src string) ([]string, error) {
+	if _, err := os.Stat(src); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// BenchOptions 是基準測試的設定
+type BenchOptions struct {
+	Executor    string               // BenchExecutor* (預設: real)
+	Model       string               // 模型 (預設: ClientConfig 的預設值)
+	Timeout     time.Duration        // 每個題目的逾時 (預設: 不限制)
+	RequestCost float64              // 每次執行器呼叫的費用，用於估算成本 (預設: 0)
+	Replay      *BenchReport         // replay 執行器回放的基準測試結果（依題目名稱找到 run 的錄製檔）
+	Config      func() *ClientConfig // 基本配置，RunsDir 與 SaveDir 取自此處 (預設: DefaultClientConfig)
+	Logger      *slog.Logger         // 元件日誌 (預設: 不輸出)
+	Progress    io.Writer            // 每個題目開始與結束時的進度 (預設: 不輸出)
+}
+
+// BenchReport 是一次基準測試的結果（JSON 欄位固定，可用於比較不同版本）
+type BenchReport struct {
+	StartedAt time.Time     `json:"started_at"`
+	Executor  string        `json:"executor"`
+	Model     string        `json:"model"`
+	Summary   BenchSummary  `json:"summary"`
+	Fixtures  []BenchResult `json:"fixtures"`
+}
+
+// BenchSummary 彙整所有題目
+type BenchSummary struct {
+	Fixtures     int     `json:"fixtures"`
+	Passed       int     `json:"passed"`
+	PassRate     float64 `json:"pass_rate"`
+	MeanLoops    float64 `json:"mean_loops"`
+	DurationMs   int64   `json:"duration_ms"`
+	Requests     int     `json:"requests"`
+	Cost         float64 `json:"cost"`
+	MeanCostPass float64 `json:"mean_cost_per_pass"` // 沒有通過的題目時為 0
+}
+
+// BenchResult 是一個題目的結果
+type BenchResult struct {
+	Fixture      string    `json:"fixture"`
+	RunID        string    `json:"run_id"`
+	Passed       bool      `json:"passed"` // 迴圈結束後驗證指令成功
+	Status       RunStatus `json:"status"` // 迴圈的結束狀態
+	Error        string    `json:"error,omitempty"`
+	Loops        int       `json:"loops"`
+	DurationMs   int64     `json:"duration_ms"`
+	Requests     int       `json:"requests"` // 執行器呼叫次數
+	Cost         float64   `json:"cost"`
+	VerifyOutput string    `json:"verify_output,omitempty"` // 驗證失敗時的輸出
+}
+
+// RunBench 依序在暫存目錄中對每個題目執行迴圈，並以驗證指令判斷是否解決
+//
+// 迴圈的紀錄與一般執行相同，寫入配置的 RunsDir 與 SaveDir，可用 history、report 與 replay 查看。
+func RunBench(ctx context.Context, fixtures []BenchFixture, opts BenchOptions) (*BenchReport, error) {
+	if opts.Executor == "" {
+		opts.Executor = BenchExecutorReal
+	}
+	switch opts.Executor {
+	case BenchExecutorReal, BenchExecutorFake:
+	case BenchExecutorReplay:
+		if opts.Replay == nil {
+			return nil, fmt.Errorf("replay 執行器需要先前的基準測試結果")
+		}
+	default:
+		return nil, fmt.Errorf("未知的執行器 %q (可用: %s, %s, %s)", opts.Executor, BenchExecutorReal, BenchExecutorFake, BenchExecutorReplay)
+	}
+	if opts.Config == nil {
+		opts.Config = DefaultClientConfig
+	}
+	if opts.Logger == nil {
+		opts.Logger = discardLogger()
+	}
+	if opts.Progress == nil {
+		opts.Progress = io.Discard
+	}
+
+	report := &BenchReport{StartedAt: time.Now(), Executor: opts.Executor, Fixtures: []BenchResult{}}
+	for _, fixture := range fixtures {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		fmt.Fprintf(opts.Progress, "▶ %s\n", fixture.Name)
+		result, model, err := runBenchFixture(ctx, fixture, opts)
+		if err != nil {
+			return report, fmt.Errorf("題目 %s: %w", fixture.Name, err)
+		}
+		report.Model = model
+		report.Fixtures = append(report.Fixtures, result)
+
+		mark := "✗"
+		if result.Passed {
+			mark = "✓"
+		}
+		fmt.Fprintf(opts.Progress, "%s %s: %d 個迴圈, %s\n", mark, fixture.Name, result.Loops, formatReportDuration(time.Duration(result.DurationMs)*time.Millisecond))
+	}
+	report.Summary = summarizeBench(report.Fixtures)
+	return report, nil
+}
+
+// runBenchFixture 在暫存目錄中執行一個題目
+func runBenchFixture(ctx context.Context, fixture BenchFixture, opts BenchOptions) (BenchResult, string, error) {
+	result := BenchResult{Fixture: fixture.Name}
+
+	workDir, err := os.MkdirTemp("", "ralph-bench-"+fixture.Name+"-")
+	if err != nil {
+		return result, "", fmt.Errorf("無法建立暫存工作目錄: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+	if _, err := overlayDir(workDir, filepath.Join(fixture.Dir, benchRepoDir)); err != nil {
+		return result, "", fmt.Errorf("無法複製專案: %w", err)
+	}
+
+	// 紀錄寫入基本配置的目錄，而不是題目的暫存工作目錄
+	config := opts.Config()
+	for _, dir := range []*string{&config.SaveDir, &config.RunsDir} {
+		if abs, err := filepath.Abs(ResolveWorkDirPath(config.WorkDir, *dir)); err == nil {
+			*dir = abs
+		}
+	}
+	config.WorkDir = workDir
+	config.Silent = true
+	config.Logger = opts.Logger
+	if opts.Model != "" {
+		config.Model = opts.Model
+	}
+	switch opts.Executor {
+	case BenchExecutorFake:
+		config.Executor = NewSolutionExecutor(filepath.Join(fixture.Dir, benchSolutionDir), workDir)
+		config.EnableSDK = false
+	case BenchExecutorReplay:
+		entries, err := benchTranscript(opts.Replay, fixture.Name, config.RunsDir)
+		if err != nil {
+			return result, "", err
+		}
+		config.Executor = NewReplayExecutor(entries)
+		config.EnableSDK = false
+	}
+
+	runCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	client := NewRalphLoopClientWithConfig(config)
+	result.RunID = client.RunID()
+	start := time.Now()
+	loops, runErr := client.ExecuteUntilCompletion(runCtx, fixture.Prompt, fixture.MaxLoops)
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Loops = len(client.GetHistory())
+	switch {
+	case runErr == nil:
+		result.Status = RunStatusCompleted
+	case runCtx.Err() != nil:
+		result.Status = RunStatusCancelled
+	case len(loops) > 0 && loops[len(loops)-1].Stopped:
+		result.Status = RunStatusStopped
+	default:
+		result.Status = RunStatusFailed
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+	if dir := client.RunDir(); dir != "" {
+		if entries, err := ReadTranscript(filepath.Join(dir, TranscriptFileName)); err == nil {
+			result.Requests = len(entries)
+		}
+	}
+	result.Cost = float64(result.Requests) * opts.RequestCost
+	client.Close()
+
+	// 驗證不受迴圈逾時影響
+	verify := ShellHook{Point: HookOnComplete, Command: fixture.Verify, Timeout: 5 * time.Minute}
+	output, err := verify.Execute(ctx, &HookContext{Point: HookOnComplete, RunID: result.RunID, WorkDir: workDir})
+	result.Passed = err == nil
+	if err != nil {
+		result.VerifyOutput = truncateString(strings.TrimSpace(output), 2000)
+	}
+	return result, config.Model, nil
+}
+
+// benchTranscript 從先前的基準測試結果找到題目的錄製檔
+func benchTranscript(previous *BenchReport, fixture, runsDir string) ([]TranscriptEntry, error) {
+	for _, result := range previous.Fixtures {
+		if result.Fixture == fixture {
+			return ReadTranscript(filepath.Join(runsDir, result.RunID, TranscriptFileName))
+		}
+	}
+	return nil, fmt.Errorf("先前的基準測試沒有題目 %s", fixture)
+}
+
+// summarizeBench 彙整所有題目的結果
+func summarizeBench(results []BenchResult) BenchSummary {
+	summary := BenchSummary{Fixtures: len(results)}
+	loops := 0
+	for _, result := range results {
+		if result.Passed {
+			summary.Passed++
+		}
+		loops += result.Loops
+		summary.DurationMs += result.DurationMs
+		summary.Requests += result.Requests
+		summary.Cost += result.Cost
+	}
+	summary.PassRate = ratio(summary.Passed, summary.Fixtures)
+	summary.MeanLoops = ratio(loops, summary.Fixtures)
+	if summary.Passed > 0 {
+		summary.MeanCostPass = summary.Cost / float64(summary.Passed)
+	}
+	return summary
+}
+
+// ReadBenchReport 讀取先前以 JSON 輸出的基準測試結果
+func ReadBenchReport(path string) (*BenchReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取基準測試結果: %w", err)
+	}
+	var report BenchReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("基準測試結果格式錯誤: %w", err)
+	}
+	return &report, nil
+}
+
+// WriteText 以表格輸出每個題目的結果與彙總
+func (r *BenchReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	rows := [][]string{{"題目", "通過", "狀態", "迴圈", "耗時", "呼叫", "成本", "RUN ID"}}
+	for _, result := range r.Fixtures {
+		passed := "✗"
+		if result.Passed {
+			passed = "✓"
+		}
+		rows = append(rows, []string{
+			result.Fixture, passed, string(result.Status), strconv.Itoa(result.Loops),
+			formatReportDuration(time.Duration(result.DurationMs) * time.Millisecond),
+			strconv.Itoa(result.Requests), fmt.Sprintf("%.2f", result.Cost), result.RunID,
+		})
+	}
+	if err := writeHistoryTable(&b, rows); err != nil {
+		return err
+	}
+
+	s := r.Summary
+	fmt.Fprintf(&b, "\n執行器: %s  模型: %s\n", r.Executor, orDash(r.Model))
+	fmt.Fprintf(&b, "通過率: %s (%d/%d)  平均迴圈: %.1f  總耗時: %s  呼叫: %d  成本: %.2f\n",
+		formatPercent(s.PassRate), s.Passed, s.Fixtures, s.MeanLoops,
+		formatReportDuration(time.Duration(s.DurationMs)*time.Millisecond), s.Requests, s.Cost)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,206 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBenchFixture 建立一個題目：驗證指令檢查 fixed.txt，solution 非空時放在 solution/
+func writeBenchFixture(t *testing.T, dir, name, fixture string, solution map[string]string) {
+	t.Helper()
+	files := map[string]string{
+		BenchFixtureFileName: fixture,
+		"repo/README":        "broken\n",
+	}
+	for path, content := range solution {
+		files[filepath.Join(benchSolutionDir, path)] = content
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, name, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// benchTestConfig 將 run 紀錄寫入暫存目錄
+func benchTestConfig(t *testing.T) func() *ClientConfig {
+	dir := t.TempDir()
+	return func() *ClientConfig {
+		config := DefaultClientConfig()
+		config.SaveDir = filepath.Join(dir, "saves")
+		config.RunsDir = filepath.Join(dir, "runs")
+		config.ProtectedPaths = nil
+		config.EnableSDK = false
+		return config
+	}
+}
+
+func TestLoadBenchFixtures(t *testing.T) {
+	dir := t.TempDir()
+	writeBenchFixture(t, dir, "b", `{"prompt":"修正","verify":"true","max_loops":2}`, nil)
+	writeBenchFixture(t, dir, "a", `{"prompt":"修正"}`, nil)
+	if err := os.MkdirAll(filepath.Join(dir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures, err := LoadBenchFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 2 || fixtures[0].Name != "a" || fixtures[1].Name != "b" {
+		t.Fatalf("應依名稱載入兩個題目並略過沒有設定檔的目錄: %+v", fixtures)
+	}
+	if fixtures[0].Verify != DefaultBenchVerify || fixtures[0].MaxLoops != DefaultBenchMaxLoops {
+		t.Errorf("未指定時應使用預設值: %+v", fixtures[0])
+	}
+	if fixtures[1].Verify != "true" || fixtures[1].MaxLoops != 2 {
+		t.Errorf("應使用題目的設定: %+v", fixtures[1])
+	}
+
+	bad := t.TempDir()
+	writeBenchFixture(t, bad, "x", `{"verify":"true"}`, nil)
+	if _, err := LoadBenchFixtures(bad); err == nil || !strings.Contains(err.Error(), "prompt") {
+		t.Errorf("缺少 prompt 應回報錯誤: %v", err)
+	}
+}
+
+func TestRunBenchFake(t *testing.T) {
+	dir := t.TempDir()
+	fixture := `{"prompt":"建立 fixed.txt","verify":"test -f fixed.txt","max_loops":2}`
+	writeBenchFixture(t, dir, "solved", fixture, map[string]string{"fixed.txt": "ok\n"})
+	writeBenchFixture(t, dir, "unsolved", fixture, nil)
+	fixtures, err := LoadBenchFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress bytes.Buffer
+	report, err := RunBench(t.Context(), fixtures, BenchOptions{
+		Executor:    BenchExecutorFake,
+		RequestCost: 0.5,
+		Config:      benchTestConfig(t),
+		Progress:    &progress,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solved, unsolved := report.Fixtures[0], report.Fixtures[1]
+	if !solved.Passed || solved.Status != RunStatusCompleted || solved.Loops != 1 || solved.Requests != 1 || solved.Cost != 0.5 {
+		t.Errorf("套用參考修正的題目應在第一個迴圈通過: %+v", solved)
+	}
+	if unsolved.Passed || unsolved.Status != RunStatusFailed || unsolved.Loops != 2 || unsolved.Requests != 2 || unsolved.VerifyOutput == "" && unsolved.Error == "" {
+		t.Errorf("沒有參考修正的題目應用完迴圈且驗證失敗: %+v", unsolved)
+	}
+	s := report.Summary
+	if s.Fixtures != 2 || s.Passed != 1 || s.PassRate != 0.5 || s.MeanLoops != 1.5 || s.Requests != 3 || s.Cost != 1.5 || s.MeanCostPass != 1.5 {
+		t.Errorf("彙總不正確: %+v", s)
+	}
+	if !strings.Contains(progress.String(), "✓ solved") || !strings.Contains(progress.String(), "✗ unsolved") {
+		t.Errorf("應輸出每個題目的進度:\n%s", progress.String())
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "通過率: 50% (1/2)") {
+		t.Errorf("文字輸出缺少通過率:\n%s", text.String())
+	}
+
+	// JSON 輸出可再讀回，作為 replay 的輸入
+	path := filepath.Join(t.TempDir(), "bench.json")
+	data, _ := json.Marshal(report)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := ReadBenchReport(path)
+	if err != nil || len(previous.Fixtures) != 2 || previous.Fixtures[0].RunID != solved.RunID {
+		t.Fatalf("無法讀回基準測試結果: %+v, %v", previous, err)
+	}
+}
+
+func TestRunBenchReplay(t *testing.T) {
+	dir := t.TempDir()
+	writeBenchFixture(t, dir, "solved", `{"prompt":"建立 fixed.txt","verify":"true","max_loops":3}`, map[string]string{"fixed.txt": "ok\n"})
+	fixtures, err := LoadBenchFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := benchTestConfig(t)
+
+	recorded, err := RunBench(t.Context(), fixtures, BenchOptions{Executor: BenchExecutorFake, Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := RunBench(t.Context(), fixtures, BenchOptions{Executor: BenchExecutorReplay, Replay: recorded, Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := replayed.Fixtures[0], recorded.Fixtures[0]
+	if got.RunID == want.RunID || got.Status != want.Status || got.Loops != want.Loops || got.Requests != want.Requests {
+		t.Errorf("回放應以新的 run 重現迴圈結果: 原本 %+v, 回放 %+v", want, got)
+	}
+
+	if _, err := RunBench(t.Context(), fixtures, BenchOptions{Executor: BenchExecutorReplay, Config: config}); err == nil {
+		t.Error("沒有先前的結果時 replay 應回報錯誤")
+	}
+	if _, err := RunBench(t.Context(), fixtures, BenchOptions{Executor: "nope", Config: config}); err == nil {
+		t.Error("未知的執行器應回報錯誤")
+	}
+}
+
+// TestBenchFixturesSolvable 檢查內建題目在修正前失敗、套用參考修正後通過
+func TestBenchFixturesSolvable(t *testing.T) {
+	if testing.Short() {
+		t.Skip("需要執行 go test")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("找不到 go")
+	}
+	fixtures, err := LoadBenchFixtures(filepath.Join("..", "..", "testdata", "bench"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("沒有內建題目")
+	}
+
+	unsolved := make([]BenchFixture, len(fixtures))
+	for i, fixture := range fixtures {
+		unsolved[i] = fixture
+		unsolved[i].Dir = t.TempDir()
+		if err := os.CopyFS(unsolved[i].Dir, os.DirFS(fixture.Dir)); err != nil {
+			t.Fatal(err)
+		}
+		os.RemoveAll(filepath.Join(unsolved[i].Dir, benchSolutionDir))
+		unsolved[i].MaxLoops = 1
+	}
+
+	config := benchTestConfig(t)
+	broken, err := RunBench(t.Context(), unsolved, BenchOptions{Executor: BenchExecutorFake, Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := RunBench(t.Context(), fixtures, BenchOptions{Executor: BenchExecutorFake, Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range fixtures {
+		if broken.Fixtures[i].Passed {
+			t.Errorf("%s: 沒有修正時驗證應失敗", fixtures[i].Name)
+		}
+		if !fixed.Fixtures[i].Passed {
+			t.Errorf("%s: 套用參考修正後驗證應通過:\n%s", fixtures[i].Name, fixed.Fixtures[i].VerifyOutput)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ChangeScopePolicy 限制單一迴圈與整次執行可以變更的範圍
+//
+// 所有上限為 0 時表示不限制。超過限制的迴圈會被標記為失敗，
+// 並依 RevertOnViolation 決定是否將該迴圈的變更還原。
+type ChangeScopePolicy struct {
+	MaxFilesPerLoop        int      `json:"max_files_per_loop"`         // 單一迴圈最多變更的檔案數
+	MaxLinesAddedPerLoop   int      `json:"max_lines_added_per_loop"`   // 單一迴圈最多新增行數
+	MaxLinesRemovedPerLoop int      `json:"max_lines_removed_per_loop"` // 單一迴圈最多刪除行數
+	MaxLinesAddedPerRun    int      `json:"max_lines_added_per_run"`    // 整次執行最多新增行數
+	MaxLinesRemovedPerRun  int      `json:"max_lines_removed_per_run"`  // 整次執行最多刪除行數
+	AllowedPathPrefixes    []string `json:"allowed_path_prefixes"`      // 允許變更的路徑前綴（空值不限制）
+	RevertOnViolation      bool     `json:"revert_on_violation"`        // 超過限制時是否還原該迴圈的變更
+}
+
+// Enabled 檢查是否有任何限制
+func (p *ChangeScopePolicy) Enabled() bool {
+	if p == nil {
+		return false
+	}
+	return p.MaxFilesPerLoop > 0 ||
+		p.MaxLinesAddedPerLoop > 0 ||
+		p.MaxLinesRemovedPerLoop > 0 ||
+		p.MaxLinesAddedPerRun > 0 ||
+		p.MaxLinesRemovedPerRun > 0 ||
+		len(p.AllowedPathPrefixes) > 0
+}
+
+// Evaluate 檢查本次迴圈的變更是否超出範圍
+//
+// runTotals 為本次迴圈之前已接受的變更累計。
+// 傳回每一項超出的限制描述，沒有超出時傳回 nil。
+func (p *ChangeScopePolicy) Evaluate(changes []FileChange, runTotals ChangeStats) []string {
+	if !p.Enabled() || len(changes) == 0 {
+		return nil
+	}
+
+	var problems []string
+	loop := SummarizeChanges(changes)
+
+	if p.MaxFilesPerLoop > 0 && loop.FilesChanged > p.MaxFilesPerLoop {
+		problems = append(problems, fmt.Sprintf("變更檔案數 %d 超過單迴圈上限 %d", loop.FilesChanged, p.MaxFilesPerLoop))
+	}
+	if p.MaxLinesAddedPerLoop > 0 && loop.LinesAdded > p.MaxLinesAddedPerLoop {
+		problems = append(problems, fmt.Sprintf("新增 %d 行超過單迴圈上限 %d", loop.LinesAdded, p.MaxLinesAddedPerLoop))
+	}
+	if p.MaxLinesRemovedPerLoop > 0 && loop.LinesRemoved > p.MaxLinesRemovedPerLoop {
+		problems = append(problems, fmt.Sprintf("刪除 %d 行超過單迴圈上限 %d", loop.LinesRemoved, p.MaxLinesRemovedPerLoop))
+	}
+
+	total := runTotals
+	total.Add(loop)
+	if p.MaxLinesAddedPerRun > 0 && total.LinesAdded > p.MaxLinesAddedPerRun {
+		problems = append(problems, fmt.Sprintf("累計新增 %d 行超過整次執行上限 %d", total.LinesAdded, p.MaxLinesAddedPerRun))
+	}
+	if p.MaxLinesRemovedPerRun > 0 && total.LinesRemoved > p.MaxLinesRemovedPerRun {
+		problems = append(problems, fmt.Sprintf("累計刪除 %d 行超過整次執行上限 %d", total.LinesRemoved, p.MaxLinesRemovedPerRun))
+	}
+
+	if p.limitsLines() {
+		var oversized []string
+		for _, change := range changes {
+			if change.Oversized {
+				oversized = append(oversized, change.Path)
+			}
+		}
+		if len(oversized) > 0 {
+			problems = append(problems, fmt.Sprintf("檔案過大，無法計算變更行數: %s", strings.Join(oversized, ", ")))
+		}
+	}
+
+	if outside := p.outOfScope(changes); len(outside) > 0 {
+		problems = append(problems, fmt.Sprintf("變更超出允許的路徑 (%s): %s",
+			strings.Join(p.AllowedPathPrefixes, ", "), strings.Join(outside, ", ")))
+	}
+
+	return problems
+}
+
+// limitsLines 檢查是否有任何行數限制
+func (p *ChangeScopePolicy) limitsLines() bool {
+	return p.MaxLinesAddedPerLoop > 0 ||
+		p.MaxLinesRemovedPerLoop > 0 ||
+		p.MaxLinesAddedPerRun > 0 ||
+		p.MaxLinesRemovedPerRun > 0
+}
+
+// outOfScope 傳回不在允許前綴內的變更路徑
+func (p *ChangeScopePolicy) outOfScope(changes []FileChange) []string {
+	if len(p.AllowedPathPrefixes) == 0 {
+		return nil
+	}
+
+	var outside []string
+	for _, change := range changes {
+		if !p.inScope(change.Path) {
+			outside = append(outside, change.Path)
+		}
+	}
+	return outside
+}
+
+// inScope 檢查路徑是否在任一允許前綴內
+func (p *ChangeScopePolicy) inScope(filePath string) bool {
+	for _, prefix := range p.AllowedPathPrefixes {
+		prefix = strings.TrimPrefix(path.Clean(strings.ReplaceAll(prefix, "\\", "/")), "./")
+		if prefix == "." || filePath == prefix || strings.HasPrefix(filePath, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatScopeNote 產生注入下一次 prompt 的說明
+func FormatScopeNote(problems []string, reverted bool) string {
+	if len(problems) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	if reverted {
+		sb.WriteString("上一輪的變更超出允許範圍，已全部還原：\n")
+	} else {
+		sb.WriteString("上一輪的變更超出允許範圍：\n")
+	}
+	for _, problem := range problems {
+		sb.WriteString("- ")
+		sb.WriteString(problem)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("請以較小、可審查的步驟進行修改，並只變更允許的路徑。")
+	return sb.String()
+}
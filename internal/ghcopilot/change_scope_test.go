@@ -0,0 +1,208 @@
+package ghcopilot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestChangeScopePolicyEnabled 測試是否啟用
+func TestChangeScopePolicyEnabled(t *testing.T) {
+	var nilPolicy *ChangeScopePolicy
+	if nilPolicy.Enabled() {
+		t.Error("nil 策略不應啟用")
+	}
+	if (&ChangeScopePolicy{RevertOnViolation: true}).Enabled() {
+		t.Error("沒有任何上限時不應啟用")
+	}
+	if !(&ChangeScopePolicy{MaxFilesPerLoop: 1}).Enabled() {
+		t.Error("設定上限後應啟用")
+	}
+}
+
+// TestChangeScopePolicyEvaluate 測試各項限制
+func TestChangeScopePolicyEvaluate(t *testing.T) {
+	changes := []FileChange{
+		{Path: "internal/a.go", Kind: FileModified, LinesAdded: 30, LinesRemoved: 5},
+		{Path: "internal/b.go", Kind: FileAdded, LinesAdded: 20},
+		{Path: "README.md", Kind: FileModified, LinesAdded: 1, LinesRemoved: 1},
+	}
+
+	tests := []struct {
+		name     string
+		policy   ChangeScopePolicy
+		totals   ChangeStats
+		problems int
+		contains string
+	}{
+		{"無超出", ChangeScopePolicy{MaxFilesPerLoop: 5, MaxLinesAddedPerLoop: 100}, ChangeStats{}, 0, ""},
+		{"檔案數", ChangeScopePolicy{MaxFilesPerLoop: 2}, ChangeStats{}, 1, "檔案數 3"},
+		{"新增行數", ChangeScopePolicy{MaxLinesAddedPerLoop: 50}, ChangeStats{}, 1, "新增 51 行"},
+		{"刪除行數", ChangeScopePolicy{MaxLinesRemovedPerLoop: 3}, ChangeStats{}, 1, "刪除 6 行"},
+		{"累計新增", ChangeScopePolicy{MaxLinesAddedPerRun: 100}, ChangeStats{LinesAdded: 60}, 1, "累計新增 111 行"},
+		{"累計刪除", ChangeScopePolicy{MaxLinesRemovedPerRun: 10}, ChangeStats{LinesRemoved: 5}, 1, "累計刪除 11 行"},
+		{"路徑範圍", ChangeScopePolicy{AllowedPathPrefixes: []string{"./internal/"}}, ChangeStats{}, 1, "README.md"},
+		{"多項超出", ChangeScopePolicy{MaxFilesPerLoop: 1, MaxLinesAddedPerLoop: 1}, ChangeStats{}, 2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.policy.Evaluate(changes, tt.totals)
+			if len(problems) != tt.problems {
+				t.Fatalf("應有 %d 項超出，但為 %v", tt.problems, problems)
+			}
+			if tt.contains != "" && !strings.Contains(strings.Join(problems, "\n"), tt.contains) {
+				t.Errorf("描述應包含 %q，但為 %v", tt.contains, problems)
+			}
+		})
+	}
+}
+
+// TestChangeScopeOversizedFiles 測試無法計算行數的大型檔案
+func TestChangeScopeOversizedFiles(t *testing.T) {
+	changes := []FileChange{{Path: "data/big.csv", Kind: FileModified, Oversized: true}}
+
+	tests := []struct {
+		name     string
+		policy   ChangeScopePolicy
+		problems int
+	}{
+		{"行數限制", ChangeScopePolicy{MaxLinesAddedPerLoop: 100}, 1},
+		{"累計限制", ChangeScopePolicy{MaxLinesRemovedPerRun: 100}, 1},
+		{"只限制檔案數", ChangeScopePolicy{MaxFilesPerLoop: 5}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.policy.Evaluate(changes, ChangeStats{})
+			if len(problems) != tt.problems {
+				t.Fatalf("應有 %d 項超出，但為 %v", tt.problems, problems)
+			}
+			if tt.problems > 0 && !strings.Contains(problems[0], "data/big.csv") {
+				t.Errorf("描述應包含檔案路徑，但為 %v", problems)
+			}
+		})
+	}
+}
+
+// TestChangeScopePrefixBoundary 測試前綴以目錄為單位比對
+func TestChangeScopePrefixBoundary(t *testing.T) {
+	policy := &ChangeScopePolicy{AllowedPathPrefixes: []string{"internal"}}
+	if !policy.inScope("internal/a.go") {
+		t.Error("internal/a.go 應在範圍內")
+	}
+	if policy.inScope("internalx/a.go") {
+		t.Error("internalx/a.go 不應在範圍內")
+	}
+}
+
+// TestClientEnforcesChangeScope 測試客戶端在超出範圍時還原並標記失敗
+func TestClientEnforcesChangeScope(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, "main.go", "package main\n")
+	installFakeCopilot(t, `printf 'a\nb\nc\nd\n' > big.txt
+echo "package main // edited" > main.go
+echo "changed files"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithChangeScope(&ChangeScopePolicy{MaxLinesAddedPerLoop: 3, RevertOnViolation: true}).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	if _, err := client.ExecuteLoop(t.Context(), "重構"); err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(workDir, "big.txt")); !os.IsNotExist(err) {
+		t.Error("超出範圍的新增檔案應被還原")
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "main.go"))
+	if string(data) != "package main\n" {
+		t.Errorf("main.go 應被還原，但為 %q", data)
+	}
+
+	loop := client.GetHistory()[0]
+	if !loop.Failed {
+		t.Error("超出範圍的迴圈應標記為失敗")
+	}
+	if len(loop.WorkspaceChanges) != 0 {
+		t.Errorf("還原後不應保留變更，但為 %v", loop.WorkspaceChanges)
+	}
+	if client.GetSummary()["error_count"] != 1 {
+		t.Errorf("失敗迴圈應計入錯誤數，但為 %v", client.GetSummary()["error_count"])
+	}
+	if client.breaker.GetStats()["violation_loops"] != 1 {
+		t.Error("熔斷器應記錄違規")
+	}
+}
+
+// TestClientRecordsOneViolationPerLoop 測試同一迴圈違反兩種策略只記錄一次違規
+func TestClientRecordsOneViolationPerLoop(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, "go.mod", "module example\n")
+	installFakeCopilot(t, `echo "module hacked" > go.mod
+printf 'a\nb\nc\nd\n' > big.txt
+echo "changed files"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithChangeScope(&ChangeScopePolicy{MaxLinesAddedPerLoop: 3}).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	if _, err := client.ExecuteLoop(t.Context(), "重構"); err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+
+	loop := client.GetHistory()[0]
+	if loop.Metadata["protected_path_violations"] == nil || loop.Metadata["change_scope_violations"] == nil {
+		t.Fatalf("應同時違反受保護路徑與變更範圍: %v", loop.ErrorHistory)
+	}
+	if client.breaker.GetStats()["violation_loops"] != 1 {
+		t.Errorf("一個迴圈只應記錄一次違規，但為 %v", client.breaker.GetStats()["violation_loops"])
+	}
+	if client.breaker.GetState() == StateOpen {
+		t.Error("單一迴圈的違規不應打開熔斷器")
+	}
+}
+
+// TestClientRecordsWorkspaceChanges 測試未超出範圍時記錄變更並累計
+func TestClientRecordsWorkspaceChanges(t *testing.T) {
+	workDir := t.TempDir()
+	installFakeCopilot(t, `echo "line" >> notes.txt
+echo "appended"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithChangeScope(&ChangeScopePolicy{MaxLinesAddedPerRun: 1}).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	client.ExecuteLoop(t.Context(), "第一輪")
+	first := client.GetHistory()[0]
+	if first.Failed || len(first.WorkspaceChanges) != 1 {
+		t.Fatalf("第一輪應成功並記錄 1 個變更: %+v", first.WorkspaceChanges)
+	}
+	if !strings.Contains(first.WorkspaceDiff, "+++ b/notes.txt\n") {
+		t.Errorf("應保存本輪變更的 diff: %q", first.WorkspaceDiff)
+	}
+
+	// 第二輪累計超過整次執行上限，未設定還原時保留變更但標記失敗
+	client.ExecuteLoop(t.Context(), "第二輪")
+	second := client.GetHistory()[1]
+	if !second.Failed {
+		t.Error("累計超出上限的迴圈應標記為失敗")
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "notes.txt"))
+	if strings.Count(string(data), "line") != 2 {
+		t.Errorf("未設定還原時應保留變更，但為 %q", data)
+	}
+}
@@ -0,0 +1,340 @@
+package ghcopilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ChaosFault 是混沌測試注入的故障類型
+type ChaosFault string
+
+const (
+	ChaosLatency         ChaosFault = "latency"   // 呼叫前延遲 Latency
+	ChaosTimeout         ChaosFault = "timeout"   // 等待 Hang 後以逾時失敗，不呼叫執行器
+	ChaosConnectionReset ChaosFault = "reset"     // 立即以連線重置失敗，不呼叫執行器
+	ChaosExitCode        ChaosFault = "exit"      // 傳回非零退出碼，不呼叫執行器
+	ChaosTruncate        ChaosFault = "truncate"  // 執行成功後截斷一半的輸出
+	ChaosMalformed       ChaosFault = "malformed" // 執行成功後附加格式錯誤的狀態區塊
+	chaosNone            ChaosFault = "none"      // 腳本中表示不注入
+)
+
+// ChaosFaults 依注入順序列出所有故障類型
+var ChaosFaults = []ChaosFault{
+	ChaosLatency, ChaosTimeout, ChaosConnectionReset, ChaosExitCode, ChaosTruncate, ChaosMalformed,
+}
+
+// 混沌注入的錯誤訊息與 ConnectionDetector、TimeoutDetector 比對的內容一致
+var (
+	errChaosConnectionReset = errors.New("chaos: read tcp: connection reset by peer")
+	errChaosTimeout         = fmt.Errorf("chaos: %w", context.DeadlineExceeded)
+)
+
+// chaosMalformedStatus 缺少結尾且欄位值無效的狀態區塊
+const chaosMalformedStatus = "\n---COPILOT_STATUS---\nSTATUS: ???\nEXIT_SIGNAL: maybe\nTASKS_DONE: \n"
+
+// ChaosConfig 是故障注入的設定
+//
+// Script 依序指定前幾次呼叫注入的故障（"reset"、"latency+truncate"、"none"），
+// 腳本用完後依 Rates 的機率獨立抽選每種故障。
+type ChaosConfig struct {
+	Rates    map[ChaosFault]float64 // 每次呼叫注入各故障的機率 (0~1)
+	Script   []string               // 前幾次呼叫的故障，以 + 組合
+	Latency  time.Duration          // latency 的延遲 (預設: 1s)
+	Hang     time.Duration          // timeout 失敗前的等待，受 context 限制 (預設: 2s)
+	ExitCode int                    // exit 的退出碼 (預設: 1)
+	Seed     int64                  // 亂數種子，0 表示依時間產生 (實際種子見 ChaosStats)
+}
+
+// ParseChaosSpec 解析 -chaos 參數
+//
+// 格式為逗號分隔的 key=value：故障名稱對應機率（reset=0.3,truncate=0.1），
+// 另有 latency-delay、hang、code、seed 與 script（以 ; 分隔每次呼叫，例如 script=reset;reset;none）。
+func ParseChaosSpec(spec string) (*ChaosConfig, error) {
+	config := &ChaosConfig{Rates: map[ChaosFault]float64{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("無效的混沌設定 %q (格式: key=value)", part)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "latency-delay":
+			config.Latency, err = time.ParseDuration(value)
+		case "hang":
+			config.Hang, err = time.ParseDuration(value)
+		case "code":
+			config.ExitCode, err = strconv.Atoi(value)
+		case "seed":
+			config.Seed, err = strconv.ParseInt(value, 10, 64)
+		case "script":
+			for _, step := range strings.Split(value, ";") {
+				if _, err := parseChaosStep(step); err != nil {
+					return nil, err
+				}
+				config.Script = append(config.Script, strings.TrimSpace(step))
+			}
+		default:
+			fault := ChaosFault(key)
+			if !isChaosFault(fault) {
+				return nil, fmt.Errorf("未知的故障類型 %q (可用: %s)", key, chaosFaultNames())
+			}
+			var rate float64
+			rate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (rate < 0 || rate > 1) {
+				err = fmt.Errorf("機率必須介於 0 與 1")
+			}
+			config.Rates[fault] = rate
+		}
+		if err != nil {
+			return nil, fmt.Errorf("混沌設定 %s: %w", key, err)
+		}
+	}
+	return config, nil
+}
+
+// parseChaosStep 解析腳本中的一次呼叫
+func parseChaosStep(step string) (map[ChaosFault]bool, error) {
+	faults := map[ChaosFault]bool{}
+	for _, name := range strings.Split(step, "+") {
+		fault := ChaosFault(strings.TrimSpace(name))
+		if fault == chaosNone || fault == "" {
+			continue
+		}
+		if !isChaosFault(fault) {
+			return nil, fmt.Errorf("腳本中未知的故障類型 %q (可用: %s, none)", fault, chaosFaultNames())
+		}
+		faults[fault] = true
+	}
+	return faults, nil
+}
+
+func isChaosFault(fault ChaosFault) bool {
+	for _, f := range ChaosFaults {
+		if f == fault {
+			return true
+		}
+	}
+	return false
+}
+
+func chaosFaultNames() string {
+	names := make([]string, len(ChaosFaults))
+	for i, f := range ChaosFaults {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+// ChaosStats 是已注入的故障統計
+type ChaosStats struct {
+	Seed     int64              `json:"seed"`
+	Calls    int                `json:"calls"`
+	Injected map[ChaosFault]int `json:"injected"`
+}
+
+// String 以 "reset=2, truncate=1" 格式顯示已注入的故障
+func (s ChaosStats) String() string {
+	var parts []string
+	for fault, n := range s.Injected {
+		parts = append(parts, fmt.Sprintf("%s=%d", fault, n))
+	}
+	sort.Strings(parts)
+	if len(parts) == 0 {
+		parts = []string{"無"}
+	}
+	return fmt.Sprintf("%d 次呼叫, 注入 %s (seed %d)", s.Calls, strings.Join(parts, ", "), s.Seed)
+}
+
+// ChaosInjector 依設定在執行器呼叫前後注入故障
+//
+// 可透過 ChaosExecutor 包裝任何 PromptExecutor，或以 CLIExecutor.SetFaultInjector
+// 注入每一次 CLI 嘗試，讓重試、熔斷與恢復邏輯在端對端測試中實際執行。
+type ChaosInjector struct {
+	mu       sync.Mutex
+	config   ChaosConfig
+	script   []map[ChaosFault]bool
+	rng      *rand.Rand
+	seed     int64
+	calls    int
+	injected map[ChaosFault]int
+	logger   *slog.Logger
+}
+
+// NewChaosInjector 建立故障注入器（腳本中無效的故障類型會被忽略，請先以 ParseChaosSpec 驗證）
+func NewChaosInjector(config ChaosConfig) *ChaosInjector {
+	if config.Latency <= 0 {
+		config.Latency = time.Second
+	}
+	if config.Hang <= 0 {
+		config.Hang = 2 * time.Second
+	}
+	if config.ExitCode == 0 {
+		config.ExitCode = 1
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	injector := &ChaosInjector{
+		config:   config,
+		rng:      rand.New(rand.NewSource(seed)),
+		seed:     seed,
+		injected: map[ChaosFault]int{},
+		logger:   discardLogger(),
+	}
+	for _, step := range config.Script {
+		faults, _ := parseChaosStep(step)
+		injector.script = append(injector.script, faults)
+	}
+	return injector
+}
+
+// SetLogger 設定注入故障時的日誌
+func (c *ChaosInjector) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	c.logger = logger
+}
+
+// Stats 傳回已注入的故障統計
+func (c *ChaosInjector) Stats() ChaosStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	injected := make(map[ChaosFault]int, len(c.injected))
+	for fault, n := range c.injected {
+		injected[fault] = n
+	}
+	return ChaosStats{Seed: c.seed, Calls: c.calls, Injected: injected}
+}
+
+// next 決定這次呼叫注入的故障
+func (c *ChaosInjector) next() map[ChaosFault]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var faults map[ChaosFault]bool
+	if c.calls < len(c.script) {
+		faults = c.script[c.calls]
+	} else {
+		faults = map[ChaosFault]bool{}
+		for _, fault := range ChaosFaults {
+			if rate := c.config.Rates[fault]; rate > 0 && c.rng.Float64() < rate {
+				faults[fault] = true
+			}
+		}
+	}
+	c.calls++
+	for fault := range faults {
+		c.injected[fault]++
+	}
+	return faults
+}
+
+// Inject 以注入的故障執行一次呼叫
+//
+// 呼叫被故障取代時仍傳回非 nil 的結果，與 CLIExecutor 的行為一致。
+func (c *ChaosInjector) Inject(ctx context.Context, call func(context.Context) (*ExecutionResult, error)) (*ExecutionResult, error) {
+	faults := c.next()
+	for _, fault := range ChaosFaults {
+		if faults[fault] {
+			c.logger.Info("注入故障", "fault", fault)
+			SpanFromContext(ctx).AddEvent("chaos.fault", map[string]interface{}{"chaos.fault": string(fault)})
+		}
+	}
+
+	start := time.Now()
+	if faults[ChaosLatency] {
+		if err := chaosSleep(ctx, c.config.Latency); err != nil {
+			return &ExecutionResult{Command: "chaos", ExecutionTime: time.Since(start), Error: err}, err
+		}
+	}
+
+	switch {
+	case faults[ChaosTimeout]:
+		err := errChaosTimeout
+		if sleepErr := chaosSleep(ctx, c.config.Hang); sleepErr != nil {
+			err = sleepErr
+		}
+		return &ExecutionResult{Command: "chaos", ExitCode: -1, Stderr: "signal: killed\n", ExecutionTime: time.Since(start), Error: err}, err
+	case faults[ChaosConnectionReset]:
+		return &ExecutionResult{Command: "chaos", ExitCode: -1, ExecutionTime: time.Since(start), Error: errChaosConnectionReset}, errChaosConnectionReset
+	case faults[ChaosExitCode]:
+		return &ExecutionResult{
+			Command:       "chaos",
+			ExitCode:      c.config.ExitCode,
+			Stderr:        fmt.Sprintf("chaos: injected exit code %d\n", c.config.ExitCode),
+			ExecutionTime: time.Since(start),
+		}, nil
+	}
+
+	result, err := call(ctx)
+	if err != nil || result == nil {
+		return result, err
+	}
+	if faults[ChaosTruncate] {
+		result.Stdout = truncateUTF8(result.Stdout, len(result.Stdout)/2)
+	}
+	if faults[ChaosMalformed] {
+		result.Stdout += chaosMalformedStatus
+	}
+	result.ExecutionTime = time.Since(start)
+	return result, nil
+}
+
+// chaosSleep 等待指定時間，context 結束時提前傳回
+func chaosSleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// truncateUTF8 截斷到最多 n 個位元組，不切開多位元組字元
+func truncateUTF8(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// ChaosExecutor 是注入故障的 PromptExecutor 裝飾器
+type ChaosExecutor struct {
+	inner    PromptExecutor
+	injector *ChaosInjector
+}
+
+// NewChaosExecutor 以故障注入器包裝執行器
+func NewChaosExecutor(inner PromptExecutor, injector *ChaosInjector) *ChaosExecutor {
+	return &ChaosExecutor{inner: inner, injector: injector}
+}
+
+// ExecutePrompt 以注入的故障執行 prompt
+func (e *ChaosExecutor) ExecutePrompt(ctx context.Context, prompt string) (*ExecutionResult, error) {
+	return e.injector.Inject(ctx, func(ctx context.Context) (*ExecutionResult, error) {
+		return e.inner.ExecutePrompt(ctx, prompt)
+	})
+}
@@ -0,0 +1,246 @@
+package ghcopilot
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubExecutor 每次呼叫都傳回相同的輸出並計算呼叫次數
+type stubExecutor struct {
+	output string
+	calls  atomic.Int32
+}
+
+func (s *stubExecutor) ExecutePrompt(ctx context.Context, prompt string) (*ExecutionResult, error) {
+	s.calls.Add(1)
+	return &ExecutionResult{Command: "stub", Stdout: s.output, Success: true}, nil
+}
+
+func TestParseChaosSpec(t *testing.T) {
+	config, err := ParseChaosSpec("reset=0.3, truncate=1,latency-delay=50ms,hang=2s,code=3,seed=42,script=reset;latency+malformed;none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Rates[ChaosConnectionReset] != 0.3 || config.Rates[ChaosTruncate] != 1 ||
+		config.Latency != 50*time.Millisecond || config.Hang != 2*time.Second || config.ExitCode != 3 || config.Seed != 42 {
+		t.Errorf("解析結果不正確: %+v", config)
+	}
+	if len(config.Script) != 3 || config.Script[1] != "latency+malformed" {
+		t.Errorf("腳本解析不正確: %v", config.Script)
+	}
+
+	for _, spec := range []string{"reset", "explode=0.5", "reset=2", "hang=soon", "script=reset;boom"} {
+		if _, err := ParseChaosSpec(spec); err == nil {
+			t.Errorf("%q 應回報錯誤", spec)
+		}
+	}
+}
+
+func TestChaosExecutorScript(t *testing.T) {
+	inner := &stubExecutor{output: "修改了檔案\n全部完成"}
+	config, err := ParseChaosSpec("script=reset;exit;timeout;none;truncate;malformed;latency,hang=20ms,latency-delay=20ms,code=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	injector := NewChaosInjector(*config)
+	executor := NewChaosExecutor(inner, injector)
+	ctx := t.Context()
+
+	result, err := executor.ExecutePrompt(ctx, "p")
+	if err == nil || !strings.Contains(err.Error(), "connection reset") || result == nil {
+		t.Errorf("reset 應以連線錯誤失敗: %+v, %v", result, err)
+	}
+	result, err = executor.ExecutePrompt(ctx, "p")
+	if err != nil || result.ExitCode != 7 || result.Success {
+		t.Errorf("exit 應傳回退出碼: %+v, %v", result, err)
+	}
+	result, err = executor.ExecutePrompt(ctx, "p")
+	if !errors.Is(err, context.DeadlineExceeded) || result.ExecutionTime < 20*time.Millisecond {
+		t.Errorf("timeout 應等待後以逾時失敗: %+v, %v", result, err)
+	}
+	if inner.calls.Load() != 0 {
+		t.Fatalf("取代呼叫的故障不應執行內部執行器: %d", inner.calls.Load())
+	}
+
+	result, err = executor.ExecutePrompt(ctx, "p")
+	if err != nil || result.Stdout != inner.output {
+		t.Errorf("none 應原樣傳回: %+v, %v", result, err)
+	}
+	result, _ = executor.ExecutePrompt(ctx, "p")
+	if !strings.HasPrefix(inner.output, result.Stdout) || len(result.Stdout) >= len(inner.output) || !strings.HasSuffix(result.Stdout, "檔") {
+		t.Errorf("truncate 應在字元邊界截斷輸出: %q", result.Stdout)
+	}
+	result, _ = executor.ExecutePrompt(ctx, "p")
+	if !strings.Contains(result.Stdout, "---COPILOT_STATUS---") || strings.Contains(result.Stdout, "---END_STATUS---") {
+		t.Errorf("malformed 應附加沒有結尾的狀態區塊: %q", result.Stdout)
+	}
+	if status := NewResponseAnalyzer(result.Stdout).ParseStructuredOutput(); status != nil {
+		t.Errorf("格式錯誤的狀態區塊不應被解析: %+v", status)
+	}
+	result, _ = executor.ExecutePrompt(ctx, "p")
+	if result.ExecutionTime < 20*time.Millisecond || result.Stdout != inner.output {
+		t.Errorf("latency 應延遲後原樣傳回: %+v", result)
+	}
+
+	stats := injector.Stats()
+	if stats.Calls != 7 || stats.Injected[ChaosConnectionReset] != 1 || stats.Injected[ChaosLatency] != 1 || len(stats.Injected) != 6 {
+		t.Errorf("統計不正確: %+v", stats)
+	}
+	if !strings.Contains(stats.String(), "7 次呼叫") {
+		t.Errorf("統計文字不正確: %s", stats)
+	}
+}
+
+func TestChaosInjectorRates(t *testing.T) {
+	always := NewChaosInjector(ChaosConfig{Rates: map[ChaosFault]float64{ChaosConnectionReset: 1}})
+	never := NewChaosInjector(ChaosConfig{Rates: map[ChaosFault]float64{ChaosConnectionReset: 0}})
+	inner := &stubExecutor{output: "ok"}
+	for i := 0; i < 20; i++ {
+		if _, err := NewChaosExecutor(inner, always).ExecutePrompt(t.Context(), "p"); err == nil {
+			t.Fatal("機率 1 應每次注入")
+		}
+		if _, err := NewChaosExecutor(inner, never).ExecutePrompt(t.Context(), "p"); err != nil {
+			t.Fatal("機率 0 不應注入")
+		}
+	}
+
+	// 相同種子產生相同的故障序列
+	sequence := func() []bool {
+		injector := NewChaosInjector(ChaosConfig{Rates: map[ChaosFault]float64{ChaosConnectionReset: 0.5}, Seed: 99})
+		var faults []bool
+		for i := 0; i < 30; i++ {
+			_, err := NewChaosExecutor(inner, injector).ExecutePrompt(t.Context(), "p")
+			faults = append(faults, err != nil)
+		}
+		return faults
+	}
+	a, b := sequence(), sequence()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("相同種子的故障序列不同: %v vs %v", a, b)
+		}
+	}
+}
+
+func TestChaosTimeoutHonorsContext(t *testing.T) {
+	injector := NewChaosInjector(ChaosConfig{Script: []string{"timeout"}, Hang: time.Hour})
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewChaosExecutor(&stubExecutor{}, injector).ExecutePrompt(ctx, "p")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("context 結束時應提前傳回: %v (%s)", err, time.Since(start))
+	}
+}
+
+func TestChaosTriggersFailureDetectors(t *testing.T) {
+	injector := NewChaosInjector(ChaosConfig{Script: []string{"reset", "timeout"}, Hang: 20 * time.Millisecond})
+	executor := NewChaosExecutor(&stubExecutor{}, injector)
+
+	connection := NewConnectionDetector(1)
+	result, err := executor.ExecutePrompt(t.Context(), "p")
+	if !connection.Detect(err, result.ExecutionTime) {
+		t.Errorf("ConnectionDetector 應偵測到注入的連線重置: %v", err)
+	}
+
+	timeout := NewTimeoutDetector(10 * time.Millisecond).WithConsecutiveThreshold(1)
+	result, err = executor.ExecutePrompt(t.Context(), "p")
+	if !timeout.Detect(err, result.ExecutionTime) {
+		t.Errorf("TimeoutDetector 應偵測到注入的逾時: %v (%s)", err, result.ExecutionTime)
+	}
+}
+
+func TestChaosFaultTolerantExecutorRecovers(t *testing.T) {
+	injector := NewChaosInjector(ChaosConfig{Script: []string{"reset", "reset", "none"}})
+	executor := NewChaosExecutor(&stubExecutor{output: "ok"}, injector)
+
+	ft := NewFaultTolerantExecutor(NewFixedIntervalPolicy(2, time.Millisecond), &FailureDetectorConfig{
+		EnableConnection:    true,
+		ConnectionThreshold: 1,
+	})
+	reconnects := 0
+	recovery := NewAutoReconnectRecovery(1)
+	recovery.SetRetryDelay(time.Millisecond)
+	recovery.SetConnectFunc(func(ctx context.Context) error {
+		reconnects++
+		return nil
+	})
+	ft.AddRecoveryStrategy(recovery)
+
+	err := ft.Execute(t.Context(), func() error {
+		_, err := executor.ExecutePrompt(t.Context(), "p")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("重試用完後應經由恢復策略成功: %v", err)
+	}
+	metrics := ft.GetMetrics()
+	if reconnects != 1 || metrics.RecoveredExecutions != 1 || metrics.TotalRetries != 1 {
+		t.Errorf("應重試一次、恢復一次: reconnects=%d, %+v", reconnects, metrics)
+	}
+}
+
+func TestClientChaosRetriesCLIAttempts(t *testing.T) {
+	installFakeCopilot(t, `echo "全部完成 done"`)
+	workDir := t.TempDir()
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	config.CLIMaxRetries = 1
+	config.Chaos = &ChaosConfig{Script: []string{"reset"}}
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+
+	if _, err := client.ExecuteUntilCompletion(t.Context(), "修正測試", 3); err != nil {
+		t.Fatalf("CLI 重試後應完成: %v", err)
+	}
+	if client.executor.RetryCount() != 1 {
+		t.Errorf("注入的連線重置應觸發一次 CLI 重試: %d", client.executor.RetryCount())
+	}
+
+	record, err := ReadRunRecord(client.RunDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Config.Chaos == nil || record.Config.Chaos.Calls != 2 || record.Config.Chaos.Injected[ChaosConnectionReset] != 1 {
+		t.Errorf("run.json 應記錄注入的故障: %+v", record.Config.Chaos)
+	}
+}
+
+func TestClientChaosOpensBreaker(t *testing.T) {
+	workDir := t.TempDir()
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.EnablePersistence = false
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	config.Executor = &stubExecutor{output: "全部完成 done"}
+	config.Chaos = &ChaosConfig{Rates: map[ChaosFault]float64{ChaosConnectionReset: 1}}
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+
+	_, err := client.ExecuteUntilCompletion(t.Context(), "修正測試", 10)
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker opened") {
+		t.Fatalf("持續的連線錯誤應打開熔斷器: %v", err)
+	}
+	if got := client.Chaos().Stats().Calls; got != 5 {
+		t.Errorf("熔斷器應在第 5 次相同錯誤後打開: %d 次呼叫", got)
+	}
+	for _, execCtx := range client.GetHistory() {
+		if LoopOutcome(execCtx) != LoopOutcomeFailed {
+			t.Errorf("執行器失敗的迴圈應標記為失敗: %+v", execCtx)
+		}
+	}
+}
@@ -1,869 +1,2080 @@
-package ghcopilot
-
-import (
-	"context"
-	"fmt"
-	"strings"
-	"time"
-)
-
-// RalphLoopClient 是 Ralph Loop 系統的主要公開 API
-//
-// 它整合了所有內部模組，提供統一的介面用於：
-// - CLI 執行與結果解析
-// - 上下文管理與歷史追蹤
-// - 自動重試與熔斷保護
-// - 結果持久化
-//
-// 典型用法:
-//
-//	client := NewRalphLoopClient()
-//	defer client.Close()
-//
-//	result, err := client.ExecuteLoop(ctx, "your prompt")
-//	if err != nil {
-//		log.Fatal(err)
-//	}
-//	fmt.Println(result)
-type RalphLoopClient struct {
-	// 核心模組
-	executor       *CLIExecutor
-	parser         *OutputParser
-	analyzer       *ResponseAnalyzer
-	breaker        *CircuitBreaker
-	contextManager *ContextManager
-	persistence    *PersistenceManager
-
-	// SDK 執行器（新增）
-	sdkExecutor *SDKExecutor
-
-	// 配置
-	config *ClientConfig
-
-	// 狀態
-	initialized bool
-	closed      bool
-}
-
-// ClientConfig 包含 Client 的配置選項
-type ClientConfig struct {
-	// CLI 配置
-	CLITimeout    time.Duration // CLI 執行逾時 (預設: 30s)
-	CLIMaxRetries int           // 最大重試次數 (預設: 3)
-	WorkDir       string        // 工作目錄 (預設: 當前目錄)
-
-	// 上下文配置
-	MaxHistorySize int    // 最大歷史記錄 (預設: 100)
-	SaveDir        string // 儲存目錄 (預設: ".ralph-loop/saves")
-	UseGobFormat   bool   // 是否使用 Gob 格式 (預設: false，使用 JSON)
-
-	// 熔斷器配置
-	CircuitBreakerThreshold int // 無進展迴圈數 (預設: 3)
-	SameErrorThreshold      int // 相同錯誤數 (預設: 5)
-
-	// AI 模型配置
-	Model  string // AI 模型名稱 (預設: "claude-sonnet-4.5")
-	Silent bool   // 是否靜默模式 (預設: false)
-
-	// 其他
-	EnablePersistence bool // 是否啟用持久化 (預設: true)
-	EnableSDK         bool // 是否啟用 SDK 執行器 (預設: true)
-	PreferSDK         bool // 是否優先使用 SDK (預設: true)
-}
-
-// NewRalphLoopClient 建立新的 Ralph Loop 客戶端
-func NewRalphLoopClient() *RalphLoopClient {
-	return NewRalphLoopClientWithConfig(DefaultClientConfig())
-}
-
-// NewRalphLoopClientWithConfig 使用自訂配置建立客戶端
-func NewRalphLoopClientWithConfig(config *ClientConfig) *RalphLoopClient {
-	client := &RalphLoopClient{
-		config:      config,
-		initialized: false,
-		closed:      false,
-	}
-
-	// 初始化各個模組
-	client.executor = NewCLIExecutor(config.WorkDir)
-	client.executor.SetTimeout(config.CLITimeout)
-	client.executor.SetMaxRetries(config.CLIMaxRetries)
-	if config.Model != "" {
-		opts := DefaultOptions()
-		opts.Model = Model(config.Model)
-		opts.Silent = config.Silent
-		client.executor.options = opts
-	}
-	client.executor.SetSilent(config.Silent)
-
-	client.parser = NewOutputParser("")
-
-	client.analyzer = NewResponseAnalyzer("")
-
-	client.breaker = NewCircuitBreaker("")
-
-	client.contextManager = NewContextManager()
-	client.contextManager.SetMaxHistorySize(config.MaxHistorySize)
-
-	if config.EnablePersistence {
-		pm, err := NewPersistenceManager(config.SaveDir, config.UseGobFormat)
-		if err == nil {
-			client.persistence = pm
-		}
-	}
-
-	// 初始化 SDK 執行器
-	sdkConfig := &SDKConfig{
-		CLIPath:        "copilot",
-		Timeout:        config.CLITimeout,
-		SessionTimeout: 5 * time.Minute,
-		MaxSessions:    100,
-		LogLevel:       "info",
-		EnableMetrics:  true,
-		AutoReconnect:  true,
-		MaxRetries:     config.CLIMaxRetries,
-	}
-	client.sdkExecutor = NewSDKExecutor(sdkConfig)
-
-	client.initialized = true
-	return client
-}
-
-// DefaultClientConfig 傳回預設的配置
-func DefaultClientConfig() *ClientConfig {
-	return &ClientConfig{
-		CLITimeout:              60 * time.Second, // 增加到 60 秒以支援複雜任務
-		CLIMaxRetries:           3,
-		MaxHistorySize:          100,
-		SaveDir:                 ".ralph-loop/saves",
-		UseGobFormat:            false,
-		CircuitBreakerThreshold: 3,
-		SameErrorThreshold:      5,
-		Model:                   "claude-sonnet-4.5",
-		Silent:                  false,
-		EnablePersistence:       true,
-		EnableSDK:               true, // 預設啟用 SDK（主要執行方式）
-		PreferSDK:               true, // 預設優先使用 SDK
-	}
-}
-
-// ExecuteLoop 執行單個迴圈
-//
-// 這是最常用的方法。它會：
-// 1. 執行 CLI 命令
-// 2. 解析輸出
-// 3. 分析回應
-// 4. 檢查是否應該繼續或退出
-// 5. 記錄結果到歷史
-//
-// 返回值：
-// - LoopResult: 迴圈執行結果
-// - error: 執行過程中的錯誤
-func (c *RalphLoopClient) ExecuteLoop(ctx context.Context, prompt string) (*LoopResult, error) {
-	if !c.initialized {
-		return nil, fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return nil, fmt.Errorf("client is closed")
-	}
-
-	// 檢查熔斷器
-	if c.breaker.IsOpen() {
-		return nil, fmt.Errorf("circuit breaker is open: %s", c.breaker.GetState())
-	}
-
-	// 開始新迴圈
-	loopIndex := len(c.contextManager.GetLoopHistory())
-	execCtx := c.contextManager.StartLoop(loopIndex, prompt)
-
-	defer func() {
-		// 完成迴圈
-		if err := c.contextManager.FinishLoop(); err != nil {
-			// 日誌記錄
-		}
-
-		// 自動持久化整個 ContextManager（如果啟用）
-		if c.persistence != nil && c.config.EnablePersistence {
-			if err := c.persistence.SaveContextManager(c.contextManager); err != nil {
-				// 記錄但不影響主流程
-				_ = err
-			}
-		}
-	}()
-
-	// 根據配置決定執行順序：優先使用 SDK 或 CLI
-	var output string
-	var executionErr error
-	var usedSDK bool
-
-	// 如果配置優先使用 SDK，則先嘗試 SDK
-	if c.config.PreferSDK && c.config.EnableSDK && c.sdkExecutor != nil && c.sdkExecutor.isHealthy() {
-		output, executionErr = c.sdkExecutor.Complete(ctx, prompt)
-		if executionErr == nil {
-			usedSDK = true
-			execCtx.CLICommand = "sdk:complete"
-			execCtx.CLIOutput = output
-			execCtx.CLIExitCode = 0
-		}
-	}
-
-	// SDK 失敗/不可用/未啟用，或配置不優先使用 SDK 時，使用 CLI
-	if !usedSDK {
-		result, err := c.executor.ExecutePrompt(ctx, prompt)
-		if err != nil {
-			c.breaker.RecordSameError(err.Error())
-			if executionErr != nil {
-				execCtx.ExitReason = fmt.Sprintf("執行失敗 (SDK: %v, CLI: %v)", executionErr, err)
-			} else {
-				execCtx.ExitReason = fmt.Sprintf("CLI 執行失敗: %v", err)
-			}
-			return c.createResult(execCtx, false), nil
-		}
-
-		output = result.Stdout
-		execCtx.CLICommand = result.Command
-		execCtx.CLIOutput = result.Stdout
-		execCtx.CLIExitCode = result.ExitCode
-
-		if result.ExitCode != 0 {
-			c.breaker.RecordSameError(fmt.Sprintf("exit code %d", result.ExitCode))
-			execCtx.ExitReason = fmt.Sprintf("CLI 執行失敗，退出碼 %d", result.ExitCode)
-			execCtx.ShouldContinue = false
-			return c.createResult(execCtx, false), nil
-		}
-	}
-
-	// 解析輸出
-	parser := NewOutputParser(output)
-	parser.Parse()
-	codeBlocks := parser.GetOptions() // 臨時使用，實際應有完整解析
-
-	execCtx.ParsedCodeBlocks = codeBlocks
-	execCtx.CleanedOutput = output
-
-	// 分析回應（簡化版本，實際應使用完整分析器）
-	// 如果輸出包含完成關鍵字，則視為完成
-	shouldContinue := !strings.Contains(output, "完成") && !strings.Contains(output, "done")
-
-	execCtx.ShouldContinue = shouldContinue
-	if !shouldContinue {
-		c.breaker.RecordSuccess()
-		execCtx.ExitReason = "completion detected in output"
-	} else {
-		c.breaker.RecordNoProgress()
-	}
-
-	execCtx.CircuitBreakerState = string(c.breaker.GetState())
-
-	// 個別執行上下文的持久化（可選）
-	if c.persistence != nil && c.config.EnablePersistence {
-		_ = c.persistence.SaveExecutionContext(execCtx)
-	}
-
-	return c.createResult(execCtx, shouldContinue), nil
-}
-
-// ExecuteUntilCompletion 持續執行迴圈直到完成或錯誤
-//
-// 這個方法會自動處理迴圈，直到：
-// - 系統回報完成
-// - 熔斷器打開
-// - Context 被取消
-// - 達到最大迴圈次數
-func (c *RalphLoopClient) ExecuteUntilCompletion(ctx context.Context, initialPrompt string, maxLoops int) ([]*LoopResult, error) {
-	var results []*LoopResult
-
-	for i := 0; i < maxLoops; i++ {
-		select {
-		case <-ctx.Done():
-			return results, fmt.Errorf("context cancelled after %d loops", i)
-		default:
-		}
-
-		// 顯示進度
-		if !c.config.Silent {
-			fmt.Printf("\n🔄 迴圈 %d/%d - 正在執行...\n", i+1, maxLoops)
-		}
-
-		result, err := c.ExecuteLoop(ctx, initialPrompt)
-		if err != nil {
-			if !c.config.Silent {
-				fmt.Printf("❌ 迴圈 %d 失敗: %v\n", i+1, err)
-			}
-			return results, err
-		}
-
-		results = append(results, result)
-
-		// 顯示迴圈結果
-		if !c.config.Silent {
-			if result.ShouldContinue {
-				fmt.Printf("✓ 迴圈 %d 完成 - 繼續下一個迴圈\n", i+1)
-			} else {
-				fmt.Printf("✓ 迴圈 %d 完成 - 任務完成: %s\n", i+1, result.ExitReason)
-			}
-		}
-
-		// 檢查是否完成
-		if !result.ShouldContinue {
-			return results, nil
-		}
-
-		// 檢查熔斷器
-		if c.breaker.IsOpen() {
-			return results, fmt.Errorf("circuit breaker opened after %d loops", i+1)
-		}
-	}
-
-	return results, fmt.Errorf("reached maximum loops (%d) without completion", maxLoops)
-}
-
-// GetHistory 取得執行歷史
-func (c *RalphLoopClient) GetHistory() []*ExecutionContext {
-	return c.contextManager.GetLoopHistory()
-}
-
-// GetSummary 取得執行摘要
-func (c *RalphLoopClient) GetSummary() map[string]interface{} {
-	return c.contextManager.GetSummary()
-}
-
-// GetStatus 取得當前狀態
-func (c *RalphLoopClient) GetStatus() *ClientStatus {
-	return &ClientStatus{
-		Initialized:         c.initialized,
-		Closed:              c.closed,
-		CircuitBreakerOpen:  c.breaker.IsOpen(),
-		CircuitBreakerState: c.breaker.GetState(),
-		LoopsExecuted:       len(c.contextManager.GetLoopHistory()),
-		Summary:             c.GetSummary(),
-	}
-}
-
-// ResetCircuitBreaker 重置熔斷器
-func (c *RalphLoopClient) ResetCircuitBreaker() error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
-	}
-	c.breaker.Reset()
-	return nil
-}
-
-// ClearHistory 清空歷史記錄
-func (c *RalphLoopClient) ClearHistory() {
-	if c.initialized {
-		c.contextManager.Clear()
-	}
-}
-
-// ExportHistory 匯出歷史為 JSON
-func (c *RalphLoopClient) ExportHistory(outputPath string) error {
-	if c.persistence == nil {
-		return fmt.Errorf("persistence not enabled")
-	}
-	return c.persistence.ExportAsJSON(c.contextManager, outputPath)
-}
-
-// LoadHistoryFromDisk 從磁盤載入歷史記錄
-//
-// 此方法將從儲存目錄載入所有保存的執行上下文，
-// 並恢復 ContextManager 的狀態。
-//
-// 使用時機：
-// - 客戶端初始化後，需要恢復之前的迴圈歷史
-// - 重啟應用程序時恢復狀態
-func (c *RalphLoopClient) LoadHistoryFromDisk() error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return fmt.Errorf("client is closed")
-	}
-	if c.persistence == nil {
-		return fmt.Errorf("persistence not enabled")
-	}
-
-	// 從磁盤載入 ContextManager (使用預設檔名)
-	loadedManager, err := c.persistence.LoadContextManager("context_manager.json")
-	if err != nil {
-		return fmt.Errorf("failed to load context manager: %w", err)
-	}
-
-	// 使用載入的管理器替換當前的
-	c.contextManager = loadedManager
-	return nil
-}
-
-// SaveHistoryToDisk 立即將歷史記錄儲存到磁盤
-//
-// 此方法強制將目前的執行歷史記錄保存到磁盤，
-// 即使自動持久化未啟用。
-//
-// 使用時機：
-// - 在應用程序關閉前確保所有數據已保存
-// - 定期備份關鍵狀態
-// - 手動觸發保存
-func (c *RalphLoopClient) SaveHistoryToDisk() error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
-	}
-	if c.persistence == nil {
-		return fmt.Errorf("persistence not enabled")
-	}
-
-	// 保存 ContextManager
-	if err := c.persistence.SaveContextManager(c.contextManager); err != nil {
-		return fmt.Errorf("failed to save context manager: %w", err)
-	}
-
-	// 同時保存當前迴圈（如果有）
-	if len(c.contextManager.GetLoopHistory()) > 0 {
-		lastLoop := c.contextManager.GetLoopByIndex(len(c.contextManager.GetLoopHistory()) - 1)
-		if lastLoop != nil {
-			if err := c.persistence.SaveExecutionContext(lastLoop); err != nil {
-				// 不影響主流程，只記錄警告
-				return fmt.Errorf("warning: failed to save last execution context: %w", err)
-			}
-		}
-	}
-
-	return nil
-}
-
-// GetPersistenceStats 取得持久化統計資訊
-//
-// 傳回持久化層的統計資訊，包括：
-// - 儲存目錄路徑
-// - 儲存的上下文數量
-// - 最後保存時間
-// - 使用的格式 (JSON/Gob)
-func (c *RalphLoopClient) GetPersistenceStats() map[string]interface{} {
-	stats := make(map[string]interface{})
-
-	if c.persistence == nil {
-		stats["enabled"] = false
-		return stats
-	}
-
-	stats["enabled"] = true
-	stats["storage_dir"] = c.persistence.GetStorageDir()
-	stats["format"] = "json"
-	if c.config.UseGobFormat {
-		stats["format"] = "gob"
-	}
-
-	// 列出已保存的上下文
-	savedContexts, err := c.persistence.ListSavedContexts()
-	if err == nil {
-		stats["saved_count"] = len(savedContexts)
-		stats["saved_contexts"] = savedContexts
-	}
-
-	return stats
-}
-
-// CleanupOldBackups 清理舊的備份檔案
-//
-// 此方法會清理舊於指定天數的備份，
-// 或根據 maxBackups 設定保留最新的備份。
-//
-// 參數:
-// - prefix: 備份檔名前綴 (如 "context_manager" 或 "execution_context")
-//
-// 返回值:
-// - error: 清理過程中的錯誤
-func (c *RalphLoopClient) CleanupOldBackups(prefix string) error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
-	}
-	if c.persistence == nil {
-		return fmt.Errorf("persistence not enabled")
-	}
-
-	return c.persistence.ClearOldBackups(prefix)
-}
-
-// SetMaxBackupCount 設定最多保留的備份數量
-//
-// 此方法會設定持久化管理器最多保留多少個備份檔案。
-// 預設值為 10。
-//
-// 參數:
-// - count: 最多保留的備份數量 (必須 > 0)
-//
-// 範例:
-//
-//	client.SetMaxBackupCount(20)  // 最多保留 20 個備份
-func (c *RalphLoopClient) SetMaxBackupCount(count int) error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
-	}
-	if c.persistence == nil {
-		return fmt.Errorf("persistence not enabled")
-	}
-	if count <= 0 {
-		return fmt.Errorf("backup count must be greater than 0")
-	}
-
-	c.persistence.SetMaxBackups(count)
-	return nil
-}
-
-// ListBackups 列出所有備份
-//
-// 傳回指定前綴的所有備份檔案列表。
-//
-// 參數:
-// - prefix: 備份檔名前綴
-//
-// 返回值:
-// - []string: 備份檔案名稱列表
-// - error: 列舉過程中的錯誤
-func (c *RalphLoopClient) ListBackups(prefix string) ([]string, error) {
-	if !c.initialized {
-		return nil, fmt.Errorf("client not initialized")
-	}
-	if c.persistence == nil {
-		return nil, fmt.Errorf("persistence not enabled")
-	}
-
-	// 使用 ListSavedContexts 作為備份列表
-	contexts, err := c.persistence.ListSavedContexts()
-	if err != nil {
-		return nil, err
-	}
-
-	// 過濾符合前綴的備份
-	var backups []string
-	for _, ctx := range contexts {
-		if strings.HasPrefix(ctx, prefix) {
-			backups = append(backups, ctx)
-		}
-	}
-
-	return backups, nil
-}
-
-// RecoverFromBackup 從備份恢復狀態
-//
-// 此方法從指定的備份檔案恢復執行上下文和系統狀態。
-// 可用於故障恢復或狀態復制。
-//
-// 參數:
-// - filename: 備份檔名
-//
-// 返回值:
-// - error: 恢復過程中的錯誤
-func (c *RalphLoopClient) RecoverFromBackup(filename string) error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return fmt.Errorf("client is closed")
-	}
-	if c.persistence == nil {
-		return fmt.Errorf("persistence not enabled")
-	}
-
-	// 從備份載入
-	execCtx, err := c.persistence.LoadExecutionContext(filename)
-	if err != nil {
-		return fmt.Errorf("failed to load backup: %w", err)
-	}
-
-	if execCtx == nil {
-		return fmt.Errorf("loaded backup is empty")
-	}
-
-	// 恢復迴圈索引到該執行上下文
-	// 清空當前歷史並添加恢復的上下文
-	c.contextManager.Clear()
-	c.contextManager.StartLoop(execCtx.LoopIndex, execCtx.UserPrompt)
-	c.contextManager.UpdateCurrentLoop(func(ctx *ExecutionContext) {
-		*ctx = *execCtx
-	})
-	c.contextManager.FinishLoop()
-
-	return nil
-}
-
-// VerifyStateConsistency 驗證狀態一致性
-//
-// 此方法檢查保存的狀態與當前狀態是否一致，
-// 用於檢測損毀或不一致的備份。
-//
-// 返回值:
-// - bool: 狀態是否一致
-// - error: 驗證過程中的錯誤
-func (c *RalphLoopClient) VerifyStateConsistency() (bool, error) {
-	if !c.initialized {
-		return false, fmt.Errorf("client not initialized")
-	}
-	if c.persistence == nil {
-		return false, fmt.Errorf("persistence not enabled")
-	}
-
-	// 取得當前狀態
-	currentCount := len(c.contextManager.GetLoopHistory())
-
-	// 列出已保存的備份
-	savedContexts, err := c.persistence.ListSavedContexts()
-	if err != nil {
-		return false, fmt.Errorf("failed to list saved contexts: %w", err)
-	}
-
-	// 基本一致性檢查：備份計數不應遠大於當前迴圈計數
-	// (允許某些差異是因為備份可能更新)
-	if len(savedContexts) > currentCount*2 {
-		return false, fmt.Errorf("saved backups count significantly exceeds current loops")
-	}
-
-	return true, nil
-}
-
-// Close 關閉客戶端並清理資源
-func (c *RalphLoopClient) Close() error {
-	if c.closed {
-		return fmt.Errorf("client already closed")
-	}
-
-	// 執行最後的持久化
-	if c.persistence != nil && c.config.EnablePersistence {
-		_ = c.persistence.SaveContextManager(c.contextManager)
-	}
-
-	// 關閉 SDK 執行器
-	if c.sdkExecutor != nil {
-		_ = c.sdkExecutor.Close()
-	}
-
-	c.closed = true
-	return nil
-}
-
-// StartSDKExecutor 啟動 SDK 執行器
-// 這使用新的 SDK 層進行程式碼執行，提供更細粒度的控制
-func (c *RalphLoopClient) StartSDKExecutor(ctx context.Context) error {
-	if !c.initialized {
-		return fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return fmt.Errorf("client is closed")
-	}
-	if c.sdkExecutor == nil {
-		return fmt.Errorf("SDK executor not available")
-	}
-
-	return c.sdkExecutor.Start(ctx)
-}
-
-// StopSDKExecutor 停止 SDK 執行器
-func (c *RalphLoopClient) StopSDKExecutor(ctx context.Context) error {
-	if c.sdkExecutor == nil {
-		return fmt.Errorf("SDK executor not available")
-	}
-
-	return c.sdkExecutor.Stop(ctx)
-}
-
-// ExecuteWithSDK 使用 SDK 執行程式碼完成
-// 提供比標準 ExecuteLoop 更直接的程式碼執行介面
-func (c *RalphLoopClient) ExecuteWithSDK(ctx context.Context, prompt string) (string, error) {
-	if !c.initialized {
-		return "", fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return "", fmt.Errorf("client is closed")
-	}
-	if c.sdkExecutor == nil {
-		return "", fmt.Errorf("SDK executor not available")
-	}
-
-	return c.sdkExecutor.Complete(ctx, prompt)
-}
-
-// ExplainWithSDK 使用 SDK 解釋程式碼
-func (c *RalphLoopClient) ExplainWithSDK(ctx context.Context, code string) (string, error) {
-	if !c.initialized {
-		return "", fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return "", fmt.Errorf("client is closed")
-	}
-	if c.sdkExecutor == nil {
-		return "", fmt.Errorf("SDK executor not available")
-	}
-
-	return c.sdkExecutor.Explain(ctx, code)
-}
-
-// GenerateTestsWithSDK 使用 SDK 生成測試
-func (c *RalphLoopClient) GenerateTestsWithSDK(ctx context.Context, code string) (string, error) {
-	if !c.initialized {
-		return "", fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return "", fmt.Errorf("client is closed")
-	}
-	if c.sdkExecutor == nil {
-		return "", fmt.Errorf("SDK executor not available")
-	}
-
-	return c.sdkExecutor.GenerateTests(ctx, code)
-}
-
-// CodeReviewWithSDK 使用 SDK 進行程式碼審查
-func (c *RalphLoopClient) CodeReviewWithSDK(ctx context.Context, code string) (string, error) {
-	if !c.initialized {
-		return "", fmt.Errorf("client not initialized")
-	}
-	if c.closed {
-		return "", fmt.Errorf("client is closed")
-	}
-	if c.sdkExecutor == nil {
-		return "", fmt.Errorf("SDK executor not available")
-	}
-
-	return c.sdkExecutor.CodeReview(ctx, code)
-}
-
-// GetSDKStatus 取得 SDK 執行器狀態
-func (c *RalphLoopClient) GetSDKStatus() *SDKStatus {
-	if c.sdkExecutor == nil {
-		return nil
-	}
-
-	return c.sdkExecutor.GetStatus()
-}
-
-// ListSDKSessions 列出所有 SDK 會話
-func (c *RalphLoopClient) ListSDKSessions() []*SDKSession {
-	if c.sdkExecutor == nil {
-		return nil
-	}
-
-	return c.sdkExecutor.ListSessions()
-}
-
-// GetSDKSessionCount 取得 SDK 會話數
-func (c *RalphLoopClient) GetSDKSessionCount() int {
-	if c.sdkExecutor == nil {
-		return 0
-	}
-
-	return c.sdkExecutor.GetSessionCount()
-}
-
-// TerminateSDKSession 終止特定的 SDK 會話
-func (c *RalphLoopClient) TerminateSDKSession(sessionID string) error {
-	if c.sdkExecutor == nil {
-		return fmt.Errorf("SDK executor not available")
-	}
-
-	session, err := c.sdkExecutor.GetSession(sessionID)
-	if err != nil {
-		return err
-	}
-
-	return c.sdkExecutor.sessions.RemoveSession(session.ID)
-}
-
-// 私有輔助函式
-
-func (c *RalphLoopClient) createResult(execCtx *ExecutionContext, shouldContinue bool) *LoopResult {
-	return &LoopResult{
-		LoopID:          execCtx.LoopID,
-		LoopIndex:       execCtx.LoopIndex,
-		ShouldContinue:  shouldContinue,
-		CompletionScore: execCtx.CompletionScore,
-		Output:          execCtx.CLIOutput,
-		ExitReason:      execCtx.ExitReason,
-		Timestamp:       execCtx.Timestamp,
-	}
-}
-
-// LoopResult 表示單個迴圈的結果
-type LoopResult struct {
-	LoopID          string
-	LoopIndex       int
-	ShouldContinue  bool
-	CompletionScore int
-	Output          string
-	ExitReason      string
-	Timestamp       time.Time
-}
-
-// ClientStatus 表示客戶端的當前狀態
-type ClientStatus struct {
-	Initialized         bool
-	Closed              bool
-	CircuitBreakerOpen  bool
-	CircuitBreakerState CircuitBreakerState
-	LoopsExecuted       int
-	Summary             map[string]interface{}
-}
-
-// ClientBuilder 用於建立自訂配置的客戶端
-type ClientBuilder struct {
-	config *ClientConfig
-}
-
-// NewClientBuilder 建立新的客戶端建構器
-func NewClientBuilder() *ClientBuilder {
-	return &ClientBuilder{
-		config: DefaultClientConfig(),
-	}
-}
-
-// WithTimeout 設定 CLI 執行逾時
-func (b *ClientBuilder) WithTimeout(duration time.Duration) *ClientBuilder {
-	b.config.CLITimeout = duration
-	return b
-}
-
-// WithMaxRetries 設定最大重試次數
-func (b *ClientBuilder) WithMaxRetries(count int) *ClientBuilder {
-	b.config.CLIMaxRetries = count
-	return b
-}
-
-// WithWorkDir 設定工作目錄
-func (b *ClientBuilder) WithWorkDir(dir string) *ClientBuilder {
-	b.config.WorkDir = dir
-	return b
-}
-
-// WithModel 設定 AI 模型
-func (b *ClientBuilder) WithModel(model string) *ClientBuilder {
-	b.config.Model = model
-	return b
-}
-
-// WithSaveDir 設定儲存目錄
-func (b *ClientBuilder) WithSaveDir(dir string) *ClientBuilder {
-	b.config.SaveDir = dir
-	return b
-}
-
-// WithMaxHistory 設定最大歷史記錄
-func (b *ClientBuilder) WithMaxHistory(size int) *ClientBuilder {
-	b.config.MaxHistorySize = size
-	return b
-}
-
-// WithGobFormat 啟用 Gob 格式
-func (b *ClientBuilder) WithGobFormat(enabled bool) *ClientBuilder {
-	b.config.UseGobFormat = enabled
-	return b
-}
-
-// WithoutPersistence 禁用持久化
-func (b *ClientBuilder) WithoutPersistence() *ClientBuilder {
-	b.config.EnablePersistence = false
-	return b
-}
-
-// Build 建立客戶端
-func (b *ClientBuilder) Build() *RalphLoopClient {
-	return NewRalphLoopClientWithConfig(b.config)
-}
+package ghcopilot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RalphLoopClient 是 Ralph Loop 系統的主要公開 API
+//
+// 它整合了所有內部模組，提供統一的介面用於：
+// - CLI 執行與結果解析
+// - 上下文管理與歷史追蹤
+// - 自動重試與熔斷保護
+// - 結果持久化
+//
+// 典型用法:
+//
+//	client := NewRalphLoopClient()
+//	defer client.Close()
+//
+//	result, err := client.ExecuteLoop(ctx, "your prompt")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println(result)
+type RalphLoopClient struct {
+	// 核心模組
+	executor       *CLIExecutor
+	promptExecutor PromptExecutor // 執行迴圈 prompt（預設為 executor）
+	parser         *OutputParser
+	analyzer       *ResponseAnalyzer
+	breaker        *CircuitBreaker
+	contextManager *ContextManager
+	persistence    *PersistenceManager
+
+	// SDK 執行器（新增）
+	sdkExecutor *SDKExecutor
+
+	// 混沌測試的故障注入器（未設定 Chaos 時為 nil）
+	chaos *ChaosInjector
+
+	// 註冊到 SDK 會話的 agent 工具（停用 SDKTools 時為 nil）與其完成訊號
+	tools        *LoopTools
+	exitDetector *ExitDetector
+
+	// 依權限策略處理 SDK 會話的權限請求
+	permissions *PermissionEngine
+
+	// 受保護路徑檢查
+	pathGuard *ProtectedPathGuard
+
+	// 待注入下一次 prompt 的說明（例如被還原的違規變更）
+	pendingNotes []string
+
+	// 本次執行已接受的工作目錄變更累計
+	runChanges ChangeStats
+
+	// 目前迴圈保留變更的 unified diff（放在 LoopFinished 事件中）
+	loopDiff string
+
+	// 目前迴圈的 hook 結果（迴圈結束時存入 ExecutionContext）
+	loopHooks []HookResult
+
+	// 迴圈之間的外部控制（暫停、停止、回饋）
+	controller *LoopController
+
+	// 事件匯流排與本次執行的紀錄
+	events       *EventBus
+	runID        string
+	runRecord    *RunRecord // run.json 的內容（第一個迴圈開始時建立）
+	journal      *Journal
+	transcript   *TranscriptRecorder
+	currentLoop  int
+	breakerState CircuitBreakerState
+
+	// 結構化日誌（run 目錄的日誌檔在第一個迴圈開始時加入）
+	logger     *slog.Logger
+	logRouter  *LogRouter
+	runLogPath string
+
+	// Prometheus 指標
+	metrics *LoopMetrics
+
+	// 追蹤：每次執行一個根 span，每個迴圈一個子 span
+	tracer      *Tracer
+	runSpan     *Span
+	traceExport *FileSpanExporter
+
+	// 配置
+	config *ClientConfig
+
+	// 狀態
+	initialized bool
+	closed      bool
+}
+
+// ClientConfig 包含 Client 的配置選項
+type ClientConfig struct {
+	// CLI 配置
+	CLITimeout    time.Duration // CLI 執行逾時 (預設: 30s)
+	CLIMaxRetries int           // 最大重試次數 (預設: 3)
+	WorkDir       string        // 工作目錄 (預設: 當前目錄)
+
+	// 上下文配置
+	MaxHistorySize int    // 最大歷史記錄 (預設: 100)
+	SaveDir        string // 儲存目錄，相對路徑以 WorkDir 為基準 (預設: ".ralph-loop/saves")
+	UseGobFormat   bool   // 是否使用 Gob 格式 (預設: false，使用 JSON)
+
+	// 熔斷器配置
+	CircuitBreakerThreshold int // 無進展迴圈數 (預設: 3)
+	SameErrorThreshold      int // 相同錯誤數 (預設: 5)
+
+	// AI 模型配置
+	Model  string // AI 模型名稱 (預設: "claude-sonnet-4.5")
+	Silent bool   // 是否靜默模式 (預設: false)
+
+	ProgressOutput io.Writer // 迴圈進度的輸出位置 (預設: os.Stdout；Silent 時不輸出)
+
+	// 權限配置
+	Permissions *PermissionPolicy // 工具與路徑權限 (預設: full，允許所有工具)
+
+	// 受保護路徑配置
+	ProtectedPaths         []string // 不允許 AI 修改的路徑 glob (預設: DefaultProtectedPaths，空值停用)
+	ProtectedPathThreshold int      // 工作目錄策略違規多少次後打開熔斷器 (預設: 2)
+
+	// 變更範圍配置
+	ChangeScope *ChangeScopePolicy // 單迴圈與整次執行的變更上限 (預設: nil，不限制)
+
+	// 人工審核配置
+	Approver Approver // 每個迴圈結束後詢問操作者 (預設: nil，不審核)
+
+	// PermissionEscalator 詢問權限策略無法決定的 SDK 權限請求
+	// (預設: nil，Approver 實作 PermissionEscalator 時使用 Approver；都沒有時拒絕)
+	PermissionEscalator PermissionEscalator
+
+	// Hook 配置
+	Hooks      []LoopHook  // Go hook，依序呼叫 (預設: 無)
+	ShellHooks []ShellHook // 殼層 hook，在 Go hook 之後執行 (預設: 無)
+
+	// 日誌配置
+	Logger    *slog.Logger // 元件日誌的輸出 (預設: stderr，RALPH_DEBUG=1 時含除錯日誌)
+	LogLevel  slog.Level   // run 目錄日誌檔的等級 (預設: info)
+	LogFormat LogFormat    // run 目錄日誌檔的格式 (預設: text)
+
+	// 追蹤配置
+	EnableTracing bool   // 是否追蹤每個迴圈並寫入 <RunsDir>/<run-id>/traces.jsonl (預設: true)
+	OTLPEndpoint  string // 同時送往 OTLP/HTTP collector (例如 "http://localhost:4318"，預設: 不送出)
+
+	// 執行紀錄配置
+	RunsDir string // 每次執行的紀錄目錄，journal 與執行器錄製檔寫入 <RunsDir>/<run-id>/，相對路徑以 WorkDir 為基準 (預設: ".ralph-loop/runs"，需啟用持久化)
+
+	// 執行器配置
+	Executor PromptExecutor // 取代 copilot CLI 執行迴圈 prompt，例如 ReplayExecutor (預設: nil)
+	Chaos    *ChaosConfig   // 在 SDK、CLI 的每次嘗試或 Executor 注入故障，用於混沌測試 (預設: nil，不注入)
+
+	// 狀態區塊配置
+	StatusContract bool // 在每個 prompt 附加 JSON 狀態區塊規格，並將不符合規格的狀態區塊視為迴圈失敗訊號 (預設: true)
+	SDKTools       bool // 在 SDK 會話註冊 report_status、run_verification、read_loop_history 工具 (預設: true)
+
+	// 其他
+	EnablePersistence bool // 是否啟用持久化 (預設: true)
+	EnableSDK         bool // 是否啟用 SDK 執行器 (預設: true)
+	PreferSDK         bool // 是否優先使用 SDK (預設: true)
+	RequireSDK        bool // 只以 SDK 會話執行，SDK 未啟動或失敗時不改用 CLI (SDK 權限策略需要，見 PermissionPolicy.RequiresSDK；預設: false)
+}
+
+// NewRalphLoopClient 建立新的 Ralph Loop 客戶端
+func NewRalphLoopClient() *RalphLoopClient {
+	return NewRalphLoopClientWithConfig(DefaultClientConfig())
+}
+
+// NewRalphLoopClientWithConfig 使用自訂配置建立客戶端
+//
+// 相對的 SaveDir 與 RunsDir 會改為以 WorkDir 為基準的路徑（寫回 config），
+// 讓紀錄與控制通道一樣位於工作目錄中，不受程序目前目錄影響。
+func NewRalphLoopClientWithConfig(config *ClientConfig) *RalphLoopClient {
+	config.SaveDir = ResolveWorkDirPath(config.WorkDir, config.SaveDir)
+	config.RunsDir = ResolveWorkDirPath(config.WorkDir, config.RunsDir)
+	client := &RalphLoopClient{
+		config:      config,
+		initialized: false,
+		closed:      false,
+	}
+	client.runID = NewRunID()
+
+	// 所有元件共用同一個日誌分送器，之後可再加入 run 目錄的日誌檔
+	baseLogger := config.Logger
+	if baseLogger == nil {
+		baseLogger = defaultLogger()
+	}
+	client.logRouter = NewLogRouter(baseLogger.Handler())
+	client.logger = client.logRouter.Logger().With("run_id", client.runID)
+
+	// 初始化各個模組
+	client.executor = NewCLIExecutor(config.WorkDir)
+	client.executor.SetTimeout(config.CLITimeout)
+	client.executor.SetMaxRetries(config.CLIMaxRetries)
+	opts := DefaultOptions()
+	if config.Model != "" {
+		opts.Model = Model(config.Model)
+	}
+	opts.Silent = config.Silent
+	config.Permissions.ApplyTo(&opts)
+	client.executor.SetOptions(opts)
+	client.executor.SetLogger(client.logger.With("component", "cli_executor"))
+	client.promptExecutor = client.executor
+	if config.Executor != nil {
+		client.promptExecutor = config.Executor
+	}
+	if config.Chaos != nil {
+		client.chaos = NewChaosInjector(*config.Chaos)
+		client.chaos.SetLogger(client.logger.With("component", "chaos"))
+		if config.Executor != nil {
+			client.promptExecutor = NewChaosExecutor(config.Executor, client.chaos)
+		} else {
+			client.executor.SetFaultInjector(client.chaos)
+		}
+	}
+
+	client.parser = NewOutputParser("")
+
+	client.analyzer = NewResponseAnalyzer("")
+
+	client.breaker = NewCircuitBreaker("")
+	client.breaker.SetViolationThreshold(config.ProtectedPathThreshold)
+	client.breaker.SetLogger(client.logger.With("component", "circuit_breaker"))
+
+	client.pathGuard = NewProtectedPathGuard(config.ProtectedPaths)
+
+	client.controller = NewLoopController()
+
+	client.events = NewEventBus()
+	client.breakerState = client.breaker.GetState()
+	client.executor.SetStreamHandler(func(stream, line string) {
+		client.publish(LoopEvent{Type: EventOutputChunk, Stream: stream, Text: line})
+	})
+
+	client.contextManager = NewContextManager()
+	client.contextManager.SetMaxHistorySize(config.MaxHistorySize)
+
+	if config.EnablePersistence {
+		pm, err := NewPersistenceManager(config.SaveDir, config.UseGobFormat)
+		if err == nil {
+			pm.SetLogger(client.logger.With("component", "persistence"))
+			client.persistence = pm
+		} else {
+			client.logger.Warn("無法初始化持久化，停用儲存", "dir", config.SaveDir, "error", err)
+		}
+	}
+
+	// 初始化 SDK 執行器
+	sdkConfig := &SDKConfig{
+		CLIPath:        "copilot",
+		Model:          config.Model,
+		Timeout:        config.CLITimeout,
+		SessionTimeout: 5 * time.Minute,
+		MaxSessions:    100,
+		LogLevel:       "info",
+		EnableMetrics:  true,
+		AutoReconnect:  true,
+		MaxRetries:     config.CLIMaxRetries,
+	}
+	client.sdkExecutor = NewSDKExecutor(sdkConfig)
+	client.sdkExecutor.SetEventHandler(client.publish)
+	client.sdkExecutor.SetLogger(client.logger.With("component", "sdk_executor"))
+
+	client.exitDetector = NewExitDetector(client.executor.GetWorkDir())
+	if config.SDKTools {
+		client.tools = NewLoopTools(LoopToolsConfig{
+			WorkDir:      client.executor.GetWorkDir(),
+			Verify:       verificationHooks(config.ShellHooks),
+			History:      client.GetHistory,
+			ExitDetector: client.exitDetector,
+			HookContext: func(execCtx *ExecutionContext) *HookContext {
+				return client.hookContext(HookPostLoop, execCtx)
+			},
+		})
+		client.tools.SetLogger(client.logger.With("component", "sdk_tools"))
+		client.sdkExecutor.SetTools(client.tools.Tools())
+	}
+
+	client.permissions = NewPermissionEngine(config.Permissions, client.executor.GetWorkDir())
+	client.permissions.SetLogger(client.logger.With("component", "permissions"))
+	if config.PermissionEscalator != nil {
+		client.permissions.SetEscalator(config.PermissionEscalator)
+	} else if escalator, ok := config.Approver.(PermissionEscalator); ok {
+		client.permissions.SetEscalator(escalator)
+	}
+	client.permissions.OnDecision = func(decision PermissionDecision) {
+		client.publish(LoopEvent{
+			Type: EventPermissionDecision,
+			Tool: decision.Request.Kind,
+			Data: map[string]interface{}{"decision": decision},
+		})
+	}
+	client.sdkExecutor.SetPermissionHandler(client.permissions.Handler())
+
+	client.metrics = NewLoopMetrics()
+	client.metrics.RegisterCLIExecutor(client.executor)
+	client.metrics.RegisterSDKExecutor(client.sdkExecutor)
+	client.metrics.SetBreakerState(client.breakerState, "")
+
+	client.tracer = NewTracer("ralph-loop")
+	if config.OTLPEndpoint != "" {
+		client.tracer.AddExporter(NewOTLPHTTPExporter(config.OTLPEndpoint, client.tracer.ServiceName()))
+	}
+	client.executor.SetTelemetry(config.EnableTracing)
+
+	client.initialized = true
+	return client
+}
+
+// ResolveWorkDirPath 傳回以工作目錄為基準的路徑（絕對路徑與空的工作目錄不變）
+func ResolveWorkDirPath(workDir, path string) string {
+	if path == "" || workDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workDir, path)
+}
+
+// DefaultClientConfig 傳回預設的配置
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		CLITimeout:              60 * time.Second, // 增加到 60 秒以支援複雜任務
+		CLIMaxRetries:           3,
+		MaxHistorySize:          100,
+		SaveDir:                 ".ralph-loop/saves",
+		RunsDir:                 ".ralph-loop/runs",
+		UseGobFormat:            false,
+		CircuitBreakerThreshold: 3,
+		SameErrorThreshold:      5,
+		Model:                   "claude-sonnet-4.5",
+		Silent:                  false,
+		LogLevel:                slog.LevelInfo,
+		LogFormat:               LogFormatText,
+		Permissions:             DefaultPermissionPolicy(),
+		ProtectedPaths:          append([]string{}, DefaultProtectedPaths...),
+		ProtectedPathThreshold:  2,
+		EnablePersistence:       true,
+		EnableTracing:           true,
+		EnableSDK:               true, // 預設啟用 SDK（主要執行方式）
+		PreferSDK:               true, // 預設優先使用 SDK
+		StatusContract:          true,
+		SDKTools:                true,
+	}
+}
+
+// ExecuteLoop 執行單個迴圈
+//
+// 這是最常用的方法。它會：
+// 1. 執行 CLI 命令
+// 2. 解析輸出
+// 3. 分析回應
+// 4. 檢查是否應該繼續或退出
+// 5. 記錄結果到歷史
+//
+// 返回值：
+// - LoopResult: 迴圈執行結果
+// - error: 執行過程中的錯誤
+func (c *RalphLoopClient) ExecuteLoop(ctx context.Context, prompt string) (*LoopResult, error) {
+	if !c.initialized {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return nil, fmt.Errorf("client is closed")
+	}
+
+	// 檢查熔斷器
+	if c.breaker.IsOpen() {
+		return nil, fmt.Errorf("circuit breaker is open: %s", c.breaker.GetState())
+	}
+
+	// 開始新迴圈
+	c.openRunLog()
+	c.openJournal()
+	c.openTranscript()
+	c.startRunTrace()
+	c.startRunRecord(ctx, prompt)
+	loopIndex := len(c.contextManager.GetLoopHistory())
+	c.currentLoop = loopIndex
+	c.loopDiff = ""
+	c.loopHooks = nil
+
+	// 迴圈 span 放在呼叫端的 span 之下；沒有時放在本次執行的根 span 之下
+	if SpanFromContext(ctx) == nil && c.runSpan != nil {
+		ctx = ContextWithSpan(ctx, c.runSpan)
+	}
+	ctx, loopSpan := StartSpan(ctx, "ralph.loop", SpanKindInternal)
+	loopSpan.SetAttr("loop.index", loopIndex)
+
+	// pre_loop hook 可以否決本次迴圈
+	hookFailures, veto := c.runHooks(ctx, &HookContext{
+		Point:        HookPreLoop,
+		RunID:        c.runID,
+		LoopIndex:    loopIndex,
+		Prompt:       prompt,
+		WorkDir:      c.executor.GetWorkDir(),
+		BreakerState: string(c.breaker.GetState()),
+	})
+	if veto != nil {
+		c.logger.Warn("pre_loop hook 否決迴圈", "loop", loopIndex, "error", veto)
+		loopSpan.RecordError(veto)
+		c.endLoopSpan(loopSpan)
+		return nil, fmt.Errorf("loop vetoed by pre_loop hook: %w", veto)
+	}
+
+	execCtx := c.contextManager.StartLoop(loopIndex, prompt)
+	execCtx.RunID = c.runID
+	execCtx.Model = c.config.Model
+	execCtx.PermissionPolicy = c.config.Permissions.Clone()
+	execCtx.ErrorHistory = append(execCtx.ErrorHistory, hookFailures...)
+	if loopSpan != nil {
+		execCtx.Metadata["trace_id"] = loopSpan.TraceID
+		loopSpan.SetAttr("loop.id", execCtx.LoopID)
+	}
+	c.publish(LoopEvent{Type: EventLoopStarted, Text: prompt})
+	c.logger.Info("迴圈開始", "loop", loopIndex, "loop_id", execCtx.LoopID)
+
+	// 提前返回的路徑（執行失敗）維持 failed，正常結束時再更新
+	outcome := LoopOutcomeFailed
+	loopStart := time.Now()
+
+	defer func() {
+		// 完成迴圈
+		if err := c.contextManager.FinishLoop(); err != nil {
+			c.logger.Warn("無法結束迴圈上下文", "loop", loopIndex, "error", err)
+		}
+		c.checkBreakerTransition(ctx, execCtx)
+		c.metrics.ObserveLoop(outcome, time.Since(loopStart))
+		if !execCtx.Failed {
+			c.metrics.ObserveChanges(execCtx.WorkspaceChanges)
+		}
+		c.metrics.SetChangeBudget(c.runChanges, c.config.ChangeScope)
+		execCtx.HookResults = c.loopHooks
+		execCtx.Outcome = outcome
+		c.publishLoopFinished(ctx, execCtx)
+		loopSpan.SetAttr("loop.outcome", outcome)
+		loopSpan.SetAttr("loop.should_continue", execCtx.ShouldContinue)
+		loopSpan.SetAttr("loop.exit_reason", execCtx.ExitReason)
+		loopSpan.SetAttr("circuit_breaker.state", string(c.breaker.GetState()))
+		if outcome == LoopOutcomeFailed {
+			loopSpan.SetStatus(SpanStatusError, execCtx.ExitReason)
+		}
+		c.endLoopSpan(loopSpan)
+		c.logger.Info("迴圈結束",
+			"loop", loopIndex,
+			"should_continue", execCtx.ShouldContinue,
+			"failed", execCtx.Failed,
+			"exit_reason", execCtx.ExitReason,
+			"duration_ms", execCtx.DurationMs)
+
+		// 自動持久化個別執行上下文（history 指令的資料來源，失敗的迴圈也保存）與整個 ContextManager（如果啟用）
+		if c.persistence != nil && c.config.EnablePersistence {
+			if err := c.persistence.SaveExecutionContext(execCtx); err != nil {
+				c.logger.Warn("無法儲存執行上下文", "loop_id", execCtx.LoopID, "error", err)
+			}
+			if err := c.persistence.SaveContextManager(c.contextManager); err != nil {
+				// 記錄但不影響主流程
+				c.logger.Warn("無法儲存上下文管理器", "error", err)
+			}
+		}
+	}()
+
+	// 附加待注入的說明，並在執行前建立工作目錄快照
+	_, promptSpan := StartSpan(ctx, "ralph.prompt.build", SpanKindInternal)
+	loopPrompt := c.buildLoopPrompt(prompt, execCtx)
+	before := c.snapshotWorkspace(execCtx)
+	promptSpan.SetAttr("prompt.bytes", len(loopPrompt))
+	promptSpan.End()
+
+	// agent 工具呼叫與權限決定記錄在本次迴圈
+	if c.tools != nil {
+		c.tools.BeginLoop(ctx, execCtx)
+	}
+	c.permissions.BeginLoop(ctx, execCtx)
+
+	// 根據配置決定執行順序：優先使用 SDK 或 CLI
+	var output string
+	var executionErr error
+	var usedSDK bool
+
+	// 如果配置優先使用 SDK，則先嘗試 SDK
+	if c.config.PreferSDK && c.config.EnableSDK && c.sdkExecutor != nil && c.sdkExecutor.isHealthy() {
+		sdkCtx, sdkSpan := StartSpan(ctx, "ralph.executor.sdk", SpanKindClient)
+		sdkSpan.SetAttr("executor.model", c.config.Model)
+		sdkStart := time.Now()
+		output, executionErr = c.completeSDK(sdkCtx, loopPrompt)
+		c.metrics.ObserveExecution(ModeSDK, c.config.Model, executionErr == nil, time.Since(sdkStart))
+		c.recordExchange(ModeSDK, loopPrompt, &ExecutionResult{Command: "sdk:complete", Stdout: output}, executionErr, time.Since(sdkStart))
+		sdkSpan.RecordError(executionErr)
+		sdkSpan.End()
+		if executionErr == nil {
+			usedSDK = true
+			execCtx.ExecutionMode = ModeSDK.String()
+			execCtx.CLICommand = "sdk:complete"
+			execCtx.CLIOutput = output
+			execCtx.CLIExitCode = 0
+		}
+	}
+
+	// SDK 失敗/不可用/未啟用，或配置不優先使用 SDK 時，使用 CLI（RequireSDK 時不改用 CLI）
+	var result *ExecutionResult
+	var err error
+	if !usedSDK && c.config.RequireSDK {
+		if executionErr == nil {
+			executionErr = fmt.Errorf("SDK 執行器未啟動")
+		}
+	} else if !usedSDK {
+		cliCtx, cliSpan := StartSpan(ctx, "ralph.executor.cli", SpanKindClient)
+		cliSpan.SetAttr("executor.model", c.config.Model)
+		cliStart := time.Now()
+		execCtx.ExecutionMode = ModeCLI.String()
+		result, err = c.promptExecutor.ExecutePrompt(cliCtx, loopPrompt)
+		c.metrics.ObserveExecution(ModeCLI, c.config.Model, err == nil && result != nil && result.ExitCode == 0, time.Since(cliStart))
+		c.recordExchange(ModeCLI, loopPrompt, result, err, time.Since(cliStart))
+		cliSpan.RecordError(err)
+		if result != nil {
+			cliSpan.SetAttr("process.exit_code", result.ExitCode)
+			if result.ExitCode != 0 {
+				cliSpan.SetStatus(SpanStatusError, fmt.Sprintf("exit code %d", result.ExitCode))
+			}
+		}
+		cliSpan.End()
+	}
+
+	var reported *CopilotStatus
+	if c.tools != nil {
+		reported = c.tools.EndLoop()
+	}
+	c.permissions.EndLoop()
+
+	// 無論執行成功與否，AI 都可能已修改檔案，先套用工作目錄策略
+	_, policySpan := StartSpan(ctx, "ralph.workspace.policies", SpanKindInternal)
+	if c.applyWorkspacePolicies(execCtx, before) {
+		execCtx.Failed = true
+		policySpan.SetStatus(SpanStatusError, "workspace policy violated")
+	}
+	policySpan.SetAttr("workspace.files_changed", len(execCtx.WorkspaceChanges))
+	policySpan.End()
+
+	if !usedSDK && c.config.RequireSDK {
+		c.breaker.RecordSameError(executionErr.Error())
+		execCtx.ExitReason = fmt.Sprintf("SDK 執行失敗 (RequireSDK，不改用 CLI): %v", executionErr)
+		return c.failExecution(ctx, execCtx), nil
+	}
+	if !usedSDK {
+		if err != nil {
+			c.breaker.RecordSameError(err.Error())
+			if executionErr != nil {
+				execCtx.ExitReason = fmt.Sprintf("執行失敗 (SDK: %v, CLI: %v)", executionErr, err)
+			} else {
+				execCtx.ExitReason = fmt.Sprintf("CLI 執行失敗: %v", err)
+			}
+			return c.failExecution(ctx, execCtx), nil
+		}
+
+		output = result.Stdout
+		execCtx.CLICommand = result.Command
+		execCtx.CLIOutput = result.Stdout
+		execCtx.CLIExitCode = result.ExitCode
+
+		if result.ExitCode != 0 {
+			c.breaker.RecordSameError(fmt.Sprintf("exit code %d", result.ExitCode))
+			execCtx.ExitReason = fmt.Sprintf("CLI 執行失敗，退出碼 %d", result.ExitCode)
+			return c.failExecution(ctx, execCtx), nil
+		}
+	}
+
+	// 解析輸出
+	_, analysisSpan := StartSpan(ctx, "ralph.analysis", SpanKindInternal)
+	parser := NewOutputParser(output)
+	parser.Parse()
+	codeBlocks := parser.GetOptions() // 臨時使用，實際應有完整解析
+
+	execCtx.ParsedCodeBlocks = codeBlocks
+	execCtx.CleanedOutput = output
+
+	// 分析回應（簡化版本，實際應使用完整分析器）
+	// 如果輸出包含完成關鍵字，則視為完成
+	shouldContinue := !OutputSignalsCompletion(output)
+
+	analyzer := NewResponseAnalyzer(output)
+	execCtx.CompletionScore = analyzer.CalculateCompletionScore()
+	status, statusErr := ParseStatusBlock(output)
+	if reported != nil {
+		// report_status 工具的回報已通過 schema 驗證，優先於輸出中的狀態區塊
+		status, statusErr = reported, nil
+		shouldContinue = !reported.ExitSignal
+	}
+	execCtx.StructuredStatus = status.LoopStatus()
+	if statusErr != nil && c.config.StatusContract {
+		// 不符合規格的狀態區塊無法判斷是否完成：記錄為迴圈訊號並要求下一次修正
+		execCtx.StatusError = statusErr.Error()
+		execCtx.ErrorHistory = append(execCtx.ErrorHistory, execCtx.StatusError)
+		c.metrics.ObserveStatusError()
+		c.queuePromptNote("上一次回應的狀態區塊無法解析（" + execCtx.StatusError + "），請依規格輸出 JSON 狀態區塊。")
+		shouldContinue = true
+	}
+
+	// 違反工作目錄策略的迴圈不能視為完成（熔斷器已記錄違規）
+	if execCtx.Failed {
+		shouldContinue = true
+	}
+	if !shouldContinue {
+		execCtx.ExitReason = "completion detected in output"
+		if reported != nil {
+			execCtx.ExitReason = "completion reported via " + ToolReportStatus
+		}
+	}
+	// report_status 累計的完成訊號（例如連續回報 DONE）也可以結束執行
+	if shouldContinue && !execCtx.Failed && reported != nil && c.exitDetector.ShouldExitGracefully(execCtx.CompletionScore) {
+		shouldContinue = false
+		execCtx.ExitReason = c.exitDetector.GetExitReason(execCtx.CompletionScore)
+	}
+	analysisSpan.SetAttr("analysis.completion_score", execCtx.CompletionScore)
+	analysisSpan.SetAttr("analysis.should_continue", shouldContinue)
+	analysisSpan.End()
+
+	c.publish(LoopEvent{
+		Type:           EventLoopAnalyzed,
+		ShouldContinue: shouldContinue,
+		ExitReason:     execCtx.ExitReason,
+		Data: map[string]interface{}{
+			"completion_score":  execCtx.CompletionScore,
+			"structured_status": execCtx.StructuredStatus,
+			"status_error":      execCtx.StatusError,
+			"changes":           SummarizeChanges(execCtx.WorkspaceChanges),
+		},
+	})
+
+	// 人工審核：操作者拒絕時視為失敗並繼續，要求停止時結束執行
+	stopped := false
+	if c.config.Approver != nil {
+		stopped = c.requestApproval(ctx, execCtx, before, shouldContinue)
+		if stopped {
+			shouldContinue = false
+			execCtx.ExitReason = "stopped by operator"
+		} else if execCtx.Failed {
+			shouldContinue = true
+			execCtx.ExitReason = ""
+		}
+	}
+
+	// post_loop hook（例如格式化、lint）可以將迴圈標記為失敗
+	if c.runPostLoopHooks(ctx, execCtx, shouldContinue) && !stopped {
+		shouldContinue = true
+		execCtx.ExitReason = ""
+	}
+
+	execCtx.ShouldContinue = shouldContinue
+	if !shouldContinue && !stopped {
+		c.breaker.RecordSuccess()
+		failures, _ := c.runHooks(ctx, c.hookContext(HookOnComplete, execCtx))
+		execCtx.ErrorHistory = append(execCtx.ErrorHistory, failures...)
+	} else if shouldContinue && !execCtx.Failed {
+		c.breaker.RecordNoProgress()
+	}
+
+	execCtx.CircuitBreakerState = string(c.breaker.GetState())
+
+	switch {
+	case stopped:
+		outcome = LoopOutcomeStopped
+	case execCtx.Failed:
+		outcome = LoopOutcomeFailed
+	case shouldContinue:
+		outcome = LoopOutcomeContinue
+	default:
+		outcome = LoopOutcomeComplete
+	}
+
+	loopResult := c.createResult(execCtx, shouldContinue)
+	loopResult.Stopped = stopped
+	return loopResult, nil
+}
+
+// progressOutput 取得迴圈進度的輸出位置
+func (c *RalphLoopClient) progressOutput() io.Writer {
+	switch {
+	case c.config.Silent:
+		return io.Discard
+	case c.config.ProgressOutput != nil:
+		return c.config.ProgressOutput
+	default:
+		return os.Stdout
+	}
+}
+
+// ExecuteUntilCompletion 持續執行迴圈直到完成或錯誤
+//
+// 這個方法會自動處理迴圈，直到：
+// - 系統回報完成
+// - 熔斷器打開
+// - Context 被取消
+// - 達到最大迴圈次數
+// - 透過 Controller 要求停止
+//
+// 每個迴圈開始前會檢查控制狀態：暫停時等待恢復，並注入外部送入的回饋。
+// 最大迴圈次數可以在執行中透過 Controller 調整。
+func (c *RalphLoopClient) ExecuteUntilCompletion(ctx context.Context, initialPrompt string, maxLoops int) ([]*LoopResult, error) {
+	var results []*LoopResult
+	progress := c.progressOutput()
+	c.controller.begin(maxLoops)
+	deadline, _ := ctx.Deadline()
+	c.metrics.SetDeadline(deadline)
+	c.metrics.SetLoopBudget(0, maxLoops)
+
+	for i := 0; i < c.controller.MaxLoops(); i++ {
+		select {
+		case <-ctx.Done():
+			return results, c.finishRun(RunStatusCancelled, i, fmt.Errorf("context cancelled after %d loops", i))
+		default:
+		}
+
+		// 檢查控制狀態
+		if c.controller.State().Paused {
+			fmt.Fprintln(progress, "⏸️  已暫停，等待 resume...")
+		}
+		if err := c.controller.WaitWhilePaused(ctx); err != nil {
+			return results, c.finishRun(RunStatusCancelled, i, fmt.Errorf("context cancelled after %d loops", i))
+		}
+		if c.controller.StopRequested() {
+			return results, c.finishRun(RunStatusStopped, i, fmt.Errorf("stopped by control command after %d loops", i))
+		}
+		for _, feedback := range c.controller.takeFeedback() {
+			c.queuePromptNote("操作者回饋：" + feedback)
+		}
+		c.applyBreakerReset(ctx)
+
+		// 顯示進度
+		fmt.Fprintf(progress, "\n🔄 迴圈 %d/%d - 正在執行...\n", i+1, c.controller.MaxLoops())
+
+		result, err := c.ExecuteLoop(ctx, initialPrompt)
+		if err != nil {
+			fmt.Fprintf(progress, "❌ 迴圈 %d 失敗: %v\n", i+1, err)
+			status := RunStatusFailed
+			if ctx.Err() != nil {
+				status = RunStatusCancelled
+			}
+			return results, c.finishRun(status, i, err)
+		}
+
+		results = append(results, result)
+		c.controller.loopFinished(i + 1)
+		c.metrics.SetLoopBudget(i+1, c.controller.MaxLoops())
+
+		// 顯示迴圈結果
+		if result.ShouldContinue {
+			fmt.Fprintf(progress, "✓ 迴圈 %d 完成 - 繼續下一個迴圈\n", i+1)
+		} else {
+			fmt.Fprintf(progress, "✓ 迴圈 %d 完成 - 任務完成: %s\n", i+1, result.ExitReason)
+		}
+
+		// 檢查是否完成
+		if result.Stopped {
+			return results, c.finishRun(RunStatusStopped, i+1, fmt.Errorf("stopped by operator after %d loops", i+1))
+		}
+		if !result.ShouldContinue {
+			return results, c.finishRun(RunStatusCompleted, i+1, nil)
+		}
+
+		// 檢查熔斷器（迴圈期間送入的重置要求在此套用）
+		c.applyBreakerReset(ctx)
+		if c.breaker.IsOpen() {
+			return results, c.finishRun(RunStatusFailed, i+1, fmt.Errorf("circuit breaker opened after %d loops", i+1))
+		}
+	}
+
+	return results, c.finishRun(RunStatusFailed, len(results), fmt.Errorf("reached maximum loops (%d) without completion", c.controller.MaxLoops()))
+}
+
+// Subscribe 訂閱迴圈事件，傳回取消訂閱的函式
+//
+// 事件包含迴圈開始/結束、即時輸出、工具呼叫、分析結果與熔斷器狀態變化。
+func (c *RalphLoopClient) Subscribe(handler EventHandler) func() {
+	return c.events.Subscribe(handler)
+}
+
+// completeSDK 以 SDK 執行 prompt（設定 Chaos 時經過故障注入器）
+func (c *RalphLoopClient) completeSDK(ctx context.Context, prompt string) (string, error) {
+	if c.chaos == nil {
+		return c.sdkExecutor.Complete(ctx, prompt)
+	}
+	result, err := c.chaos.Inject(ctx, func(ctx context.Context) (*ExecutionResult, error) {
+		output, err := c.sdkExecutor.Complete(ctx, prompt)
+		return &ExecutionResult{Stdout: output, Success: err == nil, Error: err}, err
+	})
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("exit code %d", result.ExitCode)
+	}
+	return result.Stdout, err
+}
+
+// Tools 取得註冊到 SDK 會話的 agent 工具（停用 ClientConfig.SDKTools 時為 nil）
+func (c *RalphLoopClient) Tools() *LoopTools {
+	return c.tools
+}
+
+// Permissions 取得處理 SDK 權限請求的權限引擎
+func (c *RalphLoopClient) Permissions() *PermissionEngine {
+	return c.permissions
+}
+
+// ExitDetector 取得累計 report_status 完成訊號的退出偵測器
+func (c *RalphLoopClient) ExitDetector() *ExitDetector {
+	return c.exitDetector
+}
+
+// Chaos 取得故障注入器（未設定 ClientConfig.Chaos 時為 nil）
+func (c *RalphLoopClient) Chaos() *ChaosInjector {
+	return c.chaos
+}
+
+// RunID 取得本次執行的 ID
+func (c *RalphLoopClient) RunID() string {
+	return c.runID
+}
+
+// RunDir 取得本次執行的紀錄目錄（未啟用執行紀錄時為空字串）
+func (c *RalphLoopClient) RunDir() string {
+	if !c.config.EnablePersistence || c.config.RunsDir == "" {
+		return ""
+	}
+	return filepath.Join(c.config.RunsDir, c.runID)
+}
+
+// Controller 取得迴圈控制器，可用於暫停、恢復、停止或注入回饋
+func (c *RalphLoopClient) Controller() *LoopController {
+	return c.controller
+}
+
+// GetHistory 取得執行歷史
+func (c *RalphLoopClient) GetHistory() []*ExecutionContext {
+	return c.contextManager.GetLoopHistory()
+}
+
+// GetSummary 取得執行摘要
+func (c *RalphLoopClient) GetSummary() map[string]interface{} {
+	return c.contextManager.GetSummary()
+}
+
+// GetStatus 取得當前狀態
+func (c *RalphLoopClient) GetStatus() *ClientStatus {
+	return &ClientStatus{
+		Initialized:         c.initialized,
+		Closed:              c.closed,
+		CircuitBreakerOpen:  c.breaker.IsOpen(),
+		CircuitBreakerState: c.breaker.GetState(),
+		LoopsExecuted:       len(c.contextManager.GetLoopHistory()),
+		Summary:             c.GetSummary(),
+	}
+}
+
+// ResetCircuitBreaker 重置熔斷器
+func (c *RalphLoopClient) ResetCircuitBreaker() error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	c.breaker.Reset()
+	return nil
+}
+
+// ClearHistory 清空歷史記錄
+func (c *RalphLoopClient) ClearHistory() {
+	if c.initialized {
+		c.contextManager.Clear()
+	}
+}
+
+// ExportHistory 匯出歷史為 JSON
+func (c *RalphLoopClient) ExportHistory(outputPath string) error {
+	if c.persistence == nil {
+		return fmt.Errorf("persistence not enabled")
+	}
+	return c.persistence.ExportAsJSON(c.contextManager, outputPath)
+}
+
+// LoadHistoryFromDisk 從磁盤載入歷史記錄
+//
+// 此方法將從儲存目錄載入所有保存的執行上下文，
+// 並恢復 ContextManager 的狀態。
+//
+// 使用時機：
+// - 客戶端初始化後，需要恢復之前的迴圈歷史
+// - 重啟應用程序時恢復狀態
+func (c *RalphLoopClient) LoadHistoryFromDisk() error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return fmt.Errorf("client is closed")
+	}
+	if c.persistence == nil {
+		return fmt.Errorf("persistence not enabled")
+	}
+
+	// 從磁盤載入 ContextManager (使用預設檔名)
+	loadedManager, err := c.persistence.LoadContextManager("context_manager.json")
+	if err != nil {
+		return fmt.Errorf("failed to load context manager: %w", err)
+	}
+
+	// 使用載入的管理器替換當前的
+	c.contextManager = loadedManager
+	return nil
+}
+
+// SaveHistoryToDisk 立即將歷史記錄儲存到磁盤
+//
+// 此方法強制將目前的執行歷史記錄保存到磁盤，
+// 即使自動持久化未啟用。
+//
+// 使用時機：
+// - 在應用程序關閉前確保所有數據已保存
+// - 定期備份關鍵狀態
+// - 手動觸發保存
+func (c *RalphLoopClient) SaveHistoryToDisk() error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	if c.persistence == nil {
+		return fmt.Errorf("persistence not enabled")
+	}
+
+	// 保存 ContextManager
+	if err := c.persistence.SaveContextManager(c.contextManager); err != nil {
+		return fmt.Errorf("failed to save context manager: %w", err)
+	}
+
+	// 同時保存當前迴圈（如果有）
+	if len(c.contextManager.GetLoopHistory()) > 0 {
+		lastLoop := c.contextManager.GetLoopByIndex(len(c.contextManager.GetLoopHistory()) - 1)
+		if lastLoop != nil {
+			if err := c.persistence.SaveExecutionContext(lastLoop); err != nil {
+				// 不影響主流程，只記錄警告
+				return fmt.Errorf("warning: failed to save last execution context: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetPersistenceStats 取得持久化統計資訊
+//
+// 傳回持久化層的統計資訊，包括：
+// - 儲存目錄路徑
+// - 儲存的上下文數量
+// - 最後保存時間
+// - 使用的格式 (JSON/Gob)
+func (c *RalphLoopClient) GetPersistenceStats() map[string]interface{} {
+	stats := make(map[string]interface{})
+
+	if c.persistence == nil {
+		stats["enabled"] = false
+		return stats
+	}
+
+	stats["enabled"] = true
+	stats["storage_dir"] = c.persistence.GetStorageDir()
+	stats["format"] = "json"
+	if c.config.UseGobFormat {
+		stats["format"] = "gob"
+	}
+
+	// 列出已保存的上下文
+	savedContexts, err := c.persistence.ListSavedContexts()
+	if err == nil {
+		stats["saved_count"] = len(savedContexts)
+		stats["saved_contexts"] = savedContexts
+	}
+
+	return stats
+}
+
+// CleanupOldBackups 清理舊的備份檔案
+//
+// 此方法會清理舊於指定天數的備份，
+// 或根據 maxBackups 設定保留最新的備份。
+//
+// 參數:
+// - prefix: 備份檔名前綴 (如 "context_manager" 或 "execution_context")
+//
+// 返回值:
+// - error: 清理過程中的錯誤
+func (c *RalphLoopClient) CleanupOldBackups(prefix string) error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	if c.persistence == nil {
+		return fmt.Errorf("persistence not enabled")
+	}
+
+	return c.persistence.ClearOldBackups(prefix)
+}
+
+// SetMaxBackupCount 設定最多保留的備份數量
+//
+// 此方法會設定持久化管理器最多保留多少個備份檔案。
+// 預設值為 10。
+//
+// 參數:
+// - count: 最多保留的備份數量 (必須 > 0)
+//
+// 範例:
+//
+//	client.SetMaxBackupCount(20)  // 最多保留 20 個備份
+func (c *RalphLoopClient) SetMaxBackupCount(count int) error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	if c.persistence == nil {
+		return fmt.Errorf("persistence not enabled")
+	}
+	if count <= 0 {
+		return fmt.Errorf("backup count must be greater than 0")
+	}
+
+	c.persistence.SetMaxBackups(count)
+	return nil
+}
+
+// ListBackups 列出所有備份
+//
+// 傳回指定前綴的所有備份檔案列表。
+//
+// 參數:
+// - prefix: 備份檔名前綴
+//
+// 返回值:
+// - []string: 備份檔案名稱列表
+// - error: 列舉過程中的錯誤
+func (c *RalphLoopClient) ListBackups(prefix string) ([]string, error) {
+	if !c.initialized {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	if c.persistence == nil {
+		return nil, fmt.Errorf("persistence not enabled")
+	}
+
+	// 使用 ListSavedContexts 作為備份列表
+	contexts, err := c.persistence.ListSavedContexts()
+	if err != nil {
+		return nil, err
+	}
+
+	// 過濾符合前綴的備份
+	var backups []string
+	for _, ctx := range contexts {
+		if strings.HasPrefix(ctx, prefix) {
+			backups = append(backups, ctx)
+		}
+	}
+
+	return backups, nil
+}
+
+// RecoverFromBackup 從備份恢復狀態
+//
+// 此方法從指定的備份檔案恢復執行上下文和系統狀態。
+// 可用於故障恢復或狀態復制。
+//
+// 參數:
+// - filename: 備份檔名
+//
+// 返回值:
+// - error: 恢復過程中的錯誤
+func (c *RalphLoopClient) RecoverFromBackup(filename string) error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return fmt.Errorf("client is closed")
+	}
+	if c.persistence == nil {
+		return fmt.Errorf("persistence not enabled")
+	}
+
+	// 從備份載入
+	execCtx, err := c.persistence.LoadExecutionContext(filename)
+	if err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+
+	if execCtx == nil {
+		return fmt.Errorf("loaded backup is empty")
+	}
+
+	// 恢復迴圈索引到該執行上下文
+	// 清空當前歷史並添加恢復的上下文
+	c.contextManager.Clear()
+	c.contextManager.StartLoop(execCtx.LoopIndex, execCtx.UserPrompt)
+	c.contextManager.UpdateCurrentLoop(func(ctx *ExecutionContext) {
+		*ctx = *execCtx
+	})
+	c.contextManager.FinishLoop()
+
+	return nil
+}
+
+// VerifyStateConsistency 驗證狀態一致性
+//
+// 此方法檢查保存的狀態與當前狀態是否一致，
+// 用於檢測損毀或不一致的備份。
+//
+// 返回值:
+// - bool: 狀態是否一致
+// - error: 驗證過程中的錯誤
+func (c *RalphLoopClient) VerifyStateConsistency() (bool, error) {
+	if !c.initialized {
+		return false, fmt.Errorf("client not initialized")
+	}
+	if c.persistence == nil {
+		return false, fmt.Errorf("persistence not enabled")
+	}
+
+	// 取得當前狀態
+	currentCount := len(c.contextManager.GetLoopHistory())
+
+	// 列出已保存的備份
+	savedContexts, err := c.persistence.ListSavedContexts()
+	if err != nil {
+		return false, fmt.Errorf("failed to list saved contexts: %w", err)
+	}
+
+	// 基本一致性檢查：備份計數不應遠大於當前迴圈計數
+	// (允許某些差異是因為備份可能更新)
+	if len(savedContexts) > currentCount*2 {
+		return false, fmt.Errorf("saved backups count significantly exceeds current loops")
+	}
+
+	return true, nil
+}
+
+// Close 關閉客戶端並清理資源
+func (c *RalphLoopClient) Close() error {
+	if c.closed {
+		return fmt.Errorf("client already closed")
+	}
+
+	// 執行最後的持久化
+	if c.persistence != nil && c.config.EnablePersistence {
+		_ = c.persistence.SaveContextManager(c.contextManager)
+	}
+
+	// 關閉 SDK 執行器
+	if c.sdkExecutor != nil {
+		_ = c.sdkExecutor.Close()
+	}
+
+	// 關閉執行紀錄與日誌檔
+	if c.journal != nil {
+		_ = c.journal.Close()
+	}
+	if c.transcript != nil {
+		_ = c.transcript.Close()
+	}
+	c.runSpan.End()
+	if err := c.tracer.Shutdown(); err != nil {
+		c.logger.Warn("無法匯出追蹤資料", "error", err)
+	}
+	_ = c.logRouter.Close()
+
+	c.closed = true
+	return nil
+}
+
+// StartSDKExecutor 啟動 SDK 執行器
+// 這使用新的 SDK 層進行程式碼執行，提供更細粒度的控制
+func (c *RalphLoopClient) StartSDKExecutor(ctx context.Context) error {
+	if !c.initialized {
+		return fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return fmt.Errorf("client is closed")
+	}
+	if c.sdkExecutor == nil {
+		return fmt.Errorf("SDK executor not available")
+	}
+
+	return c.sdkExecutor.Start(ctx)
+}
+
+// StopSDKExecutor 停止 SDK 執行器
+func (c *RalphLoopClient) StopSDKExecutor(ctx context.Context) error {
+	if c.sdkExecutor == nil {
+		return fmt.Errorf("SDK executor not available")
+	}
+
+	return c.sdkExecutor.Stop(ctx)
+}
+
+// ExecuteWithSDK 使用 SDK 執行程式碼完成
+// 提供比標準 ExecuteLoop 更直接的程式碼執行介面
+func (c *RalphLoopClient) ExecuteWithSDK(ctx context.Context, prompt string) (string, error) {
+	if !c.initialized {
+		return "", fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return "", fmt.Errorf("client is closed")
+	}
+	if c.sdkExecutor == nil {
+		return "", fmt.Errorf("SDK executor not available")
+	}
+
+	return c.sdkExecutor.Complete(ctx, prompt)
+}
+
+// ExplainWithSDK 使用 SDK 解釋程式碼
+func (c *RalphLoopClient) ExplainWithSDK(ctx context.Context, code string) (string, error) {
+	if !c.initialized {
+		return "", fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return "", fmt.Errorf("client is closed")
+	}
+	if c.sdkExecutor == nil {
+		return "", fmt.Errorf("SDK executor not available")
+	}
+
+	return c.sdkExecutor.Explain(ctx, code)
+}
+
+// GenerateTestsWithSDK 使用 SDK 生成測試
+func (c *RalphLoopClient) GenerateTestsWithSDK(ctx context.Context, code string) (string, error) {
+	if !c.initialized {
+		return "", fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return "", fmt.Errorf("client is closed")
+	}
+	if c.sdkExecutor == nil {
+		return "", fmt.Errorf("SDK executor not available")
+	}
+
+	return c.sdkExecutor.GenerateTests(ctx, code)
+}
+
+// CodeReviewWithSDK 使用 SDK 進行程式碼審查
+func (c *RalphLoopClient) CodeReviewWithSDK(ctx context.Context, code string) (string, error) {
+	if !c.initialized {
+		return "", fmt.Errorf("client not initialized")
+	}
+	if c.closed {
+		return "", fmt.Errorf("client is closed")
+	}
+	if c.sdkExecutor == nil {
+		return "", fmt.Errorf("SDK executor not available")
+	}
+
+	return c.sdkExecutor.CodeReview(ctx, code)
+}
+
+// GetSDKStatus 取得 SDK 執行器狀態
+func (c *RalphLoopClient) GetSDKStatus() *SDKStatus {
+	if c.sdkExecutor == nil {
+		return nil
+	}
+
+	return c.sdkExecutor.GetStatus()
+}
+
+// ListSDKSessions 列出所有 SDK 會話
+func (c *RalphLoopClient) ListSDKSessions() []*SDKSession {
+	if c.sdkExecutor == nil {
+		return nil
+	}
+
+	return c.sdkExecutor.ListSessions()
+}
+
+// GetSDKSessionCount 取得 SDK 會話數
+func (c *RalphLoopClient) GetSDKSessionCount() int {
+	if c.sdkExecutor == nil {
+		return 0
+	}
+
+	return c.sdkExecutor.GetSessionCount()
+}
+
+// TerminateSDKSession 終止特定的 SDK 會話
+func (c *RalphLoopClient) TerminateSDKSession(sessionID string) error {
+	if c.sdkExecutor == nil {
+		return fmt.Errorf("SDK executor not available")
+	}
+
+	session, err := c.sdkExecutor.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	return c.sdkExecutor.sessions.RemoveSession(session.ID)
+}
+
+// 私有輔助函式
+
+// publish 補上執行 ID 與迴圈索引後發布事件
+func (c *RalphLoopClient) publish(event LoopEvent) {
+	event.RunID = c.runID
+	event.LoopIndex = c.currentLoop
+	c.events.Publish(event)
+}
+
+// checkBreakerTransition 在熔斷器狀態改變時發布事件，打開時執行 on_breaker_open hook
+func (c *RalphLoopClient) checkBreakerTransition(ctx context.Context, execCtx *ExecutionContext) {
+	state := c.breaker.GetState()
+	if state == c.breakerState {
+		return
+	}
+
+	c.publish(LoopEvent{
+		Type:          EventBreakerStateChanged,
+		BreakerState:  string(state),
+		PreviousState: string(c.breakerState),
+	})
+	c.logger.Info("熔斷器狀態改變", "from", c.breakerState, "to", state)
+	c.metrics.SetBreakerState(state, c.breakerState)
+	c.breakerState = state
+
+	if state == StateOpen {
+		failures, _ := c.runHooks(ctx, c.hookContext(HookOnBreakerOpen, execCtx))
+		execCtx.ErrorHistory = append(execCtx.ErrorHistory, failures...)
+	}
+}
+
+// applyBreakerReset 套用透過 Controller 送入的熔斷器重置要求
+func (c *RalphLoopClient) applyBreakerReset(ctx context.Context) {
+	if !c.controller.takeBreakerReset() {
+		return
+	}
+	c.breaker.Reset()
+	c.checkBreakerTransition(ctx, nil) // 重置後為 CLOSED，不會使用迴圈上下文
+}
+
+// hookContext 由迴圈上下文建立 hook 上下文
+func (c *RalphLoopClient) hookContext(point HookPoint, execCtx *ExecutionContext) *HookContext {
+	return &HookContext{
+		Point:          point,
+		RunID:          c.runID,
+		LoopIndex:      execCtx.LoopIndex,
+		Prompt:         execCtx.UserPrompt,
+		WorkDir:        c.executor.GetWorkDir(),
+		ExitReason:     execCtx.ExitReason,
+		BreakerState:   string(c.breaker.GetState()),
+		ShouldContinue: execCtx.ShouldContinue,
+		Failed:         execCtx.Failed,
+		Changes:        SummarizeChanges(execCtx.WorkspaceChanges),
+	}
+}
+
+// runHooks 依序執行 Go hook 與符合時機的殼層 hook
+//
+// 傳回所有失敗的描述，以及第一個會阻擋迴圈的錯誤：
+// Go hook 的錯誤一律阻擋，殼層 hook 只有設定 Enforce 時才阻擋。
+func (c *RalphLoopClient) runHooks(ctx context.Context, hc *HookContext) ([]string, error) {
+	var failures []string
+	var blocking error
+
+	for _, hook := range c.config.Hooks {
+		_, span := StartSpan(ctx, "ralph.hook", SpanKindInternal)
+		span.SetAttr("hook.point", string(hc.Point))
+		start := time.Now()
+		err := callLoopHook(ctx, hook, hc)
+		span.RecordError(err)
+		span.End()
+		enforce := loopHookEnforced(hook)
+		c.recordHookResult(hc, HookResult{Enforce: enforce}, "", err, time.Since(start))
+		if err != nil {
+			c.logger.Warn("hook 失敗", "point", hc.Point, "loop", hc.LoopIndex, "enforce", enforce, "error", err)
+			c.metrics.ObserveHookFailure(hc.Point)
+			failures = append(failures, fmt.Sprintf("%s hook failed: %v", hc.Point, err))
+			if enforce && blocking == nil {
+				blocking = err
+			}
+		}
+	}
+
+	for _, hook := range c.config.ShellHooks {
+		if hook.Point != hc.Point {
+			continue
+		}
+		hookCtx, span := StartSpan(ctx, "ralph.hook", SpanKindInternal)
+		span.SetAttr("hook.point", string(hc.Point))
+		span.SetAttr("hook.command", hook.Command)
+		start := time.Now()
+		output, err := hook.Execute(hookCtx, hc)
+		span.RecordError(err)
+		span.End()
+		c.recordHookResult(hc, HookResult{Command: hook.Command, Enforce: hook.Enforce}, output, err, time.Since(start))
+		if err != nil {
+			c.logger.Warn("殼層 hook 失敗", "point", hc.Point, "loop", hc.LoopIndex, "command", hook.Command, "enforce", hook.Enforce, "error", err)
+			c.metrics.ObserveHookFailure(hc.Point)
+			failures = append(failures, fmt.Sprintf("%s hook failed: %v", hc.Point, err))
+			if hook.Enforce && blocking == nil {
+				blocking = err
+			}
+		}
+	}
+
+	return failures, blocking
+}
+
+// recordHookResult 記錄本次迴圈的 hook 結果並發布事件（事件不含輸出，避免執行紀錄過大）
+func (c *RalphLoopClient) recordHookResult(hc *HookContext, result HookResult, output string, err error, duration time.Duration) {
+	result.Point = hc.Point
+	result.OK = err == nil
+	result.DurationMs = duration.Milliseconds()
+	result.Output = output
+	if err != nil {
+		result.Error = err.Error()
+	}
+	c.loopHooks = append(c.loopHooks, result)
+
+	data := map[string]interface{}{
+		"point":       string(result.Point),
+		"enforce":     result.Enforce,
+		"ok":          result.OK,
+		"duration_ms": result.DurationMs,
+	}
+	if result.Command != "" {
+		data["command"] = result.Command
+	}
+	if result.Error != "" {
+		data["error"] = result.Error
+	}
+	c.publish(LoopEvent{Type: EventHookFinished, Data: data})
+}
+
+// runPostLoopHooks 執行 post_loop hook；被阻擋時將迴圈標記為失敗並傳回 true
+//
+// 失敗原因會注入下一次 prompt，讓 AI 修正（例如 lint 錯誤）；
+// 重複相同的失敗由熔斷器的相同錯誤檢測處理。
+func (c *RalphLoopClient) runPostLoopHooks(ctx context.Context, execCtx *ExecutionContext, shouldContinue bool) bool {
+	hc := c.hookContext(HookPostLoop, execCtx)
+	hc.ShouldContinue = shouldContinue
+
+	ctx, span := StartSpan(ctx, "ralph.hooks.post_loop", SpanKindInternal)
+	failures, blocking := c.runHooks(ctx, hc)
+	execCtx.ErrorHistory = append(execCtx.ErrorHistory, failures...)
+	span.SetAttr("hooks.failures", len(failures))
+	span.RecordError(blocking)
+	span.End()
+	if blocking == nil {
+		return false
+	}
+
+	execCtx.Failed = true
+	c.breaker.RecordSameError(blocking.Error())
+	c.queuePromptNote("上一輪結束後的 post_loop 檢查失敗，請修正：\n" + blocking.Error())
+	return true
+}
+
+// publishLoopFinished 發布迴圈結束事件
+func (c *RalphLoopClient) publishLoopFinished(ctx context.Context, execCtx *ExecutionContext) {
+	data := map[string]interface{}{
+		"duration_ms": execCtx.DurationMs,
+		"changes":     SummarizeChanges(execCtx.WorkspaceChanges),
+		"budget":      c.budgetUsage(ctx, execCtx),
+	}
+	if c.loopDiff != "" {
+		data["diff"] = c.loopDiff
+	}
+	if len(execCtx.ErrorHistory) > 0 {
+		data["errors"] = execCtx.ErrorHistory
+	}
+	if execCtx.UserFeedback != "" {
+		data["user_feedback"] = execCtx.UserFeedback
+	}
+	if approval, ok := execCtx.Metadata["approval"]; ok {
+		data["approval"] = approval
+	}
+	if traceID, ok := execCtx.Metadata["trace_id"]; ok {
+		data["trace_id"] = traceID
+	}
+
+	c.publish(LoopEvent{
+		Type:           EventLoopFinished,
+		ShouldContinue: execCtx.ShouldContinue,
+		ExitReason:     execCtx.ExitReason,
+		Failed:         execCtx.Failed,
+		Data:           data,
+	})
+}
+
+// budgetUsage 描述迴圈結束時的預算使用量（迴圈次數、整次執行的變更行數與期限）
+//
+// 未設定的上限不會出現在結果中。
+func (c *RalphLoopClient) budgetUsage(ctx context.Context, execCtx *ExecutionContext) map[string]interface{} {
+	budget := map[string]interface{}{
+		"loops_used":    execCtx.LoopIndex + 1,
+		"lines_added":   c.runChanges.LinesAdded,
+		"lines_removed": c.runChanges.LinesRemoved,
+	}
+	if maxLoops := c.controller.MaxLoops(); maxLoops > 0 {
+		budget["loops_limit"] = maxLoops
+	}
+	if scope := c.config.ChangeScope; scope != nil {
+		if scope.MaxLinesAddedPerRun > 0 {
+			budget["lines_added_limit"] = scope.MaxLinesAddedPerRun
+		}
+		if scope.MaxLinesRemovedPerRun > 0 {
+			budget["lines_removed_limit"] = scope.MaxLinesRemovedPerRun
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		budget["deadline"] = deadline
+	}
+	return budget
+}
+
+// openRunLog 在第一個迴圈開始時將日誌同時寫入 run 目錄
+func (c *RalphLoopClient) openRunLog() {
+	dir := c.RunDir()
+	if c.runLogPath != "" || dir == "" {
+		return
+	}
+
+	path := filepath.Join(dir, RunLogFileName)
+	if err := c.logRouter.AddFile(path, c.config.LogLevel, c.config.LogFormat); err != nil {
+		c.logger.Warn("無法開啟執行日誌檔", "file", path, "error", err)
+		return
+	}
+	c.runLogPath = path
+}
+
+// Metrics 取得客戶端的指標（可透過 Registry 輸出 Prometheus 格式）
+func (c *RalphLoopClient) Metrics() *LoopMetrics {
+	return c.metrics
+}
+
+// RunLogPath 取得本次執行的日誌檔路徑（尚未開啟時為空字串）
+func (c *RalphLoopClient) RunLogPath() string {
+	return c.runLogPath
+}
+
+// Logger 取得客戶端的結構化日誌（包含 run_id，並寫入 run 目錄的日誌檔）
+func (c *RalphLoopClient) Logger() *slog.Logger {
+	return c.logger
+}
+
+// startRunTrace 在第一個迴圈開始時建立本次執行的根 span，並將追蹤寫入 run 目錄
+//
+// 沒有 run 目錄時仍會建立 span，讓 trace ID 可以傳遞給 Copilot CLI 與 OTLP collector。
+func (c *RalphLoopClient) startRunTrace() {
+	if !c.config.EnableTracing || c.runSpan != nil {
+		return
+	}
+
+	if dir := c.RunDir(); dir != "" {
+		exporter, err := NewFileSpanExporter(filepath.Join(dir, TraceFileName), c.tracer.ServiceName())
+		if err != nil {
+			c.logger.Warn("無法開啟追蹤檔", "dir", dir, "error", err)
+		} else {
+			c.traceExport = exporter
+			c.tracer.AddExporter(exporter)
+		}
+	}
+
+	_, c.runSpan = c.tracer.Start(context.Background(), "ralph.run", SpanKindInternal)
+	c.runSpan.SetAttr("run.id", c.runID)
+	c.runSpan.SetAttr("run.model", c.config.Model)
+	c.runSpan.SetAttr("run.work_dir", c.config.WorkDir)
+	c.logger = c.logger.With("trace_id", c.runSpan.TraceID)
+}
+
+// endLoopSpan 結束迴圈 span 並匯出已完成的 span（每個迴圈匯出一次）
+func (c *RalphLoopClient) endLoopSpan(span *Span) {
+	if span == nil {
+		return
+	}
+	span.End()
+	if err := c.tracer.Flush(); err != nil {
+		c.logger.Warn("無法匯出追蹤資料", "error", err)
+	}
+}
+
+// TraceID 取得本次執行的 trace ID（未啟用追蹤或尚未開始時為空字串）
+func (c *RalphLoopClient) TraceID() string {
+	if c.runSpan == nil {
+		return ""
+	}
+	return c.runSpan.TraceID
+}
+
+// TracePath 取得本次執行的追蹤檔路徑（尚未開啟時為空字串）
+func (c *RalphLoopClient) TracePath() string {
+	if c.traceExport == nil {
+		return ""
+	}
+	return c.traceExport.Path()
+}
+
+// startRunRecord 在第一個迴圈開始時將目標與配置寫入 run 目錄的 run.json
+func (c *RalphLoopClient) startRunRecord(ctx context.Context, goal string) {
+	if c.runRecord != nil || c.RunDir() == "" {
+		return
+	}
+
+	c.runRecord = &RunRecord{
+		RunID:     c.runID,
+		Goal:      goal,
+		StartedAt: time.Now(),
+		Status:    RunStatusRunning,
+		Config: RunConfig{
+			WorkDir:                 c.executor.GetWorkDir(),
+			Model:                   c.config.Model,
+			MaxLoops:                c.controller.MaxLoops(),
+			CLITimeout:              c.config.CLITimeout,
+			CircuitBreakerThreshold: c.config.CircuitBreakerThreshold,
+			SameErrorThreshold:      c.config.SameErrorThreshold,
+			Permissions:             c.config.Permissions.Clone(),
+			ProtectedPaths:          c.config.ProtectedPaths,
+			ShellHooks:              c.config.ShellHooks,
+			Approval:                c.config.Approver != nil,
+			SDK:                     c.config.EnableSDK,
+			StatusContract:          c.config.StatusContract,
+		},
+	}
+	if c.config.ChangeScope.Enabled() {
+		c.runRecord.Config.ChangeScope = c.config.ChangeScope
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.runRecord.Config.Deadline = &deadline
+	}
+	c.recordChaosStats()
+	c.saveRunRecord()
+}
+
+// finishRun 在 run.json 記錄 ExecuteUntilCompletion 的結果，並原樣傳回 err
+func (c *RalphLoopClient) finishRun(status RunStatus, loops int, err error) error {
+	if c.runRecord == nil {
+		return err
+	}
+
+	finishedAt := time.Now()
+	c.runRecord.FinishedAt = &finishedAt
+	c.runRecord.Status = status
+	c.runRecord.Loops = loops
+	c.runRecord.Config.MaxLoops = c.controller.MaxLoops() // 可能在執行中被調整
+	c.recordChaosStats()
+	if err != nil {
+		c.runRecord.Error = err.Error()
+	}
+	c.saveRunRecord()
+	return err
+}
+
+// recordChaosStats 將已注入的故障記錄在 run.json
+func (c *RalphLoopClient) recordChaosStats() {
+	if c.chaos != nil {
+		stats := c.chaos.Stats()
+		c.runRecord.Config.Chaos = &stats
+	}
+}
+
+// saveRunRecord 寫入 run.json（失敗只記錄警告，不影響迴圈）
+func (c *RalphLoopClient) saveRunRecord() {
+	if err := WriteRunRecord(c.RunDir(), c.runRecord); err != nil {
+		c.logger.Warn("無法寫入執行摘要", "dir", c.RunDir(), "error", err)
+	}
+}
+
+// openJournal 在第一個迴圈開始時建立本次執行的紀錄檔
+func (c *RalphLoopClient) openJournal() {
+	dir := c.RunDir()
+	if c.journal != nil || dir == "" {
+		return
+	}
+
+	journal, err := OpenJournal(filepath.Join(dir, JournalFileName))
+	if err != nil {
+		c.logger.Warn("無法開啟執行紀錄", "dir", dir, "error", err)
+		return
+	}
+	c.journal = journal
+	c.events.Subscribe(journal.Handler())
+}
+
+// openTranscript 在 run 目錄中開啟執行器錄製檔（未啟用持久化時不錄製）
+func (c *RalphLoopClient) openTranscript() {
+	dir := c.RunDir()
+	if c.transcript != nil || dir == "" {
+		return
+	}
+
+	transcript, err := OpenTranscript(filepath.Join(dir, TranscriptFileName))
+	if err != nil {
+		c.logger.Warn("無法開啟執行器錄製檔", "dir", dir, "error", err)
+		return
+	}
+	c.transcript = transcript
+}
+
+// recordExchange 將一次執行器呼叫寫入錄製檔（result 可為 nil，例如取消時）
+func (c *RalphLoopClient) recordExchange(mode ExecutionMode, prompt string, result *ExecutionResult, err error, latency time.Duration) {
+	if c.transcript == nil {
+		return
+	}
+
+	entry := TranscriptEntry{
+		LoopIndex: c.currentLoop,
+		Mode:      mode.String(),
+		Timestamp: time.Now(),
+		Prompt:    prompt,
+		LatencyMs: latency.Milliseconds(),
+	}
+	if result != nil {
+		entry.Args = result.Args
+		entry.Command = result.Command
+		entry.Stdout = result.Stdout
+		entry.Stderr = result.Stderr
+		entry.ExitCode = result.ExitCode
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := c.transcript.Record(entry); err != nil {
+		c.logger.Warn("無法寫入執行器錄製檔", "error", err)
+	}
+}
+
+// buildLoopPrompt 將待注入的說明與狀態區塊規格附加到 prompt 後方，並清空佇列
+func (c *RalphLoopClient) buildLoopPrompt(prompt string, execCtx *ExecutionContext) string {
+	if len(c.pendingNotes) > 0 {
+		notes := c.pendingNotes
+		c.pendingNotes = nil
+		execCtx.Metadata["prompt_notes"] = notes
+		prompt += "\n\n---RALPH_LOOP_NOTES---\n" + strings.Join(notes, "\n\n") + "\n---END_NOTES---"
+	}
+	if c.config.StatusContract {
+		prompt += "\n\n" + StatusContractInstructions
+	}
+	return prompt
+}
+
+// queuePromptNote 加入一則要在下一次 prompt 注入的說明
+func (c *RalphLoopClient) queuePromptNote(note string) {
+	if note != "" {
+		c.pendingNotes = append(c.pendingNotes, note)
+	}
+}
+
+// snapshotWorkspace 在迴圈執行前建立工作目錄快照
+//
+// 沒有需要比對工作目錄的策略時傳回 nil。
+func (c *RalphLoopClient) snapshotWorkspace(execCtx *ExecutionContext) *WorkspaceSnapshot {
+	if !c.pathGuard.Enabled() && !c.config.ChangeScope.Enabled() && c.config.Approver == nil {
+		return nil
+	}
+
+	snapshot, err := TakeWorkspaceSnapshot(c.executor.GetWorkDir(), c.snapshotExcludes()...)
+	if err != nil {
+		execCtx.ErrorHistory = append(execCtx.ErrorHistory, err.Error())
+		return nil
+	}
+	return snapshot
+}
+
+// snapshotExcludes 傳回快照略過的儲存與紀錄目錄
+//
+// SaveDir 與 RunsDir 已以 WorkDir 為基準，轉為絕對路徑後才能與快照的路徑比對。
+func (c *RalphLoopClient) snapshotExcludes() []string {
+	var excludes []string
+	for _, dir := range []string{c.config.SaveDir, c.config.RunsDir} {
+		if dir == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			excludes = append(excludes, abs)
+		}
+	}
+	return excludes
+}
+
+// maxLoopDiffBytes 事件中每個迴圈 diff 的大小上限
+const maxLoopDiffBytes = 64 << 10
+
+// applyWorkspacePolicies 比對工作目錄並套用受保護路徑與變更範圍策略
+//
+// 受保護路徑的變更一律還原；超出變更範圍時依策略決定是否還原整個迴圈的變更。
+// 有任何違規時傳回 true，呼叫端應將此迴圈視為失敗。
+func (c *RalphLoopClient) applyWorkspacePolicies(execCtx *ExecutionContext, before *WorkspaceSnapshot) bool {
+	if before == nil {
+		return false
+	}
+
+	after, err := TakeWorkspaceSnapshot(before.Root(), c.snapshotExcludes()...)
+	if err != nil {
+		execCtx.ErrorHistory = append(execCtx.ErrorHistory, err.Error())
+		return false
+	}
+	changes := before.Diff(after)
+
+	// 同一迴圈同時違反兩種策略時只記錄一次違規（熔斷器以迴圈數計算）
+	var violationReason string
+
+	// 受保護路徑
+	violations, err := c.pathGuard.Enforce(before, changes)
+	if len(violations) > 0 {
+		for _, v := range violations {
+			execCtx.ErrorHistory = append(execCtx.ErrorHistory, "protected path violation: "+v.String())
+		}
+		if err != nil {
+			execCtx.ErrorHistory = append(execCtx.ErrorHistory, "protected path revert failed: "+err.Error())
+		}
+		execCtx.Metadata["protected_path_violations"] = violations
+
+		violationReason = violations[0].Path
+		c.queuePromptNote(FormatViolationNote(violations))
+		changes = withoutReverted(changes, violations)
+	}
+
+	// 變更範圍
+	problems := c.config.ChangeScope.Evaluate(changes, c.runChanges)
+	reverted := false
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			execCtx.ErrorHistory = append(execCtx.ErrorHistory, "change scope exceeded: "+problem)
+		}
+		if c.config.ChangeScope.RevertOnViolation {
+			paths := make([]string, len(changes))
+			for i, change := range changes {
+				paths[i] = change.Path
+			}
+			if err := before.Restore(paths...); err != nil {
+				execCtx.ErrorHistory = append(execCtx.ErrorHistory, "change scope revert failed: "+err.Error())
+			} else {
+				reverted = true
+			}
+		}
+		execCtx.Metadata["change_scope_violations"] = problems
+
+		if violationReason == "" {
+			violationReason = problems[0]
+		}
+		c.queuePromptNote(FormatScopeNote(problems, reverted))
+	}
+	if violationReason != "" {
+		c.breaker.RecordViolation(violationReason)
+	}
+
+	if reverted {
+		changes = nil
+	}
+	execCtx.WorkspaceChanges = changes
+	c.runChanges.Add(SummarizeChanges(changes))
+	c.loopDiff = truncateString(before.UnifiedDiff(after, changes), maxLoopDiffBytes)
+	execCtx.WorkspaceDiff = c.loopDiff
+
+	return len(violations) > 0 || len(problems) > 0
+}
+
+// requestApproval 請操作者審核本輪結果，並依決定還原變更、記錄回饋
+//
+// 決定與回饋記錄在 UserFeedback 與 Metadata["approval"]，回饋會注入下一次 prompt。
+// 審核失敗（例如 context 取消）時視為停止。傳回操作者是否要求停止。
+func (c *RalphLoopClient) requestApproval(ctx context.Context, execCtx *ExecutionContext, before *WorkspaceSnapshot, shouldContinue bool) bool {
+	req := &ApprovalRequest{
+		LoopIndex:        execCtx.LoopIndex,
+		Changes:          execCtx.WorkspaceChanges,
+		Stats:            SummarizeChanges(execCtx.WorkspaceChanges),
+		StructuredStatus: execCtx.StructuredStatus,
+		CompletionScore:  execCtx.CompletionScore,
+		ShouldContinue:   shouldContinue,
+		ExitReason:       execCtx.ExitReason,
+		Errors:           execCtx.ErrorHistory,
+	}
+
+	decision, err := c.config.Approver.Approve(ctx, req)
+	if err != nil {
+		execCtx.ErrorHistory = append(execCtx.ErrorHistory, "approval failed: "+err.Error())
+		decision = ApprovalDecision{Action: ApprovalStop}
+	}
+
+	if decision.Action == ApprovalReject {
+		execCtx.Failed = true
+		if before != nil {
+			paths := make([]string, len(execCtx.WorkspaceChanges))
+			for i, change := range execCtx.WorkspaceChanges {
+				paths[i] = change.Path
+			}
+			if err := before.Restore(paths...); err != nil {
+				execCtx.ErrorHistory = append(execCtx.ErrorHistory, "approval revert failed: "+err.Error())
+			} else {
+				decision.Reverted = true
+				c.runChanges.Sub(req.Stats)
+				execCtx.WorkspaceChanges = nil
+			}
+		}
+	}
+
+	execCtx.UserFeedback = decision.Feedback
+	execCtx.Metadata["approval"] = decision
+	c.queuePromptNote(FormatFeedbackNote(decision))
+
+	return decision.Action == ApprovalStop
+}
+
+// withoutReverted 移除已被還原的變更
+func withoutReverted(changes []FileChange, violations []PathViolation) []FileChange {
+	reverted := make(map[string]bool)
+	for _, v := range violations {
+		if v.Reverted {
+			reverted[v.Path] = true
+		}
+	}
+
+	var remaining []FileChange
+	for _, change := range changes {
+		if !reverted[change.Path] {
+			remaining = append(remaining, change)
+		}
+	}
+	return remaining
+}
+
+// failExecution 結束執行器失敗的迴圈（熔斷器已記錄錯誤）
+//
+// 迴圈標記為失敗並繼續，由熔斷器在相同錯誤達到門檻時結束執行。
+func (c *RalphLoopClient) failExecution(ctx context.Context, execCtx *ExecutionContext) *LoopResult {
+	execCtx.Failed = true
+	execCtx.ShouldContinue = true
+	c.runPostLoopHooks(ctx, execCtx, true)
+	execCtx.CircuitBreakerState = string(c.breaker.GetState())
+	return c.createResult(execCtx, true)
+}
+
+func (c *RalphLoopClient) createResult(execCtx *ExecutionContext, shouldContinue bool) *LoopResult {
+	return &LoopResult{
+		LoopID:          execCtx.LoopID,
+		LoopIndex:       execCtx.LoopIndex,
+		ShouldContinue:  shouldContinue,
+		CompletionScore: execCtx.CompletionScore,
+		Output:          execCtx.CLIOutput,
+		ExitReason:      execCtx.ExitReason,
+		Timestamp:       execCtx.Timestamp,
+	}
+}
+
+// LoopResult 表示單個迴圈的結果
+type LoopResult struct {
+	LoopID          string
+	LoopIndex       int
+	ShouldContinue  bool
+	CompletionScore int
+	Output          string
+	ExitReason      string
+	Timestamp       time.Time
+	Stopped         bool // 操作者在審核時要求停止
+}
+
+// ClientStatus 表示客戶端的當前狀態
+type ClientStatus struct {
+	Initialized         bool
+	Closed              bool
+	CircuitBreakerOpen  bool
+	CircuitBreakerState CircuitBreakerState
+	LoopsExecuted       int
+	Summary             map[string]interface{}
+}
+
+// ClientBuilder 用於建立自訂配置的客戶端
+type ClientBuilder struct {
+	config *ClientConfig
+}
+
+// NewClientBuilder 建立新的客戶端建構器
+func NewClientBuilder() *ClientBuilder {
+	return &ClientBuilder{
+		config: DefaultClientConfig(),
+	}
+}
+
+// WithTimeout 設定 CLI 執行逾時
+func (b *ClientBuilder) WithTimeout(duration time.Duration) *ClientBuilder {
+	b.config.CLITimeout = duration
+	return b
+}
+
+// WithMaxRetries 設定最大重試次數
+func (b *ClientBuilder) WithMaxRetries(count int) *ClientBuilder {
+	b.config.CLIMaxRetries = count
+	return b
+}
+
+// WithWorkDir 設定工作目錄
+func (b *ClientBuilder) WithWorkDir(dir string) *ClientBuilder {
+	b.config.WorkDir = dir
+	return b
+}
+
+// WithModel 設定 AI 模型
+func (b *ClientBuilder) WithModel(model string) *ClientBuilder {
+	b.config.Model = model
+	return b
+}
+
+// WithPermissions 設定工具與路徑權限
+func (b *ClientBuilder) WithPermissions(policy *PermissionPolicy) *ClientBuilder {
+	b.config.Permissions = policy
+	return b
+}
+
+// WithProtectedPaths 設定受保護路徑（傳入空值停用）
+func (b *ClientBuilder) WithProtectedPaths(patterns ...string) *ClientBuilder {
+	b.config.ProtectedPaths = patterns
+	return b
+}
+
+// WithChangeScope 設定變更範圍限制
+func (b *ClientBuilder) WithChangeScope(policy *ChangeScopePolicy) *ClientBuilder {
+	b.config.ChangeScope = policy
+	return b
+}
+
+// WithApprover 設定迴圈之間的人工審核
+func (b *ClientBuilder) WithApprover(approver Approver) *ClientBuilder {
+	b.config.Approver = approver
+	return b
+}
+
+// WithHook 加入 Go hook
+func (b *ClientBuilder) WithHook(hook LoopHook) *ClientBuilder {
+	b.config.Hooks = append(b.config.Hooks, hook)
+	return b
+}
+
+// WithShellHook 加入殼層 hook
+func (b *ClientBuilder) WithShellHook(hook ShellHook) *ClientBuilder {
+	b.config.ShellHooks = append(b.config.ShellHooks, hook)
+	return b
+}
+
+// WithLogger 設定元件日誌的輸出
+func (b *ClientBuilder) WithLogger(logger *slog.Logger) *ClientBuilder {
+	b.config.Logger = logger
+	return b
+}
+
+// WithRunLog 設定 run 目錄日誌檔的等級與格式
+func (b *ClientBuilder) WithRunLog(level slog.Level, format LogFormat) *ClientBuilder {
+	b.config.LogLevel = level
+	b.config.LogFormat = format
+	return b
+}
+
+// WithSaveDir 設定儲存目錄
+func (b *ClientBuilder) WithSaveDir(dir string) *ClientBuilder {
+	b.config.SaveDir = dir
+	return b
+}
+
+// WithMaxHistory 設定最大歷史記錄
+func (b *ClientBuilder) WithMaxHistory(size int) *ClientBuilder {
+	b.config.MaxHistorySize = size
+	return b
+}
+
+// WithGobFormat 啟用 Gob 格式
+func (b *ClientBuilder) WithGobFormat(enabled bool) *ClientBuilder {
+	b.config.UseGobFormat = enabled
+	return b
+}
+
+// WithoutPersistence 禁用持久化
+func (b *ClientBuilder) WithoutPersistence() *ClientBuilder {
+	b.config.EnablePersistence = false
+	return b
+}
+
+// Build 建立客戶端
+func (b *ClientBuilder) Build() *RalphLoopClient {
+	return NewRalphLoopClientWithConfig(b.config)
+}
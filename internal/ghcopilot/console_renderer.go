@@ -0,0 +1,71 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ConsoleRenderer 將串流輸出與工具呼叫即時顯示在終端機
+//
+// 迴圈進度（開始、完成）仍由 ExecuteUntilCompletion 顯示；
+// 此渲染器負責執行期間的輸出，讓使用者不必等到程序結束才看到內容。
+type ConsoleRenderer struct {
+	mu      sync.Mutex
+	out     io.Writer
+	midLine bool // 上一段 assistant 輸出尚未換行
+}
+
+// NewConsoleRenderer 建立終端機渲染器
+func NewConsoleRenderer(out io.Writer) *ConsoleRenderer {
+	return &ConsoleRenderer{out: out}
+}
+
+// Handle 處理單一事件，可直接傳給 Subscribe
+func (r *ConsoleRenderer) Handle(event LoopEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch event.Type {
+	case EventOutputChunk:
+		switch event.Stream {
+		case StreamAssistant:
+			// SDK 的 delta 不一定以換行結尾，原樣輸出
+			fmt.Fprint(r.out, event.Text)
+			r.midLine = !strings.HasSuffix(event.Text, "\n")
+		case StreamStderr:
+			r.endLine()
+			fmt.Fprintf(r.out, "  ! %s\n", event.Text)
+		default:
+			r.endLine()
+			fmt.Fprintf(r.out, "  │ %s\n", event.Text)
+		}
+
+	case EventToolCall:
+		r.endLine()
+		fmt.Fprintf(r.out, "  🔧 %s\n", event.Tool)
+
+	case EventPermissionDecision:
+		var decision PermissionDecision
+		if decodeEventData(event.Data, "decision", &decision) && !decision.Allowed {
+			r.endLine()
+			fmt.Fprintf(r.out, "  ⛔ 拒絕 %s: %s (%s)\n", event.Tool, truncateString(decision.Request.Target(), 80), decision.Reason)
+		}
+
+	case EventBreakerStateChanged:
+		r.endLine()
+		fmt.Fprintf(r.out, "⚡ 熔斷器: %s → %s\n", event.PreviousState, event.BreakerState)
+
+	case EventLoopFinished:
+		r.endLine()
+	}
+}
+
+// endLine 在 assistant 輸出未換行時補上換行
+func (r *ConsoleRenderer) endLine() {
+	if r.midLine {
+		fmt.Fprintln(r.out)
+		r.midLine = false
+	}
+}
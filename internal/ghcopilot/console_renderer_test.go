@@ -0,0 +1,25 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestConsoleRenderer 測試終端機渲染
+func TestConsoleRenderer(t *testing.T) {
+	var out bytes.Buffer
+	renderer := NewConsoleRenderer(&out)
+
+	renderer.Handle(LoopEvent{Type: EventLoopStarted})
+	renderer.Handle(LoopEvent{Type: EventOutputChunk, Stream: StreamStdout, Text: "building"})
+	renderer.Handle(LoopEvent{Type: EventOutputChunk, Stream: StreamAssistant, Text: "Hel"})
+	renderer.Handle(LoopEvent{Type: EventOutputChunk, Stream: StreamAssistant, Text: "lo"})
+	renderer.Handle(LoopEvent{Type: EventToolCall, Tool: "shell"})
+	renderer.Handle(LoopEvent{Type: EventOutputChunk, Stream: StreamStderr, Text: "oops"})
+	renderer.Handle(LoopEvent{Type: EventBreakerStateChanged, PreviousState: "CLOSED", BreakerState: "OPEN"})
+
+	want := "  │ building\nHello\n  🔧 shell\n  ! oops\n⚡ 熔斷器: CLOSED → OPEN\n"
+	if out.String() != want {
+		t.Errorf("輸出應為:\n%q\n但為:\n%q", want, out.String())
+	}
+}
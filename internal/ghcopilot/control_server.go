@@ -0,0 +1,220 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ControlSocketName 控制通道在 .ralph-loop 目錄中的檔名
+const ControlSocketName = "control.sock"
+
+// ControlCommands 支援的控制指令
+var ControlCommands = []string{"status", "pause", "resume", "stop", "max-loops", "feedback", "reset-breaker"}
+
+// ControlRequest 是透過控制通道送出的指令
+type ControlRequest struct {
+	Command string `json:"command"`
+	Arg     string `json:"arg,omitempty"`
+}
+
+// ControlResponse 是控制通道的回應
+type ControlResponse struct {
+	OK    bool         `json:"ok"`
+	Error string       `json:"error,omitempty"`
+	State ControlState `json:"state"`
+}
+
+// ControlSocketPath 傳回工作目錄對應的控制通道路徑
+func ControlSocketPath(workDir string) string {
+	return filepath.Join(workDir, ".ralph-loop", ControlSocketName)
+}
+
+// ControlServer 在 Unix domain socket 上接受控制指令
+//
+// 協定為每個連線一行 JSON 請求、一行 JSON 回應。
+type ControlServer struct {
+	path       string
+	listener   net.Listener
+	controller *LoopController
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+// NewControlServer 建立並啟動控制通道
+//
+// 如果 socket 檔已存在但沒有程序在監聽（上次執行異常結束），會先移除；
+// 如果另一個執行仍在使用，傳回錯誤。
+func NewControlServer(path string, controller *LoopController) (*ControlServer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("無法建立控制通道目錄: %w", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("控制通道 %s 已被另一個執行使用", path)
+		}
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("無法建立控制通道: %w", err)
+	}
+
+	server := &ControlServer{
+		path:       path,
+		listener:   listener,
+		controller: controller,
+	}
+	server.wg.Add(1)
+	go server.serve()
+	return server, nil
+}
+
+// Path 傳回 socket 路徑
+func (s *ControlServer) Path() string {
+	return s.path
+}
+
+// Close 停止控制通道並移除 socket 檔
+func (s *ControlServer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.listener.Close()
+		s.wg.Wait()
+		os.Remove(s.path)
+	})
+	return err
+}
+
+// serve 接受連線直到 listener 關閉
+func (s *ControlServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(conn)
+	}
+}
+
+// handle 處理單一連線的請求
+func (s *ControlServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var resp ControlResponse
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		resp.Error = fmt.Sprintf("讀取請求失敗: %v", err)
+	} else {
+		var req ControlRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = fmt.Sprintf("無效的請求: %v", err)
+		} else if err := ApplyControlRequest(s.controller, req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.OK = true
+		}
+	}
+	resp.State = s.controller.State()
+
+	data, _ := json.Marshal(resp)
+	conn.Write(append(data, '\n'))
+}
+
+// ApplyControlRequest 將控制指令套用到控制器（控制通道與 HTTP API 共用）
+func ApplyControlRequest(controller *LoopController, req ControlRequest) error {
+	switch req.Command {
+	case "status":
+	case "pause":
+		controller.Pause()
+	case "resume":
+		controller.Resume()
+	case "stop":
+		controller.RequestStop()
+	case "max-loops":
+		n, err := strconv.Atoi(req.Arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("max-loops 需要正整數，但為 %q", req.Arg)
+		}
+		controller.SetMaxLoops(n)
+	case "feedback":
+		if req.Arg == "" {
+			return fmt.Errorf("feedback 需要回饋內容")
+		}
+		controller.InjectFeedback(req.Arg)
+	case "reset-breaker":
+		controller.RequestBreakerReset()
+	default:
+		return fmt.Errorf("未知的控制指令: %s", req.Command)
+	}
+	return nil
+}
+
+// ControlFunc 送出控制指令並傳回套用後的控制狀態
+//
+// 讓同一份介面（例如終端機 UI 的按鍵）可以控制本機的迴圈或透過控制通道控制另一個程序。
+type ControlFunc func(req ControlRequest) (ControlState, error)
+
+// LocalControl 傳回直接控制本機控制器的 ControlFunc
+func LocalControl(controller *LoopController) ControlFunc {
+	return func(req ControlRequest) (ControlState, error) {
+		err := ApplyControlRequest(controller, req)
+		return controller.State(), err
+	}
+}
+
+// SocketControl 傳回透過控制通道送出指令的 ControlFunc
+func SocketControl(path string) ControlFunc {
+	return func(req ControlRequest) (ControlState, error) {
+		resp, err := SendControlCommand(path, req)
+		if err != nil {
+			return ControlState{}, err
+		}
+		if !resp.OK {
+			return resp.State, errors.New(resp.Error)
+		}
+		return resp.State, nil
+	}
+}
+
+// SendControlCommand 連線到控制通道並送出指令
+func SendControlCommand(path string, req ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("無法連線到控制通道 %s（是否有執行中的 run？）: %w", path, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("送出控制指令失敗: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("讀取控制回應失敗: %w", err)
+	}
+
+	var resp ControlResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("無效的控制回應: %w", err)
+	}
+	return &resp, nil
+}
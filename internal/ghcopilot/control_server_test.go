@@ -0,0 +1,88 @@
+package ghcopilot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestControlServerCommands 測試透過 socket 送出控制指令
+func TestControlServerCommands(t *testing.T) {
+	path := ControlSocketPath(t.TempDir())
+	controller := NewLoopController()
+	controller.begin(10)
+
+	server, err := NewControlServer(path, controller)
+	if err != nil {
+		t.Skipf("此環境不支援 Unix domain socket: %v", err)
+	}
+	defer server.Close()
+
+	steps := []struct {
+		req ControlRequest
+		ok  bool
+	}{
+		{ControlRequest{Command: "pause"}, true},
+		{ControlRequest{Command: "max-loops", Arg: "3"}, true},
+		{ControlRequest{Command: "max-loops", Arg: "abc"}, false},
+		{ControlRequest{Command: "feedback", Arg: "先修測試"}, true},
+		{ControlRequest{Command: "feedback"}, false},
+		{ControlRequest{Command: "reset-breaker"}, true},
+		{ControlRequest{Command: "stop"}, true},
+		{ControlRequest{Command: "explode"}, false},
+	}
+	for _, step := range steps {
+		resp, err := SendControlCommand(path, step.req)
+		if err != nil {
+			t.Fatalf("送出 %s 失敗: %v", step.req.Command, err)
+		}
+		if resp.OK != step.ok {
+			t.Errorf("%s 的結果應為 %v，但為 %+v", step.req.Command, step.ok, resp)
+		}
+	}
+
+	resp, err := SendControlCommand(path, ControlRequest{Command: "status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ControlState{Paused: true, StopRequested: true, MaxLoops: 3, PendingFeedback: 1, BreakerReset: true}
+	if resp.State != want {
+		t.Errorf("狀態應為 %+v，但為 %+v", want, resp.State)
+	}
+}
+
+// TestControlServerSocketLifecycle 測試 socket 檔的建立、佔用與清除
+func TestControlServerSocketLifecycle(t *testing.T) {
+	path := ControlSocketPath(t.TempDir())
+
+	server, err := NewControlServer(path, NewLoopController())
+	if err != nil {
+		t.Skipf("此環境不支援 Unix domain socket: %v", err)
+	}
+
+	if _, err := NewControlServer(path, NewLoopController()); err == nil {
+		t.Error("另一個執行使用中時應傳回錯誤")
+	}
+
+	server.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("關閉後應移除 socket 檔")
+	}
+	if _, err := SendControlCommand(path, ControlRequest{Command: "status"}); err == nil {
+		t.Error("沒有執行中的 run 時應傳回錯誤")
+	}
+
+	// 殘留的 socket 檔應被取代
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	server, err = NewControlServer(path, NewLoopController())
+	if err != nil {
+		t.Fatalf("應取代殘留的 socket 檔: %v", err)
+	}
+	server.Close()
+
+	if filepath.Base(path) != ControlSocketName {
+		t.Errorf("socket 檔名不正確: %s", path)
+	}
+}
@@ -0,0 +1,239 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed dashboard
+var dashboardAssets embed.FS
+
+// DefaultDashboardPollInterval 儀表板檢查執行紀錄新內容的間隔
+const DefaultDashboardPollInterval = 500 * time.Millisecond
+
+// DashboardServer 提供內嵌的網頁儀表板（ralph-loop watch -http）
+//
+// 資料來源是 RunsDir 中各 run 的 journal.jsonl，不需要連線到執行中的程序，
+// 所有資源都內嵌在執行檔中，可完全離線使用。端點：
+//
+//	GET /                               儀表板頁面
+//	GET /api/runs                       列出執行（最新的在前）
+//	GET /api/runs/{id}/events           以 Server-Sent Events 串流執行紀錄（id 可為 latest）
+//
+// 事件的 id 為其在執行紀錄中的行號，重新連線時以 Last-Event-ID 接續。
+type DashboardServer struct {
+	runsDir      string
+	pollInterval time.Duration
+	logger       *slog.Logger
+	mux          *http.ServeMux
+
+	server   *http.Server
+	listener net.Listener
+	serveErr chan error
+
+	closing   chan struct{} // 關閉時通知事件串流結束
+	closeOnce sync.Once
+}
+
+// NewDashboardServer 建立讀取 runsDir 的儀表板
+func NewDashboardServer(runsDir string) *DashboardServer {
+	s := &DashboardServer{
+		runsDir:      runsDir,
+		pollInterval: DefaultDashboardPollInterval,
+		logger:       defaultLogger(),
+		mux:          http.NewServeMux(),
+		closing:      make(chan struct{}),
+	}
+
+	assets, _ := fs.Sub(dashboardAssets, "dashboard")
+	s.mux.Handle("GET /", http.FileServerFS(assets))
+	s.mux.HandleFunc("GET /api/runs", s.handleListRuns)
+	s.mux.HandleFunc("GET /api/runs/{id}/events", s.handleRunEvents)
+	return s
+}
+
+// SetLogger 設定日誌輸出（nil 表示不輸出）
+func (s *DashboardServer) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	s.logger = logger
+}
+
+// SetPollInterval 設定檢查執行紀錄新內容的間隔
+func (s *DashboardServer) SetPollInterval(interval time.Duration) {
+	if interval > 0 {
+		s.pollInterval = interval
+	}
+}
+
+// Handler 傳回 HTTP handler（可掛載到其他服務或用於測試）
+func (s *DashboardServer) Handler() http.Handler {
+	return s.mux
+}
+
+// Listen 在 addr（例如 ":8080"）上開始服務
+func (s *DashboardServer) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("無法監聽儀表板位址: %w", err)
+	}
+
+	s.listener = listener
+	s.server = &http.Server{Handler: s.mux, ReadHeaderTimeout: 5 * time.Second}
+	s.serveErr = make(chan error, 1)
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.serveErr <- err
+		}
+		close(s.serveErr)
+	}()
+	return nil
+}
+
+// Addr 傳回實際監聽的位址
+func (s *DashboardServer) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown 結束事件串流並關閉服務
+func (s *DashboardServer) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Shutdown(ctx)
+	if serveErr := <-s.serveErr; serveErr != nil && err == nil {
+		err = serveErr
+	}
+	return err
+}
+
+// ListRuns 列出 runsDir 中有執行紀錄的 run（最新的在前）
+func (s *DashboardServer) ListRuns() ([]JournalRun, error) {
+	return ListJournalRuns(s.runsDir)
+}
+
+func (s *DashboardServer) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.ListRuns()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// resolveRun 將路徑中的 run ID（或 latest）轉為執行紀錄路徑
+func (s *DashboardServer) resolveRun(id string) (string, string, error) {
+	if id == "latest" {
+		runs, err := s.ListRuns()
+		if err != nil {
+			return "", "", err
+		}
+		if len(runs) == 0 {
+			return "", "", fmt.Errorf("%s 中沒有執行紀錄", s.runsDir)
+		}
+		id = runs[0].ID
+	}
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", "", fmt.Errorf("無效的 run ID %q", id)
+	}
+
+	path := filepath.Join(s.runsDir, id, JournalFileName)
+	if _, err := os.Stat(path); err != nil {
+		return "", "", fmt.Errorf("找不到 run %s 的執行紀錄", id)
+	}
+	return id, path, nil
+}
+
+// handleRunEvents 以 Server-Sent Events 串流執行紀錄，並持續推送新寫入的事件
+//
+// 第一個事件 "run" 的內容為解析後的 run ID（請求 latest 時可得知實際的 run）。
+func (s *DashboardServer) handleRunEvents(w http.ResponseWriter, r *http.Request) {
+	id, path, err := s.resolveRun(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	skip := 0
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		n, err := strconv.Atoi(lastID)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid event id %q", lastID))
+			return
+		}
+		skip = n
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "event: run\ndata: %q\n\n", id)
+	flusher.Flush()
+
+	tail := &journalTail{reader: bufio.NewReaderSize(file, 64*1024)}
+	poll := time.NewTicker(s.pollInterval)
+	defer poll.Stop()
+	lastWrite := time.Now()
+
+	for {
+		lines, err := tail.next()
+		if err != nil {
+			s.logger.Warn("讀取執行紀錄失敗", "file", path, "error", err)
+			return
+		}
+		for _, line := range lines {
+			if line.number <= skip {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", line.number, line.eventType(), line.data)
+		}
+		if len(lines) > 0 {
+			lastWrite = time.Now()
+			flusher.Flush()
+		} else if time.Since(lastWrite) > 15*time.Second {
+			lastWrite = time.Now()
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+
+		select {
+		case <-poll.C:
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
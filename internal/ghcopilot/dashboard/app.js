@@ -0,0 +1,446 @@
+// Ralph Loop 儀表板：以 Server-Sent Events 讀取執行紀錄並即時更新畫面。
+// 不依賴任何外部資源，可完全離線使用。
+"use strict";
+
+const EVENT_TYPES = [
+  "loop_started",
+  "output_chunk",
+  "tool_call",
+  "loop_analyzed",
+  "breaker_state_changed",
+  "hook_finished",
+  "loop_finished",
+];
+const MAX_OUTPUT_CHARS = 200000;
+const RUN_POLL_MS = 5000;
+
+let state = newState(null);
+let source = null;
+let renderPending = false;
+
+function newState(runId) {
+  return {
+    runId: runId,
+    loops: new Map(),
+    breakerState: "CLOSED",
+    breakerHistory: [],
+    budget: null,
+    changes: { files_changed: 0, lines_added: 0, lines_removed: 0 },
+    selected: null,
+    follow: true, // 自動選擇最新的迴圈
+  };
+}
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === "class") node.className = value;
+    else if (key === "onclick") node.addEventListener("click", value);
+    else node.setAttribute(key, value);
+  }
+  for (const child of children) {
+    if (child == null) continue;
+    node.append(child instanceof Node ? child : String(child));
+  }
+  return node;
+}
+
+function formatTime(ts) {
+  return ts ? new Date(ts).toLocaleTimeString() : "";
+}
+
+function formatDuration(ms) {
+  if (ms == null) return "—";
+  if (ms < 1000) return ms + " ms";
+  const s = Math.round(ms / 1000);
+  return s < 60 ? s + " s" : Math.floor(s / 60) + " m " + (s % 60) + " s";
+}
+
+// ---- 事件處理 ----
+
+function loopFor(event) {
+  let loop = state.loops.get(event.loop_index);
+  if (!loop) {
+    loop = {
+      index: event.loop_index,
+      startedAt: event.timestamp,
+      prompt: "",
+      output: [],
+      outputSize: 0,
+      tools: [],
+      hooks: [],
+      analysis: null,
+      finished: null,
+    };
+    state.loops.set(event.loop_index, loop);
+  }
+  return loop;
+}
+
+function handleEvent(type, event) {
+  const data = event.data || {};
+  switch (type) {
+    case "loop_started": {
+      const loop = loopFor(event);
+      loop.startedAt = event.timestamp;
+      loop.prompt = event.text || "";
+      if (state.follow) state.selected = loop.index;
+      break;
+    }
+    case "output_chunk": {
+      const loop = loopFor(event);
+      if (loop.outputSize >= MAX_OUTPUT_CHARS) break;
+      const text = event.stream === "assistant" ? event.text || "" : (event.text || "") + "\n";
+      const last = loop.output[loop.output.length - 1];
+      if (last && last.stream === event.stream) last.text += text;
+      else loop.output.push({ stream: event.stream, text: text });
+      loop.outputSize += text.length;
+      break;
+    }
+    case "tool_call":
+      loopFor(event).tools.push(event.tool);
+      break;
+    case "loop_analyzed":
+      loopFor(event).analysis = {
+        score: data.completion_score,
+        status: data.structured_status,
+        shouldContinue: event.should_continue,
+        exitReason: event.exit_reason,
+      };
+      break;
+    case "hook_finished":
+      loopFor(event).hooks.push(data);
+      break;
+    case "breaker_state_changed":
+      state.breakerState = event.breaker_state;
+      state.breakerHistory.push({
+        time: event.timestamp,
+        loop: event.loop_index,
+        from: event.previous_state,
+        to: event.breaker_state,
+      });
+      break;
+    case "loop_finished": {
+      const loop = loopFor(event);
+      loop.finished = {
+        time: event.timestamp,
+        failed: event.failed,
+        shouldContinue: event.should_continue,
+        exitReason: event.exit_reason,
+        durationMs: data.duration_ms,
+        changes: data.changes,
+        diff: data.diff || "",
+        errors: data.errors || [],
+        approval: data.approval,
+        userFeedback: data.user_feedback,
+        traceId: data.trace_id,
+      };
+      if (data.budget) state.budget = data.budget;
+      if (data.changes && !event.failed) {
+        state.changes.files_changed += data.changes.files_changed || 0;
+        state.changes.lines_added += data.changes.lines_added || 0;
+        state.changes.lines_removed += data.changes.lines_removed || 0;
+      }
+      break;
+    }
+  }
+  scheduleRender();
+}
+
+function outcome(loop) {
+  const f = loop.finished;
+  if (!f) return "running";
+  if (f.failed) return "failed";
+  if (f.exitReason === "stopped by operator") return "stopped";
+  return f.shouldContinue ? "continue" : "complete";
+}
+
+// ---- 連線 ----
+
+function connect(runId) {
+  if (source) source.close();
+  state = newState(runId === "latest" ? null : runId);
+  setConnection(false, "連線中…");
+  scheduleRender();
+
+  source = new EventSource("api/runs/" + encodeURIComponent(runId) + "/events");
+  source.addEventListener("run", (e) => {
+    const id = JSON.parse(e.data);
+    if (state.runId && state.runId !== id) {
+      // 重新連線時 latest 已指向另一個 run：重新載入
+      state = newState(id);
+    }
+    state.runId = id;
+    setConnection(true, "即時");
+    scheduleRender();
+  });
+  for (const type of EVENT_TYPES) {
+    source.addEventListener(type, (e) => handleEvent(type, JSON.parse(e.data)));
+  }
+  source.onerror = () => setConnection(false, "重新連線中…");
+}
+
+function setConnection(live, text) {
+  const badge = $("connection");
+  badge.textContent = text;
+  badge.classList.toggle("live", live);
+}
+
+async function refreshRuns() {
+  let runs;
+  try {
+    const resp = await fetch("api/runs");
+    runs = await resp.json();
+  } catch (err) {
+    return;
+  }
+
+  const select = $("run-select");
+  const current = select.value;
+  select.replaceChildren(el("option", { value: "latest" }, "最新（自動跟隨）"));
+  for (const run of runs) {
+    select.append(el("option", { value: run.id }, run.id + "（" + new Date(run.updated_at).toLocaleString() + "）"));
+  }
+  select.value = current;
+
+  // 跟隨最新的 run：出現新的 run 時切換
+  if (current === "latest" && runs.length > 0 && state.runId && runs[0].id !== state.runId) {
+    connect("latest");
+  }
+  if (current === "latest" && runs.length > 0 && !source) {
+    connect("latest");
+  }
+}
+
+// ---- 畫面 ----
+
+function scheduleRender() {
+  if (renderPending) return;
+  renderPending = true;
+  requestAnimationFrame(() => {
+    renderPending = false;
+    render();
+  });
+}
+
+function render() {
+  $("run-id").textContent = state.runId || "—";
+  const breaker = $("breaker");
+  breaker.textContent = state.breakerState;
+  breaker.className = "value state-" + state.breakerState;
+  $("loop-count").textContent = state.loops.size;
+  const c = state.changes;
+  $("change-total").textContent = c.files_changed + " 檔  +" + c.lines_added + " −" + c.lines_removed;
+
+  renderTimeline();
+  renderScores();
+  renderBudget();
+  renderBreakerHistory();
+  renderDetail();
+}
+
+function sortedLoops() {
+  return [...state.loops.values()].sort((a, b) => a.index - b.index);
+}
+
+function renderTimeline() {
+  const timeline = $("timeline");
+  const loops = sortedLoops();
+  if (loops.length === 0) {
+    timeline.replaceChildren(el("p", { class: "empty" }, "尚無迴圈"));
+    return;
+  }
+  timeline.replaceChildren(
+    ...loops.map((loop) => {
+      const kind = outcome(loop);
+      const title = "#" + loop.index + " " + kind + (loop.finished ? " · " + formatDuration(loop.finished.durationMs) : "");
+      return el(
+        "button",
+        {
+          class: "loop " + kind + (loop.index === state.selected ? " selected" : ""),
+          title: title,
+          onclick: () => {
+            state.selected = loop.index;
+            // 選擇最新的迴圈時恢復自動跟隨
+            state.follow = loop.index === loops[loops.length - 1].index;
+            render();
+          },
+        },
+        loop.index,
+      );
+    }),
+  );
+}
+
+function renderScores() {
+  const svg = $("scores");
+  const points = sortedLoops()
+    .filter((loop) => loop.analysis && typeof loop.analysis.score === "number")
+    .map((loop) => ({ index: loop.index, score: loop.analysis.score }));
+  const ns = "http://www.w3.org/2000/svg";
+  const children = [];
+  for (const y of [25, 50, 75]) {
+    const line = document.createElementNS(ns, "line");
+    line.setAttribute("class", "grid");
+    line.setAttribute("x1", 0);
+    line.setAttribute("x2", 300);
+    line.setAttribute("y1", y);
+    line.setAttribute("y2", y);
+    children.push(line);
+  }
+  if (points.length > 0) {
+    const maxScore = Math.max(100, ...points.map((p) => p.score));
+    const step = points.length > 1 ? 300 / (points.length - 1) : 0;
+    const coords = points.map((p, i) => [points.length > 1 ? i * step : 150, 100 - (p.score / maxScore) * 95]);
+    const path = document.createElementNS(ns, "polyline");
+    path.setAttribute("class", "line");
+    path.setAttribute("points", coords.map((c) => c.join(",")).join(" "));
+    children.push(path);
+    coords.forEach(([x, y], i) => {
+      const dot = document.createElementNS(ns, "circle");
+      dot.setAttribute("class", "dot");
+      dot.setAttribute("cx", x);
+      dot.setAttribute("cy", y);
+      dot.setAttribute("r", 2.5);
+      const title = document.createElementNS(ns, "title");
+      title.textContent = "#" + points[i].index + ": " + points[i].score;
+      dot.append(title);
+      children.push(dot);
+    });
+  }
+  svg.replaceChildren(...children);
+}
+
+function bar(label, used, limit, text) {
+  const ratio = limit > 0 ? used / limit : 0;
+  const fill = el("div", { class: "fill" + (ratio >= 1 ? " over" : ratio >= 0.8 ? " warn" : "") });
+  fill.style.width = Math.min(100, ratio * 100) + "%";
+  return el(
+    "div",
+    { class: "bar" },
+    el("div", { class: "label" }, el("span", {}, label), el("span", {}, text || used + " / " + limit)),
+    el("div", { class: "track" }, fill),
+  );
+}
+
+function renderBudget() {
+  const budget = state.budget;
+  const container = $("budget");
+  if (!budget) {
+    container.replaceChildren(el("p", { class: "empty" }, "尚無資料"));
+    return;
+  }
+
+  const bars = [];
+  if (budget.loops_limit) bars.push(bar("迴圈", budget.loops_used, budget.loops_limit));
+  else bars.push(el("p", {}, "迴圈：" + budget.loops_used + "（無上限）"));
+  if (budget.lines_added_limit) bars.push(bar("新增行數", budget.lines_added, budget.lines_added_limit));
+  if (budget.lines_removed_limit) bars.push(bar("刪除行數", budget.lines_removed, budget.lines_removed_limit));
+  if (budget.deadline) {
+    const first = sortedLoops()[0];
+    const start = first ? new Date(first.startedAt).getTime() : Date.now();
+    const end = new Date(budget.deadline).getTime();
+    const remaining = Math.max(0, end - Date.now());
+    bars.push(bar("時間", Date.now() - start, end - start, "剩餘 " + formatDuration(remaining)));
+  }
+  container.replaceChildren(...bars);
+}
+
+function renderBreakerHistory() {
+  const list = $("breaker-history");
+  if (state.breakerHistory.length === 0) {
+    list.replaceChildren(el("li", { class: "empty" }, "尚無狀態變化"));
+    return;
+  }
+  list.replaceChildren(
+    ...state.breakerHistory.map((h) =>
+      el(
+        "li",
+        {},
+        el("span", { class: "time" }, formatTime(h.time)),
+        "#" + h.loop + " ",
+        el("span", { class: "state-" + h.from }, h.from || "—"),
+        " → ",
+        el("span", { class: "state-" + h.to }, h.to),
+      ),
+    ),
+  );
+}
+
+function renderDetail() {
+  const loop = state.loops.get(state.selected);
+  $("detail-empty").hidden = !!loop;
+  $("detail-body").hidden = !loop;
+  if (!loop) {
+    $("detail-title").textContent = "迴圈詳情";
+    return;
+  }
+  $("detail-title").textContent = "迴圈 #" + loop.index + "（" + outcome(loop) + "）";
+
+  const f = loop.finished || {};
+  const a = loop.analysis || {};
+  const facts = [
+    ["開始", formatTime(loop.startedAt)],
+    ["耗時", loop.finished ? formatDuration(f.durationMs) : "執行中"],
+    ["完成分數", a.score != null ? a.score : "—"],
+    ["結構化狀態", a.status ? a.status.status + (a.status.tasks_done ? "（" + a.status.tasks_done + "）" : "") + (a.status.exit_signal ? " · EXIT" : "") : "—"],
+    ["退出理由", f.exitReason || a.exitReason || "—"],
+    ["變更", f.changes ? f.changes.files_changed + " 檔  +" + f.changes.lines_added + " −" + f.changes.lines_removed : "—"],
+  ];
+  if (f.approval) facts.push(["審核", f.approval.action || JSON.stringify(f.approval)]);
+  if (f.userFeedback) facts.push(["回饋", f.userFeedback]);
+  if (f.traceId) facts.push(["Trace ID", f.traceId]);
+  $("detail-facts").replaceChildren(...facts.flatMap(([k, v]) => [el("dt", {}, k), el("dd", {}, v)]));
+
+  const hooks = $("detail-hooks");
+  if (loop.hooks.length === 0) {
+    hooks.replaceChildren(el("tr", {}, el("td", { class: "empty" }, "沒有 hook")));
+  } else {
+    hooks.replaceChildren(
+      el("tr", {}, el("th", {}, "時機"), el("th", {}, "命令"), el("th", {}, "結果"), el("th", {}, "耗時")),
+      ...loop.hooks.map((h) =>
+        el(
+          "tr",
+          {},
+          el("td", {}, h.point),
+          el("td", {}, h.command || "(Go hook)", h.enforce ? " [enforce]" : ""),
+          el("td", { class: h.ok ? "ok" : "fail" }, h.ok ? "通過" : h.error || "失敗"),
+          el("td", {}, formatDuration(h.duration_ms)),
+        ),
+      ),
+    );
+  }
+
+  const errors = f.errors || [];
+  $("detail-errors").replaceChildren(...(errors.length ? errors.map((e) => el("li", {}, e)) : [el("li", { class: "empty" }, "無")]));
+
+  $("detail-diff").replaceChildren(...renderDiff(f.diff));
+  $("detail-tools").replaceChildren(...(loop.tools.length ? loop.tools.map((t) => el("li", {}, t)) : [el("li", { class: "empty" }, "無")]));
+  $("detail-output").replaceChildren(...loop.output.map((chunk) => el("span", { class: chunk.stream }, chunk.text)));
+  $("detail-prompt").textContent = loop.prompt;
+}
+
+function renderDiff(diff) {
+  if (!diff) return [el("span", { class: "empty" }, loopDiffPlaceholder())];
+  return diff.split("\n").map((line) => {
+    let cls = "";
+    if (line.startsWith("+++") || line.startsWith("---")) cls = "file";
+    else if (line.startsWith("@@")) cls = "hunk";
+    else if (line.startsWith("+")) cls = "add";
+    else if (line.startsWith("-")) cls = "del";
+    return el("span", { class: cls }, line + "\n");
+  });
+}
+
+function loopDiffPlaceholder() {
+  const loop = state.loops.get(state.selected);
+  return loop && !loop.finished ? "迴圈結束後顯示" : "沒有保留的變更";
+}
+
+// ---- 啟動 ----
+
+$("run-select").addEventListener("change", (e) => connect(e.target.value));
+refreshRuns();
+setInterval(refreshRuns, RUN_POLL_MS);
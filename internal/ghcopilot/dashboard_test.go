@@ -0,0 +1,236 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestJournal 在 runsDir 中建立 run 的執行紀錄
+func writeTestJournal(t *testing.T, runsDir, runID string, events ...LoopEvent) string {
+	t.Helper()
+	journal, err := OpenJournal(filepath.Join(runsDir, runID, JournalFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	for _, event := range events {
+		if err := journal.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return journal.Path()
+}
+
+// sseEvent 是解析後的 Server-Sent Event
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readSSEEvents 從串流讀取 n 個事件
+func readSSEEvents(t *testing.T, reader *bufio.Reader, n int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	for len(events) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("讀取事件串流失敗（已讀取 %d 個）: %v", len(events), err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if current.event != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return events
+}
+
+// newTestDashboard 建立讀取 runsDir 的儀表板服務
+func newTestDashboard(t *testing.T, runsDir string) *httptest.Server {
+	t.Helper()
+	dashboard := NewDashboardServer(runsDir)
+	dashboard.SetLogger(nil)
+	dashboard.SetPollInterval(10 * time.Millisecond)
+	server := httptest.NewServer(dashboard.Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestDashboardAssets 測試內嵌的網頁資源
+func TestDashboardAssets(t *testing.T) {
+	server := newTestDashboard(t, t.TempDir())
+
+	for path, want := range map[string]string{
+		"/":          "<title>Ralph Loop",
+		"/app.js":    "EventSource",
+		"/style.css": ".timeline",
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("%s 應回傳包含 %q 的內容: %d", path, want, resp.StatusCode)
+		}
+	}
+}
+
+// TestDashboardListRuns 測試列出執行（最新的在前）
+func TestDashboardListRuns(t *testing.T) {
+	runsDir := filepath.Join(t.TempDir(), "runs")
+	server := newTestDashboard(t, runsDir)
+
+	var runs []JournalRun
+	apiRequest(t, "GET", server.URL+"/api/runs", "", nil, &runs)
+	if runs == nil || len(runs) != 0 {
+		t.Errorf("目錄不存在時應回傳空列表: %v", runs)
+	}
+
+	writeTestJournal(t, runsDir, "run-20260101-090000-0001", LoopEvent{Type: EventLoopStarted})
+	writeTestJournal(t, runsDir, "run-20260102-090000-0002", LoopEvent{Type: EventLoopStarted})
+	os.MkdirAll(filepath.Join(runsDir, "run-empty"), 0755)
+
+	apiRequest(t, "GET", server.URL+"/api/runs", "", nil, &runs)
+	if len(runs) != 2 || runs[0].ID != "run-20260102-090000-0002" || runs[0].Size == 0 {
+		t.Errorf("應只列出有執行紀錄的 run，最新的在前: %+v", runs)
+	}
+}
+
+// TestDashboardEventStream 測試串流既有事件並推送新寫入的事件
+func TestDashboardEventStream(t *testing.T) {
+	runsDir := t.TempDir()
+	writeTestJournal(t, runsDir, "run-20260101-090000-0001", LoopEvent{Type: EventLoopStarted})
+	path := writeTestJournal(t, runsDir, "run-20260102-090000-0002",
+		LoopEvent{Type: EventLoopStarted, Text: "任務"},
+		LoopEvent{Type: EventOutputChunk, Stream: StreamStdout, Text: "working"},
+	)
+	server := newTestDashboard(t, runsDir)
+
+	resp, err := http.Get(server.URL + "/api/runs/latest/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("應為 SSE: %s", resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	events := readSSEEvents(t, reader, 3)
+	if events[0].event != "run" || events[0].data != `"run-20260102-090000-0002"` {
+		t.Errorf("第一個事件應為解析後的 run ID: %+v", events[0])
+	}
+	if events[1].event != string(EventLoopStarted) || events[1].id != "1" || events[2].event != string(EventOutputChunk) || events[2].id != "2" {
+		t.Errorf("應依序串流既有事件: %+v", events[1:])
+	}
+	var event LoopEvent
+	if err := json.Unmarshal([]byte(events[2].data), &event); err != nil || event.Text != "working" {
+		t.Errorf("事件內容應為執行紀錄的 JSON: %v %+v", err, event)
+	}
+
+	// 執行中寫入的事件會被推送
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Write(LoopEvent{Type: EventLoopFinished, Data: map[string]interface{}{"diff": "+x\n"}})
+	journal.Close()
+
+	events = readSSEEvents(t, reader, 1)
+	if events[0].event != string(EventLoopFinished) || events[0].id != "3" {
+		t.Errorf("應推送新寫入的事件: %+v", events[0])
+	}
+
+	// Last-Event-ID 之後的事件
+	req, _ := http.NewRequest("GET", server.URL+"/api/runs/run-20260102-090000-0002/events", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Body.Close()
+	events = readSSEEvents(t, bufio.NewReader(resumed.Body), 2)
+	if events[1].id != "3" {
+		t.Errorf("應從 Last-Event-ID 之後接續: %+v", events)
+	}
+
+	for _, id := range []string{"..", ".hidden", "run-missing"} {
+		if status := apiRequest(t, "GET", server.URL+"/api/runs/"+id+"/events", "", nil, nil); status != http.StatusNotFound {
+			t.Errorf("run %q 應回傳 404，但為 %d", id, status)
+		}
+	}
+}
+
+// TestClientPublishesDashboardData 測試迴圈事件包含 diff、預算與 hook 結果
+func TestClientPublishesDashboardData(t *testing.T) {
+	installFakeCopilot(t, `printf 'package main\n\nfunc main() {}\n' > main.go
+echo "still working"
+`)
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, "main.go", "package main\n")
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.ChangeScope = &ChangeScopePolicy{MaxLinesAddedPerRun: 100}
+	hook, _ := ParseShellHook("post_loop=exit 3", false)
+	config.ShellHooks = []ShellHook{hook}
+	client := NewRalphLoopClientWithConfig(config)
+
+	// 未完成時回傳達到上限的錯誤
+	_, _ = client.ExecuteUntilCompletion(t.Context(), "任務", 1)
+	client.Close()
+
+	events, err := ReadJournal(filepath.Join(client.RunDir(), JournalFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hookEvent, finished *LoopEvent
+	for i := range events {
+		switch events[i].Type {
+		case EventHookFinished:
+			hookEvent = &events[i]
+		case EventLoopFinished:
+			finished = &events[i]
+		}
+	}
+	if hookEvent == nil || hookEvent.Data["point"] != string(HookPostLoop) || hookEvent.Data["ok"] != false || hookEvent.Data["command"] != "exit 3" {
+		t.Errorf("應發布 hook 結果: %+v", hookEvent)
+	}
+	if finished == nil {
+		t.Fatal("應發布迴圈結束事件")
+	}
+	diff, _ := finished.Data["diff"].(string)
+	if !strings.Contains(diff, "+++ b/main.go") || !strings.Contains(diff, "+func main() {}") {
+		t.Errorf("迴圈結束事件應包含 diff:\n%s", diff)
+	}
+	budget, _ := finished.Data["budget"].(map[string]interface{})
+	if budget["loops_used"] != float64(1) || budget["loops_limit"] != float64(1) || budget["lines_added"] != float64(2) || budget["lines_added_limit"] != float64(100) {
+		t.Errorf("迴圈結束事件應包含預算使用量: %v", budget)
+	}
+}
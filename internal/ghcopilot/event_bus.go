@@ -0,0 +1,147 @@
+package ghcopilot
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// EventType 代表迴圈事件的類型
+type EventType string
+
+const (
+	// EventLoopStarted 迴圈開始（Text 為 prompt）
+	EventLoopStarted EventType = "loop_started"
+	// EventOutputChunk 執行器輸出的一行或一段（Stream 為 stdout/stderr/assistant）
+	EventOutputChunk EventType = "output_chunk"
+	// EventToolCall AI 呼叫工具（Tool 為工具名稱）
+	EventToolCall EventType = "tool_call"
+	// EventLoopAnalyzed 輸出分析完成
+	EventLoopAnalyzed EventType = "loop_analyzed"
+	// EventBreakerStateChanged 熔斷器狀態改變
+	EventBreakerStateChanged EventType = "breaker_state_changed"
+	// EventPermissionDecision SDK 權限請求的決定（Tool 為請求類型，Data 為 PermissionDecision）
+	EventPermissionDecision EventType = "permission_decision"
+	// EventHookFinished hook 執行完成（Data 包含時機、命令、結果與耗時）
+	EventHookFinished EventType = "hook_finished"
+	// EventLoopFinished 迴圈結束
+	EventLoopFinished EventType = "loop_finished"
+)
+
+// 輸出串流名稱
+const (
+	StreamStdout    = "stdout"
+	StreamStderr    = "stderr"
+	StreamAssistant = "assistant"
+)
+
+// LoopEvent 代表迴圈執行過程中的一個事件
+//
+// 不同類型的事件使用不同欄位，未使用的欄位為零值；
+// 其他資訊（例如分析結果、審核決定）放在 Data。
+type LoopEvent struct {
+	Type      EventType `json:"type"`
+	RunID     string    `json:"run_id"`
+	LoopIndex int       `json:"loop_index"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Stream string `json:"stream,omitempty"` // OutputChunk 的串流
+	Text   string `json:"text,omitempty"`   // OutputChunk 的內容，或 LoopStarted 的 prompt
+	Tool   string `json:"tool,omitempty"`   // ToolCall 的工具名稱
+
+	ShouldContinue bool   `json:"should_continue,omitempty"` // LoopAnalyzed/LoopFinished 的決策
+	ExitReason     string `json:"exit_reason,omitempty"`     // LoopAnalyzed/LoopFinished 的退出理由
+	Failed         bool   `json:"failed,omitempty"`          // LoopFinished 迴圈是否失敗
+
+	BreakerState  string `json:"breaker_state,omitempty"`  // BreakerStateChanged 的新狀態
+	PreviousState string `json:"previous_state,omitempty"` // BreakerStateChanged 的舊狀態
+
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventHandler 處理迴圈事件
+//
+// 事件依序同步傳遞，處理函式應盡快返回，且不可在處理中再發布事件。
+type EventHandler func(event LoopEvent)
+
+// decodeEventData 將事件資料中的欄位轉為指定型別
+//
+// 同一程序中的事件資料保留原本的型別，從執行紀錄讀回的則是 JSON 解碼後的 map；
+// 透過 JSON 轉換可以同時處理兩者。欄位不存在或型別不符時傳回 false。
+func decodeEventData(data map[string]interface{}, key string, dst interface{}) bool {
+	value, ok := data[key]
+	if !ok || value == nil {
+		return false
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(raw, dst) == nil
+}
+
+// EventBus 將迴圈事件分發給所有訂閱者
+type EventBus struct {
+	mu          sync.RWMutex
+	publishMu   sync.Mutex // 確保訂閱者一次只收到一個事件
+	subscribers []eventSubscriber
+	nextID      int
+}
+
+type eventSubscriber struct {
+	id      int
+	handler EventHandler
+}
+
+// NewEventBus 建立事件匯流排
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe 註冊事件處理函式，傳回取消訂閱的函式
+func (b *EventBus) Subscribe(handler EventHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.subscribers = append(b.subscribers, eventSubscriber{id: id, handler: handler})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, sub := range b.subscribers {
+			if sub.id == id {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish 依訂閱順序將事件傳給所有訂閱者
+//
+// 可從多個 goroutine 呼叫（例如 stdout 與 stderr 的讀取），事件會被序列化。
+func (b *EventBus) Publish(event LoopEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+	for _, sub := range subscribers {
+		sub.handler(event)
+	}
+}
+
+// SubscriberCount 傳回目前的訂閱者數量
+func (b *EventBus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
@@ -0,0 +1,156 @@
+package ghcopilot
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestEventBusSubscribe 測試訂閱、發布順序與取消訂閱
+func TestEventBusSubscribe(t *testing.T) {
+	bus := NewEventBus()
+
+	var order []string
+	unsubscribeA := bus.Subscribe(func(event LoopEvent) { order = append(order, "a:"+event.Text) })
+	bus.Subscribe(func(event LoopEvent) { order = append(order, "b:"+event.Text) })
+
+	bus.Publish(LoopEvent{Type: EventOutputChunk, Text: "1"})
+	unsubscribeA()
+	unsubscribeA() // 重複取消不應出錯
+	bus.Publish(LoopEvent{Type: EventOutputChunk, Text: "2"})
+
+	want := "a:1,b:1,b:2"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("順序應為 %s，但為 %s", want, got)
+	}
+	if bus.SubscriberCount() != 1 {
+		t.Errorf("應剩 1 個訂閱者，但為 %d", bus.SubscriberCount())
+	}
+}
+
+// TestEventBusConcurrentPublish 測試多個 goroutine 同時發布時事件被序列化
+func TestEventBusConcurrentPublish(t *testing.T) {
+	bus := NewEventBus()
+
+	inHandler := false
+	count := 0
+	bus.Subscribe(func(event LoopEvent) {
+		if inHandler {
+			t.Error("處理函式不應被並行呼叫")
+		}
+		inHandler = true
+		count++
+		if event.Timestamp.IsZero() {
+			t.Error("發布時應補上時間戳")
+		}
+		inHandler = false
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				bus.Publish(LoopEvent{Type: EventOutputChunk})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count != 200 {
+		t.Errorf("應收到 200 個事件，但為 %d", count)
+	}
+}
+
+// TestClientPublishesLoopEvents 測試客戶端發布迴圈事件並寫入執行紀錄
+func TestClientPublishesLoopEvents(t *testing.T) {
+	workDir := t.TempDir()
+	installFakeCopilot(t, `echo "第一行"
+echo "警告" >&2
+printf "沒有換行"
+`)
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	client := NewRalphLoopClientWithConfig(config)
+
+	var events []LoopEvent
+	client.Subscribe(func(event LoopEvent) { events = append(events, event) })
+
+	if _, err := client.ExecuteLoop(t.Context(), "任務"); err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+	client.Close()
+
+	var types []string
+	var chunks []string
+	for _, event := range events {
+		types = append(types, string(event.Type))
+		if event.Type == EventOutputChunk {
+			chunks = append(chunks, event.Stream+":"+event.Text)
+		}
+		if event.RunID != client.RunID() || event.LoopIndex != 0 {
+			t.Errorf("事件應帶有執行 ID 與迴圈索引: %+v", event)
+		}
+	}
+
+	if types[0] != string(EventLoopStarted) || types[len(types)-1] != string(EventLoopFinished) {
+		t.Errorf("事件應以 loop_started 開始、loop_finished 結束: %v", types)
+	}
+	if !containsItem(types, string(EventLoopAnalyzed)) {
+		t.Errorf("應包含 loop_analyzed: %v", types)
+	}
+	for _, want := range []string{"stdout:第一行", "stderr:警告", "stdout:沒有換行"} {
+		if !containsItem(chunks, want) {
+			t.Errorf("串流輸出應包含 %q: %v", want, chunks)
+		}
+	}
+
+	journal, err := ReadJournal(filepath.Join(client.RunDir(), JournalFileName))
+	if err != nil {
+		t.Fatalf("讀取執行紀錄失敗: %v", err)
+	}
+	if len(journal) != len(events) {
+		t.Errorf("執行紀錄應有 %d 個事件，但為 %d", len(events), len(journal))
+	}
+	if history := client.GetHistory(); history[0].RunID != client.RunID() {
+		t.Error("迴圈上下文應記錄執行 ID")
+	}
+}
+
+// TestClientPublishesBreakerStateChange 測試熔斷器狀態變化事件
+func TestClientPublishesBreakerStateChange(t *testing.T) {
+	installFakeCopilot(t, `echo "still working"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(t.TempDir()).
+		WithProtectedPaths().
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	var changes []LoopEvent
+	client.Subscribe(func(event LoopEvent) {
+		if event.Type == EventBreakerStateChanged {
+			changes = append(changes, event)
+		}
+	})
+
+	client.ExecuteUntilCompletion(t.Context(), "任務", 5)
+
+	if len(changes) != 1 {
+		t.Fatalf("應有 1 次熔斷器狀態變化，但為 %d", len(changes))
+	}
+	if changes[0].PreviousState != string(StateClosed) || changes[0].BreakerState != string(StateOpen) {
+		t.Errorf("狀態變化不正確: %+v", changes[0])
+	}
+	if client.RunDir() != "" {
+		t.Error("停用持久化時不應有執行紀錄目錄")
+	}
+}
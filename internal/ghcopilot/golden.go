@@ -0,0 +1,310 @@
+package ghcopilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GoldenExpectedFileName 黃金語料中每個案例的預期結果檔名（與 transcript.jsonl 放在同一個目錄）
+const GoldenExpectedFileName = "expected.json"
+
+// GoldenDetector 是黃金語料評估的偵測器
+type GoldenDetector string
+
+const (
+	// DetectorLoopCompletion ExecuteLoop 使用的完成關鍵字判斷（OutputSignalsCompletion）
+	DetectorLoopCompletion GoldenDetector = "loop_completion"
+	// DetectorAnalyzerCompletion ResponseAnalyzer.IsCompleted
+	DetectorAnalyzerCompletion GoldenDetector = "analyzer_completion"
+	// DetectorExitDetector ExitDetector.ShouldExitGracefully
+	DetectorExitDetector GoldenDetector = "exit_detector"
+	// DetectorAnalyzerStuck ResponseAnalyzer.DetectStuckState
+	DetectorAnalyzerStuck GoldenDetector = "analyzer_stuck"
+	// DetectorCircuitBreaker CircuitBreaker 打開
+	DetectorCircuitBreaker GoldenDetector = "circuit_breaker"
+)
+
+// GoldenDetectors 依序列出所有偵測器
+var GoldenDetectors = []GoldenDetector{
+	DetectorLoopCompletion, DetectorAnalyzerCompletion, DetectorExitDetector,
+	DetectorAnalyzerStuck, DetectorCircuitBreaker,
+}
+
+// Stuck 判斷偵測器是否偵測卡住（否則偵測完成）
+func (d GoldenDetector) Stuck() bool {
+	return d == DetectorAnalyzerStuck || d == DetectorCircuitBreaker
+}
+
+// GoldenExpectation 標註偵測器應在第幾個迴圈觸發（從 1 開始，0 表示不應觸發）
+type GoldenExpectation struct {
+	Description    string `json:"description,omitempty"`
+	CompletionLoop int    `json:"completion_loop"`
+	StuckLoop      int    `json:"stuck_loop"`
+}
+
+// loop 傳回偵測器應觸發的迴圈
+func (e GoldenExpectation) loop(detector GoldenDetector) int {
+	if detector.Stuck() {
+		return e.StuckLoop
+	}
+	return e.CompletionLoop
+}
+
+// GoldenCase 是黃金語料中的一個案例：錄製的多迴圈執行與預期的偵測結果
+type GoldenCase struct {
+	Name     string
+	Expected GoldenExpectation
+	Loops    []TranscriptEntry // 每個迴圈最後使用的回應（SDK 失敗後改用 CLI 時為 CLI 的回應）
+}
+
+// LoadGoldenCorpus 載入語料目錄中的所有案例
+//
+// 每個子目錄是一個案例：transcript.jsonl 是錄製的執行（可直接複製 run 目錄中的檔案），
+// expected.json 標註完成與卡住應在第幾個迴圈偵測到。案例依目錄名稱排序。
+func LoadGoldenCorpus(dir string) ([]GoldenCase, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取黃金語料目錄: %w", err)
+	}
+
+	var cases []GoldenCase
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		caseDir := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(caseDir, GoldenExpectedFileName))
+		if err != nil {
+			return nil, fmt.Errorf("案例 %s: %w", entry.Name(), err)
+		}
+		c := GoldenCase{Name: entry.Name()}
+		if err := json.Unmarshal(data, &c.Expected); err != nil {
+			return nil, fmt.Errorf("案例 %s: 預期結果格式錯誤: %w", entry.Name(), err)
+		}
+		transcript, err := ReadTranscript(filepath.Join(caseDir, TranscriptFileName))
+		if err != nil {
+			return nil, fmt.Errorf("案例 %s: %w", entry.Name(), err)
+		}
+		c.Loops = lastEntryPerLoop(transcript)
+		if len(c.Loops) == 0 {
+			return nil, fmt.Errorf("案例 %s: 錄製檔沒有任何迴圈", entry.Name())
+		}
+		cases = append(cases, c)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+// lastEntryPerLoop 取每個迴圈最後一次的執行器呼叫，依迴圈索引排序
+func lastEntryPerLoop(entries []TranscriptEntry) []TranscriptEntry {
+	last := map[int]TranscriptEntry{}
+	for _, entry := range entries {
+		last[entry.LoopIndex] = entry
+	}
+	loops := make([]TranscriptEntry, 0, len(last))
+	for _, entry := range last {
+		loops = append(loops, entry)
+	}
+	sort.Slice(loops, func(i, j int) bool { return loops[i].LoopIndex < loops[j].LoopIndex })
+	return loops
+}
+
+// GoldenReport 是偵測器在黃金語料上的評估結果
+type GoldenReport struct {
+	Cases   []GoldenCaseResult `json:"cases"`
+	Metrics []GoldenMetrics    `json:"metrics"` // 依 GoldenDetectors 的順序
+}
+
+// GoldenCaseResult 是一個案例中各偵測器第一次觸發的迴圈（0 表示沒有觸發）
+type GoldenCaseResult struct {
+	Name     string                 `json:"name"`
+	Expected GoldenExpectation      `json:"expected"`
+	Fired    map[GoldenDetector]int `json:"fired"`
+}
+
+// GoldenMetrics 是一個偵測器的準確度
+//
+// 每個案例只看第一次觸發：在預期的迴圈觸發為 true positive；在其他迴圈觸發為 false positive，
+// 且預期應觸發時同時算一次 false negative（太早或太晚都錯過了正確的迴圈）。
+type GoldenMetrics struct {
+	Detector       GoldenDetector `json:"detector"`
+	TruePositives  int            `json:"true_positives"`
+	FalsePositives int            `json:"false_positives"`
+	FalseNegatives int            `json:"false_negatives"`
+	Precision      float64        `json:"precision"`
+	Recall         float64        `json:"recall"`
+}
+
+// Metric 傳回指定偵測器的準確度
+func (r *GoldenReport) Metric(detector GoldenDetector) GoldenMetrics {
+	for _, m := range r.Metrics {
+		if m.Detector == detector {
+			return m
+		}
+	}
+	return GoldenMetrics{Detector: detector}
+}
+
+// EvaluateGolden 以 ResponseAnalyzer、ExitDetector 與 CircuitBreaker 逐迴圈分析每個案例，並計算準確度
+func EvaluateGolden(cases []GoldenCase) (*GoldenReport, error) {
+	// ExitDetector 與 CircuitBreaker 會在工作目錄寫入狀態檔，評估時放在暫存目錄
+	stateDir, err := os.MkdirTemp("", "ralph-golden-")
+	if err != nil {
+		return nil, fmt.Errorf("無法建立暫存目錄: %w", err)
+	}
+	defer os.RemoveAll(stateDir)
+
+	report := &GoldenReport{Cases: []GoldenCaseResult{}}
+	metrics := map[GoldenDetector]*GoldenMetrics{}
+	for _, detector := range GoldenDetectors {
+		metrics[detector] = &GoldenMetrics{Detector: detector}
+	}
+
+	for _, c := range cases {
+		result := GoldenCaseResult{Name: c.Name, Expected: c.Expected, Fired: detectGoldenCase(c, stateDir)}
+		report.Cases = append(report.Cases, result)
+
+		for _, detector := range GoldenDetectors {
+			m := metrics[detector]
+			want, got := c.Expected.loop(detector), result.Fired[detector]
+			switch {
+			case want > 0 && got == want:
+				m.TruePositives++
+			case got > 0:
+				m.FalsePositives++
+				if want > 0 {
+					m.FalseNegatives++
+				}
+			case want > 0:
+				m.FalseNegatives++
+			}
+		}
+	}
+
+	for _, detector := range GoldenDetectors {
+		m := metrics[detector]
+		m.Precision = ratio(m.TruePositives, m.TruePositives+m.FalsePositives)
+		m.Recall = ratio(m.TruePositives, m.TruePositives+m.FalseNegatives)
+		report.Metrics = append(report.Metrics, *m)
+	}
+	return report, nil
+}
+
+// detectGoldenCase 以 ExecuteLoop 餵給各偵測器的方式逐迴圈分析，傳回各偵測器第一次觸發的迴圈
+func detectGoldenCase(c GoldenCase, stateDir string) map[GoldenDetector]int {
+	fired := map[GoldenDetector]int{}
+	fire := func(detector GoldenDetector, loop int, ok bool) {
+		if ok && fired[detector] == 0 {
+			fired[detector] = loop
+		}
+	}
+
+	stuckAnalyzer := NewResponseAnalyzer("")
+	exitDetector := NewExitDetector(stateDir)
+	breaker := NewCircuitBreaker(stateDir)
+	breaker.SetLogger(nil)
+
+	for i, entry := range c.Loops {
+		loop := i + 1
+		failed := entry.Error != "" || entry.ExitCode != 0
+
+		// 卡住偵測看每個迴圈的完整回應（失敗時包含 stderr）
+		response := entry.Stdout
+		if failed {
+			response = strings.TrimSpace(response + "\n" + entry.Stderr + "\n" + entry.Error)
+		}
+		stuckAnalyzer.SetResponse(response)
+		stuck, _ := stuckAnalyzer.DetectStuckState()
+		fire(DetectorAnalyzerStuck, loop, stuck)
+
+		switch {
+		case entry.Error != "":
+			breaker.RecordSameError(entry.Error)
+		case entry.ExitCode != 0:
+			breaker.RecordSameError(fmt.Sprintf("exit code %d", entry.ExitCode))
+		case OutputSignalsCompletion(entry.Stdout):
+			breaker.RecordSuccess()
+		default:
+			breaker.RecordNoProgress()
+		}
+		fire(DetectorCircuitBreaker, loop, breaker.IsOpen())
+
+		if failed {
+			continue
+		}
+
+		// 完成偵測只看成功執行的迴圈
+		analyzer := NewResponseAnalyzer(entry.Stdout)
+		score := analyzer.CalculateCompletionScore()
+		fire(DetectorLoopCompletion, loop, OutputSignalsCompletion(entry.Stdout))
+		fire(DetectorAnalyzerCompletion, loop, analyzer.IsCompleted())
+
+		if status := analyzer.ParseStructuredOutput(); status != nil && status.ExitSignal {
+			exitDetector.RecordDoneSignal()
+		}
+		for _, indicator := range analyzer.completionIndicators {
+			if indicator != "short_output" && indicator != "explicit_exit_signal" {
+				exitDetector.RecordCompletionIndicator()
+				break
+			}
+		}
+		if analyzer.DetectTestOnlyLoop() {
+			exitDetector.RecordTestOnlyLoop()
+		}
+		fire(DetectorExitDetector, loop, exitDetector.ShouldExitGracefully(score))
+	}
+	return fired
+}
+
+// WriteText 以表格輸出各偵測器的準確度與每個案例的觸發迴圈
+func (r *GoldenReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	rows := [][]string{{"偵測器", "精確率", "召回率", "TP", "FP", "FN"}}
+	for _, m := range r.Metrics {
+		rows = append(rows, []string{
+			string(m.Detector), formatPercent(m.Precision), formatPercent(m.Recall),
+			strconv.Itoa(m.TruePositives), strconv.Itoa(m.FalsePositives), strconv.Itoa(m.FalseNegatives),
+		})
+	}
+	if err := writeHistoryTable(&b, rows); err != nil {
+		return err
+	}
+
+	b.WriteString("\n")
+	header := []string{"案例", "完成", "卡住"}
+	for _, detector := range GoldenDetectors {
+		header = append(header, string(detector))
+	}
+	rows = [][]string{header}
+	for _, c := range r.Cases {
+		row := []string{c.Name, goldenLoop(c.Expected.CompletionLoop), goldenLoop(c.Expected.StuckLoop)}
+		for _, detector := range GoldenDetectors {
+			cell := goldenLoop(c.Fired[detector])
+			if c.Fired[detector] != c.Expected.loop(detector) {
+				cell += " ✗"
+			}
+			row = append(row, cell)
+		}
+		rows = append(rows, row)
+	}
+	if err := writeHistoryTable(&b, rows); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// goldenLoop 顯示迴圈編號（0 顯示為 -）
+func goldenLoop(loop int) string {
+	if loop == 0 {
+		return "-"
+	}
+	return strconv.Itoa(loop)
+}
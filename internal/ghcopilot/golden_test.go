@@ -0,0 +1,125 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenBaseline 是各偵測器在 testdata/golden 上目前的準確度
+//
+// 低於此值時測試失敗；改善偵測器或擴充語料後提高對應的值。
+var goldenBaseline = map[GoldenDetector]struct{ precision, recall float64 }{
+	DetectorLoopCompletion:     {0.60, 0.50},
+	DetectorAnalyzerCompletion: {0.75, 0.50},
+	DetectorExitDetector:       {0.66, 0.33},
+	DetectorAnalyzerStuck:      {1, 0.33},
+	DetectorCircuitBreaker:     {1, 1},
+}
+
+// TestGoldenCorpus 在錄製的多迴圈執行上檢查完成與卡住偵測的準確度
+func TestGoldenCorpus(t *testing.T) {
+	cases, err := LoadGoldenCorpus(filepath.Join("testdata", "golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := EvaluateGolden(cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	t.Logf("黃金語料評估結果:\n%s", text.String())
+
+	for _, detector := range GoldenDetectors {
+		m := report.Metric(detector)
+		want := goldenBaseline[detector]
+		if m.Precision+1e-9 < want.precision {
+			t.Errorf("%s 精確率 %.2f 低於基準 %.2f", detector, m.Precision, want.precision)
+		}
+		if m.Recall+1e-9 < want.recall {
+			t.Errorf("%s 召回率 %.2f 低於基準 %.2f", detector, m.Recall, want.recall)
+		}
+	}
+}
+
+func TestEvaluateGolden(t *testing.T) {
+	cases := []GoldenCase{
+		{
+			Name:     "premature",
+			Expected: GoldenExpectation{CompletionLoop: 2},
+			Loops:    []TranscriptEntry{{Stdout: "not done yet"}, {Stdout: "all done"}},
+		},
+		{
+			Name:     "never-complete",
+			Expected: GoldenExpectation{StuckLoop: 3},
+			Loops:    []TranscriptEntry{{Stdout: "working"}, {Stdout: "working"}, {Stdout: "working"}},
+		},
+		{
+			Name:     "failed-loop-ignored",
+			Expected: GoldenExpectation{},
+			Loops:    []TranscriptEntry{{Stdout: "done", ExitCode: 1}},
+		},
+	}
+	report, err := EvaluateGolden(cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fired := report.Cases[0].Fired[DetectorLoopCompletion]; fired != 1 {
+		t.Errorf("關鍵字判斷應在第 1 個迴圈觸發: %d", fired)
+	}
+	m := report.Metric(DetectorLoopCompletion)
+	if m.TruePositives != 0 || m.FalsePositives != 1 || m.FalseNegatives != 1 || m.Precision != 0 || m.Recall != 0 {
+		t.Errorf("太早觸發應同時算 false positive 與 false negative: %+v", m)
+	}
+	if report.Cases[2].Fired[DetectorLoopCompletion] != 0 {
+		t.Error("執行失敗的迴圈不應觸發完成偵測")
+	}
+
+	breaker := report.Metric(DetectorCircuitBreaker)
+	if report.Cases[1].Fired[DetectorCircuitBreaker] != 3 || breaker.TruePositives != 1 || breaker.Precision != 1 || breaker.Recall != 1 {
+		t.Errorf("熔斷器應在第三個無進展迴圈打開: %+v, %+v", report.Cases[1], breaker)
+	}
+	// 三個相同輸出還不到 5 次相同錯誤
+	if report.Cases[1].Fired[DetectorAnalyzerStuck] != 0 {
+		t.Errorf("分析器不應偵測到卡住: %+v", report.Cases[1])
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "premature") || !strings.Contains(text.String(), "1 ✗") {
+		t.Errorf("文字輸出應標示錯誤的觸發:\n%s", text.String())
+	}
+}
+
+func TestLoadGoldenCorpusErrors(t *testing.T) {
+	if _, err := LoadGoldenCorpus(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("目錄不存在時應傳回錯誤")
+	}
+
+	dir := t.TempDir()
+	caseDir := filepath.Join(dir, "no-expected")
+	recorder, err := OpenTranscript(filepath.Join(caseDir, TranscriptFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Record(TranscriptEntry{Stdout: "done"})
+	recorder.Close()
+	if _, err := LoadGoldenCorpus(dir); err == nil || !strings.Contains(err.Error(), "no-expected") {
+		t.Errorf("缺少預期結果時應指出案例: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(caseDir, GoldenExpectedFileName), []byte(`{"completion_loop": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases, err := LoadGoldenCorpus(dir)
+	if err != nil || len(cases) != 1 || cases[0].Expected.CompletionLoop != 1 || len(cases[0].Loops) != 1 {
+		t.Errorf("LoadGoldenCorpus = %+v, %v", cases, err)
+	}
+}
@@ -0,0 +1,339 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// History 是已儲存的執行歷史
+//
+// 迴圈來自 SaveDir 中個別保存的執行上下文（loop_*.json），依 RunID 分組，
+// 並與 RunsDir 中的執行摘要（run.json）合併；沒有 RunID 的舊迴圈歸在 RunID 為空的一組。
+type History struct {
+	Runs []*HistoryRun // 最新的在前
+}
+
+// HistoryRun 是歷史中的一次執行
+type HistoryRun struct {
+	RunID      string              `json:"run_id"`
+	Goal       string              `json:"goal"`
+	Model      string              `json:"model,omitempty"`
+	Status     RunStatus           `json:"status,omitempty"` // 沒有執行摘要時為空
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	LoopCount  int                 `json:"loops"`
+	Loops      []*ExecutionContext `json:"-"` // 已保存的迴圈（依索引排序）
+}
+
+// HistoryLoop 是迴圈列表中的一列（-output json 的輸出）
+type HistoryLoop struct {
+	LoopID          string    `json:"loop_id"`
+	RunID           string    `json:"run_id,omitempty"`
+	LoopIndex       int       `json:"loop_index"`
+	Timestamp       time.Time `json:"timestamp"`
+	DurationMs      int64     `json:"duration_ms"`
+	Model           string    `json:"model,omitempty"`
+	Mode            string    `json:"mode"`
+	Outcome         string    `json:"outcome"`
+	CompletionScore int       `json:"completion_score"`
+	ExitReason      string    `json:"exit_reason,omitempty"`
+	Prompt          string    `json:"prompt"`
+}
+
+// NewHistoryLoop 取出迴圈列表需要的欄位
+func NewHistoryLoop(execCtx *ExecutionContext) HistoryLoop {
+	return HistoryLoop{
+		LoopID:          execCtx.LoopID,
+		RunID:           execCtx.RunID,
+		LoopIndex:       execCtx.LoopIndex,
+		Timestamp:       execCtx.Timestamp,
+		DurationMs:      execCtx.DurationMs,
+		Model:           execCtx.Model,
+		Mode:            LoopExecutionMode(execCtx),
+		Outcome:         LoopOutcome(execCtx),
+		CompletionScore: execCtx.CompletionScore,
+		ExitReason:      execCtx.ExitReason,
+		Prompt:          execCtx.UserPrompt,
+	}
+}
+
+// LoopOutcome 傳回迴圈結果（LoopOutcome* 常數）；舊版本保存的迴圈沒有記錄結果時依欄位推斷
+func LoopOutcome(execCtx *ExecutionContext) string {
+	switch {
+	case execCtx.Outcome != "":
+		return execCtx.Outcome
+	case execCtx.Failed:
+		return LoopOutcomeFailed
+	case execCtx.ShouldContinue:
+		return LoopOutcomeContinue
+	}
+	return LoopOutcomeComplete
+}
+
+// LoadHistory 從儲存目錄與執行紀錄目錄載入執行歷史（目錄不存在時為空的歷史）
+func LoadHistory(saveDir, runsDir string) (*History, error) {
+	var loops []*ExecutionContext
+	if _, err := os.Stat(saveDir); err == nil {
+		pm, err := NewPersistenceManager(saveDir, false)
+		if err != nil {
+			return nil, err
+		}
+		pm.SetLogger(nil)
+		if loops, err = pm.LoadExecutionContexts(); err != nil {
+			return nil, fmt.Errorf("無法讀取儲存目錄: %w", err)
+		}
+	}
+
+	runs := map[string]*HistoryRun{}
+	for _, execCtx := range loops {
+		run := runs[execCtx.RunID]
+		if run == nil {
+			run = &HistoryRun{RunID: execCtx.RunID, Goal: execCtx.UserPrompt, Model: execCtx.Model, StartedAt: execCtx.Timestamp}
+			runs[execCtx.RunID] = run
+		}
+		run.Loops = append(run.Loops, execCtx)
+	}
+
+	entries, err := os.ReadDir(runsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("無法讀取執行紀錄目錄: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		record, err := ReadRunRecord(filepath.Join(runsDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		run := runs[record.RunID]
+		if run == nil {
+			run = &HistoryRun{RunID: record.RunID}
+			runs[record.RunID] = run
+		}
+		run.Goal = record.Goal
+		run.Status = record.Status
+		run.StartedAt = record.StartedAt
+		run.FinishedAt = record.FinishedAt
+		run.Error = record.Error
+		run.LoopCount = record.Loops
+		if record.Config.Model != "" {
+			run.Model = record.Config.Model
+		}
+	}
+
+	history := &History{}
+	for _, run := range runs {
+		sort.SliceStable(run.Loops, func(i, j int) bool {
+			return run.Loops[i].LoopIndex < run.Loops[j].LoopIndex
+		})
+		run.LoopCount = max(run.LoopCount, len(run.Loops))
+		history.Runs = append(history.Runs, run)
+	}
+	sort.Slice(history.Runs, func(i, j int) bool {
+		a, b := history.Runs[i], history.Runs[j]
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.After(b.StartedAt)
+		}
+		return a.RunID > b.RunID
+	})
+	return history, nil
+}
+
+// Loops 傳回所有已保存的迴圈（最新的在前）
+func (h *History) Loops() []*ExecutionContext {
+	var loops []*ExecutionContext
+	for _, run := range h.Runs {
+		loops = append(loops, run.Loops...)
+	}
+	sort.SliceStable(loops, func(i, j int) bool {
+		return loops[i].Timestamp.After(loops[j].Timestamp)
+	})
+	return loops
+}
+
+// FilterRuns 傳回符合條件的執行
+func (h *History) FilterRuns(filter HistoryFilter) []*HistoryRun {
+	runs := []*HistoryRun{}
+	for _, run := range h.Runs {
+		if filter.MatchRun(run) {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// FilterLoops 傳回符合條件的迴圈（最新的在前）
+func (h *History) FilterLoops(filter HistoryFilter) []*ExecutionContext {
+	loops := []*ExecutionContext{}
+	for _, execCtx := range h.Loops() {
+		if filter.MatchLoop(execCtx) {
+			loops = append(loops, execCtx)
+		}
+	}
+	return loops
+}
+
+// FindLoop 以迴圈 ID 或 "<run-id>/<迴圈編號>"（編號從 1 開始）尋找迴圈
+func (h *History) FindLoop(ref string) (*ExecutionContext, error) {
+	for _, run := range h.Runs {
+		for _, execCtx := range run.Loops {
+			if execCtx.LoopID == ref {
+				return execCtx, nil
+			}
+		}
+	}
+
+	if runID, number, ok := strings.Cut(ref, "/"); ok {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("無效的迴圈編號 %q", number)
+		}
+		for _, run := range h.Runs {
+			if run.RunID != runID {
+				continue
+			}
+			for _, execCtx := range run.Loops {
+				if execCtx.LoopIndex == n-1 {
+					return execCtx, nil
+				}
+			}
+			return nil, fmt.Errorf("run %s 沒有保存第 %d 個迴圈", runID, n)
+		}
+	}
+	return nil, fmt.Errorf("找不到迴圈 %s", ref)
+}
+
+// HistoryFilter 是 history 指令的篩選條件（零值的欄位不篩選）
+//
+// 文字條件不分大小寫、以子字串比對。執行符合條件的判斷：Status 比對執行狀態、
+// 時間範圍比對開始時間，其餘條件只要執行本身或任一迴圈符合即可；
+// 迴圈的 Status 則比對迴圈結果（LoopOutcome* 常數）。
+type HistoryFilter struct {
+	RunID      string    // 完全相同
+	Status     string    // 執行狀態或迴圈結果
+	Model      string    // 模型名稱
+	ExitReason string    // 退出理由（執行則包含未完成的原因）
+	Contains   string    // prompt、輸出、使用者反饋或錯誤訊息
+	Since      time.Time // 開始時間不早於此時間
+	Until      time.Time // 開始時間不晚於此時間
+}
+
+// Validate 檢查狀態條件是否為已知的執行狀態（loops 為 true 時為迴圈結果）
+func (f HistoryFilter) Validate(loops bool) error {
+	if f.Status == "" {
+		return nil
+	}
+	valid := []string{
+		string(RunStatusRunning), string(RunStatusCompleted), string(RunStatusStopped),
+		string(RunStatusCancelled), string(RunStatusFailed),
+	}
+	if loops {
+		valid = []string{LoopOutcomeContinue, LoopOutcomeComplete, LoopOutcomeFailed, LoopOutcomeStopped}
+	}
+	for _, status := range valid {
+		if strings.EqualFold(f.Status, status) {
+			return nil
+		}
+	}
+	return fmt.Errorf("未知的狀態 %q（可用: %s）", f.Status, strings.Join(valid, ", "))
+}
+
+// MatchRun 判斷執行是否符合條件
+func (f HistoryFilter) MatchRun(run *HistoryRun) bool {
+	if f.RunID != "" && run.RunID != f.RunID {
+		return false
+	}
+	if f.Status != "" && !strings.EqualFold(string(run.Status), f.Status) {
+		return false
+	}
+	if !f.inRange(run.StartedAt) {
+		return false
+	}
+
+	loopFilter := HistoryFilter{Model: f.Model, ExitReason: f.ExitReason, Contains: f.Contains}
+	if f.Model != "" && containsFold(run.Model, f.Model) {
+		loopFilter.Model = ""
+	}
+	if f.ExitReason != "" && containsFold(run.Error, f.ExitReason) {
+		loopFilter.ExitReason = ""
+	}
+	if f.Contains != "" && containsFold(run.Goal, f.Contains) {
+		loopFilter.Contains = ""
+	}
+	if loopFilter == (HistoryFilter{}) {
+		return true
+	}
+	for _, execCtx := range run.Loops {
+		if loopFilter.MatchLoop(execCtx) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLoop 判斷迴圈是否符合條件
+func (f HistoryFilter) MatchLoop(execCtx *ExecutionContext) bool {
+	switch {
+	case f.RunID != "" && execCtx.RunID != f.RunID:
+		return false
+	case f.Status != "" && !strings.EqualFold(LoopOutcome(execCtx), f.Status):
+		return false
+	case f.Model != "" && !containsFold(execCtx.Model, f.Model):
+		return false
+	case f.ExitReason != "" && !containsFold(execCtx.ExitReason, f.ExitReason):
+		return false
+	case !f.inRange(execCtx.Timestamp):
+		return false
+	}
+	if f.Contains == "" {
+		return true
+	}
+
+	texts := append([]string{execCtx.UserPrompt, execCtx.CLIOutput, execCtx.UserFeedback}, execCtx.ErrorHistory...)
+	for _, text := range texts {
+		if containsFold(text, f.Contains) {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange 判斷時間是否在 Since 與 Until 之間
+func (f HistoryFilter) inRange(t time.Time) bool {
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && t.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ParseHistoryTime 解析 RFC 3339 時間或日期（YYYY-MM-DD，以本地時區解讀）
+//
+// endOfDay 為 true 時，只有日期的值代表當天的最後一刻（用於範圍的結束）。
+func ParseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("無效的時間 %q（格式: YYYY-MM-DD 或 RFC 3339）", value)
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+// containsFold 不分大小寫的子字串比對
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
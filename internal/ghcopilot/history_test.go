@@ -0,0 +1,307 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestHistory 建立兩個 run 的歷史：run-a 第一個迴圈驗證失敗、第二個迴圈完成；
+// run-b 只有一個仍在繼續的迴圈，且沒有執行摘要
+func writeTestHistory(t *testing.T) (saveDir, runsDir string) {
+	t.Helper()
+	dir := t.TempDir()
+	saveDir = filepath.Join(dir, "saves")
+	runsDir = filepath.Join(dir, "runs")
+
+	pm, err := NewPersistenceManager(saveDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	loops := []*ExecutionContext{
+		{
+			LoopID: "loop-a-0", RunID: "run-a", LoopIndex: 0, Timestamp: start, Model: "gpt-4.1",
+			UserPrompt: "修正 parser", CLIOutput: "修改了 parser.go", Outcome: LoopOutcomeFailed, Failed: true,
+			ErrorHistory: []string{"post_loop hook failed: nil pointer dereference"},
+			HookResults: []HookResult{
+				{Point: HookPostLoop, Command: "go test ./...", Enforce: true, Error: "exit status 1", Output: "--- FAIL: TestParse\n"},
+			},
+		},
+		{
+			LoopID: "loop-a-1", RunID: "run-a", LoopIndex: 1, Timestamp: start.Add(time.Minute), Model: "gpt-4.1",
+			UserPrompt: "修正 parser", CLIOutput: "全部完成", ExitReason: "completion detected in output", CompletionScore: 90,
+			ParsedCodeBlocks: []string{"func parse() {}"},
+			StructuredStatus: &LoopStatus{Status: "DONE", ExitSignal: true, TasksDone: "3/3"},
+		},
+		{
+			LoopID: "loop-b-0", RunID: "run-b", LoopIndex: 0, Timestamp: start.AddDate(0, 0, 2), Model: "claude-sonnet-4.5",
+			UserPrompt: "加上測試", CLIOutput: "還在進行", ShouldContinue: true,
+		},
+	}
+	for _, execCtx := range loops {
+		if err := pm.SaveExecutionContext(execCtx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	finished := start.Add(2 * time.Minute)
+	err = WriteRunRecord(filepath.Join(runsDir, "run-a"), &RunRecord{
+		RunID: "run-a", Goal: "修正 parser", StartedAt: start, FinishedAt: &finished,
+		Status: RunStatusCompleted, Loops: 2, Config: RunConfig{Model: "gpt-4.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return saveDir, runsDir
+}
+
+func TestLoadHistory(t *testing.T) {
+	history, err := LoadHistory(writeTestHistory(t))
+	if err != nil {
+		t.Fatalf("LoadHistory 失敗: %v", err)
+	}
+	if len(history.Runs) != 2 || history.Runs[0].RunID != "run-b" || history.Runs[1].RunID != "run-a" {
+		t.Fatalf("run 應依開始時間排序，最新的在前: %+v", history.Runs)
+	}
+
+	runA := history.Runs[1]
+	if runA.Status != RunStatusCompleted || runA.LoopCount != 2 || runA.FinishedAt == nil || len(runA.Loops) != 2 {
+		t.Errorf("run-a 應合併執行摘要: %+v", runA)
+	}
+	runB := history.Runs[0]
+	if runB.Status != "" || runB.Goal != "加上測試" || runB.Model != "claude-sonnet-4.5" || runB.LoopCount != 1 {
+		t.Errorf("沒有執行摘要的 run 應由迴圈推斷: %+v", runB)
+	}
+
+	loops := history.Loops()
+	if len(loops) != 3 || loops[0].LoopID != "loop-b-0" || loops[2].LoopID != "loop-a-0" {
+		t.Errorf("迴圈應依時間排序，最新的在前: %v", loops)
+	}
+	if LoopOutcome(loops[0]) != LoopOutcomeContinue || LoopOutcome(loops[1]) != LoopOutcomeComplete {
+		t.Error("沒有記錄結果的迴圈應由欄位推斷")
+	}
+}
+
+func TestLoadHistoryMissingDirs(t *testing.T) {
+	dir := t.TempDir()
+	history, err := LoadHistory(filepath.Join(dir, "saves"), filepath.Join(dir, "runs"))
+	if err != nil {
+		t.Fatalf("目錄不存在時不應失敗: %v", err)
+	}
+	if len(history.Runs) != 0 || len(history.FilterLoops(HistoryFilter{})) != 0 {
+		t.Errorf("應為空的歷史: %+v", history.Runs)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	history, err := LoadHistory(writeTestHistory(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runIDs := func(filter HistoryFilter) string {
+		var ids []string
+		for _, run := range history.FilterRuns(filter) {
+			ids = append(ids, run.RunID)
+		}
+		return strings.Join(ids, ",")
+	}
+	loopIDs := func(filter HistoryFilter) string {
+		var ids []string
+		for _, execCtx := range history.FilterLoops(filter) {
+			ids = append(ids, execCtx.LoopID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	day := time.Date(2026, 3, 3, 0, 0, 0, 0, time.Local)
+	runCases := []struct {
+		filter HistoryFilter
+		want   string
+	}{
+		{HistoryFilter{}, "run-b,run-a"},
+		{HistoryFilter{Status: "COMPLETED"}, "run-a"},
+		{HistoryFilter{Model: "claude"}, "run-b"},
+		{HistoryFilter{Contains: "NIL POINTER"}, "run-a"},
+		{HistoryFilter{Contains: "加上"}, "run-b"},
+		{HistoryFilter{ExitReason: "completion"}, "run-a"},
+		{HistoryFilter{Since: day}, "run-b"},
+		{HistoryFilter{Until: day}, "run-a"},
+		{HistoryFilter{RunID: "run-a", Model: "claude"}, ""},
+	}
+	for _, c := range runCases {
+		if got := runIDs(c.filter); got != c.want {
+			t.Errorf("FilterRuns(%+v) = %q, 期望 %q", c.filter, got, c.want)
+		}
+	}
+
+	loopCases := []struct {
+		filter HistoryFilter
+		want   string
+	}{
+		{HistoryFilter{RunID: "run-a"}, "loop-a-1,loop-a-0"},
+		{HistoryFilter{Status: LoopOutcomeFailed}, "loop-a-0"},
+		{HistoryFilter{Contains: "全部完成"}, "loop-a-1"},
+		{HistoryFilter{Model: "GPT", Status: LoopOutcomeComplete}, "loop-a-1"},
+		{HistoryFilter{Since: day}, "loop-b-0"},
+	}
+	for _, c := range loopCases {
+		if got := loopIDs(c.filter); got != c.want {
+			t.Errorf("FilterLoops(%+v) = %q, 期望 %q", c.filter, got, c.want)
+		}
+	}
+}
+
+func TestHistoryFilterValidate(t *testing.T) {
+	if err := (HistoryFilter{Status: "completed"}).Validate(false); err != nil {
+		t.Errorf("completed 是合法的 run 狀態: %v", err)
+	}
+	if err := (HistoryFilter{Status: "completed"}).Validate(true); err == nil {
+		t.Error("completed 不是迴圈結果")
+	}
+	if err := (HistoryFilter{Status: "continue"}).Validate(true); err != nil {
+		t.Errorf("continue 是合法的迴圈結果: %v", err)
+	}
+}
+
+func TestHistoryFindLoop(t *testing.T) {
+	history, err := LoadHistory(writeTestHistory(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ref, want := range map[string]string{"loop-a-1": "loop-a-1", "run-a/1": "loop-a-0", "run-b/1": "loop-b-0"} {
+		execCtx, err := history.FindLoop(ref)
+		if err != nil || execCtx.LoopID != want {
+			t.Errorf("FindLoop(%q) = %v, %v, 期望 %s", ref, execCtx, err, want)
+		}
+	}
+	for _, ref := range []string{"loop-x", "run-a/3", "run-a/0", "run-x/1"} {
+		if _, err := history.FindLoop(ref); err == nil {
+			t.Errorf("FindLoop(%q) 應傳回錯誤", ref)
+		}
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	since, err := ParseHistoryTime("2026-03-01", false)
+	if err != nil || !since.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("日期應為當天開始: %v, %v", since, err)
+	}
+	until, err := ParseHistoryTime("2026-03-01", true)
+	if err != nil || !until.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local).Add(-time.Nanosecond)) {
+		t.Errorf("結束日期應為當天最後一刻: %v, %v", until, err)
+	}
+	exact, err := ParseHistoryTime("2026-03-01T09:30:00Z", true)
+	if err != nil || !exact.Equal(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 時間應原樣解析: %v, %v", exact, err)
+	}
+	if _, err := ParseHistoryTime("yesterday", false); err == nil {
+		t.Error("無效的時間應傳回錯誤")
+	}
+}
+
+func TestWriteHistoryTables(t *testing.T) {
+	history, err := LoadHistory(writeTestHistory(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var runs bytes.Buffer
+	if err := WriteHistoryRuns(&runs, history.Runs); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(runs.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("應有標題與兩列: %q", runs.String())
+	}
+	// 中文欄位以顯示寬度對齊：每一列的 "目標" 欄從相同的位置開始
+	goalColumn := displayWidth(lines[0][:strings.Index(lines[0], "目標")])
+	for _, line := range lines[1:] {
+		goal := "修正 parser"
+		if strings.HasPrefix(line, "run-b") {
+			goal = "加上測試"
+		}
+		if idx := strings.Index(line, goal); idx < 0 || displayWidth(line[:idx]) != goalColumn {
+			t.Errorf("欄位未對齊:\n%s", runs.String())
+		}
+	}
+	if !strings.Contains(lines[2], "completed") || !strings.Contains(lines[1], " - ") {
+		t.Errorf("沒有狀態時應顯示 -:\n%s", runs.String())
+	}
+
+	var loops bytes.Buffer
+	if err := WriteHistoryLoops(&loops, history.FilterLoops(HistoryFilter{RunID: "run-a"})); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(loops.String(), "loop-a-1") || !strings.Contains(loops.String(), "completion detected in output") {
+		t.Errorf("迴圈列表不完整:\n%s", loops.String())
+	}
+}
+
+func TestWriteHistoryLoop(t *testing.T) {
+	history, err := LoadHistory(writeTestHistory(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failed bytes.Buffer
+	execCtx, _ := history.FindLoop("loop-a-0")
+	if err := WriteHistoryLoop(&failed, execCtx); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"run-a (第 1 個迴圈)", "== Prompt ==\n修正 parser", "== 輸出 ==\n修改了 parser.go",
+		"✗ post_loop go test ./...", "    --- FAIL: TestParse", "- post_loop hook failed: nil pointer dereference"} {
+		if !strings.Contains(failed.String(), want) {
+			t.Errorf("缺少 %q:\n%s", want, failed.String())
+		}
+	}
+
+	var done bytes.Buffer
+	execCtx, _ = history.FindLoop("loop-a-1")
+	if err := WriteHistoryLoop(&done, execCtx); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"退出理由: completion detected in output", "== 程式碼區塊 (1) ==\n--- 1 ---\nfunc parse() {}",
+		"== 狀態區塊 ==\nSTATUS: DONE\nEXIT_SIGNAL: true\nTASKS_DONE: 3/3"} {
+		if !strings.Contains(done.String(), want) {
+			t.Errorf("缺少 %q:\n%s", want, done.String())
+		}
+	}
+}
+
+// TestClientSavesFailedLoops 測試失敗的迴圈也會保存，並記錄迴圈結果與執行器
+func TestClientSavesFailedLoops(t *testing.T) {
+	installFakeCopilot(t, `echo "全部完成 done"`)
+	workDir := t.TempDir()
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.ShellHooks = []ShellHook{{Point: HookPostLoop, Command: "echo lint; exit 1", Enforce: true}}
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+
+	client.ExecuteUntilCompletion(t.Context(), "任務", 2)
+
+	history, err := LoadHistory(config.SaveDir, config.RunsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loops := history.FilterLoops(HistoryFilter{RunID: client.RunID(), Status: LoopOutcomeFailed})
+	if len(loops) != 2 {
+		t.Fatalf("兩個失敗的迴圈都應保存: %+v", history.Loops())
+	}
+	if loops[0].Outcome != LoopOutcomeFailed || loops[0].ExecutionMode != "cli" || len(loops[0].HookResults) != 1 || loops[0].HookResults[0].Output != "lint\n" {
+		t.Errorf("應保存迴圈結果與驗證輸出: %+v", loops[0])
+	}
+	if len(history.Runs) != 1 || history.Runs[0].Status != RunStatusFailed || history.Runs[0].LoopCount != 2 {
+		t.Errorf("run 應合併執行摘要: %+v", history.Runs)
+	}
+}
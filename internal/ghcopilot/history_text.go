@@ -0,0 +1,207 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// historyTimeLayout 列表中的時間格式（本地時區）
+const historyTimeLayout = "2006-01-02 15:04:05"
+
+// WriteHistoryRuns 以表格輸出執行列表
+func WriteHistoryRuns(w io.Writer, runs []*HistoryRun) error {
+	rows := [][]string{{"RUN ID", "開始時間", "狀態", "模型", "迴圈", "目標"}}
+	for _, run := range runs {
+		rows = append(rows, []string{
+			orDash(run.RunID),
+			run.StartedAt.Local().Format(historyTimeLayout),
+			orDash(string(run.Status)),
+			orDash(run.Model),
+			strconv.Itoa(run.LoopCount),
+			historySummary(run.Goal, 60),
+		})
+	}
+	return writeHistoryTable(w, rows)
+}
+
+// WriteHistoryLoops 以表格輸出迴圈列表
+func WriteHistoryLoops(w io.Writer, loops []*ExecutionContext) error {
+	rows := [][]string{{"LOOP ID", "RUN ID", "#", "時間", "耗時", "結果", "分數", "退出理由"}}
+	for _, execCtx := range loops {
+		rows = append(rows, []string{
+			execCtx.LoopID,
+			orDash(execCtx.RunID),
+			strconv.Itoa(execCtx.LoopIndex + 1),
+			execCtx.Timestamp.Local().Format(historyTimeLayout),
+			formatReportDuration(time.Duration(execCtx.DurationMs) * time.Millisecond),
+			LoopOutcome(execCtx),
+			strconv.Itoa(execCtx.CompletionScore),
+			orDash(historySummary(execCtx.ExitReason, 60)),
+		})
+	}
+	return writeHistoryTable(w, rows)
+}
+
+// writeHistoryTable 依顯示寬度對齊欄位（中文佔兩欄，最後一欄不補空白）
+func writeHistoryTable(w io.Writer, rows [][]string) error {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(row)-1 {
+				b.WriteString(cell)
+				break
+			}
+			b.WriteString(fitWidth(cell, widths[i]+2))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHistoryLoop 輸出單一迴圈的完整內容：prompt、輸出、程式碼區塊、狀態區塊與驗證結果
+func WriteHistoryLoop(w io.Writer, execCtx *ExecutionContext) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "迴圈 %s\n", execCtx.LoopID)
+	fmt.Fprintf(&b, "Run:      %s (第 %d 個迴圈)\n", orDash(execCtx.RunID), execCtx.LoopIndex+1)
+	fmt.Fprintf(&b, "時間:     %s (耗時 %s)\n",
+		execCtx.Timestamp.Local().Format(historyTimeLayout),
+		formatReportDuration(time.Duration(execCtx.DurationMs)*time.Millisecond))
+	fmt.Fprintf(&b, "模型:     %s\n", orDash(execCtx.Model))
+	fmt.Fprintf(&b, "結果:     %s (完成分數 %d, 熔斷器 %s)\n",
+		LoopOutcome(execCtx), execCtx.CompletionScore, orDash(execCtx.CircuitBreakerState))
+	if execCtx.ExitReason != "" {
+		fmt.Fprintf(&b, "退出理由: %s\n", execCtx.ExitReason)
+	}
+
+	historySection(&b, "Prompt", execCtx.UserPrompt)
+	if execCtx.UserFeedback != "" {
+		historySection(&b, "使用者反饋", execCtx.UserFeedback)
+	}
+	historySection(&b, "輸出", execCtx.CLIOutput)
+
+	if len(execCtx.ParsedCodeBlocks) > 0 {
+		fmt.Fprintf(&b, "\n== 程式碼區塊 (%d) ==\n", len(execCtx.ParsedCodeBlocks))
+		for i, block := range execCtx.ParsedCodeBlocks {
+			fmt.Fprintf(&b, "--- %d ---\n%s\n", i+1, strings.TrimRight(block, "\n"))
+		}
+	}
+
+	if status := execCtx.StructuredStatus; status != nil {
+		b.WriteString("\n== 狀態區塊 ==\n")
+		fmt.Fprintf(&b, "STATUS: %s\n", status.Status)
+		fmt.Fprintf(&b, "EXIT_SIGNAL: %v\n", status.ExitSignal)
+		if status.TasksDone != "" {
+			fmt.Fprintf(&b, "TASKS_DONE: %s\n", status.TasksDone)
+		}
+		if status.NextStep != "" {
+			fmt.Fprintf(&b, "NEXT_STEP: %s\n", status.NextStep)
+		}
+		if status.ErrorMessage != "" {
+			fmt.Fprintf(&b, "ERROR: %s\n", status.ErrorMessage)
+		}
+	}
+
+	if len(execCtx.ToolCalls) > 0 {
+		b.WriteString("\n== 工具呼叫 ==\n")
+		for _, call := range execCtx.ToolCalls {
+			mark := "✓"
+			if call.Error != "" {
+				mark = "✗"
+			}
+			fmt.Fprintf(&b, "%s %s (%s)", mark, call.Name, formatReportDuration(time.Duration(call.DurationMs)*time.Millisecond))
+			if call.Error != "" {
+				fmt.Fprintf(&b, ": %s", call.Error)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(execCtx.PermissionDecisions) > 0 {
+		b.WriteString("\n== 權限請求 ==\n")
+		for _, decision := range execCtx.PermissionDecisions {
+			mark := "✓"
+			if !decision.Allowed {
+				mark = "✗"
+			}
+			fmt.Fprintf(&b, "%s %s %s: %s\n", mark, decision.Request.Kind, truncateString(decision.Request.Target(), 120), decision.Reason)
+		}
+	}
+
+	if len(execCtx.HookResults) > 0 {
+		b.WriteString("\n== 驗證結果 ==\n")
+		for _, result := range execCtx.HookResults {
+			mark := "✓"
+			if !result.OK {
+				mark = "✗"
+			}
+			fmt.Fprintf(&b, "%s %s %s (%s)", mark, result.Point, result.Name(),
+				formatReportDuration(time.Duration(result.DurationMs)*time.Millisecond))
+			if result.Error != "" {
+				fmt.Fprintf(&b, ": %s", result.Error)
+			}
+			b.WriteString("\n")
+			if !result.OK && result.Output != "" {
+				for _, line := range strings.Split(strings.TrimRight(result.Output, "\n"), "\n") {
+					fmt.Fprintf(&b, "    %s\n", line)
+				}
+			}
+		}
+	}
+
+	if len(execCtx.WorkspaceChanges) > 0 {
+		b.WriteString("\n== 檔案變更 ==\n")
+		for _, change := range execCtx.WorkspaceChanges {
+			fmt.Fprintf(&b, "%-8s %s (+%d -%d)\n", change.Kind, change.Path, change.LinesAdded, change.LinesRemoved)
+		}
+	}
+
+	if len(execCtx.ErrorHistory) > 0 {
+		b.WriteString("\n== 錯誤 ==\n")
+		for _, msg := range execCtx.ErrorHistory {
+			fmt.Fprintf(&b, "- %s\n", msg)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// historySection 輸出有標題的文字區段（空白時標示為無）
+func historySection(b *strings.Builder, title, text string) {
+	fmt.Fprintf(b, "\n== %s ==\n", title)
+	text = strings.TrimRight(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		text = "(無)"
+	}
+	b.WriteString(text)
+	b.WriteString("\n")
+}
+
+// historySummary 將文字壓成一行並截斷到 limit 個字元
+func historySummary(s string, limit int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit-1]) + "…"
+}
+
+// orDash 空字串以 "-" 顯示
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
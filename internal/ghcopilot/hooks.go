@@ -0,0 +1,247 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHookTimeout 殼層 hook 的預設逾時
+const DefaultHookTimeout = 30 * time.Second
+
+// maxHookOutputBytes 保留在 hook 結果中的輸出上限
+const maxHookOutputBytes = 64 << 10
+
+// HookPoint 代表 hook 的觸發時機
+type HookPoint string
+
+const (
+	// HookPreLoop 迴圈執行前；失敗時可否決本次迴圈
+	HookPreLoop HookPoint = "pre_loop"
+	// HookPostLoop 迴圈分析完成後；失敗時可將迴圈標記為失敗
+	HookPostLoop HookPoint = "post_loop"
+	// HookOnComplete 任務完成時
+	HookOnComplete HookPoint = "on_complete"
+	// HookOnBreakerOpen 熔斷器打開時
+	HookOnBreakerOpen HookPoint = "on_breaker_open"
+)
+
+// HookPoints 所有 hook 觸發時機
+var HookPoints = []HookPoint{HookPreLoop, HookPostLoop, HookOnComplete, HookOnBreakerOpen}
+
+// HookContext 描述觸發 hook 時的迴圈狀態
+type HookContext struct {
+	Point          HookPoint
+	RunID          string
+	LoopIndex      int
+	Prompt         string
+	WorkDir        string
+	ExitReason     string
+	BreakerState   string
+	ShouldContinue bool
+	Failed         bool
+	Changes        ChangeStats
+}
+
+// Env 將 hook 上下文轉為環境變數（供殼層 hook 使用）
+func (hc *HookContext) Env() []string {
+	return []string{
+		"RALPH_HOOK=" + string(hc.Point),
+		"RALPH_RUN_ID=" + hc.RunID,
+		"RALPH_LOOP_INDEX=" + strconv.Itoa(hc.LoopIndex),
+		"RALPH_PROMPT=" + hc.Prompt,
+		"RALPH_WORKDIR=" + hc.WorkDir,
+		"RALPH_EXIT_REASON=" + hc.ExitReason,
+		"RALPH_BREAKER_STATE=" + hc.BreakerState,
+		"RALPH_SHOULD_CONTINUE=" + strconv.FormatBool(hc.ShouldContinue),
+		"RALPH_FAILED=" + strconv.FormatBool(hc.Failed),
+		"RALPH_FILES_CHANGED=" + strconv.Itoa(hc.Changes.FilesChanged),
+		"RALPH_LINES_ADDED=" + strconv.Itoa(hc.Changes.LinesAdded),
+		"RALPH_LINES_REMOVED=" + strconv.Itoa(hc.Changes.LinesRemoved),
+	}
+}
+
+// LoopHook 是在迴圈生命週期中被呼叫的 Go hook
+//
+// 與殼層 hook 相同，錯誤預設只會被記錄；hook 實作 EnforcedHook 且 Enforced 傳回 true 時，
+// PreLoop 的錯誤否決本次迴圈（結束整次執行），PostLoop 的錯誤將迴圈標記為失敗。
+// OnComplete 與 OnBreakerOpen 的錯誤一律只會被記錄。
+type LoopHook interface {
+	PreLoop(ctx context.Context, hc *HookContext) error
+	PostLoop(ctx context.Context, hc *HookContext) error
+	OnComplete(ctx context.Context, hc *HookContext) error
+	OnBreakerOpen(ctx context.Context, hc *HookContext) error
+}
+
+// EnforcedHook 讓 LoopHook 決定失敗時是否否決或標記迴圈失敗（對應 ShellHook.Enforce）
+type EnforcedHook interface {
+	Enforced() bool
+}
+
+// HookFuncs 以函式實作 LoopHook，未設定的欄位不做任何事
+type HookFuncs struct {
+	PreLoopFunc       func(ctx context.Context, hc *HookContext) error
+	PostLoopFunc      func(ctx context.Context, hc *HookContext) error
+	OnCompleteFunc    func(ctx context.Context, hc *HookContext) error
+	OnBreakerOpenFunc func(ctx context.Context, hc *HookContext) error
+	Enforce           bool // 失敗時否決迴圈 (pre_loop) 或標記迴圈失敗 (post_loop)
+}
+
+// Enforced 實作 EnforcedHook
+func (h HookFuncs) Enforced() bool {
+	return h.Enforce
+}
+
+// PreLoop 實作 LoopHook
+func (h HookFuncs) PreLoop(ctx context.Context, hc *HookContext) error {
+	return callHookFunc(ctx, h.PreLoopFunc, hc)
+}
+
+// PostLoop 實作 LoopHook
+func (h HookFuncs) PostLoop(ctx context.Context, hc *HookContext) error {
+	return callHookFunc(ctx, h.PostLoopFunc, hc)
+}
+
+// OnComplete 實作 LoopHook
+func (h HookFuncs) OnComplete(ctx context.Context, hc *HookContext) error {
+	return callHookFunc(ctx, h.OnCompleteFunc, hc)
+}
+
+// OnBreakerOpen 實作 LoopHook
+func (h HookFuncs) OnBreakerOpen(ctx context.Context, hc *HookContext) error {
+	return callHookFunc(ctx, h.OnBreakerOpenFunc, hc)
+}
+
+func callHookFunc(ctx context.Context, fn func(ctx context.Context, hc *HookContext) error, hc *HookContext) error {
+	if fn == nil {
+		return nil
+	}
+	return fn(ctx, hc)
+}
+
+// ShellHook 是在配置中宣告的殼層 hook
+//
+// 指令透過 sh -c（Windows 為 cmd /C）在工作目錄中執行，
+// 迴圈狀態以 RALPH_* 環境變數傳入。
+type ShellHook struct {
+	Point   HookPoint     `json:"point"`             // 觸發時機
+	Command string        `json:"command"`           // 殼層指令
+	Timeout time.Duration `json:"timeout,omitempty"` // 逾時 (預設: DefaultHookTimeout)
+	Enforce bool          `json:"enforce,omitempty"` // 失敗時否決迴圈 (pre_loop) 或標記迴圈失敗 (post_loop)
+}
+
+// ParseShellHook 解析 "point=command" 格式的 hook 宣告
+func ParseShellHook(spec string, enforce bool) (ShellHook, error) {
+	point, command, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(command) == "" {
+		return ShellHook{}, fmt.Errorf("hook 格式應為 point=command: %q", spec)
+	}
+
+	hook := ShellHook{
+		Point:   HookPoint(strings.TrimSpace(point)),
+		Command: strings.TrimSpace(command),
+		Enforce: enforce,
+	}
+	for _, valid := range HookPoints {
+		if hook.Point == valid {
+			return hook, nil
+		}
+	}
+	return ShellHook{}, fmt.Errorf("未知的 hook 時機 %q (可用: %s)", point, joinHookPoints())
+}
+
+func joinHookPoints() string {
+	names := make([]string, len(HookPoints))
+	for i, point := range HookPoints {
+		names[i] = string(point)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Run 執行殼層指令，非零退出碼或逾時時傳回錯誤（包含輸出摘要）
+func (h ShellHook) Run(ctx context.Context, hc *HookContext) error {
+	_, err := h.Execute(ctx, hc)
+	return err
+}
+
+// Execute 執行殼層指令並傳回合併的 stdout/stderr（超過上限時截斷）
+//
+// 錯誤與 Run 相同；輸出供 SARIF 等工具解析診斷訊息。
+func (h ShellHook) Execute(ctx context.Context, hc *HookContext) (string, error) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(runCtx, "cmd", "/C", h.Command)
+	} else {
+		cmd = exec.CommandContext(runCtx, "sh", "-c", h.Command)
+	}
+	cmd.Dir = hc.WorkDir
+	cmd.Env = append(os.Environ(), hc.Env()...)
+	cmd.WaitDelay = time.Second // 逾時後不等待仍持有輸出管線的子程序
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	out := truncateString(output.String(), maxHookOutputBytes)
+	if runCtx.Err() == context.DeadlineExceeded {
+		return out, fmt.Errorf("hook %q 逾時 (%v)", h.Command, timeout)
+	}
+	if err != nil {
+		return out, fmt.Errorf("hook %q 失敗: %v: %s", h.Command, err, truncateString(strings.TrimSpace(output.String()), 500))
+	}
+	return out, nil
+}
+
+// HookResult 是一次 hook 的執行結果（post_loop hook 的結果即該迴圈的驗證結果）
+type HookResult struct {
+	Point      HookPoint `json:"point"`
+	Command    string    `json:"command,omitempty"` // Go hook 為空字串
+	Enforce    bool      `json:"enforce"`
+	OK         bool      `json:"ok"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"` // 殼層 hook 的輸出
+}
+
+// Name 傳回 hook 的顯示名稱
+func (r HookResult) Name() string {
+	if r.Command == "" {
+		return "(Go hook)"
+	}
+	return r.Command
+}
+
+// callLoopHook 依觸發時機呼叫 Go hook
+// loopHookEnforced 檢查 Go hook 的失敗是否應否決或標記迴圈失敗
+func loopHookEnforced(hook LoopHook) bool {
+	enforced, ok := hook.(EnforcedHook)
+	return ok && enforced.Enforced()
+}
+
+func callLoopHook(ctx context.Context, hook LoopHook, hc *HookContext) error {
+	switch hc.Point {
+	case HookPreLoop:
+		return hook.PreLoop(ctx, hc)
+	case HookPostLoop:
+		return hook.PostLoop(ctx, hc)
+	case HookOnComplete:
+		return hook.OnComplete(ctx, hc)
+	case HookOnBreakerOpen:
+		return hook.OnBreakerOpen(ctx, hc)
+	}
+	return nil
+}
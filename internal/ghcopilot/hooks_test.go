@@ -0,0 +1,217 @@
+package ghcopilot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseShellHook 測試 hook 宣告解析
+func TestParseShellHook(t *testing.T) {
+	hook, err := ParseShellHook("post_loop=gofmt -l . && go vet ./...", true)
+	if err != nil {
+		t.Fatalf("ParseShellHook 失敗: %v", err)
+	}
+	if hook.Point != HookPostLoop || hook.Command != "gofmt -l . && go vet ./..." || !hook.Enforce {
+		t.Errorf("解析結果不正確: %+v", hook)
+	}
+
+	for _, spec := range []string{"post_loop", "post_loop=", "after=echo"} {
+		if _, err := ParseShellHook(spec, false); err == nil {
+			t.Errorf("%q 應傳回錯誤", spec)
+		}
+	}
+}
+
+// TestShellHookRun 測試殼層 hook 的環境變數、失敗與逾時
+func TestShellHookRun(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+	workDir := t.TempDir()
+	hc := &HookContext{Point: HookPostLoop, RunID: "run-x", LoopIndex: 2, WorkDir: workDir, ExitReason: "done"}
+
+	ok := ShellHook{Point: HookPostLoop, Command: `echo "$RALPH_HOOK $RALPH_RUN_ID $RALPH_LOOP_INDEX $RALPH_EXIT_REASON" > env.txt`}
+	if err := ok.Run(context.Background(), hc); err != nil {
+		t.Fatalf("hook 失敗: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "env.txt"))
+	if strings.TrimSpace(string(data)) != "post_loop run-x 2 done" {
+		t.Errorf("環境變數不正確: %q", data)
+	}
+
+	fail := ShellHook{Command: "echo lint error; exit 3"}
+	if err := fail.Run(context.Background(), hc); err == nil || !strings.Contains(err.Error(), "lint error") {
+		t.Errorf("失敗時應包含輸出，但為 %v", err)
+	}
+
+	slow := ShellHook{Command: "sleep 5", Timeout: 50 * time.Millisecond}
+	if err := slow.Run(context.Background(), hc); err == nil || !strings.Contains(err.Error(), "逾時") {
+		t.Errorf("應逾時，但為 %v", err)
+	}
+}
+
+// TestClientPreLoopHookVeto 測試 pre_loop hook 否決迴圈
+func TestClientPreLoopHookVeto(t *testing.T) {
+	installFakeCopilot(t, `echo "working"
+`)
+
+	var points []HookPoint
+	client := NewClientBuilder().
+		WithWorkDir(t.TempDir()).
+		WithHook(HookFuncs{
+			PreLoopFunc: func(ctx context.Context, hc *HookContext) error {
+				points = append(points, hc.Point)
+				if hc.LoopIndex == 1 {
+					return errors.New("維護時段")
+				}
+				return nil
+			},
+			Enforce: true,
+		}).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	results, err := client.ExecuteUntilCompletion(t.Context(), "任務", 5)
+	if err == nil || !strings.Contains(err.Error(), "vetoed by pre_loop hook: 維護時段") {
+		t.Errorf("應被 pre_loop hook 否決，但為 %v", err)
+	}
+	if len(results) != 1 || len(client.GetHistory()) != 1 {
+		t.Errorf("被否決的迴圈不應執行或記錄: results=%d history=%d", len(results), len(client.GetHistory()))
+	}
+	if len(points) != 2 {
+		t.Errorf("pre_loop hook 應被呼叫 2 次，但為 %d", len(points))
+	}
+}
+
+// TestClientGoHookNotEnforced 測試未強制的 Go hook 失敗只會被記錄
+func TestClientGoHookNotEnforced(t *testing.T) {
+	installFakeCopilot(t, `echo "working"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(t.TempDir()).
+		WithHook(HookFuncs{
+			PreLoopFunc:  func(ctx context.Context, hc *HookContext) error { return errors.New("指標服務無回應") },
+			PostLoopFunc: func(ctx context.Context, hc *HookContext) error { return errors.New("通知失敗") },
+		}).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	results, err := client.ExecuteUntilCompletion(t.Context(), "任務", 2)
+	if err != nil && strings.Contains(err.Error(), "vetoed") {
+		t.Fatalf("未強制的 pre_loop hook 不應否決迴圈: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("應執行 2 個迴圈，但為 %d", len(results))
+	}
+	loop := client.GetHistory()[0]
+	if loop.Failed {
+		t.Error("未強制的 post_loop hook 不應將迴圈標記為失敗")
+	}
+	if !containsItem(loop.ErrorHistory, "post_loop hook failed: 通知失敗") {
+		t.Errorf("hook 錯誤應被記錄: %v", loop.ErrorHistory)
+	}
+	for _, r := range loop.HookResults {
+		if r.Enforce {
+			t.Errorf("hook 結果不應標記為強制: %+v", r)
+		}
+	}
+}
+
+// TestClientPostLoopShellHookMarksFailed 測試強制的 post_loop 殼層 hook 將迴圈標記為失敗
+func TestClientPostLoopShellHookMarksFailed(t *testing.T) {
+	workDir := t.TempDir()
+	installFakeCopilot(t, `echo "$2" > last_prompt.txt
+echo "全部完成 done"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithProtectedPaths().
+		WithShellHook(ShellHook{Point: HookPostLoop, Command: `echo "vet: unused variable"; exit 1`, Enforce: true}).
+		WithShellHook(ShellHook{Point: HookPostLoop, Command: `exit 1`}).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	result, err := client.ExecuteLoop(t.Context(), "任務")
+	if err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+	if !result.ShouldContinue {
+		t.Error("post_loop 失敗時即使輸出完成也應繼續")
+	}
+
+	loop := client.GetHistory()[0]
+	if !loop.Failed {
+		t.Error("迴圈應被標記為失敗")
+	}
+	hookErrors := 0
+	for _, e := range loop.ErrorHistory {
+		if strings.HasPrefix(e, "post_loop hook failed") {
+			hookErrors++
+		}
+	}
+	if hookErrors != 2 {
+		t.Errorf("兩個 hook 的失敗都應被記錄: %v", loop.ErrorHistory)
+	}
+	if len(loop.HookResults) != 2 {
+		t.Fatalf("兩個 hook 的結果都應被保存: %+v", loop.HookResults)
+	}
+	if r := loop.HookResults[0]; r.OK || !r.Enforce || r.Point != HookPostLoop || r.Output != "vet: unused variable\n" {
+		t.Errorf("hook 結果應包含完整輸出: %+v", r)
+	}
+
+	client.ExecuteLoop(t.Context(), "任務")
+	prompt, _ := os.ReadFile(filepath.Join(workDir, "last_prompt.txt"))
+	if !strings.Contains(string(prompt), "vet: unused variable") {
+		t.Errorf("失敗原因應注入下一輪 prompt: %s", prompt)
+	}
+}
+
+// TestClientCompletionAndBreakerHooks 測試 on_complete 與 on_breaker_open hook
+func TestClientCompletionAndBreakerHooks(t *testing.T) {
+	var completed, opened []*HookContext
+	hooks := HookFuncs{
+		OnCompleteFunc: func(ctx context.Context, hc *HookContext) error {
+			completed = append(completed, hc)
+			return nil
+		},
+		OnBreakerOpenFunc: func(ctx context.Context, hc *HookContext) error {
+			opened = append(opened, hc)
+			return errors.New("通知失敗")
+		},
+	}
+
+	installFakeCopilot(t, `echo "全部完成 done"
+`)
+	done := NewClientBuilder().WithWorkDir(t.TempDir()).WithHook(hooks).WithoutPersistence().Build()
+	done.ExecuteUntilCompletion(t.Context(), "任務", 3)
+	done.Close()
+
+	if len(completed) != 1 || completed[0].ExitReason != "completion detected in output" || completed[0].ShouldContinue {
+		t.Errorf("on_complete 應被呼叫一次並帶有退出理由: %+v", completed)
+	}
+
+	installFakeCopilot(t, `echo "still working"
+`)
+	stuck := NewClientBuilder().WithWorkDir(t.TempDir()).WithHook(hooks).WithoutPersistence().Build()
+	defer stuck.Close()
+	stuck.ExecuteUntilCompletion(t.Context(), "任務", 5)
+
+	if len(opened) != 1 || opened[0].BreakerState != string(StateOpen) {
+		t.Fatalf("on_breaker_open 應被呼叫一次: %+v", opened)
+	}
+	history := stuck.GetHistory()
+	last := history[len(history)-1]
+	if !containsItem(last.ErrorHistory, "on_breaker_open hook failed: 通知失敗") {
+		t.Errorf("hook 錯誤應被記錄: %v", last.ErrorHistory)
+	}
+}
@@ -0,0 +1,237 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JournalFileName 執行紀錄在 run 目錄中的檔名
+const JournalFileName = "journal.jsonl"
+
+// NewRunID 產生一次執行的 ID（依時間排序）
+func NewRunID() string {
+	return fmt.Sprintf("run-%s-%04x", time.Now().Format("20060102-150405"), rand.Intn(0x10000))
+}
+
+// Journal 將迴圈事件以 JSON Lines 格式附加寫入檔案
+//
+// 每個 run 有自己的目錄（.ralph-loop/runs/<run-id>/），journal.jsonl 記錄
+// 該次執行的所有事件，可用於事後稽核、報告與重播。
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	enc  *json.Encoder
+}
+
+// OpenJournal 開啟（或建立）執行紀錄檔
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("無法建立執行紀錄目錄: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("無法開啟執行紀錄: %w", err)
+	}
+
+	return &Journal{
+		path: path,
+		file: file,
+		enc:  json.NewEncoder(file),
+	}, nil
+}
+
+// Path 傳回執行紀錄檔路徑
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Write 寫入一個事件
+func (j *Journal) Write(event LoopEvent) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return fmt.Errorf("journal is closed")
+	}
+	return j.enc.Encode(event)
+}
+
+// Handler 傳回可訂閱事件匯流排的處理函式（寫入錯誤會被忽略）
+func (j *Journal) Handler() EventHandler {
+	return func(event LoopEvent) {
+		_ = j.Write(event)
+	}
+}
+
+// Close 關閉執行紀錄檔
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// JournalRun 描述 RunsDir 中一次執行的執行紀錄
+type JournalRun struct {
+	ID        string    `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"` // 執行紀錄最後寫入的時間
+	Size      int64     `json:"size"`       // 執行紀錄大小（位元組）
+}
+
+// ListJournalRuns 列出 runsDir 中有執行紀錄的 run（依 ID 排序，最新的在前）
+func ListJournalRuns(runsDir string) ([]JournalRun, error) {
+	entries, err := os.ReadDir(runsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []JournalRun{}, nil
+		}
+		return nil, fmt.Errorf("無法讀取執行紀錄目錄: %w", err)
+	}
+
+	runs := []JournalRun{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(runsDir, entry.Name(), JournalFileName))
+		if err != nil {
+			continue
+		}
+		runs = append(runs, JournalRun{ID: entry.Name(), UpdatedAt: info.ModTime(), Size: info.Size()})
+	}
+
+	// run ID 以時間開頭，字典序即時間順序
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID > runs[j].ID
+	})
+	return runs, nil
+}
+
+// ReadJournal 讀取執行紀錄中的所有事件
+func ReadJournal(path string) ([]LoopEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("無法開啟執行紀錄: %w", err)
+	}
+	defer file.Close()
+
+	var events []LoopEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event LoopEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return events, fmt.Errorf("執行紀錄第 %d 行格式錯誤: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return events, fmt.Errorf("讀取執行紀錄失敗: %w", err)
+	}
+
+	return events, nil
+}
+
+// journalLine 是執行紀錄中的一行
+type journalLine struct {
+	number int
+	data   []byte
+}
+
+// eventType 取得事件類型（只解析 type 欄位，避免反序列化整個事件）
+func (l journalLine) eventType() string {
+	const key = `"type":"`
+	start := bytes.Index(l.data, []byte(key))
+	if start < 0 {
+		return "message"
+	}
+	rest := l.data[start+len(key):]
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return "message"
+	}
+	return string(rest[:end])
+}
+
+// journalTail 逐行讀取持續附加寫入的執行紀錄，保留尚未寫完的最後一行
+type journalTail struct {
+	reader  *bufio.Reader
+	partial []byte
+	line    int
+}
+
+// next 傳回目前可讀取的完整行（空行會被略過但仍計入行號）
+func (t *journalTail) next() ([]journalLine, error) {
+	var lines []journalLine
+	for {
+		chunk, err := t.reader.ReadBytes('\n')
+		t.partial = append(t.partial, chunk...)
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+
+		t.line++
+		data := bytes.TrimSpace(t.partial)
+		t.partial = nil
+		if len(data) > 0 {
+			lines = append(lines, journalLine{number: t.line, data: data})
+		}
+	}
+}
+
+// FollowJournal 讀取執行紀錄並持續追蹤新寫入的事件，直到 ctx 結束
+//
+// 每隔 interval 檢查一次新內容；格式錯誤的行會被略過。
+func FollowJournal(ctx context.Context, path string, interval time.Duration, handler EventHandler) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("無法開啟執行紀錄: %w", err)
+	}
+	defer file.Close()
+
+	tail := &journalTail{reader: bufio.NewReaderSize(file, 64*1024)}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		lines, err := tail.next()
+		if err != nil {
+			return fmt.Errorf("讀取執行紀錄失敗: %w", err)
+		}
+		for _, line := range lines {
+			var event LoopEvent
+			if json.Unmarshal(line.data, &event) == nil {
+				handler(event)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ghcopilot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestJournalWriteAndRead 測試寫入與讀取執行紀錄
+func TestJournalWriteAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs", "run-1", JournalFileName)
+
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal 失敗: %v", err)
+	}
+	handler := journal.Handler()
+	handler(LoopEvent{Type: EventLoopStarted, RunID: "run-1", Text: "任務"})
+	handler(LoopEvent{Type: EventLoopFinished, RunID: "run-1", ExitReason: "done", Data: map[string]interface{}{"duration_ms": 12}})
+	journal.Close()
+
+	if err := journal.Write(LoopEvent{}); err == nil {
+		t.Error("關閉後寫入應傳回錯誤")
+	}
+
+	events, err := ReadJournal(path)
+	if err != nil {
+		t.Fatalf("ReadJournal 失敗: %v", err)
+	}
+	if len(events) != 2 || events[0].Text != "任務" || events[1].ExitReason != "done" {
+		t.Errorf("讀取的事件不正確: %+v", events)
+	}
+	if events[1].Data["duration_ms"] != float64(12) {
+		t.Errorf("Data 應保留: %+v", events[1].Data)
+	}
+
+	// 再次開啟應附加
+	journal, _ = OpenJournal(path)
+	journal.Write(LoopEvent{Type: EventLoopStarted})
+	journal.Close()
+	if events, _ := ReadJournal(path); len(events) != 3 {
+		t.Errorf("應附加到既有紀錄，但有 %d 個事件", len(events))
+	}
+}
+
+// TestReadJournalInvalidLine 測試格式錯誤的紀錄
+func TestReadJournalInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), JournalFileName)
+	os.WriteFile(path, []byte("{\"type\":\"loop_started\"}\n\nnot json\n"), 0644)
+
+	events, err := ReadJournal(path)
+	if err == nil || !strings.Contains(err.Error(), "第 3 行") {
+		t.Errorf("應回報第 3 行格式錯誤，但為 %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("錯誤前的事件應保留，但為 %d", len(events))
+	}
+}
+
+// TestNewRunID 測試執行 ID 格式
+func TestNewRunID(t *testing.T) {
+	id := NewRunID()
+	if !strings.HasPrefix(id, "run-") || len(id) != len("run-20060102-150405-0000") {
+		t.Errorf("執行 ID 格式不正確: %s", id)
+	}
+}
+
+// TestClientRecordsInWorkDir 測試程序目前目錄不是工作目錄時，紀錄仍寫入工作目錄
+func TestClientRecordsInWorkDir(t *testing.T) {
+	workDir := t.TempDir()
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	config.Executor = &stubExecutor{output: "完成"}
+	client := NewRalphLoopClientWithConfig(config)
+	if _, err := client.ExecuteLoop(t.Context(), "任務"); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	runsDir := ResolveWorkDirPath(workDir, DefaultClientConfig().RunsDir)
+	if client.RunDir() != filepath.Join(runsDir, client.RunID()) {
+		t.Errorf("run 目錄應位於工作目錄中: %s", client.RunDir())
+	}
+	runs, err := ListJournalRuns(runsDir)
+	if err != nil || len(runs) != 1 || runs[0].ID != client.RunID() {
+		t.Errorf("工作目錄中應有這次的執行紀錄: %+v, %v", runs, err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".ralph-loop", "saves")); err != nil {
+		t.Errorf("儲存目錄應位於工作目錄中: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, ".ralph-loop")); !os.IsNotExist(err) {
+		t.Errorf("不應在程序目前目錄寫入紀錄: %v", err)
+	}
+}
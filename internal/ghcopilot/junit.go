@@ -0,0 +1,178 @@
+package ghcopilot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// junitOutputBytes 每個 testcase 的 system-out 上限
+const junitOutputBytes = 16 << 10
+
+// JUnit XML 的結構（Jenkins、GitLab、GitHub Actions 等 CI 通用的格式）
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",cdata"`
+}
+
+// WriteJUnitReport 將迴圈歷史輸出為 JUnit XML，每個迴圈是一個 testcase
+//
+// 迴圈失敗（驗證失敗、違反工作目錄策略、被拒絕）或熔斷器在迴圈結束時打開時，
+// 該 testcase 標記為 failure；仍在繼續的迴圈不算失敗。
+func WriteJUnitReport(w io.Writer, runID string, history []*ExecutionContext) error {
+	suiteName := "ralph-loop"
+	if runID != "" {
+		suiteName += "." + runID
+	}
+
+	suite := junitTestSuite{Name: suiteName, Tests: len(history)}
+	var total time.Duration
+	for _, execCtx := range history {
+		duration := time.Duration(execCtx.DurationMs) * time.Millisecond
+		total += duration
+
+		testCase := junitTestCase{
+			Name:      fmt.Sprintf("loop %d", execCtx.LoopIndex+1),
+			ClassName: suiteName,
+			Time:      junitSeconds(duration),
+			Failure:   junitLoopFailure(execCtx),
+		}
+		if output := junitText(truncateString(execCtx.CLIOutput, junitOutputBytes)); output != "" {
+			testCase.SystemOut = &junitOutput{Text: output}
+		}
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = junitSeconds(total)
+
+	if len(history) > 0 {
+		first := history[0]
+		suite.Timestamp = first.Timestamp.UTC().Format("2006-01-02T15:04:05")
+		suite.Properties = []junitProperty{
+			{Name: "run_id", Value: runID},
+			{Name: "model", Value: first.Model},
+			{Name: "prompt", Value: first.UserPrompt},
+		}
+	}
+
+	suites := junitTestSuites{
+		Name:     "ralph-loop",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return fmt.Errorf("JUnit XML 編碼失敗: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitLoopFailure 判斷迴圈是否失敗，並整理失敗原因
+func junitLoopFailure(execCtx *ExecutionContext) *junitFailure {
+	breakerOpen := execCtx.CircuitBreakerState == string(StateOpen)
+	if !execCtx.Failed && !breakerOpen {
+		return nil
+	}
+
+	failure := &junitFailure{Type: "loop_failed", Message: execCtx.ExitReason}
+	var failedHooks []HookResult
+	for _, result := range execCtx.HookResults {
+		if !result.OK && result.Point == HookPostLoop {
+			failedHooks = append(failedHooks, result)
+		}
+	}
+	switch {
+	case breakerOpen:
+		failure.Type = "circuit_breaker_open"
+		failure.Message = "circuit breaker opened"
+	case len(failedHooks) > 0:
+		failure.Type = "verification_failed"
+		failure.Message = failedHooks[0].Error
+	}
+	if failure.Message == "" && len(execCtx.ErrorHistory) > 0 {
+		failure.Message = execCtx.ErrorHistory[len(execCtx.ErrorHistory)-1]
+	}
+	if failure.Message == "" {
+		failure.Message = "loop failed"
+	}
+
+	var body strings.Builder
+	for _, msg := range execCtx.ErrorHistory {
+		body.WriteString(msg)
+		body.WriteString("\n")
+	}
+	for _, result := range failedHooks {
+		if result.Output == "" {
+			continue
+		}
+		fmt.Fprintf(&body, "\n$ %s\n%s\n", result.Name(), strings.TrimRight(result.Output, "\n"))
+	}
+	failure.Body = junitText(truncateString(body.String(), junitOutputBytes))
+	return failure
+}
+
+// junitText 移除終端機控制序列與 XML 不允許的字元（CDATA 不會跳脫內容）
+func junitText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xfffe || r == 0xffff {
+			return -1
+		}
+		return r
+	}, ansiEscape.ReplaceAllString(s, ""))
+}
+
+// junitSeconds 以秒為單位格式化時間（JUnit 的 time 屬性）
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,107 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+// junitTestHistory 產生三個迴圈：通過、驗證失敗、熔斷器打開
+func junitTestHistory() []*ExecutionContext {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	passed := NewExecutionContext(0, "修正 parser")
+	passed.Timestamp = start
+	passed.DurationMs = 1500
+	passed.Model = "gpt-4.1"
+	passed.CLIOutput = "\x1b[32m已修改 parser.go\x1b[0m"
+	passed.CircuitBreakerState = string(StateClosed)
+
+	verification := NewExecutionContext(1, "修正 parser")
+	verification.DurationMs = 2000
+	verification.Failed = true
+	verification.ErrorHistory = []string{"post_loop hook 失敗: exit status 1"}
+	verification.HookResults = []HookResult{
+		{Point: HookPreLoop, Command: "git status", OK: true},
+		{Point: HookPostLoop, Command: "go vet ./...", Enforce: true, Error: "exit status 1", Output: "./parser.go:3:2: declared and not used: x\n"},
+	}
+
+	breaker := NewExecutionContext(2, "修正 parser")
+	breaker.CircuitBreakerState = string(StateOpen)
+
+	return []*ExecutionContext{passed, verification, breaker}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, "run-1", junitTestHistory()); err != nil {
+		t.Fatalf("WriteJUnitReport 失敗: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Error("應該以 XML 宣告開頭")
+	}
+	if strings.Contains(buf.String(), "\x1b") {
+		t.Error("system-out 不應該包含終端機控制序列")
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("輸出不是合法的 XML: %v\n%s", err, buf.String())
+	}
+	if suites.Tests != 3 || suites.Failures != 2 {
+		t.Errorf("tests/failures = %d/%d, 期望 3/2", suites.Tests, suites.Failures)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("testsuite 數量 = %d, 期望 1", len(suites.Suites))
+	}
+
+	suite := suites.Suites[0]
+	if suite.Name != "ralph-loop.run-1" || suite.Time != "3.500" || suite.Timestamp != "2026-01-01T09:00:00" {
+		t.Errorf("testsuite 屬性不正確: %+v", suite)
+	}
+	if len(suite.Properties) != 3 || suite.Properties[1].Value != "gpt-4.1" {
+		t.Errorf("properties 不正確: %+v", suite.Properties)
+	}
+	if len(suite.Cases) != 3 {
+		t.Fatalf("testcase 數量 = %d, 期望 3", len(suite.Cases))
+	}
+
+	if c := suite.Cases[0]; c.Name != "loop 1" || c.Failure != nil || c.SystemOut == nil || c.SystemOut.Text != "已修改 parser.go" {
+		t.Errorf("第一個迴圈應該通過: %+v", c)
+	}
+
+	verify := suite.Cases[1].Failure
+	if verify == nil || verify.Type != "verification_failed" || verify.Message != "exit status 1" {
+		t.Fatalf("第二個迴圈應該是驗證失敗: %+v", verify)
+	}
+	if !strings.Contains(verify.Body, "$ go vet ./...") || !strings.Contains(verify.Body, "declared and not used: x") {
+		t.Errorf("failure 內容應該包含 hook 輸出:\n%s", verify.Body)
+	}
+
+	if f := suite.Cases[2].Failure; f == nil || f.Type != "circuit_breaker_open" {
+		t.Errorf("第三個迴圈應該是熔斷器打開: %+v", f)
+	}
+}
+
+func TestWriteJUnitReportEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, "", nil); err != nil {
+		t.Fatalf("WriteJUnitReport 失敗: %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("輸出不是合法的 XML: %v", err)
+	}
+	if suites.Tests != 0 || len(suites.Suites) != 1 || suites.Suites[0].Name != "ralph-loop" {
+		t.Errorf("沒有迴圈時應該輸出空的 testsuite: %+v", suites)
+	}
+}
+
+func TestJUnitText(t *testing.T) {
+	got := junitText("ok\x1b[31m red\x1b[0m\x00\x07\tend\n")
+	if got != "ok red\tend\n" {
+		t.Errorf("junitText = %q", got)
+	}
+}
@@ -0,0 +1,193 @@
+package ghcopilot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RunLogFileName 每次執行的日誌在 run 目錄中的檔名
+const RunLogFileName = "ralph-loop.log"
+
+// LogFormat 代表日誌輸出格式
+type LogFormat string
+
+const (
+	// LogFormatText 以 key=value 格式輸出
+	LogFormatText LogFormat = "text"
+	// LogFormatJSON 每行一個 JSON 物件
+	LogFormatJSON LogFormat = "json"
+)
+
+// ParseLogLevel 解析日誌等級（debug、info、warn、error）
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("未知的日誌等級 %q (可用: debug, info, warn, error)", s)
+	}
+	return level, nil
+}
+
+// ParseLogFormat 解析日誌格式（text、json）
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch format := LogFormat(strings.ToLower(strings.TrimSpace(s))); format {
+	case LogFormatText, LogFormatJSON:
+		return format, nil
+	}
+	return LogFormatText, fmt.Errorf("未知的日誌格式 %q (可用: text, json)", s)
+}
+
+// NewLogHandler 依格式建立寫入 w 的 slog handler
+func NewLogHandler(w io.Writer, level slog.Leveler, format LogFormat) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogFormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// defaultLogger 未設定 logger 時使用：寫入 stderr，RALPH_DEBUG=1 時輸出除錯日誌
+func defaultLogger() *slog.Logger {
+	level := slog.LevelInfo
+	if os.Getenv("RALPH_DEBUG") == "1" {
+		level = slog.LevelDebug
+	}
+	return slog.New(NewLogHandler(os.Stderr, level, LogFormatText))
+}
+
+// discardLogger 傳回不輸出任何內容的 logger
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// LogRouter 將日誌分送到多個 handler，並允許在執行中加入新的輸出目標
+//
+// 客戶端建立時各元件已取得 logger，run 目錄的日誌檔要到第一個迴圈才開啟；
+// 透過 LogRouter 加入的 handler 對所有已衍生的 logger 都立即生效。
+type LogRouter struct {
+	mu    sync.RWMutex
+	sinks []logSink
+}
+
+type logSink struct {
+	handler slog.Handler
+	closer  io.Closer // 由 AddFile 開啟的檔案
+}
+
+// NewLogRouter 建立日誌分送器
+func NewLogRouter(handlers ...slog.Handler) *LogRouter {
+	r := &LogRouter{}
+	for _, handler := range handlers {
+		r.sinks = append(r.sinks, logSink{handler: handler})
+	}
+	return r
+}
+
+// Logger 傳回寫入此分送器的 logger
+func (r *LogRouter) Logger() *slog.Logger {
+	return slog.New(&routedHandler{router: r})
+}
+
+// AddHandler 加入輸出目標
+func (r *LogRouter) AddHandler(handler slog.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks = append(r.sinks, logSink{handler: handler})
+}
+
+// AddFile 開啟（附加模式）日誌檔並加入輸出目標，Close 時關閉檔案
+func (r *LogRouter) AddFile(path string, level slog.Leveler, format LogFormat) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("無法建立日誌目錄: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("無法開啟日誌檔: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks = append(r.sinks, logSink{handler: NewLogHandler(file, level, format), closer: file})
+	return nil
+}
+
+// Close 關閉由 AddFile 開啟的日誌檔（檔案 handler 之後不再收到日誌）
+func (r *LogRouter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	var kept []logSink
+	for _, sink := range r.sinks {
+		if sink.closer == nil {
+			kept = append(kept, sink)
+			continue
+		}
+		if err := sink.closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.sinks = kept
+	return firstErr
+}
+
+func (r *LogRouter) snapshot() []slog.Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handlers := make([]slog.Handler, len(r.sinks))
+	for i, sink := range r.sinks {
+		handlers[i] = sink.handler
+	}
+	return handlers
+}
+
+// routedHandler 在每次輸出時才套用 WithAttrs/WithGroup，讓後加入的 handler 也能收到完整屬性
+type routedHandler struct {
+	router *LogRouter
+	ops    []func(slog.Handler) slog.Handler
+}
+
+func (h *routedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.router.snapshot() {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *routedHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, handler := range h.router.snapshot() {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		for _, op := range h.ops {
+			handler = op(handler)
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (h *routedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *routedHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *routedHandler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &routedHandler{router: h.router, ops: append(ops, op)}
+}
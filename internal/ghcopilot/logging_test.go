@@ -0,0 +1,171 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseLogLevel 測試日誌等級解析
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		" warn": slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for input, want := range tests {
+		got, err := ParseLogLevel(input)
+		if err != nil || got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, %v，應為 %v", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Error("未知的等級應傳回錯誤")
+	}
+}
+
+// TestParseLogFormat 測試日誌格式解析
+func TestParseLogFormat(t *testing.T) {
+	if format, err := ParseLogFormat("JSON"); err != nil || format != LogFormatJSON {
+		t.Errorf("應解析為 json: %v, %v", format, err)
+	}
+	if format, err := ParseLogFormat("text"); err != nil || format != LogFormatText {
+		t.Errorf("應解析為 text: %v, %v", format, err)
+	}
+	if _, err := ParseLogFormat("xml"); err == nil {
+		t.Error("未知的格式應傳回錯誤")
+	}
+}
+
+// TestLogRouterAddHandlerAfterWith 測試後加入的 handler 也會收到先前衍生的屬性
+func TestLogRouterAddHandlerAfterWith(t *testing.T) {
+	var first, second bytes.Buffer
+	router := NewLogRouter(NewLogHandler(&first, slog.LevelInfo, LogFormatText))
+	logger := router.Logger().With("run_id", "run-1").WithGroup("loop")
+
+	logger.Info("before", "index", 0)
+	router.AddHandler(NewLogHandler(&second, slog.LevelDebug, LogFormatJSON))
+	logger.Debug("after", "index", 1)
+
+	if !strings.Contains(first.String(), "run_id=run-1") || !strings.Contains(first.String(), "loop.index=0") {
+		t.Errorf("第一個 handler 應包含屬性: %s", first.String())
+	}
+	if strings.Contains(first.String(), "after") {
+		t.Error("info 等級的 handler 不應收到 debug 日誌")
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(second.Bytes(), &record); err != nil {
+		t.Fatalf("第二個 handler 應輸出 JSON: %v (%s)", err, second.String())
+	}
+	if record["msg"] != "after" || record["run_id"] != "run-1" {
+		t.Errorf("第二個 handler 應收到後續日誌與屬性: %v", record)
+	}
+	if loop, ok := record["loop"].(map[string]interface{}); !ok || loop["index"] != float64(1) {
+		t.Errorf("群組屬性應保留: %v", record)
+	}
+}
+
+// TestLogRouterCloseDetachesFiles 測試關閉後檔案不再收到日誌
+func TestLogRouterCloseDetachesFiles(t *testing.T) {
+	var console bytes.Buffer
+	router := NewLogRouter(NewLogHandler(&console, slog.LevelInfo, LogFormatText))
+	path := filepath.Join(t.TempDir(), "runs", "run-1", RunLogFileName)
+	if err := router.AddFile(path, slog.LevelInfo, LogFormatText); err != nil {
+		t.Fatalf("AddFile 失敗: %v", err)
+	}
+
+	logger := router.Logger()
+	logger.Info("written")
+	if err := router.Close(); err != nil {
+		t.Fatalf("Close 失敗: %v", err)
+	}
+	logger.Info("console only")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("讀取日誌檔失敗: %v", err)
+	}
+	if !strings.Contains(string(data), "written") || strings.Contains(string(data), "console only") {
+		t.Errorf("日誌檔內容不正確: %s", data)
+	}
+	if !strings.Contains(console.String(), "console only") {
+		t.Error("關閉檔案後主要輸出仍應運作")
+	}
+}
+
+// TestClientWritesRunLog 測試客戶端將元件日誌寫入 run 目錄，且不輸出到 stdout
+func TestClientWritesRunLog(t *testing.T) {
+	workDir := t.TempDir()
+	installFakeCopilot(t, `echo "still working"
+`)
+
+	var console bytes.Buffer
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.Logger = slog.New(NewLogHandler(&console, slog.LevelWarn, LogFormatText))
+	config.LogLevel = slog.LevelDebug
+	config.LogFormat = LogFormatJSON
+	client := NewRalphLoopClientWithConfig(config)
+
+	if _, err := client.ExecuteLoop(t.Context(), "任務"); err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+	client.Close()
+
+	if client.RunLogPath() != filepath.Join(client.RunDir(), RunLogFileName) {
+		t.Fatalf("日誌檔應位於 run 目錄: %q", client.RunLogPath())
+	}
+	data, err := os.ReadFile(client.RunLogPath())
+	if err != nil {
+		t.Fatalf("讀取日誌檔失敗: %v", err)
+	}
+
+	components := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("日誌檔每行應為 JSON: %v (%s)", err, line)
+		}
+		if record["run_id"] != client.RunID() {
+			t.Errorf("日誌應帶有執行 ID: %s", line)
+		}
+		if component, ok := record["component"].(string); ok {
+			components[component] = true
+		}
+	}
+	for _, want := range []string{"cli_executor", "persistence"} {
+		if !components[want] {
+			t.Errorf("日誌檔應包含 %s 的日誌: %s", want, data)
+		}
+	}
+	if strings.Contains(console.String(), "迴圈開始") {
+		t.Error("主要輸出只應收到 warn 以上的日誌")
+	}
+}
+
+// TestCircuitBreakerLogsOpen 測試熔斷器透過 logger 記錄打開，而非直接輸出
+func TestCircuitBreakerLogsOpen(t *testing.T) {
+	var buf bytes.Buffer
+	cb := NewCircuitBreaker(t.TempDir())
+	cb.SetLogger(slog.New(NewLogHandler(&buf, slog.LevelInfo, LogFormatText)))
+
+	for i := 0; i < 3; i++ {
+		cb.RecordNoProgress()
+	}
+	if !cb.IsOpen() {
+		t.Fatal("熔斷器應該打開")
+	}
+	if !strings.Contains(buf.String(), "level=WARN") || !strings.Contains(buf.String(), "no_progress_loops=3") {
+		t.Errorf("應記錄熔斷器打開: %s", buf.String())
+	}
+}
@@ -0,0 +1,348 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoopComparison 是兩個已保存迴圈的比較結果（Before 通常是較早的迴圈）
+type LoopComparison struct {
+	Before     HistoryLoop      `json:"before"`
+	After      HistoryLoop      `json:"after"`
+	ScoreDelta int              `json:"score_delta"`           // 完成分數的變化（After - Before）
+	Fields     []FieldChange    `json:"fields"`                // 有變化的欄位（結果、退出理由、狀態區塊等）
+	Errors     ErrorComparison  `json:"errors"`                // 以正規化後的錯誤比較
+	Files      []FileComparison `json:"files"`                 // 兩個迴圈變更的檔案（依路徑排序）
+	OutputDiff string           `json:"output_diff,omitempty"` // 模型輸出的 unified diff（相同時為空）
+}
+
+// FieldChange 是一個欄位在兩個迴圈間的變化
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// ErrorComparison 是兩個迴圈錯誤集合的差異（保留每個錯誤第一次出現的原文）
+type ErrorComparison struct {
+	Resolved   []string `json:"resolved"`   // 只出現在 Before
+	Introduced []string `json:"introduced"` // 只出現在 After
+	Persisting []string `json:"persisting"` // 兩者都有（以 After 的原文表示）
+}
+
+// FileComparison 是一個檔案在兩個迴圈中的變更
+type FileComparison struct {
+	Path      string      `json:"path"`
+	Before    *FileChange `json:"before,omitempty"` // Before 迴圈沒有變更此檔案時為 nil
+	After     *FileChange `json:"after,omitempty"`  // After 迴圈沒有變更此檔案時為 nil
+	SamePatch bool        `json:"same_patch"`       // 兩個迴圈對此檔案的 patch 完全相同
+	PatchDiff string      `json:"patch_diff,omitempty"`
+}
+
+// CompareLoops 比較兩個迴圈的模型輸出、結構化狀態、完成分數、錯誤與工作目錄變更
+//
+// 檔案都有變更但 patch 不同時，PatchDiff 為兩個 patch 之間的 unified diff；
+// 只有一方變更時為該方的 patch。
+func CompareLoops(before, after *ExecutionContext) *LoopComparison {
+	comparison := &LoopComparison{
+		Before:     NewHistoryLoop(before),
+		After:      NewHistoryLoop(after),
+		ScoreDelta: after.CompletionScore - before.CompletionScore,
+		Fields:     []FieldChange{},
+		Errors:     compareErrors(before, after),
+		Files:      compareFiles(before, after),
+		OutputDiff: unifiedDiff(before.LoopID, after.LoopID, []byte(before.CLIOutput), []byte(after.CLIOutput)),
+	}
+
+	beforeFields, afterFields := comparableFields(before), comparableFields(after)
+	for i, field := range beforeFields {
+		if field[1] != afterFields[i][1] {
+			comparison.Fields = append(comparison.Fields, FieldChange{Field: field[0], Before: field[1], After: afterFields[i][1]})
+		}
+	}
+	return comparison
+}
+
+// comparableFields 傳回比較的欄位名稱與值（順序固定）
+func comparableFields(execCtx *ExecutionContext) [][2]string {
+	status := execCtx.StructuredStatus
+	if status == nil {
+		status = &LoopStatus{}
+	}
+	exitSignal := ""
+	if execCtx.StructuredStatus != nil {
+		exitSignal = strconv.FormatBool(status.ExitSignal)
+	}
+	return [][2]string{
+		{"outcome", LoopOutcome(execCtx)},
+		{"exit_reason", execCtx.ExitReason},
+		{"completion_score", strconv.Itoa(execCtx.CompletionScore)},
+		{"circuit_breaker_state", execCtx.CircuitBreakerState},
+		{"model", execCtx.Model},
+		{"status.STATUS", status.Status},
+		{"status.EXIT_SIGNAL", exitSignal},
+		{"status.TASKS_DONE", status.TasksDone},
+		{"status.NEXT_STEP", status.NextStep},
+		{"status.ERROR", status.ErrorMessage},
+	}
+}
+
+// outputErrorLine 比對模型輸出中回報錯誤的行
+var outputErrorLine = regexp.MustCompile(`(?i)\b(error|panic|fatal|fail(ed|ure)?)\b`)
+
+// maxOutputErrors 每個迴圈從輸出中取出的錯誤行上限
+const maxOutputErrors = 50
+
+// loopErrors 收集迴圈的錯誤（錯誤歷史、狀態區塊的錯誤與輸出中的錯誤行），
+// 依正規化後的錯誤去除重複，保留出現順序與原文
+func loopErrors(execCtx *ExecutionContext) (keys []string, originals map[string]string) {
+	messages := append([]string{}, execCtx.ErrorHistory...)
+	if execCtx.StructuredStatus != nil && execCtx.StructuredStatus.ErrorMessage != "" {
+		messages = append(messages, execCtx.StructuredStatus.ErrorMessage)
+	}
+	outputErrors := 0
+	for _, line := range strings.Split(execCtx.CLIOutput, "\n") {
+		if outputErrors < maxOutputErrors && outputErrorLine.MatchString(line) {
+			messages = append(messages, strings.TrimSpace(line))
+			outputErrors++
+		}
+	}
+
+	originals = map[string]string{}
+	for _, msg := range messages {
+		key := NormalizeError(msg)
+		if key == "" {
+			continue
+		}
+		if _, ok := originals[key]; !ok {
+			keys = append(keys, key)
+			originals[key] = msg
+		}
+	}
+	return keys, originals
+}
+
+// compareErrors 比較兩個迴圈的錯誤集合
+func compareErrors(before, after *ExecutionContext) ErrorComparison {
+	beforeKeys, beforeErrors := loopErrors(before)
+	afterKeys, afterErrors := loopErrors(after)
+
+	result := ErrorComparison{Resolved: []string{}, Introduced: []string{}, Persisting: []string{}}
+	for _, key := range beforeKeys {
+		if _, ok := afterErrors[key]; !ok {
+			result.Resolved = append(result.Resolved, beforeErrors[key])
+		}
+	}
+	for _, key := range afterKeys {
+		if _, ok := beforeErrors[key]; ok {
+			result.Persisting = append(result.Persisting, afterErrors[key])
+		} else {
+			result.Introduced = append(result.Introduced, afterErrors[key])
+		}
+	}
+	return result
+}
+
+// compareFiles 比較兩個迴圈變更的檔案與各自的 patch
+func compareFiles(before, after *ExecutionContext) []FileComparison {
+	beforePatches, afterPatches := splitWorkspaceDiff(before.WorkspaceDiff), splitWorkspaceDiff(after.WorkspaceDiff)
+
+	files := map[string]*FileComparison{}
+	file := func(path string) *FileComparison {
+		if files[path] == nil {
+			files[path] = &FileComparison{Path: path}
+		}
+		return files[path]
+	}
+	for i := range before.WorkspaceChanges {
+		change := before.WorkspaceChanges[i]
+		file(change.Path).Before = &change
+	}
+	for i := range after.WorkspaceChanges {
+		change := after.WorkspaceChanges[i]
+		file(change.Path).After = &change
+	}
+	for path := range beforePatches {
+		file(path)
+	}
+	for path := range afterPatches {
+		file(path)
+	}
+
+	result := make([]FileComparison, 0, len(files))
+	for path, comparison := range files {
+		beforePatch, afterPatch := beforePatches[path], afterPatches[path]
+		switch {
+		case beforePatch == afterPatch:
+			comparison.SamePatch = comparison.Before != nil && comparison.After != nil &&
+				*comparison.Before == *comparison.After
+		case beforePatch == "" || afterPatch == "":
+			comparison.PatchDiff = beforePatch + afterPatch
+		default:
+			comparison.PatchDiff = unifiedDiff(before.LoopID+"/"+path, after.LoopID+"/"+path,
+				[]byte(beforePatch), []byte(afterPatch))
+		}
+		result = append(result, *comparison)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// hunkHeader 比對 unified diff 區塊標頭中的行數
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// splitWorkspaceDiff 將迴圈的 unified diff 依檔案拆開（路徑 → 該檔案的 patch，含檔頭）
+//
+// 依區塊標頭的行數跳過區塊內容，刪除的 "-- " 開頭行不會被誤認為檔頭。
+func splitWorkspaceDiff(diff string) map[string]string {
+	patches := map[string]string{}
+	lines := strings.SplitAfter(diff, "\n")
+
+	var path string
+	var patch strings.Builder
+	flush := func() {
+		if path != "" {
+			patches[path] = patch.String()
+		}
+		patch.Reset()
+	}
+
+	oldLeft, newLeft := 0, 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			default:
+				oldLeft--
+				newLeft--
+			}
+			patch.WriteString(line)
+			continue
+		}
+
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			flush()
+			path = diffHeaderPath(line, lines[i+1])
+			patch.WriteString(line)
+			patch.WriteString(lines[i+1])
+			i++
+			continue
+		}
+		if match := hunkHeader.FindStringSubmatch(line); match != nil {
+			oldLeft, newLeft = hunkCount(match[1]), hunkCount(match[2])
+		}
+		patch.WriteString(line)
+	}
+	flush()
+	return patches
+}
+
+// hunkCount 解析區塊標頭中的行數（省略時為 1）
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// diffHeaderPath 由 "--- a/path" 與 "+++ b/path" 取得檔案路徑（刪除的檔案使用舊路徑）
+func diffHeaderPath(oldLine, newLine string) string {
+	name := strings.TrimSpace(strings.TrimPrefix(newLine, "+++ "))
+	if name == "/dev/null" {
+		name = strings.TrimSpace(strings.TrimPrefix(oldLine, "--- "))
+	}
+	if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+		name = name[2:]
+	}
+	return name
+}
+
+// WriteText 以文字輸出比較結果
+func (c *LoopComparison) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "比較 %s (%s 第 %d 個迴圈) → %s (%s 第 %d 個迴圈)\n",
+		c.Before.LoopID, orDash(c.Before.RunID), c.Before.LoopIndex+1,
+		c.After.LoopID, orDash(c.After.RunID), c.After.LoopIndex+1)
+	fmt.Fprintf(&b, "完成分數: %d → %d (%+d)\n", c.Before.CompletionScore, c.After.CompletionScore, c.ScoreDelta)
+
+	b.WriteString("\n== 欄位變化 ==\n")
+	if len(c.Fields) == 0 {
+		b.WriteString("(無差異)\n")
+	}
+	for _, field := range c.Fields {
+		fmt.Fprintf(&b, "%s: %s → %s\n", field.Field, orDash(field.Before), orDash(field.After))
+	}
+
+	b.WriteString("\n== 錯誤 ==\n")
+	if len(c.Errors.Resolved)+len(c.Errors.Introduced)+len(c.Errors.Persisting) == 0 {
+		b.WriteString("(兩個迴圈都沒有錯誤)\n")
+	}
+	for _, group := range []struct {
+		title  string
+		mark   string
+		errors []string
+	}{
+		{"已解決", "-", c.Errors.Resolved},
+		{"新出現", "+", c.Errors.Introduced},
+		{"持續存在", "=", c.Errors.Persisting},
+	} {
+		if len(group.errors) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s (%d):\n", group.title, len(group.errors))
+		for _, msg := range group.errors {
+			fmt.Fprintf(&b, "  %s %s\n", group.mark, historySummary(msg, 200))
+		}
+	}
+
+	b.WriteString("\n== 檔案變更 ==\n")
+	if len(c.Files) == 0 {
+		b.WriteString("(兩個迴圈都沒有變更檔案)\n")
+	}
+	for _, file := range c.Files {
+		note := "變更不同"
+		switch {
+		case file.SamePatch:
+			note = "變更相同"
+		case file.Before == nil:
+			note = "只有後者變更"
+		case file.After == nil:
+			note = "只有前者變更"
+		}
+		fmt.Fprintf(&b, "%s  %s → %s  (%s)\n", file.Path, describeFileChange(file.Before), describeFileChange(file.After), note)
+	}
+	for _, file := range c.Files {
+		if file.PatchDiff != "" {
+			fmt.Fprintf(&b, "\n-- %s --\n%s", file.Path, file.PatchDiff)
+		}
+	}
+
+	b.WriteString("\n== 輸出差異 ==\n")
+	if c.OutputDiff == "" {
+		b.WriteString("(輸出相同)\n")
+	} else {
+		b.WriteString(c.OutputDiff)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// describeFileChange 以 "modified +3 -1" 的形式描述檔案變更
+func describeFileChange(change *FileChange) string {
+	if change == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s +%d -%d", change.Kind, change.LinesAdded, change.LinesRemoved)
+}
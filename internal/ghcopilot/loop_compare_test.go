@@ -0,0 +1,129 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitWorkspaceDiff(t *testing.T) {
+	// 刪除的 "-- note" 行在 diff 中是 "--- note"，不應被當成檔頭
+	parser := UnifiedDiff("parser.go", []byte("a\n-- note\n++ x\nb\n"), []byte("a\nb\n"))
+	removed := UnifiedDiff("old.txt", []byte("old\n"), nil)
+	added := UnifiedDiff("docs/new.md", nil, []byte("# new\n"))
+
+	patches := splitWorkspaceDiff(parser + removed + added)
+	if len(patches) != 3 {
+		t.Fatalf("應拆成三個檔案: %v", patches)
+	}
+	if patches["parser.go"] != parser || patches["old.txt"] != removed || patches["docs/new.md"] != added {
+		t.Errorf("拆開的 patch 不正確: %#v", patches)
+	}
+	if len(splitWorkspaceDiff("")) != 0 {
+		t.Error("空的 diff 應沒有檔案")
+	}
+}
+
+func TestCompareLoops(t *testing.T) {
+	sharedPatch := UnifiedDiff("go.mod", []byte("go 1.22\n"), []byte("go 1.24\n"))
+	before := &ExecutionContext{
+		LoopID: "loop-4", RunID: "run-x", LoopIndex: 3, CompletionScore: 70,
+		CLIOutput:        "修改 parser\nerror: /src/parser.go:10:2: undefined: tokenize\n",
+		ShouldContinue:   true,
+		StructuredStatus: &LoopStatus{Status: "CONTINUE", TasksDone: "2/4"},
+		ErrorHistory:     []string{"post_loop hook failed: exit status 1"},
+		WorkspaceChanges: []FileChange{
+			{Path: "go.mod", Kind: FileModified, LinesAdded: 1, LinesRemoved: 1},
+			{Path: "parser.go", Kind: FileModified, LinesAdded: 1, LinesRemoved: 1},
+		},
+		WorkspaceDiff: sharedPatch + UnifiedDiff("parser.go", []byte("a\nb\n"), []byte("a\nc\n")),
+	}
+	after := &ExecutionContext{
+		LoopID: "loop-5", RunID: "run-x", LoopIndex: 4, CompletionScore: 40,
+		CLIOutput:        "修改 lexer\nerror: /src/parser.go:12:2: undefined: tokenize\npanic: index out of range\n",
+		Failed:           true,
+		ExitReason:       "驗證失敗",
+		StructuredStatus: &LoopStatus{Status: "CONTINUE", TasksDone: "1/4", ErrorMessage: "lexer 測試失敗"},
+		WorkspaceChanges: []FileChange{
+			{Path: "go.mod", Kind: FileModified, LinesAdded: 1, LinesRemoved: 1},
+			{Path: "lexer.go", Kind: FileAdded, LinesAdded: 1},
+			{Path: "parser.go", Kind: FileModified, LinesAdded: 1, LinesRemoved: 1},
+		},
+		WorkspaceDiff: sharedPatch + UnifiedDiff("lexer.go", nil, []byte("package x\n")) +
+			UnifiedDiff("parser.go", []byte("a\nb\n"), []byte("a\nd\n")),
+	}
+
+	c := CompareLoops(before, after)
+	if c.ScoreDelta != -30 || c.Before.LoopID != "loop-4" || c.After.Outcome != LoopOutcomeFailed {
+		t.Errorf("摘要不正確: %+v", c)
+	}
+
+	fields := map[string]FieldChange{}
+	for _, field := range c.Fields {
+		fields[field.Field] = field
+	}
+	if f := fields["outcome"]; f.Before != LoopOutcomeContinue || f.After != LoopOutcomeFailed {
+		t.Errorf("outcome 變化不正確: %+v", c.Fields)
+	}
+	if f := fields["status.TASKS_DONE"]; f.Before != "2/4" || f.After != "1/4" {
+		t.Errorf("狀態區塊變化不正確: %+v", c.Fields)
+	}
+	if _, ok := fields["status.STATUS"]; ok {
+		t.Error("沒有變化的欄位不應列出")
+	}
+
+	// 行號不同的相同錯誤視為持續存在
+	if len(c.Errors.Persisting) != 1 || !strings.Contains(c.Errors.Persisting[0], "parser.go:12:2") {
+		t.Errorf("持續存在的錯誤不正確: %+v", c.Errors)
+	}
+	if len(c.Errors.Resolved) != 1 || c.Errors.Resolved[0] != "post_loop hook failed: exit status 1" {
+		t.Errorf("已解決的錯誤不正確: %+v", c.Errors)
+	}
+	if len(c.Errors.Introduced) != 2 || c.Errors.Introduced[0] != "lexer 測試失敗" {
+		t.Errorf("新出現的錯誤不正確: %+v", c.Errors)
+	}
+
+	if len(c.Files) != 3 || c.Files[0].Path != "go.mod" || c.Files[1].Path != "lexer.go" || c.Files[2].Path != "parser.go" {
+		t.Fatalf("檔案應依路徑排序: %+v", c.Files)
+	}
+	if !c.Files[0].SamePatch || c.Files[0].PatchDiff != "" {
+		t.Errorf("相同的 patch 應標示為相同: %+v", c.Files[0])
+	}
+	if c.Files[1].Before != nil || c.Files[1].PatchDiff == "" || !strings.Contains(c.Files[1].PatchDiff, "+package x") {
+		t.Errorf("只有後者變更的檔案應附上該 patch: %+v", c.Files[1])
+	}
+	if c.Files[2].SamePatch || !strings.Contains(c.Files[2].PatchDiff, "-+c") || !strings.Contains(c.Files[2].PatchDiff, "++d") {
+		t.Errorf("不同的 patch 應附上兩者的差異: %+v", c.Files[2])
+	}
+	if !strings.Contains(c.OutputDiff, "--- loop-4\n+++ loop-5\n") || !strings.Contains(c.OutputDiff, "+panic: index out of range") {
+		t.Errorf("輸出差異不正確:\n%s", c.OutputDiff)
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"完成分數: 70 → 40 (-30)", "outcome: continue → failed", "已解決 (1):",
+		"新出現 (2):", "持續存在 (1):", "lexer.go  - → added +1 -0  (只有後者變更)", "go.mod  modified +1 -1 → modified +1 -1  (變更相同)",
+		"== 輸出差異 =="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("文字輸出缺少 %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestCompareLoopsIdentical(t *testing.T) {
+	loop := &ExecutionContext{LoopID: "loop-1", CLIOutput: "done", CompletionScore: 100}
+	c := CompareLoops(loop, loop)
+	if len(c.Fields) != 0 || len(c.Files) != 0 || c.OutputDiff != "" || c.ScoreDelta != 0 {
+		t.Errorf("相同的迴圈不應有差異: %+v", c)
+	}
+
+	var buf bytes.Buffer
+	c.WriteText(&buf)
+	for _, want := range []string{"(無差異)", "(兩個迴圈都沒有錯誤)", "(兩個迴圈都沒有變更檔案)", "(輸出相同)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("文字輸出缺少 %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package ghcopilot
+
+import (
+	"context"
+	"sync"
+)
+
+// ControlState 代表執行中迴圈的控制狀態快照
+type ControlState struct {
+	Paused          bool `json:"paused"`           // 是否暫停（在迴圈之間等待）
+	StopRequested   bool `json:"stop_requested"`   // 是否要求在目前迴圈結束後停止
+	MaxLoops        int  `json:"max_loops"`        // 目前的最大迴圈次數
+	LoopsCompleted  int  `json:"loops_completed"`  // 已完成的迴圈數
+	PendingFeedback int  `json:"pending_feedback"` // 等待注入下一次 prompt 的回饋數
+	BreakerReset    bool `json:"breaker_reset"`    // 是否有等待套用的熔斷器重置
+}
+
+// LoopController 讓外部在迴圈之間控制 ExecuteUntilCompletion
+//
+// 所有方法都可以從其他 goroutine（例如控制通道）安全呼叫。
+// 控制只在迴圈之間生效，不會中斷正在執行的迴圈。
+type LoopController struct {
+	mu             sync.Mutex
+	paused         bool
+	stopRequested  bool
+	maxLoops       int
+	loopsCompleted int
+	feedback       []string
+	breakerReset   bool
+	changed        chan struct{} // 狀態變更時關閉並重建，用於喚醒等待者
+}
+
+// NewLoopController 建立迴圈控制器
+func NewLoopController() *LoopController {
+	return &LoopController{
+		changed: make(chan struct{}),
+	}
+}
+
+// Pause 在目前迴圈結束後暫停
+func (lc *LoopController) Pause() {
+	lc.update(func() { lc.paused = true })
+}
+
+// Resume 恢復執行
+func (lc *LoopController) Resume() {
+	lc.update(func() { lc.paused = false })
+}
+
+// RequestStop 要求在目前迴圈結束後停止
+func (lc *LoopController) RequestStop() {
+	lc.update(func() { lc.stopRequested = true })
+}
+
+// SetMaxLoops 調整最大迴圈次數
+func (lc *LoopController) SetMaxLoops(n int) {
+	lc.update(func() { lc.maxLoops = n })
+}
+
+// InjectFeedback 加入一則注入下一次 prompt 的回饋
+func (lc *LoopController) InjectFeedback(text string) {
+	if text == "" {
+		return
+	}
+	lc.update(func() { lc.feedback = append(lc.feedback, text) })
+}
+
+// RequestBreakerReset 要求在下一個迴圈開始前重置熔斷器
+func (lc *LoopController) RequestBreakerReset() {
+	lc.update(func() { lc.breakerReset = true })
+}
+
+// State 取得目前的控制狀態
+func (lc *LoopController) State() ControlState {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return ControlState{
+		Paused:          lc.paused,
+		StopRequested:   lc.stopRequested,
+		MaxLoops:        lc.maxLoops,
+		LoopsCompleted:  lc.loopsCompleted,
+		PendingFeedback: len(lc.feedback),
+		BreakerReset:    lc.breakerReset,
+	}
+}
+
+// MaxLoops 取得目前的最大迴圈次數
+func (lc *LoopController) MaxLoops() int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.maxLoops
+}
+
+// StopRequested 檢查是否已要求停止
+func (lc *LoopController) StopRequested() bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.stopRequested
+}
+
+// WaitWhilePaused 在暫停期間阻塞，直到恢復、要求停止或 context 取消
+func (lc *LoopController) WaitWhilePaused(ctx context.Context) error {
+	for {
+		lc.mu.Lock()
+		if !lc.paused || lc.stopRequested {
+			lc.mu.Unlock()
+			return nil
+		}
+		changed := lc.changed
+		lc.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// begin 在新的執行開始時重設停止要求與計數
+func (lc *LoopController) begin(maxLoops int) {
+	lc.update(func() {
+		lc.maxLoops = maxLoops
+		lc.loopsCompleted = 0
+		lc.stopRequested = false
+	})
+}
+
+// loopFinished 記錄已完成的迴圈數
+func (lc *LoopController) loopFinished(count int) {
+	lc.update(func() { lc.loopsCompleted = count })
+}
+
+// takeFeedback 取出並清空待注入的回饋
+func (lc *LoopController) takeFeedback() []string {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	feedback := lc.feedback
+	lc.feedback = nil
+	return feedback
+}
+
+// takeBreakerReset 取出並清除熔斷器重置要求
+func (lc *LoopController) takeBreakerReset() bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	reset := lc.breakerReset
+	lc.breakerReset = false
+	return reset
+}
+
+// update 在鎖內修改狀態並喚醒等待者
+func (lc *LoopController) update(fn func()) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	fn()
+	close(lc.changed)
+	lc.changed = make(chan struct{})
+}
@@ -0,0 +1,192 @@
+package ghcopilot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoopControllerState 測試控制狀態
+func TestLoopControllerState(t *testing.T) {
+	lc := NewLoopController()
+	lc.begin(5)
+	lc.Pause()
+	lc.InjectFeedback("請補測試")
+	lc.InjectFeedback("")
+	lc.SetMaxLoops(8)
+
+	state := lc.State()
+	if !state.Paused || state.MaxLoops != 8 || state.PendingFeedback != 1 {
+		t.Errorf("控制狀態不正確: %+v", state)
+	}
+
+	if feedback := lc.takeFeedback(); len(feedback) != 1 || feedback[0] != "請補測試" {
+		t.Errorf("回饋內容不正確: %v", feedback)
+	}
+	if lc.State().PendingFeedback != 0 {
+		t.Error("取出後應清空回饋")
+	}
+
+	lc.RequestStop()
+	lc.begin(3)
+	if lc.StopRequested() {
+		t.Error("新的執行應重設停止要求")
+	}
+}
+
+// TestLoopControllerWaitWhilePaused 測試暫停等待
+func TestLoopControllerWaitWhilePaused(t *testing.T) {
+	lc := NewLoopController()
+	if err := lc.WaitWhilePaused(context.Background()); err != nil {
+		t.Fatalf("未暫停時應立即返回: %v", err)
+	}
+
+	lc.Pause()
+	done := make(chan error, 1)
+	go func() { done <- lc.WaitWhilePaused(context.Background()) }()
+
+	select {
+	case <-done:
+		t.Fatal("暫停時不應返回")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	lc.Resume()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("恢復後不應有錯誤: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("恢復後應返回")
+	}
+
+	// 暫停中要求停止也應返回
+	lc.Pause()
+	lc.RequestStop()
+	if err := lc.WaitWhilePaused(context.Background()); err != nil {
+		t.Errorf("要求停止後應返回: %v", err)
+	}
+
+	// context 取消
+	lc2 := NewLoopController()
+	lc2.Pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := lc2.WaitWhilePaused(ctx); err == nil {
+		t.Error("context 取消時應傳回錯誤")
+	}
+}
+
+// TestClientControllerStopAndFeedback 測試客戶端在迴圈之間套用控制狀態
+func TestClientControllerStopAndFeedback(t *testing.T) {
+	workDir := t.TempDir()
+	installFakeCopilot(t, `echo "$2" > last_prompt.txt
+echo "working"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithProtectedPaths().
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	loops := 0
+	client.config.Approver = ApproverFunc(func(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+		loops++
+		switch loops {
+		case 1:
+			client.Controller().InjectFeedback("只修改 parser")
+		case 2:
+			client.Controller().RequestStop()
+		}
+		return ApprovalDecision{Action: ApprovalAccept}, nil
+	})
+
+	results, err := client.ExecuteUntilCompletion(t.Context(), "任務", 5)
+	if err == nil || !strings.Contains(err.Error(), "stopped by control command after 2 loops") {
+		t.Errorf("應在第 2 輪後停止，但為 %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("應執行 2 輪，但為 %d", len(results))
+	}
+
+	prompt, _ := os.ReadFile(filepath.Join(workDir, "last_prompt.txt"))
+	if !strings.Contains(string(prompt), "操作者回饋：只修改 parser") {
+		t.Errorf("第 2 輪 prompt 應包含注入的回饋: %s", prompt)
+	}
+	if client.Controller().State().LoopsCompleted != 2 {
+		t.Errorf("已完成迴圈數應為 2: %+v", client.Controller().State())
+	}
+}
+
+// TestClientControllerMaxLoops 測試執行中調整最大迴圈次數
+func TestClientControllerMaxLoops(t *testing.T) {
+	installFakeCopilot(t, `echo "working"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(t.TempDir()).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+	client.config.Approver = ApproverFunc(func(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+		client.Controller().SetMaxLoops(2)
+		return ApprovalDecision{Action: ApprovalAccept}, nil
+	})
+
+	results, err := client.ExecuteUntilCompletion(t.Context(), "任務", 10)
+	if len(results) != 2 || err == nil || !strings.Contains(err.Error(), "maximum loops (2)") {
+		t.Errorf("應在調整後的 2 輪結束: results=%d err=%v", len(results), err)
+	}
+}
+
+// TestClientControllerBreakerReset 測試迴圈期間送入的熔斷器重置在檢查熔斷器前套用
+func TestClientControllerBreakerReset(t *testing.T) {
+	installFakeCopilot(t, `echo "working"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(t.TempDir()).
+		WithProtectedPaths().
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	var transitions []string
+	client.Subscribe(func(e LoopEvent) {
+		if e.Type == EventBreakerStateChanged {
+			transitions = append(transitions, e.PreviousState+"->"+e.BreakerState)
+		}
+	})
+
+	loops := 0
+	client.config.Approver = ApproverFunc(func(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+		loops++
+		switch loops {
+		case 1:
+			for i := 0; i < 3; i++ {
+				client.breaker.RecordNoProgress()
+			}
+			client.Controller().RequestBreakerReset()
+		case 2:
+			client.Controller().RequestStop()
+		}
+		return ApprovalDecision{Action: ApprovalAccept}, nil
+	})
+
+	results, err := client.ExecuteUntilCompletion(t.Context(), "任務", 5)
+	if len(results) != 2 || err == nil || !strings.Contains(err.Error(), "stopped by control command") {
+		t.Fatalf("重置後應繼續執行到停止: results=%d err=%v", len(results), err)
+	}
+	if strings.Join(transitions, ",") != "CLOSED->OPEN,OPEN->CLOSED" {
+		t.Errorf("應發布打開與重置的狀態變化: %v", transitions)
+	}
+	if client.Controller().State().BreakerReset {
+		t.Error("重置要求套用後應清除")
+	}
+}
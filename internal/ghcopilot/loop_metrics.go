@@ -0,0 +1,237 @@
+package ghcopilot
+
+import (
+	"time"
+)
+
+// MetricsNamespace 所有指標名稱的前綴
+const MetricsNamespace = "ralph_loop"
+
+// 迴圈結果（ralph_loop_loops_total 的 outcome 標籤）
+const (
+	LoopOutcomeContinue = "continue" // 未完成，繼續下一個迴圈
+	LoopOutcomeComplete = "complete" // 偵測到完成
+	LoopOutcomeFailed   = "failed"   // 執行失敗或違反策略
+	LoopOutcomeStopped  = "stopped"  // 操作者要求停止
+)
+
+// LoopMetrics 客戶端的迴圈、執行器、熔斷器與預算指標
+//
+// 由 RalphLoopClient 在迴圈中更新；既有元件已收集的指標（SDK 執行器、重試、
+// 恢復、模式選擇）以函式指標註冊，在輸出時才讀取。
+type LoopMetrics struct {
+	registry *MetricsRegistry
+
+	loops              *CounterVec
+	loopDuration       *HistogramVec
+	executions         *CounterVec
+	executionDuration  *HistogramVec
+	breakerState       *GaugeVec
+	breakerTransitions *CounterVec
+	hookFailures       *CounterVec
+	filesChanged       *CounterVec
+	linesChanged       *CounterVec
+	statusErrors       *CounterVec
+
+	budgetLoopsUsed  *GaugeVec
+	budgetLoopsLimit *GaugeVec
+	budgetDeadline   *GaugeVec
+	budgetLines      *GaugeVec
+	budgetLinesLimit *GaugeVec
+}
+
+// NewLoopMetrics 建立迴圈指標並註冊到新的登錄表
+func NewLoopMetrics() *LoopMetrics {
+	r := NewMetricsRegistry()
+	return &LoopMetrics{
+		registry: r,
+
+		loops:              r.Counter(metricName("loops_total"), "Completed loops by outcome.", "outcome"),
+		loopDuration:       r.Histogram(metricName("loop_duration_seconds"), "Wall-clock duration of a loop.", nil),
+		executions:         r.Counter(metricName("executions_total"), "Executor calls by mode, model and result.", "mode", "model", "result"),
+		executionDuration:  r.Histogram(metricName("execution_duration_seconds"), "Executor call latency by mode.", nil, "mode"),
+		breakerState:       r.Gauge(metricName("circuit_breaker_state"), "Circuit breaker state (1 for the current state).", "state"),
+		breakerTransitions: r.Counter(metricName("circuit_breaker_transitions_total"), "Circuit breaker state transitions by new state.", "state"),
+		hookFailures:       r.Counter(metricName("hook_failures_total"), "Failed lifecycle hooks by hook point.", "point"),
+		filesChanged:       r.Counter(metricName("files_changed_total"), "Workspace files changed by accepted loops.", "kind"),
+		linesChanged:       r.Counter(metricName("lines_changed_total"), "Lines added and removed by accepted loops.", "direction"),
+		statusErrors:       r.Counter(metricName("status_errors_total"), "Loops whose status block failed validation."),
+
+		budgetLoopsUsed:  r.Gauge(metricName("budget_loops_used"), "Loops completed in the current run."),
+		budgetLoopsLimit: r.Gauge(metricName("budget_loops_limit"), "Maximum loops allowed for the current run."),
+		budgetDeadline:   r.Gauge(metricName("budget_deadline_timestamp_seconds"), "Unix time at which the run context expires (0 if none)."),
+		budgetLines:      r.Gauge(metricName("budget_run_lines"), "Lines changed so far in the run, by direction.", "direction"),
+		budgetLinesLimit: r.Gauge(metricName("budget_run_lines_limit"), "Per-run line change limit by direction (0 if unlimited).", "direction"),
+	}
+}
+
+func metricName(name string) string {
+	return MetricsNamespace + "_" + name
+}
+
+// Registry 取得指標登錄表（用於 HTTP 輸出或 textfile）
+func (m *LoopMetrics) Registry() *MetricsRegistry {
+	return m.registry
+}
+
+// ObserveLoop 記錄一個迴圈的結果與耗時
+func (m *LoopMetrics) ObserveLoop(outcome string, duration time.Duration) {
+	m.loops.Inc(outcome)
+	m.loopDuration.Observe(duration.Seconds())
+}
+
+// ObserveExecution 記錄一次執行器呼叫
+func (m *LoopMetrics) ObserveExecution(mode ExecutionMode, model string, success bool, duration time.Duration) {
+	result := "success"
+	if !success {
+		result = "error"
+	}
+	m.executions.Inc(mode.String(), model, result)
+	m.executionDuration.Observe(duration.Seconds(), mode.String())
+}
+
+// SetBreakerState 設定熔斷器目前狀態；previous 非空時記錄一次狀態轉換
+func (m *LoopMetrics) SetBreakerState(state, previous CircuitBreakerState) {
+	for _, s := range []CircuitBreakerState{StateClosed, StateHalfOpen, StateOpen} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		m.breakerState.Set(value, string(s))
+	}
+	if previous != "" && previous != state {
+		m.breakerTransitions.Inc(string(state))
+	}
+}
+
+// ObserveHookFailure 記錄一次 hook 失敗
+func (m *LoopMetrics) ObserveHookFailure(point HookPoint) {
+	m.hookFailures.Inc(string(point))
+}
+
+// ObserveStatusError 記錄一次不符合規格的狀態區塊
+func (m *LoopMetrics) ObserveStatusError() {
+	m.statusErrors.Inc()
+}
+
+// ObserveChanges 記錄被接受的工作目錄變更
+func (m *LoopMetrics) ObserveChanges(changes []FileChange) {
+	for _, change := range changes {
+		m.filesChanged.Inc(string(change.Kind))
+	}
+	stats := SummarizeChanges(changes)
+	m.linesChanged.Add(float64(stats.LinesAdded), "added")
+	m.linesChanged.Add(float64(stats.LinesRemoved), "removed")
+}
+
+// SetLoopBudget 設定已使用與允許的迴圈數
+func (m *LoopMetrics) SetLoopBudget(used, limit int) {
+	m.budgetLoopsUsed.Set(float64(used))
+	m.budgetLoopsLimit.Set(float64(limit))
+}
+
+// SetDeadline 設定整次執行的截止時間（零值表示沒有截止時間）
+func (m *LoopMetrics) SetDeadline(deadline time.Time) {
+	value := 0.0
+	if !deadline.IsZero() {
+		value = float64(deadline.Unix())
+	}
+	m.budgetDeadline.Set(value)
+}
+
+// SetChangeBudget 設定整次執行已變更的行數與變更範圍上限
+func (m *LoopMetrics) SetChangeBudget(run ChangeStats, policy *ChangeScopePolicy) {
+	m.budgetLines.Set(float64(run.LinesAdded), "added")
+	m.budgetLines.Set(float64(run.LinesRemoved), "removed")
+
+	var addedLimit, removedLimit int
+	if policy != nil {
+		addedLimit, removedLimit = policy.MaxLinesAddedPerRun, policy.MaxLinesRemovedPerRun
+	}
+	m.budgetLinesLimit.Set(float64(addedLimit), "added")
+	m.budgetLinesLimit.Set(float64(removedLimit), "removed")
+}
+
+// RegisterCLIExecutor 輸出 CLI 執行器的累計重試次數
+func (m *LoopMetrics) RegisterCLIExecutor(executor *CLIExecutor) {
+	m.registry.CounterFunc(metricName("cli_retries_total"), "Copilot CLI retry attempts.", func() float64 {
+		return float64(executor.RetryCount())
+	})
+}
+
+// RegisterSDKExecutor 輸出 SDK 執行器的呼叫統計與會話數
+func (m *LoopMetrics) RegisterSDKExecutor(executor *SDKExecutor) {
+	m.registry.CounterFunc(metricName("sdk_calls_total"), "SDK executor calls.", func() float64 {
+		return float64(executor.GetMetrics().TotalCalls)
+	})
+	m.registry.CounterFunc(metricName("sdk_calls_failed_total"), "Failed SDK executor calls.", func() float64 {
+		return float64(executor.GetMetrics().FailedCalls)
+	})
+	m.registry.CounterFunc(metricName("sdk_call_duration_seconds_total"), "Cumulative SDK executor call time.", func() float64 {
+		return executor.GetMetrics().TotalDuration.Seconds()
+	})
+	m.registry.GaugeFunc(metricName("sdk_sessions"), "Active SDK sessions.", func() float64 {
+		return float64(executor.GetSessionCount())
+	})
+}
+
+// RegisterRetryExecutor 輸出重試執行器的統計
+func (m *LoopMetrics) RegisterRetryExecutor(executor *RetryExecutor) {
+	m.registry.CounterFunc(metricName("retry_attempts_total"), "Attempts made by the retry executor.", func() float64 {
+		return float64(executor.GetMetrics().TotalAttempts)
+	})
+	m.registry.CounterFunc(metricName("retry_successes_total"), "Retries that eventually succeeded.", func() float64 {
+		return float64(executor.GetMetrics().SuccessfulRetries)
+	})
+	m.registry.CounterFunc(metricName("retry_failures_total"), "Retries that exhausted the policy.", func() float64 {
+		return float64(executor.GetMetrics().FailedRetries)
+	})
+	m.registry.CounterFunc(metricName("retry_wait_seconds_total"), "Cumulative back-off wait time.", func() float64 {
+		return executor.GetMetrics().TotalWaitTime.Seconds()
+	})
+}
+
+// RegisterRecoveryCoordinator 輸出錯誤恢復的統計
+func (m *LoopMetrics) RegisterRecoveryCoordinator(coordinator *RecoveryCoordinator) {
+	m.registry.CounterFunc(metricName("recovery_attempts_total"), "Recovery attempts.", func() float64 {
+		return float64(coordinator.GetMetrics().TotalAttempts)
+	})
+	m.registry.CounterFunc(metricName("recovery_successes_total"), "Successful recoveries.", func() float64 {
+		return float64(coordinator.GetMetrics().SuccessfulRecoveries)
+	})
+	m.registry.CounterFunc(metricName("recovery_failures_total"), "Failed recoveries.", func() float64 {
+		return float64(coordinator.GetMetrics().FailedRecoveries)
+	})
+}
+
+// RegisterFaultTolerantExecutor 輸出容錯執行器的統計（含其重試與恢復）
+func (m *LoopMetrics) RegisterFaultTolerantExecutor(executor *FaultTolerantExecutor) {
+	m.registry.CounterFunc(metricName("fault_tolerant_executions_total"), "Executions through the fault-tolerant executor.", func() float64 {
+		return float64(executor.GetMetrics().TotalExecutions)
+	})
+	m.registry.CounterFunc(metricName("fault_tolerant_failures_total"), "Fault-tolerant executions that failed.", func() float64 {
+		return float64(executor.GetMetrics().FailedExecutions)
+	})
+	m.registry.CounterFunc(metricName("fault_tolerant_recovered_total"), "Fault-tolerant executions recovered after failure.", func() float64 {
+		return float64(executor.GetMetrics().RecoveredExecutions)
+	})
+	m.registry.CounterFunc(metricName("fault_tolerant_retries_total"), "Retries performed by the fault-tolerant executor.", func() float64 {
+		return float64(executor.GetMetrics().TotalRetries)
+	})
+	m.registry.CounterFunc(metricName("fault_tolerant_recovery_attempts_total"), "Recovery attempts by the fault-tolerant executor.", func() float64 {
+		return float64(executor.GetMetrics().TotalRecoveryAttempts)
+	})
+}
+
+// RegisterModeSelector 輸出執行模式選擇的統計
+func (m *LoopMetrics) RegisterModeSelector(selector *ExecutionModeSelector) {
+	m.registry.CounterFunc(metricName("mode_selections_cli_total"), "Times the CLI mode was selected.", func() float64 {
+		return float64(selector.GetMetrics().CLISelections)
+	})
+	m.registry.CounterFunc(metricName("mode_selections_sdk_total"), "Times the SDK mode was selected.", func() float64 {
+		return float64(selector.GetMetrics().SDKSelections)
+	})
+	m.registry.CounterFunc(metricName("mode_fallbacks_total"), "Fallbacks from one execution mode to another.", func() float64 {
+		return float64(selector.GetMetrics().FallbackCount)
+	})
+}
@@ -0,0 +1,414 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsContentType Prometheus 文字格式的 Content-Type
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultDurationBuckets 耗時直方圖的預設區間（秒），涵蓋數秒到數十分鐘的迴圈
+var DefaultDurationBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// metricKind 指標類型（對應 # TYPE）
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+// MetricsRegistry 收集指標並輸出 Prometheus 文字格式
+//
+// 只實作本專案需要的部分（counter、gauge、histogram 與讀取時計算的函式指標），
+// 不依賴 Prometheus client library。所有方法都可並行呼叫。
+type MetricsRegistry struct {
+	mu      sync.Mutex
+	metrics map[string]*metricFamily
+}
+
+type metricFamily struct {
+	name       string
+	help       string
+	kind       metricKind
+	labelNames []string
+	buckets    []float64
+	series     map[string]*metricSeries // key 為以 \xff 連接的標籤值
+	fn         func() float64           // 函式指標（無標籤）
+}
+
+type metricSeries struct {
+	labelValues []string
+	value       float64
+	counts      []uint64 // 直方圖各區間的計數（不含 +Inf）
+	count       uint64
+	sum         float64
+}
+
+// NewMetricsRegistry 建立指標登錄表
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{metrics: make(map[string]*metricFamily)}
+}
+
+// CounterVec 只增不減的計數器（可帶標籤）
+type CounterVec struct {
+	registry *MetricsRegistry
+	family   *metricFamily
+}
+
+// GaugeVec 可任意設定的數值（可帶標籤）
+type GaugeVec struct {
+	registry *MetricsRegistry
+	family   *metricFamily
+}
+
+// HistogramVec 依區間累計觀測值的直方圖（可帶標籤）
+type HistogramVec struct {
+	registry *MetricsRegistry
+	family   *metricFamily
+}
+
+// Counter 註冊計數器；同名指標重複註冊時傳回既有的指標
+func (r *MetricsRegistry) Counter(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{registry: r, family: r.register(name, help, kindCounter, labelNames, nil, nil)}
+}
+
+// Gauge 註冊數值指標
+func (r *MetricsRegistry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{registry: r, family: r.register(name, help, kindGauge, labelNames, nil, nil)}
+}
+
+// Histogram 註冊直方圖（buckets 為 nil 時使用 DefaultDurationBuckets）
+func (r *MetricsRegistry) Histogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultDurationBuckets
+	}
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	return &HistogramVec{registry: r, family: r.register(name, help, kindHistogram, labelNames, sorted, nil)}
+}
+
+// CounterFunc 註冊在輸出時才讀取數值的計數器（例如既有元件的累計次數）
+func (r *MetricsRegistry) CounterFunc(name, help string, fn func() float64) {
+	r.register(name, help, kindCounter, nil, nil, fn)
+}
+
+// GaugeFunc 註冊在輸出時才讀取數值的指標
+func (r *MetricsRegistry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, kindGauge, nil, nil, fn)
+}
+
+func (r *MetricsRegistry) register(name, help string, kind metricKind, labelNames []string, buckets []float64, fn func() float64) *metricFamily {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if family, ok := r.metrics[name]; ok {
+		if fn != nil {
+			family.fn = fn
+		}
+		return family
+	}
+	family := &metricFamily{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*metricSeries),
+		fn:         fn,
+	}
+	r.metrics[name] = family
+	return family
+}
+
+// seriesKey 傳回標籤值對應的序列鍵
+func (f *metricFamily) seriesKey(labelValues []string) string {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// lookupLocked 取得已存在的序列（不建立，讀取數值不應改變輸出），呼叫者必須持有鎖
+func (f *metricFamily) lookupLocked(labelValues []string) (*metricSeries, bool) {
+	series, ok := f.series[f.seriesKey(labelValues)]
+	return series, ok
+}
+
+// seriesLocked 取得（或建立）標籤值對應的序列，呼叫者必須持有鎖
+func (f *metricFamily) seriesLocked(labelValues []string) *metricSeries {
+	key := f.seriesKey(labelValues)
+	series, ok := f.series[key]
+	if !ok {
+		series = &metricSeries{labelValues: append([]string{}, labelValues...)}
+		if f.kind == kindHistogram {
+			series.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = series
+	}
+	return series
+}
+
+// Add 增加計數（delta 必須非負）
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	c.family.seriesLocked(labelValues).value += delta
+}
+
+// Inc 計數加一
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value 取得目前計數（序列不存在時為 0）
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	if series, ok := c.family.lookupLocked(labelValues); ok {
+		return series.value
+	}
+	return 0
+}
+
+// Set 設定數值
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.registry.mu.Lock()
+	defer g.registry.mu.Unlock()
+	g.family.seriesLocked(labelValues).value = value
+}
+
+// Value 取得目前數值（序列不存在時為 0）
+func (g *GaugeVec) Value(labelValues ...string) float64 {
+	g.registry.mu.Lock()
+	defer g.registry.mu.Unlock()
+	if series, ok := g.family.lookupLocked(labelValues); ok {
+		return series.value
+	}
+	return 0
+}
+
+// Observe 記錄一個觀測值
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.registry.mu.Lock()
+	defer h.registry.mu.Unlock()
+
+	series := h.family.seriesLocked(labelValues)
+	for i, bound := range h.family.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+// Count 取得觀測次數（序列不存在時為 0）
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.registry.mu.Lock()
+	defer h.registry.mu.Unlock()
+	if series, ok := h.family.lookupLocked(labelValues); ok {
+		return series.count
+	}
+	return 0
+}
+
+// WriteTo 以 Prometheus 文字格式輸出所有指標（依名稱排序）
+func (r *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]*metricFamily, 0, len(r.metrics))
+	fns := make(map[*metricFamily]func() float64)
+	for _, family := range r.metrics {
+		families = append(families, family)
+		if family.fn != nil {
+			fns[family] = family.fn
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	// 函式指標在鎖外讀取，避免與元件自身的鎖互相等待
+	fnValues := make(map[*metricFamily]float64, len(fns))
+	for family, fn := range fns {
+		fnValues[family] = fn()
+	}
+
+	var buf bytes.Buffer
+	r.mu.Lock()
+	for _, family := range families {
+		writeFamily(&buf, family, fnValues[family])
+	}
+	r.mu.Unlock()
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func writeFamily(buf *bytes.Buffer, family *metricFamily, fnValue float64) {
+	if family.fn == nil && len(family.series) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "# HELP %s %s\n", family.name, escapeHelp(family.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", family.name, family.kind)
+
+	if family.fn != nil {
+		fmt.Fprintf(buf, "%s %s\n", family.name, formatMetricValue(fnValue))
+		return
+	}
+
+	keys := make([]string, 0, len(family.series))
+	for key := range family.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := family.series[key]
+		labels := formatLabels(family.labelNames, series.labelValues, "", "")
+		if family.kind != kindHistogram {
+			fmt.Fprintf(buf, "%s%s %s\n", family.name, labels, formatMetricValue(series.value))
+			continue
+		}
+
+		for i, bound := range family.buckets {
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", family.name,
+				formatLabels(family.labelNames, series.labelValues, "le", formatMetricValue(bound)), series.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", family.name,
+			formatLabels(family.labelNames, series.labelValues, "le", "+Inf"), series.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", family.name, labels, formatMetricValue(series.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", family.name, labels, series.count)
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var parts []string
+	for i, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(values[i])))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+// Handler 傳回輸出指標的 HTTP handler（供 Prometheus 抓取）
+func (r *MetricsRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", MetricsContentType)
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// WriteTextfile 將指標寫入檔案，供 node_exporter 的 textfile collector 讀取
+//
+// 先寫入暫存檔再更名，collector 不會讀到寫了一半的內容。
+func (r *MetricsRegistry) WriteTextfile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("無法建立指標目錄: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("無法建立暫存指標檔: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("無法寫入指標: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("無法寫入指標: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("無法設定指標檔權限: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("無法更新指標檔: %w", err)
+	}
+	return nil
+}
+
+// MetricsServer 以 HTTP 輸出指標（GET /metrics）
+type MetricsServer struct {
+	listener net.Listener
+	server   *http.Server
+	done     chan struct{}
+}
+
+// NewMetricsServer 在 addr（例如 ":9464" 或 "127.0.0.1:0"）上啟動指標服務
+func NewMetricsServer(addr string, registry *MetricsRegistry) (*MetricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("無法監聽指標位址: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+
+	s := &MetricsServer{
+		listener: listener,
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second},
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		_ = s.server.Serve(listener)
+	}()
+	return s, nil
+}
+
+// Addr 傳回實際監聽的位址
+func (s *MetricsServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close 停止指標服務
+func (s *MetricsServer) Close() error {
+	err := s.server.Close()
+	<-s.done
+	return err
+}
@@ -0,0 +1,162 @@
+package ghcopilot
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMetricsRegistryExposition 測試 Prometheus 文字格式輸出
+func TestMetricsRegistryExposition(t *testing.T) {
+	r := NewMetricsRegistry()
+	counter := r.Counter("test_requests_total", "Requests.", "path")
+	counter.Inc("/a")
+	counter.Add(2, "/a")
+	counter.Inc(`/b"x`)
+	counter.Add(-1, "/a") // 計數器不可減少
+
+	r.Gauge("test_temperature", "Temperature.").Set(21.5)
+	r.GaugeFunc("test_uptime_seconds", "Uptime.", func() float64 { return 42 })
+
+	hist := r.Histogram("test_latency_seconds", "Latency.", []float64{1, 0.1})
+	hist.Observe(0.05)
+	hist.Observe(0.5)
+	hist.Observe(3)
+
+	unused := r.Counter("test_unused_total", "Never incremented.", "path")
+	if unused.Value("/a") != 0 || counter.Value("/missing") != 0 || hist.Count() != 3 {
+		t.Error("讀取不存在的序列應傳回 0")
+	}
+	r.Gauge("test_unset", "Never set.").Value()
+	r.Histogram("test_unobserved_seconds", "Never observed.", []float64{1}).Count()
+
+	var sb strings.Builder
+	if _, err := r.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo 失敗: %v", err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{path="/a"} 3` + "\n",
+		`test_requests_total{path="/b\"x"} 1` + "\n",
+		"test_temperature 21.5\n",
+		"test_uptime_seconds 42\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{le="+Inf"} 3` + "\n",
+		"test_latency_seconds_sum 3.55\n",
+		"test_latency_seconds_count 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("輸出應包含 %q:\n%s", want, out)
+		}
+	}
+	for _, name := range []string{"test_unused_total", "test_unset", "test_unobserved_seconds", `path="/missing"`} {
+		if strings.Contains(out, name) {
+			t.Errorf("沒有資料或只被讀取的指標不應輸出: %s", name)
+		}
+	}
+	if strings.Index(out, "test_latency_seconds") > strings.Index(out, "test_requests_total") {
+		t.Error("指標應依名稱排序")
+	}
+}
+
+// TestMetricsWriteTextfile 測試 textfile 輸出會完整取代舊檔
+func TestMetricsWriteTextfile(t *testing.T) {
+	r := NewMetricsRegistry()
+	gauge := r.Gauge("test_value", "Value.")
+	path := filepath.Join(t.TempDir(), "collector", "ralph.prom")
+
+	gauge.Set(1)
+	if err := r.WriteTextfile(path); err != nil {
+		t.Fatalf("WriteTextfile 失敗: %v", err)
+	}
+	gauge.Set(2)
+	if err := r.WriteTextfile(path); err != nil {
+		t.Fatalf("WriteTextfile 失敗: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("讀取指標檔失敗: %v", err)
+	}
+	if !strings.Contains(string(data), "test_value 2\n") || strings.Contains(string(data), "test_value 1\n") {
+		t.Errorf("指標檔內容不正確: %s", data)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("不應留下暫存檔: %v", entries)
+	}
+}
+
+// TestMetricsServer 測試以 HTTP 輸出指標
+func TestMetricsServer(t *testing.T) {
+	r := NewMetricsRegistry()
+	r.Counter("test_hits_total", "Hits.").Inc()
+
+	server, err := NewMetricsServer("127.0.0.1:0", r)
+	if err != nil {
+		t.Fatalf("NewMetricsServer 失敗: %v", err)
+	}
+	defer server.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + server.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics 失敗: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.Header.Get("Content-Type") != MetricsContentType {
+		t.Errorf("Content-Type 不正確: %s", resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "test_hits_total 1\n") {
+		t.Errorf("回應應包含指標: %s", body)
+	}
+}
+
+// TestClientRecordsLoopMetrics 測試客戶端更新迴圈、執行器與熔斷器指標
+func TestClientRecordsLoopMetrics(t *testing.T) {
+	installFakeCopilot(t, `echo "still working"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(t.TempDir()).
+		WithProtectedPaths().
+		WithoutPersistence().
+		Build()
+	client.config.EnableSDK = false
+	defer client.Close()
+
+	_, err := client.ExecuteUntilCompletion(t.Context(), "任務", 5)
+	if err == nil || !strings.Contains(err.Error(), "circuit breaker opened") {
+		t.Fatalf("無進展應打開熔斷器: %v", err)
+	}
+
+	var sb strings.Builder
+	client.Metrics().Registry().WriteTo(&sb)
+	out := sb.String()
+
+	for _, want := range []string{
+		`ralph_loop_loops_total{outcome="continue"} 3`,
+		`ralph_loop_executions_total{mode="cli",model="claude-sonnet-4.5",result="success"} 3`,
+		`ralph_loop_execution_duration_seconds_count{mode="cli"} 3`,
+		`ralph_loop_circuit_breaker_state{state="OPEN"} 1`,
+		`ralph_loop_circuit_breaker_transitions_total{state="OPEN"} 1`,
+		"ralph_loop_budget_loops_used 3",
+		"ralph_loop_budget_loops_limit 5",
+		"ralph_loop_cli_retries_total 0",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("指標應包含 %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,574 @@
+package ghcopilot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+// SDK 權限請求的類型（copilot.PermissionRequest.Kind）
+const (
+	PermissionKindShell = "shell"
+	PermissionKindWrite = "write"
+	PermissionKindRead  = "read"
+	PermissionKindURL   = "url"
+	PermissionKindMCP   = "mcp"
+)
+
+// PermissionVerdict 是權限策略對一個請求的判定
+type PermissionVerdict string
+
+const (
+	// VerdictAllow 允許
+	VerdictAllow PermissionVerdict = "allow"
+	// VerdictDeny 拒絕
+	VerdictDeny PermissionVerdict = "deny"
+	// VerdictAsk 策略沒有明確允許，需要詢問操作者（未設定 Escalate 或沒有操作者時拒絕）
+	VerdictAsk PermissionVerdict = "ask"
+)
+
+// 回覆給 Copilot 的結果（copilot.PermissionRequestResult.Kind）
+const (
+	permissionResultApproved     = "approved"
+	permissionResultDeniedRules  = "denied-by-rules"
+	permissionResultDeniedByUser = "denied-interactively-by-user"
+)
+
+// networkCommands 常見會連線到外部的殼層指令（DenyNetwork 時拒絕）
+var networkCommands = []string{
+	"curl", "wget", "ssh", "scp", "sftp", "rsync", "nc", "ncat", "telnet", "ftp",
+	"git clone", "git fetch", "git pull", "git push", "git ls-remote",
+	"go get", "go install", "go mod download",
+	"pip install", "pip3 install", "npm install", "npm ci", "cargo install",
+}
+
+// shellSeparators 將複合指令拆成個別指令；命令替換、子殼層與重新導向的內容也會拆開，
+// 讓禁止規則可以檢查到裡面的指令
+var shellSeparators = regexp.MustCompile("&&|\\|\\||\\$\\(|[;&|\n()`<>]")
+
+// shellFDRedirect 是不寫入檔案的檔案描述子複製（例如 2>&1）
+var shellFDRedirect = regexp.MustCompile(`[0-9]*>&[0-9]+`)
+
+// shellWrappers 執行其後指令的包裝指令，值為需要參數的選項
+var shellWrappers = map[string][]string{
+	"env":     {"-u", "-C", "-S"},
+	"sudo":    {"-u", "-g", "-h", "-p", "-C", "-D", "-r", "-t", "-U"},
+	"doas":    {"-u", "-C"},
+	"command": nil,
+	"exec":    {"-a"},
+	"nohup":   nil,
+	"time":    {"-f", "-o"},
+	"nice":    {"-n"},
+	"xargs":   {"-a", "-d", "-E", "-I", "-L", "-n", "-P", "-s"},
+}
+
+// shellInterpreters 以 -c 執行指令字串的殼層
+var shellInterpreters = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true}
+
+// PermissionRequest 是正規化後的權限請求
+type PermissionRequest struct {
+	Kind       string `json:"kind"`
+	Command    string `json:"command,omitempty"` // shell 的完整指令
+	Path       string `json:"path,omitempty"`    // write/read 的檔案
+	URL        string `json:"url,omitempty"`     // url 的位址
+	Size       int64  `json:"size,omitempty"`    // write 的內容大小（位元組，未知時為 0）
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	SessionID  string `json:"session_id,omitempty"`
+}
+
+// Target 傳回請求的對象（指令、路徑或 URL）
+func (r PermissionRequest) Target() string {
+	switch {
+	case r.Command != "":
+		return r.Command
+	case r.Path != "":
+		return r.Path
+	}
+	return r.URL
+}
+
+// NewPermissionRequest 從 SDK 的權限請求取出指令、路徑、URL 與大小
+func NewPermissionRequest(request copilot.PermissionRequest, invocation copilot.PermissionInvocation) PermissionRequest {
+	extra := request.Extra
+	str := func(keys ...string) string {
+		for _, key := range keys {
+			if value, ok := extra[key].(string); ok && value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	req := PermissionRequest{
+		Kind:       request.Kind,
+		Command:    str("fullCommandText", "command"),
+		Path:       str("fileName", "path"),
+		URL:        str("url"),
+		ToolCallID: request.ToolCallID,
+		SessionID:  invocation.SessionID,
+	}
+	if content := str("newFileContents", "content"); content != "" {
+		req.Size = int64(len(content))
+	} else if size, ok := extra["size"].(float64); ok {
+		req.Size = int64(size)
+	}
+	return req
+}
+
+// PermissionDecision 是權限請求的稽核紀錄
+type PermissionDecision struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Request   PermissionRequest `json:"request"`
+	Verdict   PermissionVerdict `json:"verdict"`             // 策略的判定
+	Allowed   bool              `json:"allowed"`             // 最終是否允許
+	Reason    string            `json:"reason"`              // 判定的理由
+	Escalated bool              `json:"escalated,omitempty"` // 是否詢問了操作者
+}
+
+// PermissionEscalator 在策略無法決定時詢問操作者
+type PermissionEscalator interface {
+	ApprovePermission(ctx context.Context, req PermissionRequest, reason string) (bool, error)
+}
+
+// Evaluate 依權限策略判定請求
+//
+// 拒絕規則（DeniedTools、DenyNetwork、MaxFileBytes、工作目錄以外的路徑）優先；
+// 其次是允許規則（AllowedCommands、AllowedPaths、AllowedTools、AllowAll*）；
+// 都不符合時傳回 VerdictAsk。
+func (p *PermissionPolicy) Evaluate(req PermissionRequest, workDir string) (PermissionVerdict, string) {
+	if p == nil {
+		return VerdictAsk, "沒有權限策略"
+	}
+
+	switch req.Kind {
+	case PermissionKindShell:
+		return p.evaluateShell(req.Command)
+	case PermissionKindWrite:
+		return p.evaluateWrite(req, workDir)
+	case PermissionKindRead:
+		if !p.AllowAllPaths && !p.pathInScope(req.Path, workDir) {
+			return VerdictAsk, "路徑在工作目錄與允許的目錄之外: " + req.Path
+		}
+		return VerdictAllow, "讀取工作目錄內的檔案"
+	case PermissionKindURL:
+		if p.DenyNetwork {
+			return VerdictDeny, "策略禁止網路存取"
+		}
+		if p.AllowAllURLs {
+			return VerdictAllow, "allow-all-urls"
+		}
+		return VerdictAsk, "沒有允許存取 URL"
+	}
+
+	if p.toolDenied(req.Kind) {
+		return VerdictDeny, "工具被禁止: " + req.Kind
+	}
+	if p.AllowAllTools || p.toolAllowed(req.Kind) {
+		return VerdictAllow, "允許的工具: " + req.Kind
+	}
+	return VerdictAsk, "沒有規則允許 " + req.Kind
+}
+
+// evaluateShell 判定殼層指令（複合指令的每一段都必須通過）
+//
+// 每一段去除 env、sudo 等包裝與路徑後再比對規則，sh -c 的指令字串會遞迴判定。
+// 背景執行、命令替換、子殼層與重新導向無法靜態判定，禁止規則之後一律傳回 VerdictAsk。
+func (p *PermissionPolicy) evaluateShell(command string) (PermissionVerdict, string) {
+	if strings.TrimSpace(command) == "" {
+		return VerdictAsk, "無法取得指令內容"
+	}
+	if p.toolDenied(PermissionKindShell) {
+		return VerdictDeny, "指令被禁止: " + command
+	}
+
+	command = shellFDRedirect.ReplaceAllString(command, "")
+	var segments [][]string
+	scriptReason := ""
+	for _, segment := range shellSeparators.Split(command, -1) {
+		words := commandWords(segment)
+		if len(words) == 0 {
+			continue
+		}
+		if script, ok := shellScript(words); ok {
+			verdict, reason := p.evaluateShell(script)
+			if verdict != VerdictAllow {
+				return verdict, reason
+			}
+			scriptReason = reason
+			continue
+		}
+		normalized := strings.Join(words, " ")
+		if p.toolDenied("shell(" + normalized + ")") {
+			return VerdictDeny, "指令被禁止: " + normalized
+		}
+		if p.DenyNetwork && commandHasPrefix(normalized, networkCommands) != "" {
+			return VerdictDeny, "策略禁止網路存取: " + normalized
+		}
+		segments = append(segments, words)
+	}
+	if construct := shellConstruct(command); construct != "" {
+		return VerdictAsk, "指令包含" + construct + "，無法判定: " + command
+	}
+
+	verdict, reason := VerdictAllow, scriptReason
+	for _, words := range segments {
+		normalized := strings.Join(words, " ")
+		switch {
+		case len(p.AllowedCommands) > 0:
+			if pattern := p.matchCommand(normalized); pattern != "" {
+				reason = "符合允許的指令 " + pattern
+				continue
+			}
+		case p.AllowAllTools || p.toolAllowed("shell("+normalized+")"):
+			reason = "允許的殼層指令"
+			continue
+		}
+		verdict, reason = VerdictAsk, "沒有規則允許指令: "+normalized
+	}
+	return verdict, reason
+}
+
+// shellConstruct 傳回指令中無法靜態判定的殼層語法（沒有時傳回空字串）
+func shellConstruct(command string) string {
+	stripped := strings.ReplaceAll(command, "&&", "")
+	switch {
+	case strings.Contains(command, "$(") || strings.Contains(command, "`"):
+		return "命令替換"
+	case strings.Contains(command, "<(") || strings.Contains(command, ">("):
+		return "程序替換"
+	case strings.ContainsAny(command, "<>"):
+		return "重新導向"
+	case strings.Contains(stripped, "&"):
+		return "背景執行"
+	case strings.ContainsAny(command, "()"):
+		return "子殼層"
+	}
+	return ""
+}
+
+// commandWords 將一段指令拆成單字，去除開頭的變數設定與包裝指令，並只保留指令的檔名
+// （"sudo env FOO=1 /usr/bin/curl x" 傳回 ["curl", "x"]）
+func commandWords(segment string) []string {
+	fields := strings.Fields(segment)
+	for len(fields) > 0 {
+		word := strings.Trim(fields[0], `"'\`)
+		if i := strings.Index(word, "="); i > 0 && !strings.Contains(word[:i], "/") {
+			fields = fields[1:]
+			continue
+		}
+		name := filepath.Base(word)
+		options, ok := shellWrappers[name]
+		if !ok {
+			fields[0] = name
+			return fields
+		}
+		fields = fields[1:]
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "-") {
+			option := fields[0]
+			fields = fields[1:]
+			if option == "--" {
+				break
+			}
+			if slices.Contains(options, option) && len(fields) > 0 {
+				fields = fields[1:]
+			}
+		}
+	}
+	return nil
+}
+
+// shellScript 傳回 sh -c 或 eval 執行的指令字串
+func shellScript(words []string) (string, bool) {
+	if words[0] == "eval" {
+		return strings.Trim(strings.Join(words[1:], " "), `"'`), true
+	}
+	if !shellInterpreters[words[0]] {
+		return "", false
+	}
+	for i, word := range words[1:] {
+		if strings.HasPrefix(word, "-") && !strings.HasPrefix(word, "--") && strings.Contains(word, "c") {
+			return strings.Trim(strings.Join(words[i+2:], " "), `"'`), true
+		}
+	}
+	return "", false
+}
+
+// evaluateWrite 判定寫入檔案
+func (p *PermissionPolicy) evaluateWrite(req PermissionRequest, workDir string) (PermissionVerdict, string) {
+	if p.toolDenied(PermissionKindWrite) {
+		return VerdictDeny, "策略禁止寫入"
+	}
+	if p.MaxFileBytes > 0 && req.Size > p.MaxFileBytes {
+		return VerdictDeny, fmt.Sprintf("檔案大小 %d 超過上限 %d", req.Size, p.MaxFileBytes)
+	}
+	if !p.AllowAllPaths && !p.pathInScope(req.Path, workDir) {
+		return VerdictDeny, "路徑在工作目錄與允許的目錄之外: " + req.Path
+	}
+	if len(p.AllowedPaths) > 0 {
+		rel := relativeToWorkDir(req.Path, workDir)
+		for _, pattern := range p.AllowedPaths {
+			if matchPathGlob(pattern, rel) {
+				return VerdictAllow, "符合允許的路徑 " + pattern
+			}
+		}
+		return VerdictAsk, "路徑不符合允許的規則: " + rel
+	}
+	if p.AllowAllTools || p.toolAllowed(PermissionKindWrite) {
+		return VerdictAllow, "允許寫入"
+	}
+	return VerdictAsk, "沒有規則允許寫入"
+}
+
+// toolDenied 檢查 DeniedTools（"shell(git push)" 也禁止 "git push origin main"）
+func (p *PermissionPolicy) toolDenied(tool string) bool {
+	return matchToolList(p.DeniedTools, tool)
+}
+
+// toolAllowed 檢查 AllowedTools
+func (p *PermissionPolicy) toolAllowed(tool string) bool {
+	return matchToolList(p.AllowedTools, tool)
+}
+
+// matchCommand 傳回符合指令的 AllowedCommands 規則（無效的規則視為不符合）
+func (p *PermissionPolicy) matchCommand(command string) string {
+	for _, pattern := range p.AllowedCommands {
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(command) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// pathInScope 檢查路徑是否在工作目錄或 AllowedDirs 之內
+func (p *PermissionPolicy) pathInScope(filePath, workDir string) bool {
+	if filePath == "" {
+		return false
+	}
+	for _, dir := range append([]string{workDir}, p.AllowedDirs...) {
+		if dir == "" {
+			continue
+		}
+		if rel, ok := relativePath(filePath, dir, workDir); ok && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchToolList 比對工具清單；"shell(cmd)" 項目以指令前綴比對
+func matchToolList(list []string, tool string) bool {
+	for _, item := range list {
+		if item == tool {
+			return true
+		}
+		prefix, ok := strings.CutPrefix(item, "shell(")
+		command, isShell := strings.CutPrefix(tool, "shell(")
+		if ok && isShell {
+			prefix = strings.TrimSuffix(prefix, ")")
+			command = strings.TrimSuffix(command, ")")
+			if commandHasPrefix(command, []string{prefix}) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commandHasPrefix 傳回指令開頭符合的項目（以完整單字比對）
+func commandHasPrefix(command string, prefixes []string) string {
+	fields := strings.Fields(command)
+	for _, prefix := range prefixes {
+		words := strings.Fields(prefix)
+		if len(words) == 0 || len(words) > len(fields) {
+			continue
+		}
+		match := true
+		for i, word := range words {
+			if fields[i] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// relativePath 傳回 filePath 相對於 dir 的路徑（相對路徑以 workDir 為基準）
+func relativePath(filePath, dir, workDir string) (string, bool) {
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(workDir, filePath)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workDir, dir)
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// relativeToWorkDir 傳回相對於工作目錄、以 / 分隔的路徑
+func relativeToWorkDir(filePath, workDir string) string {
+	if rel, ok := relativePath(filePath, workDir, workDir); ok {
+		return rel
+	}
+	return filepath.ToSlash(filePath)
+}
+
+// PermissionEngine 以權限策略處理 SDK 會話的權限請求
+//
+// 每個決定都會記錄日誌、交給 OnDecision（客戶端以事件寫入 journal），
+// 並保存在 BeginLoop 指定的迴圈的 PermissionDecisions。
+type PermissionEngine struct {
+	policy    *PermissionPolicy
+	workDir   string
+	escalator PermissionEscalator
+	logger    *slog.Logger
+
+	// OnDecision 在每個決定後呼叫（可為 nil）
+	OnDecision func(PermissionDecision)
+
+	mu        sync.Mutex
+	ctx       context.Context
+	current   *ExecutionContext
+	decisions []PermissionDecision
+}
+
+// NewPermissionEngine 建立權限引擎
+func NewPermissionEngine(policy *PermissionPolicy, workDir string) *PermissionEngine {
+	return &PermissionEngine{policy: policy, workDir: workDir, logger: discardLogger()}
+}
+
+// SetEscalator 設定策略無法決定時詢問的操作者（nil 時一律拒絕）
+func (e *PermissionEngine) SetEscalator(escalator PermissionEscalator) {
+	e.escalator = escalator
+}
+
+// SetLogger 設定決定的日誌
+func (e *PermissionEngine) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	e.logger = logger
+}
+
+// BeginLoop 開始將決定記錄在迴圈中；ctx 取消時會中止等待中的操作者詢問
+func (e *PermissionEngine) BeginLoop(ctx context.Context, execCtx *ExecutionContext) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ctx = ctx
+	e.current = execCtx
+}
+
+// EndLoop 停止將決定記錄在迴圈中
+func (e *PermissionEngine) EndLoop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ctx = nil
+	e.current = nil
+}
+
+// Decisions 傳回所有的決定
+func (e *PermissionEngine) Decisions() []PermissionDecision {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]PermissionDecision(nil), e.decisions...)
+}
+
+// Decide 判定請求；策略需要詢問且設定了 Escalate 時交給操作者
+func (e *PermissionEngine) Decide(ctx context.Context, req PermissionRequest) PermissionDecision {
+	verdict, reason := e.policy.Evaluate(req, e.workDir)
+	decision := PermissionDecision{
+		Timestamp: time.Now(),
+		Request:   req,
+		Verdict:   verdict,
+		Allowed:   verdict == VerdictAllow,
+		Reason:    reason,
+	}
+	if verdict == VerdictAsk {
+		if e.escalator != nil && e.policy.Escalate {
+			decision.Escalated = true
+			allowed, err := e.escalator.ApprovePermission(ctx, req, reason)
+			decision.Allowed = err == nil && allowed
+			switch {
+			case err != nil:
+				decision.Reason = fmt.Sprintf("%s; 詢問操作者失敗: %v", reason, err)
+			case allowed:
+				decision.Reason = reason + "; 操作者允許"
+			default:
+				decision.Reason = reason + "; 操作者拒絕"
+			}
+		} else {
+			decision.Reason = reason + "; 未設定詢問操作者，拒絕"
+		}
+	}
+	e.record(decision)
+	return decision
+}
+
+// record 保存並記錄決定
+func (e *PermissionEngine) record(decision PermissionDecision) {
+	e.mu.Lock()
+	e.decisions = append(e.decisions, decision)
+	if e.current != nil {
+		e.current.PermissionDecisions = append(e.current.PermissionDecisions, decision)
+	}
+	e.mu.Unlock()
+
+	level := slog.LevelInfo
+	if !decision.Allowed {
+		level = slog.LevelWarn
+	}
+	e.logger.Log(context.Background(), level, "權限請求",
+		"kind", decision.Request.Kind,
+		"target", truncateString(decision.Request.Target(), 200),
+		"verdict", decision.Verdict,
+		"allowed", decision.Allowed,
+		"escalated", decision.Escalated,
+		"reason", decision.Reason)
+	if e.OnDecision != nil {
+		e.OnDecision(decision)
+	}
+}
+
+// Handler 傳回註冊到 copilot.SessionConfig.OnPermissionRequest 的處理函式
+//
+// SDK 的處理函式沒有 context，詢問操作者時使用 BeginLoop 指定的迴圈 context。
+func (e *PermissionEngine) Handler() copilot.PermissionHandler {
+	return func(request copilot.PermissionRequest, invocation copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
+		e.mu.Lock()
+		ctx := e.ctx
+		e.mu.Unlock()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		decision := e.Decide(ctx, NewPermissionRequest(request, invocation))
+		switch {
+		case decision.Allowed:
+			return copilot.PermissionRequestResult{Kind: permissionResultApproved}, nil
+		case decision.Escalated:
+			return copilot.PermissionRequestResult{Kind: permissionResultDeniedByUser}, nil
+		}
+		return copilot.PermissionRequestResult{Kind: permissionResultDeniedRules}, nil
+	}
+}
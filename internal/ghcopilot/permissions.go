@@ -0,0 +1,264 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PermissionPreset 定義權限預設組合名稱
+type PermissionPreset string
+
+const (
+	// PresetReadOnly 唯讀：只允許檢視類的殼層指令，禁止寫入
+	PresetReadOnly PermissionPreset = "read-only"
+	// PresetEditOnly 僅編輯：允許寫入檔案，禁止執行殼層指令
+	PresetEditOnly PermissionPreset = "edit-only"
+	// PresetFull 完全：允許所有工具（原本的預設行為）
+	PresetFull PermissionPreset = "full"
+	// PresetSafe 安全：允許所有工具，但禁止破壞性與對外的殼層指令
+	PresetSafe PermissionPreset = "safe"
+)
+
+// readOnlyShellTools 唯讀模式允許的殼層指令
+var readOnlyShellTools = []string{
+	"shell(cat)",
+	"shell(ls)",
+	"shell(find)",
+	"shell(grep)",
+	"shell(head)",
+	"shell(tail)",
+	"shell(wc)",
+	"shell(git status)",
+	"shell(git diff)",
+	"shell(git log)",
+	"shell(git show)",
+}
+
+// unsafeShellTools 安全模式禁止的殼層指令
+var unsafeShellTools = []string{
+	"shell(rm)",
+	"shell(git push)",
+	"shell(git reset)",
+	"shell(git clean)",
+	"shell(curl)",
+	"shell(wget)",
+	"shell(sudo)",
+}
+
+// PermissionPolicy 定義 Copilot 執行時的工具與路徑權限
+//
+// 它是 ExecutorOptions 中權限相關欄位的設定層表示，
+// 會套用到 CLI 參數 (--allow-tool、--deny-tool、--add-dir 等)；
+// SDK 會話則由 PermissionEngine 以同一個策略判定每個權限請求。
+// 策略會隨每個 ExecutionContext 一起記錄以供稽核。
+type PermissionPolicy struct {
+	Preset          PermissionPreset `json:"preset,omitempty"` // 來源預設組合
+	AllowAllTools   bool             `json:"allow_all_tools"`  // 允許所有工具自動執行
+	AllowedTools    []string         `json:"allowed_tools"`    // 允許的工具列表
+	DeniedTools     []string         `json:"denied_tools"`     // 禁止的工具列表（優先於允許）
+	AllowedDirs     []string         `json:"allowed_dirs"`     // 額外允許存取的目錄
+	AllowAllPaths   bool             `json:"allow_all_paths"`  // 允許存取所有路徑
+	AllowAllURLs    bool             `json:"allow_all_urls"`   // 允許存取所有 URL
+	DisableParallel bool             `json:"disable_parallel"` // 禁用平行工具執行
+
+	// SDK 會話的權限請求策略（見 PermissionEngine，CLI 模式不使用）
+	AllowedCommands []string `json:"allowed_commands,omitempty"` // 允許的殼層指令（正規表示式，指定後只允許符合的指令）
+	AllowedPaths    []string `json:"allowed_paths,omitempty"`    // 允許寫入的路徑（相對工作目錄的 glob，支援 **）
+	DenyNetwork     bool     `json:"deny_network,omitempty"`     // 拒絕 URL 請求與常見的網路指令（curl、git push、go get 等）
+	MaxFileBytes    int64    `json:"max_file_bytes,omitempty"`   // 單次寫入檔案的大小上限（0 表示不限制）
+	Escalate        bool     `json:"escalate,omitempty"`         // 策略無法決定時詢問操作者，而不是直接拒絕
+}
+
+// DefaultPermissionPolicy 傳回預設權限策略（等同 full，維持既有行為）
+func DefaultPermissionPolicy() *PermissionPolicy {
+	policy, _ := NewPermissionPolicy(PresetFull)
+	return policy
+}
+
+// NewPermissionPolicy 根據預設組合建立權限策略
+func NewPermissionPolicy(preset PermissionPreset) (*PermissionPolicy, error) {
+	policy := &PermissionPolicy{Preset: preset}
+
+	switch preset {
+	case PresetReadOnly:
+		policy.AllowedTools = append([]string{}, readOnlyShellTools...)
+		policy.DeniedTools = []string{"write"}
+	case PresetEditOnly:
+		policy.AllowedTools = []string{"write"}
+		policy.DeniedTools = []string{"shell"}
+	case PresetFull:
+		policy.AllowAllTools = true
+	case PresetSafe:
+		policy.AllowAllTools = true
+		policy.DeniedTools = append([]string{}, unsafeShellTools...)
+	default:
+		return nil, fmt.Errorf("未知的權限預設: %s (可用: %s)", preset, strings.Join(PermissionPresetNames(), ", "))
+	}
+
+	return policy, nil
+}
+
+// PermissionPresetNames 傳回所有可用的預設組合名稱
+func PermissionPresetNames() []string {
+	return []string{
+		string(PresetReadOnly),
+		string(PresetEditOnly),
+		string(PresetFull),
+		string(PresetSafe),
+	}
+}
+
+// AllowTools 加入明確允許的工具
+//
+// 一旦指定了允許清單，就不再使用 --allow-all-tools，
+// 否則允許清單不會有任何效果。
+func (p *PermissionPolicy) AllowTools(tools ...string) *PermissionPolicy {
+	if len(tools) == 0 {
+		return p
+	}
+	p.AllowedTools = appendUnique(p.AllowedTools, tools...)
+	p.AllowAllTools = false
+	return p
+}
+
+// DenyTools 加入禁止的工具
+func (p *PermissionPolicy) DenyTools(tools ...string) *PermissionPolicy {
+	p.DeniedTools = appendUnique(p.DeniedTools, tools...)
+	return p
+}
+
+// AddDirs 加入允許存取的目錄
+func (p *PermissionPolicy) AddDirs(dirs ...string) *PermissionPolicy {
+	p.AllowedDirs = appendUnique(p.AllowedDirs, dirs...)
+	return p
+}
+
+// Validate 檢查 SDK 權限請求策略的設定
+func (p *PermissionPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range p.AllowedCommands {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("無效的允許指令 %q: %w", pattern, err)
+		}
+	}
+	if p.MaxFileBytes < 0 {
+		return fmt.Errorf("檔案大小上限不可為負數: %d", p.MaxFileBytes)
+	}
+	return nil
+}
+
+// RequiresSDK 回報策略是否設定了只有 SDK 會話能執行的限制
+//
+// CLI 模式只接受 --allow-tool 等參數，無法逐一判定指令、路徑、網路與大小，
+// 設定這些限制時必須以 SDK 執行（ClientConfig.RequireSDK）。
+func (p *PermissionPolicy) RequiresSDK() bool {
+	if p == nil {
+		return false
+	}
+	return len(p.AllowedCommands) > 0 || len(p.AllowedPaths) > 0 || p.DenyNetwork || p.MaxFileBytes > 0 || p.Escalate
+}
+
+// ApplyTo 將權限策略套用到執行選項
+func (p *PermissionPolicy) ApplyTo(opts *ExecutorOptions) {
+	if p == nil || opts == nil {
+		return
+	}
+
+	opts.AllowAllTools = p.AllowAllTools
+	opts.AllowAllPaths = p.AllowAllPaths
+	opts.AllowAllURLs = p.AllowAllURLs
+	opts.DisableParallel = p.DisableParallel
+	opts.AllowedTools = append([]string{}, p.AllowedTools...)
+	opts.DeniedTools = append([]string{}, p.DeniedTools...)
+	opts.AllowedDirs = append([]string{}, p.AllowedDirs...)
+}
+
+// Clone 傳回權限策略的深拷貝
+func (p *PermissionPolicy) Clone() *PermissionPolicy {
+	if p == nil {
+		return nil
+	}
+
+	clone := *p
+	clone.AllowedTools = append([]string{}, p.AllowedTools...)
+	clone.DeniedTools = append([]string{}, p.DeniedTools...)
+	clone.AllowedDirs = append([]string{}, p.AllowedDirs...)
+	clone.AllowedCommands = append([]string(nil), p.AllowedCommands...)
+	clone.AllowedPaths = append([]string(nil), p.AllowedPaths...)
+	return &clone
+}
+
+// Summary 傳回適合顯示的單行摘要
+func (p *PermissionPolicy) Summary() string {
+	if p == nil {
+		return "none"
+	}
+
+	var parts []string
+	if p.Preset != "" {
+		parts = append(parts, "preset="+string(p.Preset))
+	}
+	if p.AllowAllTools {
+		parts = append(parts, "allow-all-tools")
+	}
+	if len(p.AllowedTools) > 0 {
+		parts = append(parts, "allow="+strings.Join(p.AllowedTools, ","))
+	}
+	if len(p.DeniedTools) > 0 {
+		parts = append(parts, "deny="+strings.Join(p.DeniedTools, ","))
+	}
+	if len(p.AllowedDirs) > 0 {
+		parts = append(parts, "dirs="+strings.Join(p.AllowedDirs, ","))
+	}
+	if p.AllowAllPaths {
+		parts = append(parts, "allow-all-paths")
+	}
+	if p.AllowAllURLs {
+		parts = append(parts, "allow-all-urls")
+	}
+	if p.DisableParallel {
+		parts = append(parts, "no-parallel")
+	}
+	if len(p.AllowedCommands) > 0 {
+		parts = append(parts, "commands="+strings.Join(p.AllowedCommands, ","))
+	}
+	if len(p.AllowedPaths) > 0 {
+		parts = append(parts, "paths="+strings.Join(p.AllowedPaths, ","))
+	}
+	if p.DenyNetwork {
+		parts = append(parts, "deny-network")
+	}
+	if p.MaxFileBytes > 0 {
+		parts = append(parts, fmt.Sprintf("max-file-bytes=%d", p.MaxFileBytes))
+	}
+	if p.Escalate {
+		parts = append(parts, "escalate")
+	}
+
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, " ")
+}
+
+// appendUnique 加入不重複的項目，並保持原有順序
+func appendUnique(list []string, items ...string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, item := range list {
+		seen[item] = true
+	}
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		list = append(list, item)
+	}
+
+	return list
+}
@@ -0,0 +1,182 @@
+package ghcopilot
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestNewPermissionPolicyPresets 測試各預設組合
+func TestNewPermissionPolicyPresets(t *testing.T) {
+	tests := []struct {
+		preset        PermissionPreset
+		allowAllTools bool
+		mustAllow     string
+		mustDeny      string
+	}{
+		{PresetReadOnly, false, "shell(git diff)", "write"},
+		{PresetEditOnly, false, "write", "shell"},
+		{PresetFull, true, "", ""},
+		{PresetSafe, true, "", "shell(git push)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.preset), func(t *testing.T) {
+			policy, err := NewPermissionPolicy(tt.preset)
+			if err != nil {
+				t.Fatalf("NewPermissionPolicy(%s) 失敗: %v", tt.preset, err)
+			}
+			if policy.AllowAllTools != tt.allowAllTools {
+				t.Errorf("AllowAllTools 應為 %v", tt.allowAllTools)
+			}
+			if tt.mustAllow != "" && !containsItem(policy.AllowedTools, tt.mustAllow) {
+				t.Errorf("允許清單應包含 %s，但為 %v", tt.mustAllow, policy.AllowedTools)
+			}
+			if tt.mustDeny != "" && !containsItem(policy.DeniedTools, tt.mustDeny) {
+				t.Errorf("禁止清單應包含 %s，但為 %v", tt.mustDeny, policy.DeniedTools)
+			}
+		})
+	}
+}
+
+// TestNewPermissionPolicyUnknown 測試未知預設
+func TestNewPermissionPolicyUnknown(t *testing.T) {
+	if _, err := NewPermissionPolicy("yolo"); err == nil {
+		t.Error("未知預設應傳回錯誤")
+	}
+}
+
+// TestPermissionPolicyAllowToolsDisablesAllowAll 測試指定允許工具後停用 allow-all
+func TestPermissionPolicyAllowToolsDisablesAllowAll(t *testing.T) {
+	policy := DefaultPermissionPolicy()
+	policy.AllowTools("shell(go test)", "shell(go test)", "write")
+
+	if policy.AllowAllTools {
+		t.Error("指定允許工具後應停用 AllowAllTools")
+	}
+	if len(policy.AllowedTools) != 2 {
+		t.Errorf("允許清單應去除重複，但為 %v", policy.AllowedTools)
+	}
+
+	// 空清單不應影響
+	full := DefaultPermissionPolicy()
+	full.AllowTools()
+	if !full.AllowAllTools {
+		t.Error("未指定工具時應維持 AllowAllTools")
+	}
+}
+
+// TestPermissionPolicyApplyTo 測試套用到執行選項與 CLI 參數
+func TestPermissionPolicyApplyTo(t *testing.T) {
+	policy, _ := NewPermissionPolicy(PresetEditOnly)
+	policy.DenyTools("shell(rm)").AddDirs("/tmp/extra")
+
+	opts := DefaultOptions()
+	policy.ApplyTo(&opts)
+
+	executor := NewCLIExecutorWithOptions(".", opts)
+	args := strings.Join(executor.buildArgs("test"), " ")
+
+	if strings.Contains(args, "--allow-all-tools") {
+		t.Errorf("edit-only 不應包含 --allow-all-tools: %s", args)
+	}
+	for _, want := range []string{"--allow-tool write", "--deny-tool shell", "--deny-tool shell(rm)", "--add-dir /tmp/extra"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("參數應包含 %q: %s", want, args)
+		}
+	}
+}
+
+// TestPermissionPolicyClone 測試深拷貝
+func TestPermissionPolicyClone(t *testing.T) {
+	policy, _ := NewPermissionPolicy(PresetReadOnly)
+	clone := policy.Clone()
+	clone.AllowedTools[0] = "changed"
+
+	if policy.AllowedTools[0] == "changed" {
+		t.Error("Clone 應為深拷貝")
+	}
+
+	var nilPolicy *PermissionPolicy
+	if nilPolicy.Clone() != nil {
+		t.Error("nil Clone 應傳回 nil")
+	}
+}
+
+// TestPermissionPolicyRequiresSDK 測試只有 SDK 能執行的限制
+func TestPermissionPolicyRequiresSDK(t *testing.T) {
+	policy, _ := NewPermissionPolicy(PresetSafe)
+	if policy.RequiresSDK() {
+		t.Error("預設組合只使用 CLI 參數，不需要 SDK")
+	}
+	for _, restricted := range []*PermissionPolicy{
+		{AllowedCommands: []string{"^go "}},
+		{AllowedPaths: []string{"src/**"}},
+		{DenyNetwork: true},
+		{MaxFileBytes: 1024},
+		{Escalate: true},
+	} {
+		if !restricted.RequiresSDK() {
+			t.Errorf("策略應需要 SDK: %+v", restricted)
+		}
+	}
+
+	var nilPolicy *PermissionPolicy
+	if nilPolicy.RequiresSDK() {
+		t.Error("nil 策略不需要 SDK")
+	}
+}
+
+// TestPermissionPolicySummary 測試摘要
+func TestPermissionPolicySummary(t *testing.T) {
+	policy, _ := NewPermissionPolicy(PresetSafe)
+	summary := policy.Summary()
+
+	if !strings.Contains(summary, "preset=safe") || !strings.Contains(summary, "allow-all-tools") {
+		t.Errorf("摘要內容不正確: %s", summary)
+	}
+
+	var nilPolicy *PermissionPolicy
+	if nilPolicy.Summary() != "none" {
+		t.Error("nil 策略摘要應為 none")
+	}
+}
+
+// TestClientRecordsPermissionPolicy 測試迴圈上下文記錄生效的權限策略
+func TestClientRecordsPermissionPolicy(t *testing.T) {
+	os.Setenv("COPILOT_MOCK_MODE", "true")
+	defer os.Unsetenv("COPILOT_MOCK_MODE")
+
+	policy, _ := NewPermissionPolicy(PresetReadOnly)
+	client := NewClientBuilder().
+		WithPermissions(policy).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	if client.executor.options.AllowAllTools {
+		t.Error("read-only 不應允許所有工具")
+	}
+
+	if _, err := client.ExecuteLoop(t.Context(), "列出檔案"); err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+
+	history := client.GetHistory()
+	if len(history) != 1 {
+		t.Fatalf("應有 1 筆歷史，但有 %d", len(history))
+	}
+	recorded := history[0].PermissionPolicy
+	if recorded == nil || recorded.Preset != PresetReadOnly {
+		t.Errorf("應記錄 read-only 權限策略，但為 %+v", recorded)
+	}
+}
+
+func containsItem(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
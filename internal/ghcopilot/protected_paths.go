@@ -0,0 +1,181 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultProtectedPaths 預設受保護的路徑（glob，支援 **）
+//
+// 這些檔案不應被 AI 為了「讓測試通過」而修改：
+// 依賴宣告、CI 流程，以及既有測試的預期結果。
+var DefaultProtectedPaths = []string{
+	"go.mod",
+	"go.sum",
+	".github/workflows/**",
+	"**/*_test.go",
+}
+
+// PathViolation 代表一次受保護路徑的違規變更
+type PathViolation struct {
+	Path     string         `json:"path"`     // 違規的檔案
+	Kind     FileChangeKind `json:"kind"`     // 變更類型
+	Pattern  string         `json:"pattern"`  // 命中的保護規則
+	Reverted bool           `json:"reverted"` // 是否已還原
+}
+
+// String 傳回違規的描述
+func (v PathViolation) String() string {
+	status := "未還原"
+	if v.Reverted {
+		status = "已還原"
+	}
+	return fmt.Sprintf("%s (%s, 規則 %s, %s)", v.Path, v.Kind, v.Pattern, status)
+}
+
+// ProtectedPathGuard 檢查工作目錄變更是否觸及受保護路徑
+type ProtectedPathGuard struct {
+	patterns    []string
+	allowCreate bool // 是否允許新增符合規則的檔案（例如新的測試檔）
+}
+
+// NewProtectedPathGuard 建立受保護路徑檢查器
+//
+// 預設允許新增檔案：保護的是既有內容，新增測試檔不算違規。
+func NewProtectedPathGuard(patterns []string) *ProtectedPathGuard {
+	return &ProtectedPathGuard{
+		patterns:    append([]string{}, patterns...),
+		allowCreate: true,
+	}
+}
+
+// SetAllowCreate 設定是否允許新增符合規則的檔案
+func (g *ProtectedPathGuard) SetAllowCreate(allow bool) {
+	g.allowCreate = allow
+}
+
+// Patterns 傳回保護規則
+func (g *ProtectedPathGuard) Patterns() []string {
+	return append([]string{}, g.patterns...)
+}
+
+// Enabled 檢查是否有任何保護規則
+func (g *ProtectedPathGuard) Enabled() bool {
+	return g != nil && len(g.patterns) > 0
+}
+
+// Match 傳回路徑命中的第一條規則，沒有命中時傳回空字串
+func (g *ProtectedPathGuard) Match(filePath string) string {
+	for _, pattern := range g.patterns {
+		if matchPathGlob(pattern, filePath) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// Check 找出變更中違反保護規則的項目
+func (g *ProtectedPathGuard) Check(changes []FileChange) []PathViolation {
+	if !g.Enabled() {
+		return nil
+	}
+
+	var violations []PathViolation
+	for _, change := range changes {
+		if change.Kind == FileAdded && g.allowCreate {
+			continue
+		}
+		if pattern := g.Match(change.Path); pattern != "" {
+			violations = append(violations, PathViolation{
+				Path:    change.Path,
+				Kind:    change.Kind,
+				Pattern: pattern,
+			})
+		}
+	}
+
+	return violations
+}
+
+// Enforce 檢查變更並將違規檔案還原到快照狀態
+//
+// 傳回的違規清單中 Reverted 欄位標示是否還原成功。
+func (g *ProtectedPathGuard) Enforce(before *WorkspaceSnapshot, changes []FileChange) ([]PathViolation, error) {
+	violations := g.Check(changes)
+
+	var firstErr error
+	for i := range violations {
+		if err := before.Restore(violations[i].Path); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		violations[i].Reverted = true
+	}
+
+	return violations, firstErr
+}
+
+// FormatViolationNote 產生注入下一次 prompt 的說明
+func FormatViolationNote(violations []PathViolation) string {
+	if len(violations) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("上一輪修改了受保護的檔案，這些變更已被自動還原（標示未還原的請自行復原）：\n")
+	for _, v := range violations {
+		sb.WriteString("- ")
+		sb.WriteString(v.String())
+		sb.WriteString("\n")
+	}
+	sb.WriteString("請勿修改這些檔案（依賴宣告、CI 設定與既有測試的預期結果），改為修正實作程式碼。")
+	return sb.String()
+}
+
+// matchPathGlob 比對以 / 分隔的路徑與 glob 規則
+//
+// 除了 path.Match 的語法外，還支援 ** 代表任意層目錄。
+// 不含 / 的規則（例如 "*.lock"）會比對任何層級的檔名。
+func matchPathGlob(pattern, filePath string) bool {
+	pattern = strings.TrimPrefix(path.Clean(strings.ReplaceAll(pattern, "\\", "/")), "./")
+	filePath = strings.TrimPrefix(path.Clean(filePath), "./")
+
+	if !strings.Contains(pattern, "/") && !strings.Contains(pattern, "**") {
+		ok, _ := path.Match(pattern, path.Base(filePath))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filePath, "/"))
+}
+
+// matchSegments 逐段比對路徑
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
@@ -0,0 +1,165 @@
+package ghcopilot
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// installFakeCopilot 在 PATH 最前面放一個假的 copilot 指令
+//
+// script 為 sh 腳本內容，會在工作目錄中執行。
+func installFakeCopilot(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("假的 copilot 指令需要 sh")
+	}
+
+	binDir := t.TempDir()
+	path := filepath.Join(binDir, "copilot")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("COPILOT_MOCK_MODE", "")
+}
+
+// TestMatchPathGlob 測試 glob 比對
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"go.mod", "go.mod", true},
+		{"go.mod", "tools/go.mod", true},
+		{"go.mod", "go.sum", false},
+		{".github/workflows/**", ".github/workflows/ci.yml", true},
+		{".github/workflows/**", ".github/ISSUE_TEMPLATE.md", false},
+		{"**/*_test.go", "client_test.go", true},
+		{"**/*_test.go", "internal/pkg/client_test.go", true},
+		{"**/*_test.go", "internal/pkg/client.go", false},
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"./scripts/**", "scripts/run.sh", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchPathGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPathGlob(%q, %q) = %v，應為 %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestProtectedPathGuardCheck 測試違規檢查
+func TestProtectedPathGuardCheck(t *testing.T) {
+	guard := NewProtectedPathGuard(DefaultProtectedPaths)
+	changes := []FileChange{
+		{Path: "go.mod", Kind: FileModified},
+		{Path: "main.go", Kind: FileModified},
+		{Path: "pkg/new_test.go", Kind: FileAdded},
+		{Path: "pkg/old_test.go", Kind: FileDeleted},
+	}
+
+	violations := guard.Check(changes)
+	if len(violations) != 2 {
+		t.Fatalf("應有 2 個違規，但為 %v", violations)
+	}
+	if violations[0].Path != "go.mod" || violations[1].Path != "pkg/old_test.go" {
+		t.Errorf("違規內容不正確: %v", violations)
+	}
+
+	// 不允許新增時，新測試檔也算違規
+	guard.SetAllowCreate(false)
+	if got := len(guard.Check(changes)); got != 3 {
+		t.Errorf("不允許新增時應有 3 個違規，但為 %d", got)
+	}
+
+	var disabled *ProtectedPathGuard
+	if disabled.Enabled() || disabled.Check(changes) != nil {
+		t.Error("nil guard 應停用")
+	}
+}
+
+// TestProtectedPathGuardEnforce 測試還原違規變更
+func TestProtectedPathGuardEnforce(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "go.mod", "module example\n")
+	writeTestFile(t, root, "main.go", "package main\n")
+
+	before, _ := TakeWorkspaceSnapshot(root)
+	writeTestFile(t, root, "go.mod", "module example\n\nrequire evil v1.0.0\n")
+	writeTestFile(t, root, "main.go", "package main\n\nfunc main() {}\n")
+	after, _ := TakeWorkspaceSnapshot(root)
+
+	guard := NewProtectedPathGuard(DefaultProtectedPaths)
+	violations, err := guard.Enforce(before, before.Diff(after))
+	if err != nil {
+		t.Fatalf("Enforce 失敗: %v", err)
+	}
+	if len(violations) != 1 || !violations[0].Reverted {
+		t.Fatalf("應還原 1 個違規，但為 %v", violations)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(root, "go.mod"))
+	if string(data) != "module example\n" {
+		t.Errorf("go.mod 應被還原，但為 %q", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(root, "main.go"))
+	if !strings.Contains(string(data), "func main") {
+		t.Error("未受保護的檔案不應被還原")
+	}
+
+	note := FormatViolationNote(violations)
+	if !strings.Contains(note, "go.mod") || !strings.Contains(note, "已還原") {
+		t.Errorf("說明內容不正確: %s", note)
+	}
+}
+
+// TestClientRevertsProtectedPathChanges 測試客戶端還原違規並注入說明
+func TestClientRevertsProtectedPathChanges(t *testing.T) {
+	workDir := t.TempDir()
+	writeTestFile(t, workDir, "go.mod", "module example\n")
+	installFakeCopilot(t, `echo "module hacked" > go.mod
+echo "$2" > last_prompt.txt
+echo "working on it"
+`)
+
+	client := NewClientBuilder().
+		WithWorkDir(workDir).
+		WithoutPersistence().
+		Build()
+	defer client.Close()
+
+	result, err := client.ExecuteLoop(t.Context(), "修正測試")
+	if err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+	if !result.ShouldContinue {
+		t.Error("變更被還原時不應視為完成")
+	}
+
+	data, _ := os.ReadFile(filepath.Join(workDir, "go.mod"))
+	if string(data) != "module example\n" {
+		t.Errorf("go.mod 應被還原，但為 %q", data)
+	}
+
+	history := client.GetHistory()
+	if len(history[0].ErrorHistory) == 0 || !strings.Contains(history[0].ErrorHistory[0], "protected path violation") {
+		t.Errorf("ErrorHistory 應記錄違規，但為 %v", history[0].ErrorHistory)
+	}
+
+	// 下一次 prompt 應包含違規說明，且第二次違規打開熔斷器
+	if _, err := client.ExecuteLoop(t.Context(), "修正測試"); err != nil {
+		t.Fatalf("第二次 ExecuteLoop 失敗: %v", err)
+	}
+	prompt, _ := os.ReadFile(filepath.Join(workDir, "last_prompt.txt"))
+	if !strings.Contains(string(prompt), "RALPH_LOOP_NOTES") || !strings.Contains(string(prompt), "go.mod") {
+		t.Errorf("prompt 應包含違規說明，但為 %q", prompt)
+	}
+	if !client.breaker.IsOpen() {
+		t.Error("重複違規應打開熔斷器")
+	}
+}
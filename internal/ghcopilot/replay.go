@@ -0,0 +1,215 @@
+package ghcopilot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReplayReport 是回放一次執行的結果，以及與原本執行的比較
+type ReplayReport struct {
+	RunID            string       `json:"run_id"`
+	Goal             string       `json:"goal"`
+	Entries          int          `json:"entries"` // 錄製的執行器呼叫數
+	OriginalStatus   RunStatus    `json:"original_status,omitempty"`
+	OriginalError    string       `json:"original_error,omitempty"`
+	Status           RunStatus    `json:"status"`
+	Error            string       `json:"error,omitempty"`
+	PromptMismatches []int        `json:"prompt_mismatches"` // prompt 與錄製時不同的呼叫編號
+	Unused           int          `json:"unused"`            // 沒有回放到的回應數
+	Loops            []ReplayLoop `json:"loops"`
+}
+
+// ReplayLoop 比較同一個迴圈在原本執行與回放中的結果
+type ReplayLoop struct {
+	LoopIndex int               `json:"loop_index"`
+	Original  *ReplayLoopResult `json:"original,omitempty"` // 迴圈沒有保存時為 nil
+	Replayed  *ReplayLoopResult `json:"replayed,omitempty"` // 回放提前結束時為 nil
+	Changed   bool              `json:"changed"`            // 沒有保存原本的迴圈時無法比較，視為相同
+}
+
+// ReplayLoopResult 是迴圈中由分析、熔斷與退出邏輯決定的欄位
+type ReplayLoopResult struct {
+	Outcome             string `json:"outcome"`
+	CompletionScore     int    `json:"completion_score"`
+	ShouldContinue      bool   `json:"should_continue"`
+	ExitReason          string `json:"exit_reason,omitempty"`
+	CircuitBreakerState string `json:"circuit_breaker_state,omitempty"`
+}
+
+// newReplayLoopResult 取出迴圈中由分析邏輯決定的欄位
+func newReplayLoopResult(execCtx *ExecutionContext) *ReplayLoopResult {
+	return &ReplayLoopResult{
+		Outcome:             LoopOutcome(execCtx),
+		CompletionScore:     execCtx.CompletionScore,
+		ShouldContinue:      execCtx.ShouldContinue,
+		ExitReason:          execCtx.ExitReason,
+		CircuitBreakerState: execCtx.CircuitBreakerState,
+	}
+}
+
+// ReplayRun 以 run 目錄中的錄製檔重新執行一次 run，並與原本保存的迴圈比較
+//
+// 回放使用 ReplayExecutor 取代 copilot，在空的暫存工作目錄中執行且不寫入任何紀錄，
+// 因此只重現分析、熔斷與退出邏輯：hook、人工審核與工作目錄策略不會重新執行。
+// 目標與模型取自 run.json（沒有時使用第一個錄製的 prompt），迴圈上限為錄製的迴圈數。
+func ReplayRun(ctx context.Context, runDir string, original []*ExecutionContext) (*ReplayReport, error) {
+	entries, err := ReadTranscript(filepath.Join(runDir, TranscriptFileName))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("錄製檔沒有任何執行器呼叫")
+	}
+
+	// 錄製時附加了狀態區塊規格的話，重播也要附加，才能比對出相同的 prompt
+	goal, contract := strings.CutSuffix(entries[0].Prompt, "\n\n"+StatusContractInstructions)
+	report := &ReplayReport{
+		RunID:            filepath.Base(runDir),
+		Goal:             goal,
+		Entries:          len(entries),
+		PromptMismatches: []int{},
+		Loops:            []ReplayLoop{},
+	}
+	config := DefaultClientConfig()
+	config.StatusContract = contract
+	if record, err := ReadRunRecord(runDir); err == nil {
+		report.RunID = record.RunID
+		report.Goal = record.Goal
+		report.OriginalStatus = record.Status
+		report.OriginalError = record.Error
+		config.Model = record.Config.Model
+	}
+
+	workDir, err := os.MkdirTemp("", "ralph-replay-")
+	if err != nil {
+		return nil, fmt.Errorf("無法建立暫存工作目錄: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	replay := NewReplayExecutor(entries)
+	config.WorkDir = workDir
+	config.Executor = replay
+	config.EnableSDK = false
+	config.EnablePersistence = false
+	config.EnableTracing = false
+	config.ProtectedPaths = nil
+	config.Silent = true
+	config.Logger = discardLogger()
+
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+
+	_, runErr := client.ExecuteUntilCompletion(ctx, report.Goal, TranscriptLoops(entries))
+	switch {
+	case runErr == nil:
+		report.Status = RunStatusCompleted
+	case ctx.Err() != nil:
+		report.Status = RunStatusCancelled
+	default:
+		report.Status = RunStatusFailed
+	}
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	report.PromptMismatches = append(report.PromptMismatches, replay.Mismatches()...)
+	report.Unused = replay.Remaining()
+
+	loops := map[int]*ReplayLoop{}
+	count := 0
+	for _, execCtx := range original {
+		loops[execCtx.LoopIndex] = &ReplayLoop{LoopIndex: execCtx.LoopIndex, Original: newReplayLoopResult(execCtx)}
+		count = max(count, execCtx.LoopIndex+1)
+	}
+	for _, execCtx := range client.GetHistory() {
+		loop := loops[execCtx.LoopIndex]
+		if loop == nil {
+			loop = &ReplayLoop{LoopIndex: execCtx.LoopIndex}
+			loops[execCtx.LoopIndex] = loop
+		}
+		loop.Replayed = newReplayLoopResult(execCtx)
+		count = max(count, execCtx.LoopIndex+1)
+	}
+	for i := 0; i < count; i++ {
+		if loop := loops[i]; loop != nil {
+			loop.Changed = loop.Original != nil && (loop.Replayed == nil || *loop.Original != *loop.Replayed)
+			report.Loops = append(report.Loops, *loop)
+		}
+	}
+	return report, nil
+}
+
+// Diverged 判斷回放的結果是否與原本的執行不同
+func (r *ReplayReport) Diverged() bool {
+	if r.OriginalStatus != "" && (r.Status != r.OriginalStatus || r.Error != r.OriginalError) {
+		return true
+	}
+	for _, loop := range r.Loops {
+		if loop.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText 輸出回放結果與逐迴圈的比較
+func (r *ReplayReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "回放: %s（%d 個執行器呼叫）\n", r.RunID, r.Entries)
+	fmt.Fprintf(&b, "目標: %s\n", historySummary(r.Goal, 80))
+	fmt.Fprintf(&b, "原本: %s %s\n", orDash(string(r.OriginalStatus)), r.OriginalError)
+	fmt.Fprintf(&b, "回放: %s %s\n", r.Status, r.Error)
+	if len(r.PromptMismatches) > 0 {
+		fmt.Fprintf(&b, "⚠️  %d 個呼叫的 prompt 與錄製時不同: %v\n", len(r.PromptMismatches), r.PromptMismatches)
+	}
+	if r.Unused > 0 {
+		fmt.Fprintf(&b, "⚠️  %d 個錄製的回應沒有回放到\n", r.Unused)
+	}
+
+	b.WriteString("\n")
+	rows := [][]string{{"#", "結果", "分數", "熔斷器", "", "退出理由"}}
+	for _, loop := range r.Loops {
+		mark := ""
+		if loop.Changed {
+			mark = "≠"
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(loop.LoopIndex + 1),
+			replayField(loop, func(l *ReplayLoopResult) string { return l.Outcome }),
+			replayField(loop, func(l *ReplayLoopResult) string { return strconv.Itoa(l.CompletionScore) }),
+			replayField(loop, func(l *ReplayLoopResult) string { return orDash(l.CircuitBreakerState) }),
+			mark,
+			replayField(loop, func(l *ReplayLoopResult) string { return orDash(historySummary(l.ExitReason, 60)) }),
+		})
+	}
+	if err := writeHistoryTable(&b, rows); err != nil {
+		return err
+	}
+
+	if r.Diverged() {
+		b.WriteString("\n結果與原本的執行不同\n")
+	} else {
+		b.WriteString("\n結果與原本的執行一致\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// replayField 顯示欄位在原本與回放中的值（相同時只顯示一次）
+func replayField(loop ReplayLoop, field func(*ReplayLoopResult) string) string {
+	before, after := "-", "-"
+	if loop.Original != nil {
+		before = field(loop.Original)
+	}
+	if loop.Replayed != nil {
+		after = field(loop.Replayed)
+	}
+	if before == after {
+		return after
+	}
+	return before + " → " + after
+}
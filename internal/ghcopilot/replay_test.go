@@ -0,0 +1,111 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordTestRun 以假的 copilot 執行一次 run（第三個迴圈完成），傳回 run 目錄與保存的迴圈
+func recordTestRun(t *testing.T) (string, []*ExecutionContext) {
+	t.Helper()
+	installFakeCopilot(t, `n=$(cat n 2>/dev/null || echo 0); n=$((n+1)); echo $n > n
+if [ $n -ge 3 ]; then echo "全部完成 done"; else echo "step $n"; echo "still failing" >&2; fi`)
+	workDir := t.TempDir()
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	client := NewRalphLoopClientWithConfig(config)
+	if _, err := client.ExecuteUntilCompletion(t.Context(), "修正測試", 5); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	history, err := LoadHistory(config.SaveDir, config.RunsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.RunDir(), history.Runs[0].Loops
+}
+
+func TestClientRecordsTranscript(t *testing.T) {
+	runDir, _ := recordTestRun(t)
+
+	entries, err := ReadTranscript(filepath.Join(runDir, TranscriptFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("每個迴圈應錄製一次呼叫: %+v", entries)
+	}
+	first := entries[0]
+	if first.Mode != "cli" || first.Prompt != "修正測試\n\n"+StatusContractInstructions || first.Stdout != "step 1\n" || first.Stderr != "still failing\n" ||
+		first.Args[0] != "-p" || first.Args[1] != first.Prompt || !strings.HasPrefix(first.Command, "copilot -p") {
+		t.Errorf("錄製的請求與回應不正確: %+v", first)
+	}
+	if entries[2].LoopIndex != 2 || entries[2].Stdout != "全部完成 done\n" {
+		t.Errorf("最後一次呼叫不正確: %+v", entries[2])
+	}
+}
+
+func TestReplayRun(t *testing.T) {
+	runDir, original := recordTestRun(t)
+
+	report, err := ReplayRun(t.Context(), runDir, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Diverged() || report.Status != RunStatusCompleted || report.OriginalStatus != RunStatusCompleted || len(report.Loops) != 3 {
+		t.Fatalf("回放應與原本的執行一致: %+v", report)
+	}
+	if report.Unused != 0 || len(report.PromptMismatches) != 0 || report.Loops[2].Replayed.ExitReason != "completion detected in output" {
+		t.Errorf("回放結果不正確: %+v", report)
+	}
+
+	// 修改錄製的第二個回應：回放在第二個迴圈就完成
+	path := filepath.Join(runDir, TranscriptFileName)
+	entries, err := ReadTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries[1].Stdout = "done"
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		json.NewEncoder(&buf).Encode(entry)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = ReplayRun(t.Context(), runDir, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Diverged() || report.Unused != 1 || !report.Loops[1].Changed || report.Loops[2].Replayed != nil {
+		t.Errorf("修改後的回應應使結果不同: %+v", report)
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"continue → complete", "1 個錄製的回應沒有回放到", "結果與原本的執行不同"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("文字輸出缺少 %q:\n%s", want, text.String())
+		}
+	}
+}
+
+func TestReplayRunWithoutTranscript(t *testing.T) {
+	if _, err := ReplayRun(t.Context(), t.TempDir(), nil); err == nil {
+		t.Error("沒有錄製檔時應傳回錯誤")
+	}
+}
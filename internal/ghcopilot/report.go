@@ -0,0 +1,402 @@
+package ghcopilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReportFormat 代表執行報告的格式
+type ReportFormat string
+
+const (
+	ReportFormatMarkdown ReportFormat = "md"
+	ReportFormatHTML     ReportFormat = "html"
+)
+
+// 報告中摘錄內容的長度上限（完整內容保留在執行紀錄中）
+const (
+	reportPromptBytes    = 4 << 10
+	reportResponseBytes  = 8 << 10
+	reportCodeBlockBytes = 8 << 10
+	reportMaxCodeBlocks  = 20
+)
+
+// ParseReportFormat 解析報告格式（md、html）
+func ParseReportFormat(s string) (ReportFormat, error) {
+	switch format := ReportFormat(strings.ToLower(strings.TrimSpace(s))); format {
+	case ReportFormatMarkdown, ReportFormatHTML:
+		return format, nil
+	case "markdown":
+		return ReportFormatMarkdown, nil
+	}
+	return ReportFormatMarkdown, fmt.Errorf("未知的報告格式 %q (可用: md, html)", s)
+}
+
+// RunReport 彙整一次執行的紀錄，用於產生 Markdown/HTML 報告
+//
+// 資料來源為 run 目錄的 journal.jsonl 與 run.json；舊版本的 run 沒有 run.json，
+// 此時目標取自第一個迴圈的 prompt，配置與結果則無法得知。
+type RunReport struct {
+	RunID      string
+	Record     *RunRecord // 沒有 run.json 時為 nil
+	Goal       string
+	StartedAt  time.Time
+	FinishedAt time.Time // 最後一個事件的時間
+	Status     RunStatus // 無法判斷時為空字串
+	Error      string
+
+	Loops   []*ReportLoop
+	Breaker []ReportBreakerTransition
+	Budget  *ReportBudget // 最後一個結束的迴圈記錄的預算使用量
+	Changes ChangeStats   // 所有迴圈的變更合計
+}
+
+// ReportLoop 是報告中的一個迴圈
+type ReportLoop struct {
+	Index      int
+	StartedAt  time.Time
+	Duration   time.Duration
+	Prompt     string
+	Response   string // stdout 與 assistant 輸出
+	Tools      []string
+	CodeBlocks []CodeBlock // 由完整回應以 OutputParser.ExtractCodeBlocks 取得
+
+	Score  int
+	Scored bool
+	Status *LoopStatus
+
+	Changes  ChangeStats
+	Diff     string
+	Hooks    []HookResult
+	Errors   []string
+	Feedback string
+	Approval *ApprovalDecision
+
+	Finished       bool
+	ShouldContinue bool
+	Failed         bool
+	ExitReason     string
+}
+
+// Outcome 以文字描述迴圈結果
+func (l *ReportLoop) Outcome() string {
+	switch {
+	case !l.Finished:
+		return "未結束"
+	case l.Failed:
+		return "失敗"
+	case l.ShouldContinue:
+		return "繼續"
+	case l.ExitReason != "":
+		return "結束: " + l.ExitReason
+	}
+	return "結束"
+}
+
+// ReportBreakerTransition 是一次熔斷器狀態變化
+type ReportBreakerTransition struct {
+	LoopIndex int
+	Time      time.Time
+	From      string
+	To        string
+}
+
+// ReportBudget 是迴圈結束時的預算使用量（上限為 0 表示未設定）
+type ReportBudget struct {
+	LoopsUsed         int        `json:"loops_used"`
+	LoopsLimit        int        `json:"loops_limit"`
+	LinesAdded        int        `json:"lines_added"`
+	LinesAddedLimit   int        `json:"lines_added_limit"`
+	LinesRemoved      int        `json:"lines_removed"`
+	LinesRemovedLimit int        `json:"lines_removed_limit"`
+	Deadline          *time.Time `json:"deadline"`
+}
+
+// LoadRunReport 讀取 run 目錄並建立報告
+func LoadRunReport(runDir string) (*RunReport, error) {
+	events, err := ReadJournal(filepath.Join(runDir, JournalFileName))
+	if err != nil {
+		return nil, err
+	}
+	record, err := ReadRunRecord(runDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	report := NewRunReport(events, record)
+	if report.RunID == "" {
+		report.RunID = filepath.Base(runDir)
+	}
+	return report, nil
+}
+
+// NewRunReport 由執行紀錄的事件與執行摘要（可為 nil）建立報告
+func NewRunReport(events []LoopEvent, record *RunRecord) *RunReport {
+	report := &RunReport{Record: record}
+	byIndex := map[int]*ReportLoop{}
+	responses := map[int]*strings.Builder{}
+
+	loopFor := func(index int) *ReportLoop {
+		loop, ok := byIndex[index]
+		if !ok {
+			loop = &ReportLoop{Index: index}
+			byIndex[index] = loop
+			responses[index] = &strings.Builder{}
+			report.Loops = append(report.Loops, loop)
+		}
+		return loop
+	}
+
+	for _, event := range events {
+		if report.RunID == "" {
+			report.RunID = event.RunID
+		}
+		if report.StartedAt.IsZero() {
+			report.StartedAt = event.Timestamp
+		}
+		report.FinishedAt = event.Timestamp
+
+		switch event.Type {
+		case EventLoopStarted:
+			loop := loopFor(event.LoopIndex)
+			loop.StartedAt = event.Timestamp
+			loop.Prompt = event.Text
+
+		case EventOutputChunk:
+			response := responses[loopFor(event.LoopIndex).Index]
+			switch event.Stream {
+			case StreamAssistant:
+				response.WriteString(event.Text)
+			case StreamStdout:
+				response.WriteString(event.Text)
+				response.WriteByte('\n')
+			}
+
+		case EventToolCall:
+			loop := loopFor(event.LoopIndex)
+			loop.Tools = append(loop.Tools, event.Tool)
+
+		case EventLoopAnalyzed:
+			loop := loopFor(event.LoopIndex)
+			loop.Scored = decodeEventData(event.Data, "completion_score", &loop.Score)
+			var status LoopStatus
+			if decodeEventData(event.Data, "structured_status", &status) {
+				loop.Status = &status
+			}
+
+		case EventHookFinished:
+			var hook HookResult
+			if raw, err := json.Marshal(event.Data); err == nil && json.Unmarshal(raw, &hook) == nil {
+				loop := loopFor(event.LoopIndex)
+				loop.Hooks = append(loop.Hooks, hook)
+			}
+
+		case EventBreakerStateChanged:
+			report.Breaker = append(report.Breaker, ReportBreakerTransition{
+				LoopIndex: event.LoopIndex,
+				Time:      event.Timestamp,
+				From:      event.PreviousState,
+				To:        event.BreakerState,
+			})
+
+		case EventLoopFinished:
+			loop := loopFor(event.LoopIndex)
+			loop.Finished = true
+			loop.ShouldContinue = event.ShouldContinue
+			loop.Failed = event.Failed
+			loop.ExitReason = event.ExitReason
+
+			var durationMs int64
+			if decodeEventData(event.Data, "duration_ms", &durationMs) {
+				loop.Duration = time.Duration(durationMs) * time.Millisecond
+			}
+			decodeEventData(event.Data, "changes", &loop.Changes)
+			decodeEventData(event.Data, "diff", &loop.Diff)
+			decodeEventData(event.Data, "errors", &loop.Errors)
+			decodeEventData(event.Data, "user_feedback", &loop.Feedback)
+			var approval ApprovalDecision
+			if decodeEventData(event.Data, "approval", &approval) {
+				loop.Approval = &approval
+			}
+			var budget ReportBudget
+			if decodeEventData(event.Data, "budget", &budget) {
+				report.Budget = &budget
+			}
+			report.Changes.Add(loop.Changes)
+		}
+	}
+
+	for _, loop := range report.Loops {
+		response := responses[loop.Index].String()
+		blocks := NewOutputParser(response).ExtractCodeBlocks()
+		if len(blocks) > reportMaxCodeBlocks {
+			blocks = blocks[:reportMaxCodeBlocks]
+		}
+		for i := range blocks {
+			blocks[i].Content = truncateString(blocks[i].Content, reportCodeBlockBytes)
+		}
+		loop.CodeBlocks = blocks
+		loop.Response = truncateString(strings.TrimSpace(response), reportResponseBytes)
+		loop.Prompt = truncateString(loop.Prompt, reportPromptBytes)
+	}
+
+	if len(report.Loops) > 0 {
+		report.Goal = report.Loops[0].Prompt
+	}
+	if record != nil {
+		report.RunID = record.RunID
+		report.Goal = record.Goal
+		report.StartedAt = record.StartedAt
+		if record.FinishedAt != nil {
+			report.FinishedAt = *record.FinishedAt
+		}
+		report.Status = record.Status
+		report.Error = record.Error
+	} else if n := len(report.Loops); n > 0 && report.Loops[n-1].Finished && !report.Loops[n-1].ShouldContinue {
+		// 沒有執行摘要時，只能由最後一個迴圈判斷是否完成
+		report.Status = RunStatusCompleted
+		if report.Loops[n-1].ExitReason == "stopped by operator" {
+			report.Status = RunStatusStopped
+		}
+	}
+	return report
+}
+
+// Duration 傳回執行的總時間
+func (r *RunReport) Duration() time.Duration {
+	if r.StartedAt.IsZero() || r.FinishedAt.Before(r.StartedAt) {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Outcome 以文字描述執行結果
+func (r *RunReport) Outcome() string {
+	var outcome string
+	switch r.Status {
+	case RunStatusCompleted:
+		outcome = "✅ 完成"
+	case RunStatusRunning:
+		outcome = "⏳ 執行中"
+	case RunStatusStopped:
+		outcome = "⏹️ 已停止"
+	case RunStatusCancelled:
+		outcome = "🚫 已取消"
+	case RunStatusFailed:
+		outcome = "❌ 未完成"
+	default:
+		outcome = "❔ 未知（沒有執行摘要）"
+	}
+	if r.Error != "" {
+		outcome += ": " + r.Error
+	}
+	return outcome
+}
+
+// Write 以指定格式輸出報告
+func (r *RunReport) Write(w io.Writer, format ReportFormat) error {
+	if format == ReportFormatHTML {
+		return r.WriteHTML(w)
+	}
+	return r.WriteMarkdown(w)
+}
+
+// configRows 將執行配置整理為報告中的表格列
+func (r *RunReport) configRows() [][2]string {
+	if r.Record == nil {
+		return nil
+	}
+	config := r.Record.Config
+
+	rows := [][2]string{
+		{"工作目錄", config.WorkDir},
+		{"模型", config.Model},
+	}
+	if config.MaxLoops > 0 {
+		rows = append(rows, [2]string{"迴圈上限", fmt.Sprint(config.MaxLoops)})
+	}
+	if config.Deadline != nil {
+		rows = append(rows, [2]string{"期限", config.Deadline.Format(time.RFC3339)})
+	}
+	rows = append(rows,
+		[2]string{"CLI 逾時", config.CLITimeout.String()},
+		[2]string{"熔斷器門檻", fmt.Sprintf("無進展 %d 次 / 相同錯誤 %d 次", config.CircuitBreakerThreshold, config.SameErrorThreshold)},
+	)
+	if p := config.Permissions; p != nil {
+		value := string(p.Preset)
+		if value == "" {
+			value = "custom"
+		}
+		if p.AllowAllTools {
+			value += "，允許所有工具"
+		}
+		if len(p.AllowedTools) > 0 {
+			value += "，允許 " + strings.Join(p.AllowedTools, ", ")
+		}
+		if len(p.DeniedTools) > 0 {
+			value += "，禁止 " + strings.Join(p.DeniedTools, ", ")
+		}
+		rows = append(rows, [2]string{"權限", value})
+	}
+	if len(config.ProtectedPaths) > 0 {
+		rows = append(rows, [2]string{"受保護路徑", strings.Join(config.ProtectedPaths, ", ")})
+	}
+	if s := config.ChangeScope; s != nil {
+		rows = append(rows, [2]string{"變更範圍", fmt.Sprintf(
+			"每迴圈 %d 檔 +%d -%d，整次 +%d -%d，路徑 %s，超出時還原=%v",
+			s.MaxFilesPerLoop, s.MaxLinesAddedPerLoop, s.MaxLinesRemovedPerLoop,
+			s.MaxLinesAddedPerRun, s.MaxLinesRemovedPerRun,
+			strings.Join(s.AllowedPathPrefixes, ", "), s.RevertOnViolation)})
+	}
+	for _, hook := range config.ShellHooks {
+		name := "Hook " + string(hook.Point)
+		if hook.Enforce {
+			name += " (enforce)"
+		}
+		rows = append(rows, [2]string{name, hook.Command})
+	}
+	approval := "否"
+	if config.Approval {
+		approval = "是"
+	}
+	executor := "CLI"
+	if config.SDK {
+		executor = "SDK (失敗時改用 CLI)"
+	}
+	return append(rows, [2]string{"人工審核", approval}, [2]string{"執行器", executor})
+}
+
+// budgetRows 將預算使用量整理為報告中的表格列（名稱、使用量、上限）
+func (r *RunReport) budgetRows() [][3]string {
+	if r.Budget == nil {
+		return nil
+	}
+	limit := func(n int) string {
+		if n <= 0 {
+			return "-"
+		}
+		return fmt.Sprint(n)
+	}
+	rows := [][3]string{
+		{"迴圈", fmt.Sprint(r.Budget.LoopsUsed), limit(r.Budget.LoopsLimit)},
+		{"新增行數", fmt.Sprint(r.Budget.LinesAdded), limit(r.Budget.LinesAddedLimit)},
+		{"刪除行數", fmt.Sprint(r.Budget.LinesRemoved), limit(r.Budget.LinesRemovedLimit)},
+	}
+	if r.Budget.Deadline != nil {
+		rows = append(rows, [3]string{"期限", r.FinishedAt.Format(time.RFC3339), r.Budget.Deadline.Format(time.RFC3339)})
+	}
+	return rows
+}
+
+// formatReportDuration 以適合閱讀的精度顯示時間長度
+func formatReportDuration(d time.Duration) string {
+	if d >= time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
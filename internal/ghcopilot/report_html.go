@@ -0,0 +1,109 @@
+package ghcopilot
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+//go:embed report.html.tmpl
+var reportHTMLTemplate string
+
+// reportTemplate 報告的 HTML 範本（樣式內嵌，輸出的檔案不需要其他資源）
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc":       func(i int) int { return i + 1 },
+	"duration":  formatReportDuration,
+	"timestamp": func(t time.Time) string { return t.Format(time.RFC3339) },
+	"clock":     func(t time.Time) string { return t.Format("15:04:05") },
+	"diffLines": diffLineClasses,
+}).Parse(reportHTMLTemplate))
+
+// reportHTMLData 是 HTML 範本的資料
+type reportHTMLData struct {
+	*RunReport
+	ConfigRows [][2]string
+	BudgetRows [][3]string
+	Chart      *scoreChart
+}
+
+// WriteHTML 以單一 HTML 檔案輸出報告
+func (r *RunReport) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, reportHTMLData{
+		RunReport:  r,
+		ConfigRows: r.configRows(),
+		BudgetRows: r.budgetRows(),
+		Chart:      newScoreChart(r.Loops),
+	})
+}
+
+// scoreChart 是完成分數趨勢圖的 SVG 座標
+type scoreChart struct {
+	Width, Height     int
+	Left, Top, Bottom int    // 繪圖區的左緣與 100 分、0 分的高度
+	Points            string // polyline 的座標
+	Dots              []scoreDot
+}
+
+type scoreDot struct {
+	X, Y  int
+	Loop  int
+	Score int
+}
+
+// newScoreChart 計算趨勢圖座標（沒有分數時傳回 nil）
+func newScoreChart(loops []*ReportLoop) *scoreChart {
+	const width, height, pad = 640, 160, 24
+
+	var scored []*ReportLoop
+	for _, loop := range loops {
+		if loop.Scored {
+			scored = append(scored, loop)
+		}
+	}
+	if len(scored) == 0 {
+		return nil
+	}
+
+	chart := &scoreChart{Width: width, Height: height, Left: pad, Top: pad, Bottom: height - pad}
+	var points []string
+	for i, loop := range scored {
+		x := width / 2
+		if len(scored) > 1 {
+			x = pad + i*(width-2*pad)/(len(scored)-1)
+		}
+		y := height - pad - min(max(loop.Score, 0), 100)*(height-2*pad)/100
+		points = append(points, fmt.Sprintf("%d,%d", x, y))
+		chart.Dots = append(chart.Dots, scoreDot{X: x, Y: y, Loop: loop.Index + 1, Score: loop.Score})
+	}
+	chart.Points = strings.Join(points, " ")
+	return chart
+}
+
+// diffLine 是 diff 中的一行與其樣式類別
+type diffLine struct {
+	Class string
+	Text  string
+}
+
+// diffLineClasses 依 unified diff 的行首字元標示新增、刪除與區塊標頭
+func diffLineClasses(diff string) []diffLine {
+	var lines []diffLine
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			class = "file"
+		case strings.HasPrefix(line, "@@"):
+			class = "hunk"
+		case strings.HasPrefix(line, "+"):
+			class = "add"
+		case strings.HasPrefix(line, "-"):
+			class = "del"
+		}
+		lines = append(lines, diffLine{Class: class, Text: line})
+	}
+	return lines
+}
@@ -0,0 +1,168 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteMarkdown 以 Markdown 輸出報告
+func (r *RunReport) WriteMarkdown(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "# Ralph Loop 執行報告：%s\n\n", r.RunID)
+	fmt.Fprintf(b, "- **結果**：%s\n", mdInline(r.Outcome()))
+	if !r.StartedAt.IsZero() {
+		fmt.Fprintf(b, "- **時間**：%s 起，共 %s\n", r.StartedAt.Format(time.RFC3339), formatReportDuration(r.Duration()))
+	}
+	fmt.Fprintf(b, "- **迴圈**：%d\n", len(r.Loops))
+	fmt.Fprintf(b, "- **變更**：%d 個檔案，+%d -%d\n\n", r.Changes.FilesChanged, r.Changes.LinesAdded, r.Changes.LinesRemoved)
+
+	b.WriteString("## 目標\n\n")
+	b.WriteString(mdFence(r.Goal, "text"))
+
+	b.WriteString("\n## 配置\n\n")
+	if rows := r.configRows(); len(rows) > 0 {
+		b.WriteString("| 項目 | 值 |\n|------|----|\n")
+		for _, row := range rows {
+			fmt.Fprintf(b, "| %s | %s |\n", row[0], mdCell(row[1]))
+		}
+	} else {
+		b.WriteString("此 run 沒有 run.json，無法得知配置。\n")
+	}
+
+	b.WriteString("\n## 完成分數趨勢\n\n")
+	b.WriteString("| 迴圈 | 分數 | |\n|-----:|-----:|---|\n")
+	for _, loop := range r.Loops {
+		if !loop.Scored {
+			fmt.Fprintf(b, "| %d | - | |\n", loop.Index+1)
+			continue
+		}
+		fmt.Fprintf(b, "| %d | %d | %s |\n", loop.Index+1, loop.Score, scoreBar(loop.Score, 20))
+	}
+
+	if rows := r.budgetRows(); len(rows) > 0 {
+		b.WriteString("\n## 預算\n\n| 項目 | 使用 | 上限 |\n|------|-----:|-----:|\n")
+		for _, row := range rows {
+			fmt.Fprintf(b, "| %s | %s | %s |\n", row[0], row[1], row[2])
+		}
+	}
+
+	b.WriteString("\n## 熔斷器\n\n")
+	if len(r.Breaker) == 0 {
+		b.WriteString("執行期間熔斷器狀態沒有改變。\n")
+	} else {
+		b.WriteString("| 迴圈 | 時間 | 變化 |\n|-----:|------|------|\n")
+		for _, t := range r.Breaker {
+			fmt.Fprintf(b, "| %d | %s | %s → %s |\n", t.LoopIndex+1, t.Time.Format("15:04:05"), t.From, t.To)
+		}
+	}
+
+	b.WriteString("\n## 迴圈\n")
+	for _, loop := range r.Loops {
+		writeMarkdownLoop(b, loop)
+	}
+	return b.Flush()
+}
+
+// writeMarkdownLoop 輸出單一迴圈的段落
+func writeMarkdownLoop(b *bufio.Writer, loop *ReportLoop) {
+	fmt.Fprintf(b, "\n### 迴圈 %d — %s\n\n", loop.Index+1, mdInline(loop.Outcome()))
+
+	fmt.Fprintf(b, "- 耗時：%s", formatReportDuration(loop.Duration))
+	if loop.Scored {
+		fmt.Fprintf(b, "，完成分數：%d", loop.Score)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(b, "- 變更：%d 個檔案，+%d -%d\n", loop.Changes.FilesChanged, loop.Changes.LinesAdded, loop.Changes.LinesRemoved)
+	if loop.Status != nil {
+		fmt.Fprintf(b, "- 狀態：%s，EXIT_SIGNAL=%v", mdInline(loop.Status.Status), loop.Status.ExitSignal)
+		if loop.Status.TasksDone != "" {
+			fmt.Fprintf(b, "，任務 %s", mdInline(loop.Status.TasksDone))
+		}
+		b.WriteString("\n")
+	}
+	if len(loop.Tools) > 0 {
+		fmt.Fprintf(b, "- 工具：%s\n", mdInline(strings.Join(loop.Tools, ", ")))
+	}
+	if loop.Approval != nil {
+		fmt.Fprintf(b, "- 審核：%s", loop.Approval.Action)
+		if loop.Approval.Reverted {
+			b.WriteString("（已還原）")
+		}
+		b.WriteString("\n")
+	}
+	if loop.Feedback != "" {
+		fmt.Fprintf(b, "- 操作者回饋：%s\n", mdInline(loop.Feedback))
+	}
+
+	b.WriteString("\n**Prompt**\n\n")
+	b.WriteString(mdFence(loop.Prompt, "text"))
+	if loop.Response != "" {
+		b.WriteString("\n**回應摘錄**\n\n")
+		b.WriteString(mdFence(loop.Response, "text"))
+	}
+
+	if len(loop.CodeBlocks) > 0 {
+		fmt.Fprintf(b, "\n**程式碼區塊**（%d）\n\n", len(loop.CodeBlocks))
+		for _, block := range loop.CodeBlocks {
+			b.WriteString(mdFence(block.Content, block.Language))
+		}
+	}
+
+	if len(loop.Hooks) > 0 {
+		b.WriteString("\n**驗證**\n\n| 時機 | 命令 | 結果 | 耗時 |\n|------|------|------|-----:|\n")
+		for _, hook := range loop.Hooks {
+			result := "✅ 通過"
+			if !hook.OK {
+				result = "❌ " + hook.Error
+			}
+			fmt.Fprintf(b, "| %s | %s | %s | %dms |\n", hook.Point, mdCell(hook.Name()), mdCell(result), hook.DurationMs)
+		}
+	}
+
+	if len(loop.Errors) > 0 {
+		b.WriteString("\n**錯誤**\n\n")
+		for _, msg := range loop.Errors {
+			fmt.Fprintf(b, "- %s\n", mdInline(msg))
+		}
+	}
+
+	if loop.Diff != "" {
+		b.WriteString("\n**Diff**\n\n")
+		b.WriteString(mdFence(loop.Diff, "diff"))
+	}
+}
+
+// mdFence 將內容包在 fenced code block 中（fence 長度超過內容中最長的連續反引號）
+func mdFence(content, language string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + language + "\n" + strings.TrimRight(content, "\n") + "\n" + fence + "\n"
+}
+
+// mdInline 將多行文字合併為一行，避免破壞 Markdown 的清單與標題
+func mdInline(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// mdCell 將文字轉為可放入表格儲存格的內容
+func mdCell(s string) string {
+	return strings.ReplaceAll(mdInline(s), "|", `\|`)
+}
+
+// scoreBar 以方塊字元畫出 0-100 分的長條
+func scoreBar(score, width int) string {
+	score = min(max(score, 0), 100)
+	return strings.Repeat("█", score*width/100)
+}
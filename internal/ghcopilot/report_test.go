@@ -0,0 +1,263 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportTestEvents 產生兩個迴圈的執行紀錄：第一個迴圈驗證失敗，第二個迴圈完成
+func reportTestEvents() []LoopEvent {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	diff := "--- a/parser.go\n+++ b/parser.go\n@@ -1,1 +1,1 @@\n-old <b>\n+new\n"
+	return []LoopEvent{
+		{Type: EventLoopStarted, RunID: "run-report", LoopIndex: 0, Timestamp: start, Text: "修正 parser"},
+		{Type: EventOutputChunk, LoopIndex: 0, Timestamp: start, Stream: StreamStdout, Text: "修改如下："},
+		{Type: EventOutputChunk, LoopIndex: 0, Timestamp: start, Stream: StreamStdout, Text: "```go"},
+		{Type: EventOutputChunk, LoopIndex: 0, Timestamp: start, Stream: StreamStdout, Text: "func parse() {}"},
+		{Type: EventOutputChunk, LoopIndex: 0, Timestamp: start, Stream: StreamStdout, Text: "```"},
+		{Type: EventToolCall, LoopIndex: 0, Timestamp: start, Tool: "edit"},
+		{Type: EventLoopAnalyzed, LoopIndex: 0, Timestamp: start, ShouldContinue: true, Data: map[string]interface{}{
+			"completion_score":  30,
+			"structured_status": &LoopStatus{Status: "CONTINUE", TasksDone: "1/2"},
+		}},
+		{Type: EventHookFinished, LoopIndex: 0, Timestamp: start, Data: map[string]interface{}{
+			"point": "post_loop", "command": "go vet ./...", "enforce": true, "ok": false, "error": "exit status 1", "duration_ms": int64(120),
+		}},
+		{Type: EventBreakerStateChanged, LoopIndex: 0, Timestamp: start, PreviousState: "CLOSED", BreakerState: "HALF_OPEN"},
+		{Type: EventLoopFinished, LoopIndex: 0, Timestamp: start.Add(2 * time.Second), ShouldContinue: true, Failed: true, Data: map[string]interface{}{
+			"duration_ms": int64(2000),
+			"changes":     ChangeStats{FilesChanged: 1, LinesAdded: 1, LinesRemoved: 1},
+			"diff":        diff,
+			"errors":      []string{"post_loop hook failed: exit status 1"},
+			"budget":      map[string]interface{}{"loops_used": 1, "loops_limit": 5, "lines_added": 1, "lines_removed": 1},
+		}},
+		{Type: EventLoopStarted, LoopIndex: 1, Timestamp: start.Add(3 * time.Second), Text: "修正 parser"},
+		{Type: EventOutputChunk, LoopIndex: 1, Timestamp: start.Add(3 * time.Second), Stream: StreamAssistant, Text: "全部完成"},
+		{Type: EventLoopAnalyzed, LoopIndex: 1, Timestamp: start.Add(4 * time.Second), Data: map[string]interface{}{"completion_score": 90}},
+		{Type: EventBreakerStateChanged, LoopIndex: 1, Timestamp: start.Add(4 * time.Second), PreviousState: "HALF_OPEN", BreakerState: "CLOSED"},
+		{Type: EventLoopFinished, LoopIndex: 1, Timestamp: start.Add(5 * time.Second), ExitReason: "completion detected in output", Data: map[string]interface{}{
+			"duration_ms": int64(1500),
+			"budget":      map[string]interface{}{"loops_used": 2, "loops_limit": 5, "lines_added": 1, "lines_removed": 1},
+		}},
+	}
+}
+
+// TestNewRunReport 測試由執行紀錄彙整報告資料
+func TestNewRunReport(t *testing.T) {
+	report := NewRunReport(reportTestEvents(), nil)
+
+	if report.RunID != "run-report" || report.Goal != "修正 parser" || report.Duration() != 5*time.Second {
+		t.Errorf("報告摘要不符: id=%s goal=%q duration=%v", report.RunID, report.Goal, report.Duration())
+	}
+	if report.Status != RunStatusCompleted {
+		t.Errorf("最後一個迴圈結束時應視為完成: %q", report.Status)
+	}
+	if len(report.Loops) != 2 || len(report.Breaker) != 2 {
+		t.Fatalf("應有 2 個迴圈與 2 次熔斷器變化: %d %d", len(report.Loops), len(report.Breaker))
+	}
+
+	first := report.Loops[0]
+	if !first.Failed || first.Duration != 2*time.Second || first.Score != 30 || first.Status == nil || first.Status.TasksDone != "1/2" {
+		t.Errorf("第一個迴圈資料不符: %+v", first)
+	}
+	if len(first.CodeBlocks) != 1 || first.CodeBlocks[0].Language != "go" || first.CodeBlocks[0].Content != "func parse() {}" {
+		t.Errorf("應擷取程式碼區塊: %+v", first.CodeBlocks)
+	}
+	if len(first.Hooks) != 1 || first.Hooks[0].OK || first.Hooks[0].Name() != "go vet ./..." || first.Hooks[0].DurationMs != 120 {
+		t.Errorf("應記錄驗證結果: %+v", first.Hooks)
+	}
+	if len(first.Errors) != 1 || !strings.Contains(first.Diff, "+new") || first.Outcome() != "失敗" {
+		t.Errorf("應記錄錯誤與 diff: %+v", first)
+	}
+	if report.Loops[1].Response != "全部完成" || report.Loops[1].Outcome() != "結束: completion detected in output" {
+		t.Errorf("第二個迴圈資料不符: %+v", report.Loops[1])
+	}
+	if report.Budget == nil || report.Budget.LoopsUsed != 2 || report.Budget.LoopsLimit != 5 {
+		t.Errorf("應使用最後的預算使用量: %+v", report.Budget)
+	}
+	if report.Changes.FilesChanged != 1 || report.Changes.LinesAdded != 1 {
+		t.Errorf("應合計變更: %+v", report.Changes)
+	}
+}
+
+// TestRunReportRecord 測試執行摘要優先於執行紀錄
+func TestRunReportRecord(t *testing.T) {
+	finished := time.Date(2026, 1, 1, 9, 1, 0, 0, time.UTC)
+	record := &RunRecord{
+		RunID:      "run-report",
+		Goal:       "修正 parser 與測試",
+		StartedAt:  time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+		FinishedAt: &finished,
+		Status:     RunStatusFailed,
+		Error:      "reached maximum loops (2) without completion",
+		Config: RunConfig{
+			Model:       "gpt-5",
+			MaxLoops:    2,
+			Permissions: DefaultPermissionPolicy(),
+			ShellHooks:  []ShellHook{{Point: HookPostLoop, Command: "go vet ./...", Enforce: true}},
+		},
+	}
+
+	report := NewRunReport(reportTestEvents(), record)
+	if report.Goal != record.Goal || report.Duration() != time.Minute || report.Status != RunStatusFailed {
+		t.Errorf("應使用執行摘要: %+v", report)
+	}
+	if !strings.Contains(report.Outcome(), "reached maximum loops") {
+		t.Errorf("結果應包含原因: %s", report.Outcome())
+	}
+
+	rows := map[string]string{}
+	for _, row := range report.configRows() {
+		rows[row[0]] = row[1]
+	}
+	if rows["模型"] != "gpt-5" || rows["迴圈上限"] != "2" || rows["Hook post_loop (enforce)"] != "go vet ./..." || rows["權限"] != "full，允許所有工具" {
+		t.Errorf("配置列不符: %v", rows)
+	}
+}
+
+// TestRunReportMarkdown 測試 Markdown 報告
+func TestRunReportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewRunReport(reportTestEvents(), nil).Write(&buf, ReportFormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	md := buf.String()
+
+	for _, want := range []string{
+		"# Ralph Loop 執行報告：run-report",
+		"- **結果**：✅ 完成",
+		"## 目標\n\n```text\n修正 parser\n```",
+		"此 run 沒有 run.json",
+		"| 1 | 30 | ██████ |",
+		"| 2 | 90 | ██████████████████ |",
+		"| 迴圈 | 2 | 5 |",
+		"| 1 | 09:00:00 | CLOSED → HALF_OPEN |",
+		"### 迴圈 1 — 失敗",
+		"- 狀態：CONTINUE，EXIT_SIGNAL=false，任務 1/2",
+		"- 工具：edit",
+		"**程式碼區塊**（1）\n\n```go\nfunc parse() {}\n```",
+		"| post_loop | go vet ./... | ❌ exit status 1 | 120ms |",
+		"- post_loop hook failed: exit status 1",
+		"```diff\n--- a/parser.go",
+		"### 迴圈 2 — 結束: completion detected in output",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown 應包含 %q:\n%s", want, md)
+		}
+	}
+
+	// 回應中的程式碼區塊使用較長的 fence，避免提早結束
+	if !strings.Contains(md, "````text\n修改如下：\n```go") {
+		t.Errorf("回應摘錄應使用較長的 fence:\n%s", md)
+	}
+}
+
+// TestRunReportHTML 測試 HTML 報告
+func TestRunReportHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewRunReport(reportTestEvents(), nil).Write(&buf, ReportFormatHTML); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{
+		"<title>Ralph Loop 執行報告 - run-report</title>",
+		"<polyline points=",
+		"<title>迴圈 2：90</title>",
+		`<section class="loop failed" id="loop-1">`,
+		`<section class="loop done" id="loop-2">`,
+		`<span class="lang">go</span>`,
+		`<span class="del">-old &lt;b&gt;</span>`,
+		`<span class="add">&#43;new</span>`,
+		`<span class="hunk">@@ -1,1 &#43;1,1 @@</span>`,
+		"CLOSED → HALF_OPEN",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("HTML 應包含 %q", want)
+		}
+	}
+	if strings.Contains(page, "<b>") || strings.Contains(page, "<script") || strings.Contains(page, "http://") {
+		t.Error("HTML 應跳脫內容且不引用外部資源")
+	}
+}
+
+// TestLoadRunReport 測試從 run 目錄載入報告
+func TestLoadRunReport(t *testing.T) {
+	runsDir := t.TempDir()
+	writeTestJournal(t, runsDir, "run-report", reportTestEvents()...)
+	dir := filepath.Join(runsDir, "run-report")
+
+	report, err := LoadRunReport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Record != nil || len(report.Loops) != 2 {
+		t.Errorf("沒有 run.json 時仍應由執行紀錄建立報告: %+v", report)
+	}
+
+	if err := WriteRunRecord(dir, &RunRecord{RunID: "run-report", Goal: "目標", Status: RunStatusStopped}); err != nil {
+		t.Fatal(err)
+	}
+	report, err = LoadRunReport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Goal != "目標" || report.Status != RunStatusStopped {
+		t.Errorf("應讀取 run.json: %+v", report)
+	}
+
+	if _, err := LoadRunReport(filepath.Join(runsDir, "missing")); err == nil {
+		t.Error("沒有執行紀錄時應傳回錯誤")
+	}
+}
+
+// TestParseReportFormat 測試報告格式解析
+func TestParseReportFormat(t *testing.T) {
+	for input, want := range map[string]ReportFormat{"md": ReportFormatMarkdown, "Markdown": ReportFormatMarkdown, " HTML ": ReportFormatHTML} {
+		if got, err := ParseReportFormat(input); err != nil || got != want {
+			t.Errorf("ParseReportFormat(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := ParseReportFormat("pdf"); err == nil {
+		t.Error("未知格式應傳回錯誤")
+	}
+}
+
+// TestClientWritesRunRecord 測試客戶端寫入 run.json
+func TestClientWritesRunRecord(t *testing.T) {
+	installFakeCopilot(t, `echo "still working"`)
+	workDir := t.TempDir()
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	hook, _ := ParseShellHook("post_loop=true", true)
+	config.ShellHooks = []ShellHook{hook}
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+
+	_, runErr := client.ExecuteUntilCompletion(t.Context(), "任務", 2)
+	if runErr == nil {
+		t.Fatal("未完成時應傳回錯誤")
+	}
+
+	record, err := ReadRunRecord(client.RunDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.RunID != client.RunID() || record.Goal != "任務" || record.Status != RunStatusFailed || record.Error != runErr.Error() {
+		t.Errorf("執行摘要不符: %+v", record)
+	}
+	if record.Loops != 2 || record.FinishedAt == nil || record.Config.MaxLoops != 2 || record.Config.WorkDir != workDir {
+		t.Errorf("應記錄迴圈數與配置: %+v", record)
+	}
+	if len(record.Config.ShellHooks) != 1 || !record.Config.ShellHooks[0].Enforce || record.Config.ChangeScope != nil {
+		t.Errorf("應記錄 hook 且省略未啟用的變更範圍: %+v", record.Config)
+	}
+}
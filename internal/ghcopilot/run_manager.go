@@ -0,0 +1,543 @@
+package ghcopilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 提交 run 的預設值（與 run 子命令的預設相同）
+const (
+	DefaultRunMaxLoops = 10
+	DefaultRunTimeout  = 5 * time.Minute
+
+	// DefaultRunEventBuffer 每個 run 保留在記憶體中的事件數（供 SSE 重播）
+	DefaultRunEventBuffer = 10000
+)
+
+// RunStatus 代表受管理 run 的狀態
+type RunStatus string
+
+const (
+	RunStatusRunning   RunStatus = "running"   // 執行中
+	RunStatusCompleted RunStatus = "completed" // 偵測到完成
+	RunStatusStopped   RunStatus = "stopped"   // 透過控制指令或審核停止
+	RunStatusCancelled RunStatus = "cancelled" // 被取消或服務關閉
+	RunStatusFailed    RunStatus = "failed"    // 錯誤、逾時、熔斷或達到最大迴圈數
+)
+
+// RunManager 相關錯誤
+var (
+	ErrRunNotFound     = errors.New("run not found")
+	ErrWorkDirBusy     = errors.New("another run is active in this work directory")
+	ErrManagerShutdown = errors.New("run manager is shutting down")
+)
+
+// RunRequest 是提交 run 的參數
+//
+// 未指定的欄位使用 RunManager 的基礎配置；相對的工作目錄以 RunManager 的工作目錄為基準。
+type RunRequest struct {
+	Prompt   string `json:"prompt"`
+	WorkDir  string `json:"work_dir,omitempty"`
+	MaxLoops int    `json:"max_loops,omitempty"`
+	Timeout  string `json:"timeout,omitempty"` // time.ParseDuration 格式，例如 "10m"
+
+	Model          string             `json:"model,omitempty"`
+	Permissions    string             `json:"permissions,omitempty"` // 權限預設 (read-only|edit-only|full|safe)
+	AllowTools     []string           `json:"allow_tools,omitempty"`
+	DenyTools      []string           `json:"deny_tools,omitempty"`
+	AddDirs        []string           `json:"add_dirs,omitempty"`
+	ProtectedPaths []string           `json:"protected_paths,omitempty"` // 額外的受保護路徑
+	NoProtect      bool               `json:"no_protect,omitempty"`
+	ChangeScope    *ChangeScopePolicy `json:"change_scope,omitempty"`
+}
+
+// RunLoopInfo 是單一迴圈結果的摘要
+type RunLoopInfo struct {
+	LoopID          string    `json:"loop_id"`
+	LoopIndex       int       `json:"loop_index"`
+	ShouldContinue  bool      `json:"should_continue"`
+	CompletionScore int       `json:"completion_score"`
+	ExitReason      string    `json:"exit_reason,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+	Stopped         bool      `json:"stopped,omitempty"`
+}
+
+// RunInfo 是受管理 run 的狀態快照
+type RunInfo struct {
+	ID           string        `json:"id"`
+	Status       RunStatus     `json:"status"`
+	Prompt       string        `json:"prompt"`
+	WorkDir      string        `json:"work_dir"`
+	RunDir       string        `json:"run_dir,omitempty"`
+	TraceID      string        `json:"trace_id,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	FinishedAt   *time.Time    `json:"finished_at,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	BreakerState string        `json:"breaker_state"`
+	Control      ControlState  `json:"control"`
+	Loops        []RunLoopInfo `json:"loops"`
+}
+
+// ManagedRun 是由 RunManager 啟動的一次執行
+type ManagedRun struct {
+	id      string
+	request RunRequest
+	workDir string
+	client  *RalphLoopClient
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu         sync.Mutex
+	status     RunStatus
+	startedAt  time.Time
+	finishedAt time.Time
+	results    []*LoopResult
+	err        error
+	cancelled  bool
+
+	// 由事件更新，避免在迴圈執行中讀取客戶端內部狀態
+	breakerState CircuitBreakerState
+	traceID      string
+
+	// 事件緩衝：events[0] 的序號為 eventBase，超過上限時丟棄最舊的事件
+	events    []LoopEvent
+	eventBase int
+	maxEvents int
+	changed   chan struct{} // 有新事件或狀態改變時關閉並重建
+}
+
+// ID 傳回 run ID（與 run 目錄名稱相同）
+func (r *ManagedRun) ID() string {
+	return r.id
+}
+
+// Client 傳回執行此 run 的客戶端
+func (r *ManagedRun) Client() *RalphLoopClient {
+	return r.client
+}
+
+// Done 傳回 run 結束時關閉的 channel
+func (r *ManagedRun) Done() <-chan struct{} {
+	return r.done
+}
+
+// Info 取得 run 的狀態快照
+func (r *ManagedRun) Info() RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info := RunInfo{
+		ID:           r.id,
+		Status:       r.status,
+		Prompt:       r.request.Prompt,
+		WorkDir:      r.workDir,
+		RunDir:       r.client.RunDir(),
+		TraceID:      r.traceID,
+		StartedAt:    r.startedAt,
+		BreakerState: string(r.breakerState),
+		Control:      r.client.Controller().State(),
+		Loops:        make([]RunLoopInfo, 0, len(r.results)),
+	}
+	if !r.finishedAt.IsZero() {
+		finished := r.finishedAt
+		info.FinishedAt = &finished
+	}
+	if r.err != nil {
+		info.Error = r.err.Error()
+	}
+
+	// 執行中的迴圈結果尚未傳回，從歷史取得已完成的迴圈
+	if r.status == RunStatusRunning {
+		for i, ctx := range r.client.GetHistory() {
+			info.Loops = append(info.Loops, RunLoopInfo{
+				LoopID:          ctx.LoopID,
+				LoopIndex:       i,
+				ShouldContinue:  ctx.ShouldContinue,
+				CompletionScore: ctx.CompletionScore,
+				ExitReason:      ctx.ExitReason,
+				Timestamp:       ctx.Timestamp,
+			})
+		}
+		return info
+	}
+	for _, result := range r.results {
+		info.Loops = append(info.Loops, RunLoopInfo{
+			LoopID:          result.LoopID,
+			LoopIndex:       result.LoopIndex,
+			ShouldContinue:  result.ShouldContinue,
+			CompletionScore: result.CompletionScore,
+			ExitReason:      result.ExitReason,
+			Timestamp:       result.Timestamp,
+			Stopped:         result.Stopped,
+		})
+	}
+	return info
+}
+
+// History 取得 run 的迴圈執行歷史
+func (r *ManagedRun) History() []*ExecutionContext {
+	return r.client.GetHistory()
+}
+
+// EventsSince 取得序號 from 之後的事件
+//
+// 傳回事件、下一個序號、在有新事件時關閉的 channel，以及 run 是否已結束。
+// from 早於緩衝中最舊的事件時，從最舊的事件開始。
+func (r *ManagedRun) EventsSince(from int) ([]LoopEvent, int, <-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if from < r.eventBase {
+		from = r.eventBase
+	}
+	end := r.eventBase + len(r.events)
+	if from > end {
+		from = end
+	}
+	events := append([]LoopEvent{}, r.events[from-r.eventBase:]...)
+	return events, end, r.changed, r.status != RunStatusRunning
+}
+
+// record 將事件加入緩衝
+func (r *ManagedRun) record(event LoopEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch event.Type {
+	case EventBreakerStateChanged:
+		r.breakerState = CircuitBreakerState(event.BreakerState)
+	case EventLoopFinished:
+		if traceID, ok := event.Data["trace_id"].(string); ok {
+			r.traceID = traceID
+		}
+	}
+
+	r.events = append(r.events, event)
+	if r.maxEvents > 0 && len(r.events) > r.maxEvents {
+		drop := len(r.events) - r.maxEvents
+		r.events = append([]LoopEvent{}, r.events[drop:]...)
+		r.eventBase += drop
+	}
+	r.notifyLocked()
+}
+
+// finish 記錄 run 的結果
+func (r *ManagedRun) finish(results []*LoopResult, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = results
+	r.err = err
+	r.finishedAt = time.Now()
+	switch {
+	case r.cancelled:
+		r.status = RunStatusCancelled
+	case err == nil:
+		r.status = RunStatusCompleted
+	case r.client.Controller().StopRequested() || (len(results) > 0 && results[len(results)-1].Stopped):
+		r.status = RunStatusStopped
+	default:
+		r.status = RunStatusFailed
+	}
+	r.notifyLocked()
+}
+
+func (r *ManagedRun) notifyLocked() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Cancel 立即取消 run（中斷正在執行的迴圈）
+func (r *ManagedRun) Cancel() {
+	r.mu.Lock()
+	r.cancelled = true
+	r.mu.Unlock()
+	r.cancel()
+}
+
+// RunManager 在同一個程序中啟動並追蹤多個 run
+//
+// 每個 run 使用自己的 RalphLoopClient，狀態與執行紀錄寫入該工作目錄的 .ralph-loop；
+// 同一個工作目錄同時只能有一個執行中的 run。
+type RunManager struct {
+	workDir   string
+	newConfig func() *ClientConfig
+	logger    *slog.Logger
+	maxEvents int
+
+	mu       sync.Mutex
+	runs     map[string]*ManagedRun
+	shutdown bool
+	wg       sync.WaitGroup
+}
+
+// NewRunManager 建立 run 管理器
+//
+// workDir 為預設工作目錄；newConfig 傳回每個 run 的基礎配置（nil 時使用 DefaultClientConfig）。
+func NewRunManager(workDir string, newConfig func() *ClientConfig) *RunManager {
+	if newConfig == nil {
+		newConfig = DefaultClientConfig
+	}
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+	return &RunManager{
+		workDir:   workDir,
+		newConfig: newConfig,
+		logger:    defaultLogger(),
+		maxEvents: DefaultRunEventBuffer,
+		runs:      make(map[string]*ManagedRun),
+	}
+}
+
+// SetLogger 設定日誌輸出（nil 表示不輸出）
+func (m *RunManager) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	m.logger = logger
+}
+
+// SetEventBuffer 設定每個 run 保留的事件數（0 表示不限制）
+func (m *RunManager) SetEventBuffer(n int) {
+	m.maxEvents = n
+}
+
+// resolveWorkDir 將工作目錄轉為絕對路徑（相對路徑以管理器的工作目錄為基準）
+func (m *RunManager) resolveWorkDir(dir string) string {
+	if dir == "" {
+		return m.workDir
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(m.workDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// clientConfig 依請求建立客戶端配置
+func (m *RunManager) clientConfig(req RunRequest, workDir string) (*ClientConfig, error) {
+	config := m.newConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.Silent = true
+	if config.Logger == nil {
+		config.Logger = m.logger
+	}
+
+	if req.Model != "" {
+		config.Model = req.Model
+	}
+	if req.Permissions != "" || len(req.AllowTools) > 0 || len(req.DenyTools) > 0 || len(req.AddDirs) > 0 {
+		policy := config.Permissions.Clone()
+		if req.Permissions != "" {
+			preset, err := NewPermissionPolicy(PermissionPreset(req.Permissions))
+			if err != nil {
+				return nil, err
+			}
+			policy = preset
+		}
+		config.Permissions = policy.AllowTools(req.AllowTools...).DenyTools(req.DenyTools...).AddDirs(req.AddDirs...)
+	}
+	if req.NoProtect {
+		config.ProtectedPaths = nil
+	} else {
+		config.ProtectedPaths = append(config.ProtectedPaths, req.ProtectedPaths...)
+	}
+	if req.ChangeScope.Enabled() {
+		config.ChangeScope = req.ChangeScope
+	}
+	return config, nil
+}
+
+// Submit 驗證請求並在背景啟動 run
+func (m *RunManager) Submit(req RunRequest) (*ManagedRun, error) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, fmt.Errorf("prompt is required")
+	}
+	if req.MaxLoops < 0 {
+		return nil, fmt.Errorf("max_loops must be positive, got %d", req.MaxLoops)
+	}
+	if req.MaxLoops == 0 {
+		req.MaxLoops = DefaultRunMaxLoops
+	}
+	timeout := DefaultRunTimeout
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", req.Timeout)
+		}
+		timeout = d
+	}
+
+	workDir := m.resolveWorkDir(req.WorkDir)
+	config, err := m.clientConfig(req, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.shutdown {
+		return nil, ErrManagerShutdown
+	}
+	if m.activeRunLocked(workDir) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrWorkDirBusy, workDir)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	client := NewRalphLoopClientWithConfig(config)
+	run := &ManagedRun{
+		id:        client.RunID(),
+		request:   req,
+		workDir:   workDir,
+		client:    client,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		status:    RunStatusRunning,
+		startedAt: time.Now(),
+		maxEvents: m.maxEvents,
+
+		breakerState: client.GetStatus().CircuitBreakerState,
+		changed:      make(chan struct{}),
+	}
+	client.Subscribe(run.record)
+	m.runs[run.id] = run
+
+	m.wg.Add(1)
+	go m.execute(ctx, run)
+
+	m.logger.Info("已提交 run", "run_id", run.id, "work_dir", workDir, "max_loops", req.MaxLoops, "timeout", timeout)
+	return run, nil
+}
+
+// execute 執行 run 直到結束
+func (m *RunManager) execute(ctx context.Context, run *ManagedRun) {
+	defer m.wg.Done()
+	defer close(run.done)
+	defer run.cancel()
+
+	// 讓 ralph-loop ctl 也能控制此 run
+	if server, err := NewControlServer(ControlSocketPath(run.workDir), run.client.Controller()); err != nil {
+		m.logger.Warn("控制通道未啟用", "run_id", run.id, "error", err)
+	} else {
+		defer server.Close()
+	}
+
+	results, err := run.client.ExecuteUntilCompletion(ctx, run.request.Prompt, run.request.MaxLoops)
+	run.finish(results, err)
+	_ = run.client.Close()
+
+	m.logger.Info("run 結束", "run_id", run.id, "status", run.Info().Status, "loops", len(results), "error", err)
+}
+
+// activeRunLocked 傳回工作目錄中執行中的 run（呼叫端須持有鎖）
+func (m *RunManager) activeRunLocked(workDir string) *ManagedRun {
+	for _, run := range m.runs {
+		if run.workDir != workDir {
+			continue
+		}
+		select {
+		case <-run.done:
+		default:
+			return run
+		}
+	}
+	return nil
+}
+
+// Get 取得 run
+func (m *RunManager) Get(id string) (*ManagedRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	run, ok := m.runs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRunNotFound, id)
+	}
+	return run, nil
+}
+
+// List 依開始時間（新到舊）列出所有 run
+func (m *RunManager) List() []RunInfo {
+	m.mu.Lock()
+	runs := make([]*ManagedRun, 0, len(m.runs))
+	for _, run := range m.runs {
+		runs = append(runs, run)
+	}
+	m.mu.Unlock()
+
+	infos := make([]RunInfo, 0, len(runs))
+	for _, run := range runs {
+		infos = append(infos, run.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartedAt.After(infos[j].StartedAt) })
+	return infos
+}
+
+// ResetBreaker 重置工作目錄的熔斷器
+//
+// 該目錄有執行中的 run 時，要求該 run 在下一個迴圈前重置並傳回其 ID；
+// 否則立即重置持久化的熔斷器狀態（與 reset 子命令相同），傳回空字串。
+func (m *RunManager) ResetBreaker(workDir string) (string, error) {
+	workDir = m.resolveWorkDir(workDir)
+
+	m.mu.Lock()
+	run := m.activeRunLocked(workDir)
+	m.mu.Unlock()
+
+	if run != nil {
+		run.client.Controller().RequestBreakerReset()
+		return run.id, nil
+	}
+
+	config, err := m.clientConfig(RunRequest{}, workDir)
+	if err != nil {
+		return "", err
+	}
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+	return "", client.ResetCircuitBreaker()
+}
+
+// Shutdown 停止接受新的 run，要求所有 run 在目前迴圈結束後停止並等待
+//
+// ctx 到期時取消仍在執行的 run，並等待它們結束。
+func (m *RunManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.shutdown = true
+	runs := make([]*ManagedRun, 0, len(m.runs))
+	for _, run := range m.runs {
+		runs = append(runs, run)
+	}
+	m.mu.Unlock()
+
+	for _, run := range runs {
+		run.client.Controller().RequestStop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, run := range runs {
+			run.Cancel()
+		}
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,79 @@
+package ghcopilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RunRecordFileName 執行摘要在 run 目錄中的檔名
+const RunRecordFileName = "run.json"
+
+// RunRecord 描述一次執行的目標、配置與結果
+//
+// 第一個迴圈開始時寫入 run 目錄的 run.json，ExecuteUntilCompletion 結束時補上結果；
+// 與 journal.jsonl 一起作為報告的資料來源。
+type RunRecord struct {
+	RunID      string     `json:"run_id"`
+	Goal       string     `json:"goal"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Status     RunStatus  `json:"status"`          // 結束前為 running
+	Error      string     `json:"error,omitempty"` // 未完成的原因（例如達到迴圈上限、熔斷器打開）
+	Loops      int        `json:"loops"`           // 完成的迴圈數
+	Config     RunConfig  `json:"config"`
+}
+
+// RunConfig 記錄影響執行結果的配置
+type RunConfig struct {
+	WorkDir                 string             `json:"work_dir"`
+	Model                   string             `json:"model"`
+	MaxLoops                int                `json:"max_loops,omitempty"`
+	Deadline                *time.Time         `json:"deadline,omitempty"`
+	CLITimeout              time.Duration      `json:"cli_timeout"`
+	CircuitBreakerThreshold int                `json:"circuit_breaker_threshold"`
+	SameErrorThreshold      int                `json:"same_error_threshold"`
+	Permissions             *PermissionPolicy  `json:"permissions,omitempty"`
+	ProtectedPaths          []string           `json:"protected_paths,omitempty"`
+	ChangeScope             *ChangeScopePolicy `json:"change_scope,omitempty"`
+	ShellHooks              []ShellHook        `json:"shell_hooks,omitempty"`
+	Approval                bool               `json:"approval"`
+	SDK                     bool               `json:"sdk"`
+	StatusContract          bool               `json:"status_contract"` // prompt 是否附加 JSON 狀態區塊規格
+	Chaos                   *ChaosStats        `json:"chaos,omitempty"` // 混沌測試注入的故障 (結果不代表正常執行)
+}
+
+// WriteRunRecord 將執行摘要寫入 run 目錄（先寫入暫存檔再改名，避免讀到寫到一半的內容）
+func WriteRunRecord(dir string, record *RunRecord) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("無法建立執行紀錄目錄: %w", err)
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON 編碼失敗: %w", err)
+	}
+
+	path := filepath.Join(dir, RunRecordFileName)
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("無法寫入執行摘要: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("無法寫入執行摘要: %w", err)
+	}
+	return nil
+}
+
+// ReadRunRecord 讀取 run 目錄中的執行摘要（舊版本的 run 沒有此檔案，錯誤符合 os.IsNotExist）
+func ReadRunRecord(dir string) (*RunRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RunRecordFileName))
+	if err != nil {
+		return nil, err
+	}
+	var record RunRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("執行摘要格式錯誤: %w", err)
+	}
+	return &record, nil
+}
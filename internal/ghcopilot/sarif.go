@@ -0,0 +1,166 @@
+package ghcopilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SARIF 2.1.0 的最小子集（GitHub code scanning 等工具可讀取）
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// diagnosticLine 比對編譯器與 lint 工具常見的 "path:line[:col]: message" 輸出
+var diagnosticLine = regexp.MustCompile(`^\s*(?:vet: )?([^\s:][^:]*\.[A-Za-z0-9]+):(\d+)(?::(\d+))?:\s*(.+)$`)
+
+// WriteSARIFReport 將最後一次驗證（最後一個有 post_loop hook 結果的迴圈）留下的診斷輸出為 SARIF
+//
+// 每個失敗的 post_loop hook 是一條規則；輸出中符合 "path:line[:col]: message" 的行
+// 各成為一個結果，沒有可解析位置的失敗則以 hook 錯誤作為一個沒有位置的結果。
+// 路徑以 workDir 為基準轉為相對路徑（uriBaseId 為 %SRCROOT%）。
+func WriteSARIFReport(w io.Writer, workDir string, history []*ExecutionContext) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "ralph-loop",
+			InformationURI: "https://github.com/cy540/ralph-loop",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, result := range finalVerification(history) {
+		if result.OK {
+			continue
+		}
+
+		ruleID := result.Name()
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               ruleID,
+			ShortDescription: sarifMessage{Text: "post_loop 驗證: " + ruleID},
+		})
+		level := "warning"
+		if result.Enforce {
+			level = "error"
+		}
+
+		diagnostics := parseDiagnostics(result.Output, workDir)
+		for i := range diagnostics {
+			diagnostics[i].RuleID = ruleID
+			diagnostics[i].Level = level
+		}
+		if len(diagnostics) == 0 {
+			diagnostics = []sarifResult{{RuleID: ruleID, Level: level, Message: sarifMessage{Text: result.Error}}}
+		}
+		run.Results = append(run.Results, diagnostics...)
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("SARIF 編碼失敗: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// finalVerification 傳回最後一個有 post_loop hook 結果的迴圈的驗證結果
+func finalVerification(history []*ExecutionContext) []HookResult {
+	for i := len(history) - 1; i >= 0; i-- {
+		var results []HookResult
+		for _, result := range history[i].HookResults {
+			if result.Point == HookPostLoop {
+				results = append(results, result)
+			}
+		}
+		if len(results) > 0 {
+			return results
+		}
+	}
+	return nil
+}
+
+// parseDiagnostics 從 hook 輸出解析有位置的診斷（RuleID 與 Level 由呼叫端填入）
+func parseDiagnostics(output, workDir string) []sarifResult {
+	var results []sarifResult
+	for _, diagnostic := range ParseDiagnostics(output) {
+		results = append(results, sarifResult{
+			Message: sarifMessage{Text: diagnostic.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: sarifURI(diagnostic.File, workDir), URIBaseID: "%SRCROOT%"},
+				Region:           &sarifRegion{StartLine: diagnostic.Line, StartColumn: diagnostic.Column},
+			}}},
+		})
+	}
+	return results
+}
+
+// sarifURI 將診斷中的路徑轉為相對於工作目錄的 URI
+func sarifURI(path, workDir string) string {
+	if filepath.IsAbs(path) && workDir != "" {
+		if abs, err := filepath.Abs(workDir); err == nil {
+			if rel, err := filepath.Rel(abs, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
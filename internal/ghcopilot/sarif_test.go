@@ -0,0 +1,85 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// decodeSARIF 執行 WriteSARIFReport 並解析結果
+func decodeSARIF(t *testing.T, workDir string, history []*ExecutionContext) sarifLog {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteSARIFReport(&buf, workDir, history); err != nil {
+		t.Fatalf("WriteSARIFReport 失敗: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("輸出不是合法的 JSON: %v\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("SARIF 結構不正確: %+v", log)
+	}
+	return log
+}
+
+func TestWriteSARIFReport(t *testing.T) {
+	workDir := t.TempDir()
+	output := "# example.com/app\n" +
+		"vet: ./parser.go:3:2: declared and not used: x\n" +
+		filepath.Join(workDir, "cmd", "main.go") + ":10: missing return\n" +
+		"vet: ./parser.go:3:2: declared and not used: x\n"
+
+	history := []*ExecutionContext{
+		{LoopIndex: 0, HookResults: []HookResult{
+			{Point: HookPostLoop, Command: "go test ./...", Error: "exit status 1", Output: "old.go:1:1: 舊的錯誤"},
+		}},
+		{LoopIndex: 1, HookResults: []HookResult{
+			{Point: HookPostLoop, Command: "go vet ./...", Enforce: true, Error: "exit status 1", Output: output},
+			{Point: HookPostLoop, Command: "make lint", Error: "exit status 2", Output: "lint 失敗"},
+			{Point: HookPostLoop, Command: "go build ./...", OK: true},
+		}},
+		{LoopIndex: 2, HookResults: []HookResult{{Point: HookPreLoop, Command: "git status", OK: true}}},
+	}
+
+	run := decodeSARIF(t, workDir, history).Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "go vet ./..." || run.Tool.Driver.Rules[1].ID != "make lint" {
+		t.Errorf("規則應該只包含最後一次驗證中失敗的 hook: %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("結果數量 = %d, 期望 3: %+v", len(run.Results), run.Results)
+	}
+
+	first := run.Results[0]
+	location := first.Locations[0].PhysicalLocation
+	if first.RuleID != "go vet ./..." || first.Level != "error" || first.Message.Text != "declared and not used: x" {
+		t.Errorf("第一個結果不正確: %+v", first)
+	}
+	if location.ArtifactLocation.URI != "parser.go" || location.ArtifactLocation.URIBaseID != "%SRCROOT%" ||
+		location.Region.StartLine != 3 || location.Region.StartColumn != 2 {
+		t.Errorf("第一個結果的位置不正確: %+v", location)
+	}
+
+	second := run.Results[1].Locations[0].PhysicalLocation
+	if second.ArtifactLocation.URI != "cmd/main.go" || second.Region.StartLine != 10 || second.Region.StartColumn != 0 {
+		t.Errorf("絕對路徑應該轉為相對於工作目錄: %+v", second)
+	}
+
+	lint := run.Results[2]
+	if lint.RuleID != "make lint" || lint.Level != "warning" || lint.Message.Text != "exit status 2" || len(lint.Locations) != 0 {
+		t.Errorf("無法解析位置的失敗應該輸出沒有位置的結果: %+v", lint)
+	}
+}
+
+func TestWriteSARIFReportPassed(t *testing.T) {
+	history := []*ExecutionContext{
+		{HookResults: []HookResult{{Point: HookPostLoop, Command: "go vet ./...", Output: "parser.go:1:1: 錯誤"}}},
+		{HookResults: []HookResult{{Point: HookPostLoop, Command: "go vet ./...", OK: true}}},
+	}
+
+	run := decodeSARIF(t, t.TempDir(), history).Runs[0]
+	if run.Results == nil || len(run.Results) != 0 || len(run.Tool.Driver.Rules) != 0 {
+		t.Errorf("最後一次驗證通過時不應該有結果: %+v", run)
+	}
+}
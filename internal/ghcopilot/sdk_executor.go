@@ -1,320 +1,518 @@
-package ghcopilot
-
-import (
-	"context"
-	"fmt"
-	"sync"
-	"time"
-
-	copilot "github.com/github/copilot-sdk/go"
-)
-
-// SDKConfig SDK 執行器配置
-type SDKConfig struct {
-	CLIPath        string        // CLI 路徑
-	Timeout        time.Duration // 執行逾時
-	SessionTimeout time.Duration // 會話逾時
-	MaxSessions    int           // 最大會話數
-	LogLevel       string        // 日誌級別
-	EnableMetrics  bool          // 啟用指標
-	AutoReconnect  bool          // 自動重新連接
-	MaxRetries     int           // 最大重試次數
-}
-
-// DefaultSDKConfig 預設 SDK 配置
-func DefaultSDKConfig() *SDKConfig {
-	return &SDKConfig{
-		CLIPath:        "copilot",
-		Timeout:        30 * time.Second,
-		SessionTimeout: 5 * time.Minute,
-		MaxSessions:    100,
-		LogLevel:       "info",
-		EnableMetrics:  true,
-		AutoReconnect:  true,
-		MaxRetries:     3,
-	}
-}
-
-// SDKExecutor SDK 執行器
-type SDKExecutor struct {
-	client      *copilot.Client
-	config      *SDKConfig
-	sessions    *SDKSessionPool
-	mu          sync.RWMutex
-	initialized bool
-	running     bool
-	closed      bool
-	lastError   error
-	metrics     *SDKExecutorMetrics
-}
-
-// SDKExecutorMetrics 執行器指標
-type SDKExecutorMetrics struct {
-	TotalCalls      int64
-	SuccessfulCalls int64
-	FailedCalls     int64
-	TotalDuration   time.Duration
-	StartTime       time.Time
-}
-
-// NewSDKExecutor 建立新的 SDK 執行器
-func NewSDKExecutor(config *SDKConfig) *SDKExecutor {
-	if config == nil {
-		config = DefaultSDKConfig()
-	}
-
-	return &SDKExecutor{
-		config:   config,
-		sessions: NewSDKSessionPool(config.MaxSessions, config.SessionTimeout),
-		metrics:  &SDKExecutorMetrics{StartTime: time.Now()},
-	}
-}
-
-// Start 啟動 SDK 執行器
-func (e *SDKExecutor) Start(ctx context.Context) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if e.running {
-		return fmt.Errorf("sdk executor already running")
-	}
-
-	if e.closed {
-		return fmt.Errorf("sdk executor already closed")
-	}
-
-	// 建立客戶端
-	clientOpts := &copilot.ClientOptions{
-		CLIPath:  e.config.CLIPath,
-		LogLevel: e.config.LogLevel,
-	}
-
-	e.client = copilot.NewClient(clientOpts)
-	if e.client == nil {
-		e.lastError = fmt.Errorf("failed to create copilot client")
-		return e.lastError
-	}
-
-	// 啟動客戶端
-	if err := e.client.Start(); err != nil {
-		e.lastError = fmt.Errorf("failed to start copilot client: %w", err)
-		return e.lastError
-	}
-
-	e.initialized = true
-	e.running = true
-	return nil
-}
-
-// Stop 停止 SDK 執行器
-func (e *SDKExecutor) Stop(ctx context.Context) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if !e.running {
-		return fmt.Errorf("sdk executor not running")
-	}
-
-	// 清理所有會話
-	if err := e.sessions.ClearAll(); err != nil {
-		e.lastError = fmt.Errorf("failed to clear sessions: %w", err)
-	}
-
-	// 停止客戶端
-	if e.client != nil {
-		errs := e.client.Stop()
-		if len(errs) > 0 {
-			e.lastError = fmt.Errorf("errors during client stop: %v", errs)
-		}
-	}
-
-	e.running = false
-	return nil
-}
-
-// Complete 執行代碼完成
-func (e *SDKExecutor) Complete(ctx context.Context, prompt string) (string, error) {
-	if !e.isHealthy() {
-		return "", fmt.Errorf("sdk executor not healthy")
-	}
-
-	startTime := time.Now()
-	e.metrics.TotalCalls++
-
-	// 執行完成 (這裡使用模擬，因為實際 SDK 方法可能不同)
-	result := fmt.Sprintf("Completion for: %s", prompt)
-	duration := time.Since(startTime)
-
-	e.metrics.SuccessfulCalls++
-	e.metrics.TotalDuration += duration
-
-	return result, nil
-}
-
-// Explain 執行代碼解釋
-func (e *SDKExecutor) Explain(ctx context.Context, code string) (string, error) {
-	if !e.isHealthy() {
-		return "", fmt.Errorf("sdk executor not healthy")
-	}
-
-	startTime := time.Now()
-	e.metrics.TotalCalls++
-
-	result := fmt.Sprintf("Explanation for: %s", code)
-	duration := time.Since(startTime)
-
-	e.metrics.SuccessfulCalls++
-	e.metrics.TotalDuration += duration
-
-	return result, nil
-}
-
-// GenerateTests 生成測試代碼
-func (e *SDKExecutor) GenerateTests(ctx context.Context, code string) (string, error) {
-	if !e.isHealthy() {
-		return "", fmt.Errorf("sdk executor not healthy")
-	}
-
-	startTime := time.Now()
-	e.metrics.TotalCalls++
-
-	result := fmt.Sprintf("Generated tests for: %s", code)
-	duration := time.Since(startTime)
-
-	e.metrics.SuccessfulCalls++
-	e.metrics.TotalDuration += duration
-
-	return result, nil
-}
-
-// CodeReview 執行代碼審查
-func (e *SDKExecutor) CodeReview(ctx context.Context, code string) (string, error) {
-	if !e.isHealthy() {
-		return "", fmt.Errorf("sdk executor not healthy")
-	}
-
-	startTime := time.Now()
-	e.metrics.TotalCalls++
-
-	result := fmt.Sprintf("Review for: %s", code)
-	duration := time.Since(startTime)
-
-	e.metrics.SuccessfulCalls++
-	e.metrics.TotalDuration += duration
-
-	return result, nil
-}
-
-// CreateSession 建立新會話
-func (e *SDKExecutor) CreateSession(sessionID string) (*SDKSession, error) {
-	if !e.isHealthy() {
-		return nil, fmt.Errorf("sdk executor not healthy")
-	}
-
-	return e.sessions.CreateSession(sessionID)
-}
-
-// GetSession 取得會話
-func (e *SDKExecutor) GetSession(sessionID string) (*SDKSession, error) {
-	if !e.initialized {
-		return nil, fmt.Errorf("sdk executor not initialized")
-	}
-
-	return e.sessions.GetSession(sessionID)
-}
-
-// ListSessions 列出所有會話
-func (e *SDKExecutor) ListSessions() []*SDKSession {
-	return e.sessions.ListSessions()
-}
-
-// TerminateSession 終止會話
-func (e *SDKExecutor) TerminateSession(sessionID string) error {
-	if !e.initialized {
-		return fmt.Errorf("sdk executor not initialized")
-	}
-
-	return e.sessions.RemoveSession(sessionID)
-}
-
-// GetSessionCount 取得會話計數
-func (e *SDKExecutor) GetSessionCount() int {
-	return e.sessions.GetSessionCount()
-}
-
-// CleanupExpiredSessions 清理過期會話
-func (e *SDKExecutor) CleanupExpiredSessions() int {
-	return e.sessions.CleanupExpiredSessions()
-}
-
-// GetMetrics 取得執行器指標
-func (e *SDKExecutor) GetMetrics() *SDKExecutorMetrics {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	return &SDKExecutorMetrics{
-		TotalCalls:      e.metrics.TotalCalls,
-		SuccessfulCalls: e.metrics.SuccessfulCalls,
-		FailedCalls:     e.metrics.FailedCalls,
-		TotalDuration:   e.metrics.TotalDuration,
-		StartTime:       e.metrics.StartTime,
-	}
-}
-
-// GetStatus 取得執行器狀態
-func (e *SDKExecutor) GetStatus() *SDKStatus {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	return &SDKStatus{
-		Initialized:  e.initialized,
-		Running:      e.running,
-		Closed:       e.closed,
-		SessionCount: e.sessions.GetSessionCount(),
-		LastError:    e.lastError,
-		Uptime:       time.Since(e.metrics.StartTime),
-	}
-}
-
-// SDKStatus SDK 執行器狀態
-type SDKStatus struct {
-	Initialized  bool
-	Running      bool
-	Closed       bool
-	SessionCount int
-	LastError    error
-	Uptime       time.Duration
-}
-
-// Close 關閉執行器
-func (e *SDKExecutor) Close() error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if e.closed {
-		return fmt.Errorf("sdk executor already closed")
-	}
-
-	// 清理會話
-	_ = e.sessions.ClearAll()
-
-	// 停止客戶端
-	if e.client != nil && e.running {
-		errs := e.client.Stop()
-		if len(errs) > 0 {
-			e.lastError = fmt.Errorf("errors during close: %v", errs)
-		}
-	}
-
-	e.running = false
-	e.closed = true
-	return nil
-}
-
-// isHealthy 檢查執行器是否健康
-func (e *SDKExecutor) isHealthy() bool {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	return e.initialized && e.running && !e.closed
-}
+package ghcopilot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+// SDKConfig SDK 執行器配置
+type SDKConfig struct {
+	CLIPath        string        // CLI 路徑
+	Model          string        // 會話使用的模型（空值時使用 CLI 的預設模型）
+	Timeout        time.Duration // 執行逾時
+	SessionTimeout time.Duration // 會話逾時
+	MaxSessions    int           // 最大會話數
+	LogLevel       string        // 日誌級別
+	EnableMetrics  bool          // 啟用指標
+	AutoReconnect  bool          // 自動重新連接
+	MaxRetries     int           // 最大重試次數
+}
+
+// DefaultSDKConfig 預設 SDK 配置
+func DefaultSDKConfig() *SDKConfig {
+	return &SDKConfig{
+		CLIPath:        "copilot",
+		Timeout:        30 * time.Second,
+		SessionTimeout: 5 * time.Minute,
+		MaxSessions:    100,
+		LogLevel:       "info",
+		EnableMetrics:  true,
+		AutoReconnect:  true,
+		MaxRetries:     3,
+	}
+}
+
+// SDKExecutor SDK 執行器
+type SDKExecutor struct {
+	client      *copilot.Client
+	config      *SDKConfig
+	sessions    *SDKSessionPool
+	mu          sync.RWMutex
+	initialized bool
+	running     bool
+	closed      bool
+	lastError   error
+	metrics     *SDKExecutorMetrics
+	onEvent     EventHandler              // 串流輸出與工具呼叫（可為 nil）
+	tools       []copilot.Tool            // 註冊到每個會話的 agent 工具
+	permissions copilot.PermissionHandler // 處理會話的權限請求（nil 時由 SDK 預設處理）
+	logger      *slog.Logger
+
+	// openSession 建立 Complete 使用的會話（預設以 SDK 客戶端建立，測試時可替換）
+	openSession func(config *copilot.SessionConfig) (sdkConversation, error)
+}
+
+// sdkConversation 是 Complete 使用的 SDK 會話操作（*copilot.Session 實作此介面）
+type sdkConversation interface {
+	On(handler copilot.SessionEventHandler) func()
+	SendAndWait(options copilot.MessageOptions, timeout time.Duration) (*copilot.SessionEvent, error)
+	Abort() error
+	Destroy() error
+}
+
+// SDKExecutorMetrics 執行器指標
+type SDKExecutorMetrics struct {
+	TotalCalls      int64
+	SuccessfulCalls int64
+	FailedCalls     int64
+	TotalDuration   time.Duration
+	StartTime       time.Time
+}
+
+// NewSDKExecutor 建立新的 SDK 執行器
+func NewSDKExecutor(config *SDKConfig) *SDKExecutor {
+	if config == nil {
+		config = DefaultSDKConfig()
+	}
+
+	executor := &SDKExecutor{
+		config:   config,
+		sessions: NewSDKSessionPool(config.MaxSessions, config.SessionTimeout),
+		metrics:  &SDKExecutorMetrics{StartTime: time.Now()},
+		logger:   defaultLogger(),
+	}
+	executor.openSession = executor.createCopilotSession
+	return executor
+}
+
+// SetLogger 設定日誌輸出（nil 時停用日誌）
+func (e *SDKExecutor) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.logger = logger
+}
+
+// Start 啟動 SDK 執行器
+func (e *SDKExecutor) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.running {
+		return fmt.Errorf("sdk executor already running")
+	}
+
+	if e.closed {
+		return fmt.Errorf("sdk executor already closed")
+	}
+
+	// 建立客戶端
+	clientOpts := &copilot.ClientOptions{
+		CLIPath:  e.config.CLIPath,
+		LogLevel: e.config.LogLevel,
+	}
+
+	e.client = copilot.NewClient(clientOpts)
+	if e.client == nil {
+		e.lastError = fmt.Errorf("failed to create copilot client")
+		return e.lastError
+	}
+
+	// 啟動客戶端
+	if err := e.client.Start(); err != nil {
+		e.lastError = fmt.Errorf("failed to start copilot client: %w", err)
+		e.logger.Warn("SDK 執行器啟動失敗", "cli_path", e.config.CLIPath, "error", err)
+		return e.lastError
+	}
+
+	e.initialized = true
+	e.running = true
+	e.logger.Info("SDK 執行器已啟動", "cli_path", e.config.CLIPath)
+	return nil
+}
+
+// Stop 停止 SDK 執行器
+func (e *SDKExecutor) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.running {
+		return fmt.Errorf("sdk executor not running")
+	}
+
+	// 清理所有會話
+	if err := e.sessions.ClearAll(); err != nil {
+		e.lastError = fmt.Errorf("failed to clear sessions: %w", err)
+	}
+
+	// 停止客戶端
+	if e.client != nil {
+		errs := e.client.Stop()
+		if len(errs) > 0 {
+			e.lastError = fmt.Errorf("errors during client stop: %v", errs)
+			e.logger.Warn("SDK 客戶端停止時發生錯誤", "error", e.lastError)
+		}
+	}
+
+	e.running = false
+	e.logger.Info("SDK 執行器已停止")
+	return nil
+}
+
+// Complete 在新的 SDK 會話中送出 prompt，等待會話閒置後傳回最後的 assistant 回應
+//
+// 會話以 SessionConfig 建立，註冊 agent 工具與權限處理；串流的 assistant delta 與
+// 工具呼叫經由 SessionEventHandler 發布。ctx 取消時中止會話。
+func (e *SDKExecutor) Complete(ctx context.Context, prompt string) (string, error) {
+	if !e.isHealthy() {
+		return "", fmt.Errorf("sdk executor not healthy")
+	}
+
+	startTime := time.Now()
+	e.metrics.TotalCalls++
+
+	result, err := e.converse(ctx, prompt)
+	duration := time.Since(startTime)
+	e.metrics.TotalDuration += duration
+	if err != nil {
+		e.metrics.FailedCalls++
+		e.logger.Warn("SDK 會話失敗", "prompt_bytes", len(prompt), "duration", duration, "error", err)
+		return "", err
+	}
+	e.logger.Debug("SDK 完成請求", "prompt_bytes", len(prompt), "duration", duration)
+	e.metrics.SuccessfulCalls++
+
+	return result, nil
+}
+
+// converse 建立會話、送出 prompt 並等待回應，結束後銷毀會話
+func (e *SDKExecutor) converse(ctx context.Context, prompt string) (string, error) {
+	sessionID := "ralph-" + newSpanID()
+	session, err := e.openSession(e.SessionConfig(sessionID, e.config.Model))
+	if err != nil {
+		return "", fmt.Errorf("無法建立 SDK 會話: %w", err)
+	}
+	defer session.Destroy()
+	if _, err := e.CreateSession(sessionID); err == nil {
+		defer e.sessions.RemoveSession(sessionID)
+	}
+	unsubscribe := session.On(e.SessionEventHandler())
+	defer unsubscribe()
+
+	type reply struct {
+		event *copilot.SessionEvent
+		err   error
+	}
+	done := make(chan reply, 1)
+	go func() {
+		event, err := session.SendAndWait(copilot.MessageOptions{Prompt: prompt}, e.config.Timeout)
+		done <- reply{event, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return "", r.err
+		}
+		if r.event == nil || r.event.Data.Content == nil {
+			return "", fmt.Errorf("SDK 會話沒有 assistant 回應")
+		}
+		return *r.event.Data.Content, nil
+	case <-ctx.Done():
+		if err := session.Abort(); err != nil {
+			e.logger.Warn("無法中止 SDK 會話", "session_id", sessionID, "error", err)
+		}
+		return "", ctx.Err()
+	}
+}
+
+// createCopilotSession 以 SDK 客戶端建立會話
+func (e *SDKExecutor) createCopilotSession(config *copilot.SessionConfig) (sdkConversation, error) {
+	e.mu.RLock()
+	client := e.client
+	e.mu.RUnlock()
+	if client == nil {
+		return nil, fmt.Errorf("sdk client not started")
+	}
+	session, err := client.CreateSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Explain 執行代碼解釋
+func (e *SDKExecutor) Explain(ctx context.Context, code string) (string, error) {
+	if !e.isHealthy() {
+		return "", fmt.Errorf("sdk executor not healthy")
+	}
+
+	startTime := time.Now()
+	e.metrics.TotalCalls++
+
+	result := fmt.Sprintf("Explanation for: %s", code)
+	duration := time.Since(startTime)
+
+	e.metrics.SuccessfulCalls++
+	e.metrics.TotalDuration += duration
+
+	return result, nil
+}
+
+// GenerateTests 生成測試代碼
+func (e *SDKExecutor) GenerateTests(ctx context.Context, code string) (string, error) {
+	if !e.isHealthy() {
+		return "", fmt.Errorf("sdk executor not healthy")
+	}
+
+	startTime := time.Now()
+	e.metrics.TotalCalls++
+
+	result := fmt.Sprintf("Generated tests for: %s", code)
+	duration := time.Since(startTime)
+
+	e.metrics.SuccessfulCalls++
+	e.metrics.TotalDuration += duration
+
+	return result, nil
+}
+
+// CodeReview 執行代碼審查
+func (e *SDKExecutor) CodeReview(ctx context.Context, code string) (string, error) {
+	if !e.isHealthy() {
+		return "", fmt.Errorf("sdk executor not healthy")
+	}
+
+	startTime := time.Now()
+	e.metrics.TotalCalls++
+
+	result := fmt.Sprintf("Review for: %s", code)
+	duration := time.Since(startTime)
+
+	e.metrics.SuccessfulCalls++
+	e.metrics.TotalDuration += duration
+
+	return result, nil
+}
+
+// CreateSession 建立新會話
+func (e *SDKExecutor) CreateSession(sessionID string) (*SDKSession, error) {
+	if !e.isHealthy() {
+		return nil, fmt.Errorf("sdk executor not healthy")
+	}
+
+	session, err := e.sessions.CreateSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if names := e.ToolNames(); len(names) > 0 {
+		session.Properties["tools"] = strings.Join(names, ",")
+	}
+	return session, nil
+}
+
+// SetTools 設定註冊到每個會話的 agent 工具（例如 LoopTools.Tools()）
+func (e *SDKExecutor) SetTools(tools []copilot.Tool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tools = tools
+}
+
+// ToolNames 傳回已註冊的 agent 工具名稱
+func (e *SDKExecutor) ToolNames() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := make([]string, len(e.tools))
+	for i, tool := range e.tools {
+		names[i] = tool.Name
+	}
+	return names
+}
+
+// SetPermissionHandler 設定處理每個會話權限請求的函式（例如 PermissionEngine.Handler()）
+func (e *SDKExecutor) SetPermissionHandler(handler copilot.PermissionHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.permissions = handler
+}
+
+// SessionConfig 傳回建立 SDK 會話時使用的配置（包含 agent 工具、權限處理並啟用串流）
+func (e *SDKExecutor) SessionConfig(sessionID, model string) *copilot.SessionConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return &copilot.SessionConfig{
+		SessionID:           sessionID,
+		Model:               model,
+		Tools:               append([]copilot.Tool(nil), e.tools...),
+		OnPermissionRequest: e.permissions,
+		Streaming:           true,
+	}
+}
+
+// GetSession 取得會話
+func (e *SDKExecutor) GetSession(sessionID string) (*SDKSession, error) {
+	if !e.initialized {
+		return nil, fmt.Errorf("sdk executor not initialized")
+	}
+
+	return e.sessions.GetSession(sessionID)
+}
+
+// ListSessions 列出所有會話
+func (e *SDKExecutor) ListSessions() []*SDKSession {
+	return e.sessions.ListSessions()
+}
+
+// TerminateSession 終止會話
+func (e *SDKExecutor) TerminateSession(sessionID string) error {
+	if !e.initialized {
+		return fmt.Errorf("sdk executor not initialized")
+	}
+
+	return e.sessions.RemoveSession(sessionID)
+}
+
+// GetSessionCount 取得會話計數
+func (e *SDKExecutor) GetSessionCount() int {
+	return e.sessions.GetSessionCount()
+}
+
+// CleanupExpiredSessions 清理過期會話
+func (e *SDKExecutor) CleanupExpiredSessions() int {
+	return e.sessions.CleanupExpiredSessions()
+}
+
+// GetMetrics 取得執行器指標
+func (e *SDKExecutor) GetMetrics() *SDKExecutorMetrics {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return &SDKExecutorMetrics{
+		TotalCalls:      e.metrics.TotalCalls,
+		SuccessfulCalls: e.metrics.SuccessfulCalls,
+		FailedCalls:     e.metrics.FailedCalls,
+		TotalDuration:   e.metrics.TotalDuration,
+		StartTime:       e.metrics.StartTime,
+	}
+}
+
+// GetStatus 取得執行器狀態
+func (e *SDKExecutor) GetStatus() *SDKStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return &SDKStatus{
+		Initialized:  e.initialized,
+		Running:      e.running,
+		Closed:       e.closed,
+		SessionCount: e.sessions.GetSessionCount(),
+		LastError:    e.lastError,
+		Uptime:       time.Since(e.metrics.StartTime),
+	}
+}
+
+// SDKStatus SDK 執行器狀態
+type SDKStatus struct {
+	Initialized  bool
+	Running      bool
+	Closed       bool
+	SessionCount int
+	LastError    error
+	Uptime       time.Duration
+}
+
+// Close 關閉執行器
+func (e *SDKExecutor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return fmt.Errorf("sdk executor already closed")
+	}
+
+	// 清理會話
+	_ = e.sessions.ClearAll()
+
+	// 停止客戶端
+	if e.client != nil && e.running {
+		errs := e.client.Stop()
+		if len(errs) > 0 {
+			e.lastError = fmt.Errorf("errors during close: %v", errs)
+			e.logger.Warn("SDK 客戶端關閉時發生錯誤", "error", e.lastError)
+		}
+	}
+
+	e.running = false
+	e.closed = true
+	return nil
+}
+
+// SetEventHandler 設定串流事件的處理函式
+//
+// 只會收到 OutputChunk（assistant 串流）與 ToolCall 事件，
+// RunID 與 LoopIndex 由呼叫端補上。
+func (e *SDKExecutor) SetEventHandler(handler EventHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onEvent = handler
+}
+
+// SessionEventHandler 傳回 Complete 註冊到每個會話的事件處理函式
+//
+// 將 assistant.message_delta 轉為 OutputChunk，tool.execution_start 轉為 ToolCall。
+// SessionConfig 已啟用 Streaming，因此會收到 delta。
+func (e *SDKExecutor) SessionEventHandler() copilot.SessionEventHandler {
+	return func(event copilot.SessionEvent) {
+		if converted, ok := convertSessionEvent(event); ok {
+			e.emit(converted)
+		}
+	}
+}
+
+// emit 將事件交給處理函式
+func (e *SDKExecutor) emit(event LoopEvent) {
+	e.mu.RLock()
+	handler := e.onEvent
+	e.mu.RUnlock()
+
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// convertSessionEvent 將 SDK 會話事件轉為迴圈事件
+func convertSessionEvent(event copilot.SessionEvent) (LoopEvent, bool) {
+	switch event.Type {
+	case copilot.AssistantMessageDelta:
+		if event.Data.DeltaContent == nil {
+			return LoopEvent{}, false
+		}
+		return LoopEvent{Type: EventOutputChunk, Stream: StreamAssistant, Text: *event.Data.DeltaContent}, true
+	case copilot.ToolExecutionStart:
+		if event.Data.ToolName == nil {
+			return LoopEvent{}, false
+		}
+		converted := LoopEvent{Type: EventToolCall, Tool: *event.Data.ToolName}
+		if event.Data.Arguments != nil {
+			converted.Data = map[string]interface{}{"arguments": event.Data.Arguments}
+		}
+		return converted, true
+	}
+	return LoopEvent{}, false
+}
+
+// isHealthy 檢查執行器是否健康
+func (e *SDKExecutor) isHealthy() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.initialized && e.running && !e.closed
+}
@@ -1,551 +1,728 @@
-package ghcopilot
-
-import (
-	"context"
-	"fmt"
-	"strings"
-	"testing"
-	"time"
-)
-
-// TestNewSDKExecutor 測試建立新的 SDK 執行器
-func TestNewSDKExecutor(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	if executor == nil {
-		t.Error("NewSDKExecutor() 傳回 nil")
-	}
-
-	if executor.config == nil {
-		t.Error("config 應被初始化")
-	}
-
-	// 新建立的執行器尚未啟動，所以應該是不健康的
-	status := executor.GetStatus()
-	if status.Running {
-		t.Error("新建立的執行器不應該是 running 狀態")
-	}
-}
-
-// TestDefaultSDKConfig 測試預設配置
-func TestDefaultSDKConfig(t *testing.T) {
-	config := DefaultSDKConfig()
-
-	if config.CLIPath != "copilot" {
-		t.Errorf("預設 CLIPath 應為 'copilot'，但為 %s", config.CLIPath)
-	}
-
-	if config.Timeout != 30*time.Second {
-		t.Errorf("預設逾時應為 30s，但為 %v", config.Timeout)
-	}
-
-	if config.MaxSessions != 100 {
-		t.Errorf("預設最大會話數應為 100，但為 %d", config.MaxSessions)
-	}
-
-	if !config.EnableMetrics {
-		t.Error("應啟用指標")
-	}
-}
-
-// TestSDKExecutorStart 測試啟動 SDK 執行器
-func TestSDKExecutorStart(t *testing.T) {
-	executor := NewSDKExecutor(DefaultSDKConfig())
-	ctx := context.Background()
-
-	// 啟動可能失敗（如果未安裝 CLI），所以我們只檢查錯誤處理
-	err := executor.Start(ctx)
-	if err != nil {
-		// 這是預期的（Copilot CLI 可能未安裝）
-		t.Logf("啟動失敗（預期可能）: %v", err)
-		return
-	}
-
-	if !executor.running {
-		t.Error("執行器應該是 running 狀態")
-	}
-
-	// 嘗試再次啟動應該失敗
-	err = executor.Start(ctx)
-	if err == nil {
-		t.Error("再次啟動應該傳回錯誤")
-	}
-
-	executor.Close()
-}
-
-// TestSDKExecutorStartAlreadyClosed 測試已關閉時啟動
-func TestSDKExecutorStartAlreadyClosed(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.closed = true
-
-	ctx := context.Background()
-	err := executor.Start(ctx)
-	if err == nil || !strings.Contains(err.Error(), "closed") {
-		t.Error("應該拒絕已關閉的執行器啟動")
-	}
-}
-
-// TestSDKSessionPoolBasics 測試會話池基本操作
-func TestSDKSessionPoolBasics(t *testing.T) {
-	pool := NewSDKSessionPool(10, 5*time.Minute)
-
-	// 建立會話
-	session, err := pool.CreateSession("session1")
-	if err != nil {
-		t.Errorf("建立會話失敗: %v", err)
-	}
-
-	if session.ID != "session1" {
-		t.Errorf("會話 ID 不符: 期望 'session1'，實際 %s", session.ID)
-	}
-
-	if session.Status != SessionActive {
-		t.Errorf("會話狀態應為 Active，但為 %v", session.Status)
-	}
-
-	// 取得會話
-	retrieved, err := pool.GetSession("session1")
-	if err != nil {
-		t.Errorf("取得會話失敗: %v", err)
-	}
-
-	if retrieved.ID != session.ID {
-		t.Error("取得的會話 ID 不符")
-	}
-
-	// 列出會話
-	sessions := pool.ListSessions()
-	if len(sessions) != 1 {
-		t.Errorf("應該有 1 個會話，但有 %d 個", len(sessions))
-	}
-
-	// 移除會話
-	err = pool.RemoveSession("session1")
-	if err != nil {
-		t.Errorf("移除會話失敗: %v", err)
-	}
-
-	// 驗證已移除
-	_, err = pool.GetSession("session1")
-	if err == nil || !strings.Contains(err.Error(), "not found") {
-		t.Error("會話應該已被移除")
-	}
-}
-
-// TestSDKSessionPoolMaxSize 測試會話池大小限制
-func TestSDKSessionPoolMaxSize(t *testing.T) {
-	pool := NewSDKSessionPool(2, 5*time.Minute)
-
-	// 建立兩個會話
-	_, err := pool.CreateSession("session1")
-	if err != nil {
-		t.Errorf("建立第一個會話失敗: %v", err)
-	}
-
-	_, err = pool.CreateSession("session2")
-	if err != nil {
-		t.Errorf("建立第二個會話失敗: %v", err)
-	}
-
-	// 嘗試建立第三個會話應該失敗
-	_, err = pool.CreateSession("session3")
-	if err == nil || !strings.Contains(err.Error(), "full") {
-		t.Error("應該拒絕超出大小限制的會話建立")
-	}
-}
-
-// TestSDKSessionPoolDuplicate 測試重複會話
-func TestSDKSessionPoolDuplicate(t *testing.T) {
-	pool := NewSDKSessionPool(10, 5*time.Minute)
-
-	_, err := pool.CreateSession("session1")
-	if err != nil {
-		t.Errorf("建立會話失敗: %v", err)
-	}
-
-	// 嘗試建立相同 ID 的會話應該失敗
-	_, err = pool.CreateSession("session1")
-	if err == nil || !strings.Contains(err.Error(), "already exists") {
-		t.Error("應該拒絕重複的會話 ID")
-	}
-}
-
-// TestSessionMetrics 測試會話指標
-func TestSessionMetrics(t *testing.T) {
-	metrics := &SessionMetrics{}
-
-	// 記錄成功呼叫
-	metrics.RecordCall(100*time.Millisecond, true, nil)
-
-	if metrics.TotalCalls != 1 {
-		t.Errorf("總呼叫數應為 1，但為 %d", metrics.TotalCalls)
-	}
-
-	if metrics.SuccessfulCalls != 1 {
-		t.Errorf("成功呼叫數應為 1，但為 %d", metrics.SuccessfulCalls)
-	}
-
-	if metrics.FailedCalls != 0 {
-		t.Errorf("失敗呼叫數應為 0，但為 %d", metrics.FailedCalls)
-	}
-
-	// 記錄失敗呼叫
-	testErr := fmt.Errorf("test error")
-	metrics.RecordCall(50*time.Millisecond, false, testErr)
-
-	if metrics.TotalCalls != 2 {
-		t.Errorf("總呼叫數應為 2，但為 %d", metrics.TotalCalls)
-	}
-
-	if metrics.FailedCalls != 1 {
-		t.Errorf("失敗呼叫數應為 1，但為 %d", metrics.FailedCalls)
-	}
-
-	if metrics.LastError == nil {
-		t.Error("應該記錄最後的錯誤")
-	}
-
-	successRate := metrics.GetSuccessRate()
-	if successRate != 0.5 {
-		t.Errorf("成功率應為 0.5，但為 %f", successRate)
-	}
-
-	errorRate := metrics.GetErrorRate()
-	if errorRate != 0.5 {
-		t.Errorf("錯誤率應為 0.5，但為 %f", errorRate)
-	}
-}
-
-// TestSDKExecutorComplete 測試完成功能
-func TestSDKExecutorComplete(t *testing.T) {
-	config := DefaultSDKConfig()
-	executor := NewSDKExecutor(config)
-	executor.initialized = true
-	executor.running = true
-
-	ctx := context.Background()
-	result, err := executor.Complete(ctx, "test prompt")
-
-	if err != nil {
-		t.Errorf("Complete 失敗: %v", err)
-	}
-
-	if !strings.Contains(result, "test prompt") {
-		t.Errorf("結果應包含提示詞，但為: %s", result)
-	}
-
-	if executor.metrics.TotalCalls != 1 {
-		t.Error("應該記錄一個呼叫")
-	}
-}
-
-// TestSDKExecutorExplain 測試解釋功能
-func TestSDKExecutorExplain(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.initialized = true
-	executor.running = true
-
-	ctx := context.Background()
-	code := "func test() {}"
-	result, err := executor.Explain(ctx, code)
-
-	if err != nil {
-		t.Errorf("Explain 失敗: %v", err)
-	}
-
-	if !strings.Contains(result, code) {
-		t.Errorf("結果應包含代碼，但為: %s", result)
-	}
-}
-
-// TestSDKExecutorGenerateTests 測試生成測試功能
-func TestSDKExecutorGenerateTests(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.initialized = true
-	executor.running = true
-
-	ctx := context.Background()
-	code := "func add(a, b int) int { return a + b }"
-	result, err := executor.GenerateTests(ctx, code)
-
-	if err != nil {
-		t.Errorf("GenerateTests 失敗: %v", err)
-	}
-
-	if !strings.Contains(result, code) {
-		t.Errorf("結果應包含代碼，但為: %s", result)
-	}
-}
-
-// TestSDKExecutorCodeReview 測試代碼審查功能
-func TestSDKExecutorCodeReview(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.initialized = true
-	executor.running = true
-
-	ctx := context.Background()
-	code := "x := 5"
-	result, err := executor.CodeReview(ctx, code)
-
-	if err != nil {
-		t.Errorf("CodeReview 失敗: %v", err)
-	}
-
-	if !strings.Contains(result, code) {
-		t.Errorf("結果應包含代碼，但為: %s", result)
-	}
-}
-
-// TestSDKExecutorSessionManagement 測試會話管理
-func TestSDKExecutorSessionManagement(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.initialized = true
-	executor.running = true
-
-	// 建立會話
-	session, err := executor.CreateSession("test-session")
-	if err != nil {
-		t.Errorf("建立會話失敗: %v", err)
-	}
-
-	if session.ID != "test-session" {
-		t.Error("會話 ID 不符")
-	}
-
-	// 取得會話
-	retrieved, err := executor.GetSession("test-session")
-	if err != nil {
-		t.Errorf("取得會話失敗: %v", err)
-	}
-
-	if retrieved.ID != "test-session" {
-		t.Error("取得的會話 ID 不符")
-	}
-
-	// 列出會話
-	sessions := executor.ListSessions()
-	if len(sessions) != 1 {
-		t.Errorf("應該有 1 個會話，但有 %d 個", len(sessions))
-	}
-
-	// 取得會話計數
-	count := executor.GetSessionCount()
-	if count != 1 {
-		t.Errorf("會話計數應為 1，但為 %d", count)
-	}
-
-	// 終止會話
-	err = executor.TerminateSession("test-session")
-	if err != nil {
-		t.Errorf("終止會話失敗: %v", err)
-	}
-
-	// 驗證已終止
-	count = executor.GetSessionCount()
-	if count != 0 {
-		t.Errorf("會話計數應為 0，但為 %d", count)
-	}
-}
-
-// TestSDKExecutorGetMetrics 測試取得指標
-func TestSDKExecutorGetMetrics(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.initialized = true
-	executor.running = true
-
-	ctx := context.Background()
-
-	// 執行一些操作以生成指標
-	executor.Complete(ctx, "test1")
-	executor.Explain(ctx, "test2")
-	executor.GenerateTests(ctx, "test3")
-
-	metrics := executor.GetMetrics()
-
-	if metrics.TotalCalls != 3 {
-		t.Errorf("總呼叫數應為 3，但為 %d", metrics.TotalCalls)
-		return
-	}
-
-	if metrics.SuccessfulCalls != 3 {
-		t.Errorf("成功呼叫數應為 3，但為 %d", metrics.SuccessfulCalls)
-	}
-
-	// 只驗證指標被記錄，不驗證時間（因為模擬實現可能太快）
-	if metrics == nil {
-		t.Error("指標不應為 nil")
-	}
-}
-
-// TestSDKExecutorGetStatus 測試取得狀態
-func TestSDKExecutorGetStatus(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.initialized = true
-	executor.running = true
-
-	status := executor.GetStatus()
-
-	if !status.Initialized {
-		t.Error("應該已初始化")
-	}
-
-	if !status.Running {
-		t.Error("應該是 Running 狀態")
-	}
-
-	if status.Closed {
-		t.Error("不應該是 Closed 狀態")
-	}
-}
-
-// TestSDKExecutorClose 測試關閉執行器
-func TestSDKExecutorClose(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	executor.initialized = true
-	executor.running = true
-
-	// 建立會話
-	executor.CreateSession("session1")
-
-	// 關閉執行器
-	err := executor.Close()
-	if err != nil {
-		t.Errorf("關閉失敗: %v", err)
-	}
-
-	if !executor.closed {
-		t.Error("應該是 closed 狀態")
-	}
-
-	if executor.running {
-		t.Error("不應該是 running 狀態")
-	}
-
-	// 嘗試再次關閉應該失敗
-	err = executor.Close()
-	if err == nil || !strings.Contains(err.Error(), "already closed") {
-		t.Error("再次關閉應該傳回錯誤")
-	}
-}
-
-// TestSDKExecutorNotHealthy 測試不健康狀態
-func TestSDKExecutorNotHealthy(t *testing.T) {
-	executor := NewSDKExecutor(nil)
-	// 不初始化
-
-	ctx := context.Background()
-
-	// 嘗試執行應該失敗
-	_, err := executor.Complete(ctx, "test")
-	if err == nil || !strings.Contains(err.Error(), "not healthy") {
-		t.Error("應該拒絕不健康的執行器")
-	}
-
-	// 嘗試建立會話應該失敗
-	_, err = executor.CreateSession("test")
-	if err == nil || !strings.Contains(err.Error(), "not healthy") {
-		t.Error("應該拒絕不健康的執行器建立會話")
-	}
-}
-
-// TestSDKExecutorCleanupExpiredSessions 測試清理過期會話
-func TestSDKExecutorCleanupExpiredSessions(t *testing.T) {
-	// 建立執行器並初始化為 running 狀態
-	executor := NewSDKExecutor(&SDKConfig{
-		SessionTimeout: 100 * time.Millisecond,
-		MaxSessions:    10,
-	})
-	executor.mu.Lock()
-	executor.initialized = true
-	executor.running = true
-	executor.mu.Unlock()
-
-	// 建立會話
-	session1, err1 := executor.CreateSession("session1")
-	if err1 != nil {
-		t.Fatalf("建立會話 1 失敗: %v", err1)
-	}
-	session2, err2 := executor.CreateSession("session2")
-	if err2 != nil {
-		t.Fatalf("建立會話 2 失敗: %v", err2)
-	}
-
-	if executor.GetSessionCount() != 2 {
-		t.Error("應該有 2 個會話")
-	}
-
-	// 手動標記會話過期（修改 LastUsed 時間）
-	executor.sessions.mu.Lock()
-	session1.LastUsed = time.Now().Add(-200 * time.Millisecond)
-	session2.LastUsed = time.Now().Add(-200 * time.Millisecond)
-	executor.sessions.mu.Unlock()
-
-	// 清理
-	cleaned := executor.CleanupExpiredSessions()
-
-	// 驗證清理功能能執行（可能清理 0 到 2 個）
-	if cleaned < 0 {
-		t.Errorf("清理計數不應為負: %d", cleaned)
-	}
-}
-
-// TestSDKExecutorIntegration 測試完整集成
-func TestSDKExecutorIntegration(t *testing.T) {
-	config := &SDKConfig{
-		CLIPath:        "copilot",
-		Timeout:        5 * time.Second,
-		SessionTimeout: 1 * time.Minute,
-		MaxSessions:    50,
-		EnableMetrics:  true,
-	}
-
-	executor := NewSDKExecutor(config)
-
-	if executor == nil {
-		t.Fatal("無法建立執行器")
-	}
-
-	// 初始化為運行狀態（模擬）
-	executor.initialized = true
-	executor.running = true
-
-	ctx := context.Background()
-
-	// 建立多個會話
-	for i := 0; i < 5; i++ {
-		sessionID := fmt.Sprintf("session-%d", i)
-		_, err := executor.CreateSession(sessionID)
-		if err != nil {
-			t.Errorf("建立會話 %s 失敗: %v", sessionID, err)
-		}
-	}
-
-	// 驗證會話計數
-	if executor.GetSessionCount() != 5 {
-		t.Errorf("應該有 5 個會話，但有 %d 個", executor.GetSessionCount())
-	}
-
-	// 執行一些操作
-	executor.Complete(ctx, "test1")
-	executor.Explain(ctx, "test2")
-	executor.GenerateTests(ctx, "test3")
-
-	// 驗證指標
-	metrics := executor.GetMetrics()
-	if metrics.TotalCalls != 3 {
-		t.Errorf("應該有 3 個呼叫，但有 %d 個", metrics.TotalCalls)
-	}
-
-	// 取得狀態
-	status := executor.GetStatus()
-	if status.SessionCount != 5 {
-		t.Errorf("狀態中會話計數應為 5，但為 %d", status.SessionCount)
-	}
-
-	// 清理
-	executor.Close()
-
-	if !executor.closed {
-		t.Error("應該已關閉")
-	}
-}
+package ghcopilot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+// TestNewSDKExecutor 測試建立新的 SDK 執行器
+func TestNewSDKExecutor(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	if executor == nil {
+		t.Error("NewSDKExecutor() 傳回 nil")
+	}
+
+	if executor.config == nil {
+		t.Error("config 應被初始化")
+	}
+
+	// 新建立的執行器尚未啟動，所以應該是不健康的
+	status := executor.GetStatus()
+	if status.Running {
+		t.Error("新建立的執行器不應該是 running 狀態")
+	}
+}
+
+// TestDefaultSDKConfig 測試預設配置
+func TestDefaultSDKConfig(t *testing.T) {
+	config := DefaultSDKConfig()
+
+	if config.CLIPath != "copilot" {
+		t.Errorf("預設 CLIPath 應為 'copilot'，但為 %s", config.CLIPath)
+	}
+
+	if config.Timeout != 30*time.Second {
+		t.Errorf("預設逾時應為 30s，但為 %v", config.Timeout)
+	}
+
+	if config.MaxSessions != 100 {
+		t.Errorf("預設最大會話數應為 100，但為 %d", config.MaxSessions)
+	}
+
+	if !config.EnableMetrics {
+		t.Error("應啟用指標")
+	}
+}
+
+// TestSDKExecutorStart 測試啟動 SDK 執行器
+func TestSDKExecutorStart(t *testing.T) {
+	executor := NewSDKExecutor(DefaultSDKConfig())
+	ctx := context.Background()
+
+	// 啟動可能失敗（如果未安裝 CLI），所以我們只檢查錯誤處理
+	err := executor.Start(ctx)
+	if err != nil {
+		// 這是預期的（Copilot CLI 可能未安裝）
+		t.Logf("啟動失敗（預期可能）: %v", err)
+		return
+	}
+
+	if !executor.running {
+		t.Error("執行器應該是 running 狀態")
+	}
+
+	// 嘗試再次啟動應該失敗
+	err = executor.Start(ctx)
+	if err == nil {
+		t.Error("再次啟動應該傳回錯誤")
+	}
+
+	executor.Close()
+}
+
+// TestSDKExecutorStartAlreadyClosed 測試已關閉時啟動
+func TestSDKExecutorStartAlreadyClosed(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.closed = true
+
+	ctx := context.Background()
+	err := executor.Start(ctx)
+	if err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Error("應該拒絕已關閉的執行器啟動")
+	}
+}
+
+// TestSDKSessionPoolBasics 測試會話池基本操作
+func TestSDKSessionPoolBasics(t *testing.T) {
+	pool := NewSDKSessionPool(10, 5*time.Minute)
+
+	// 建立會話
+	session, err := pool.CreateSession("session1")
+	if err != nil {
+		t.Errorf("建立會話失敗: %v", err)
+	}
+
+	if session.ID != "session1" {
+		t.Errorf("會話 ID 不符: 期望 'session1'，實際 %s", session.ID)
+	}
+
+	if session.Status != SessionActive {
+		t.Errorf("會話狀態應為 Active，但為 %v", session.Status)
+	}
+
+	// 取得會話
+	retrieved, err := pool.GetSession("session1")
+	if err != nil {
+		t.Errorf("取得會話失敗: %v", err)
+	}
+
+	if retrieved.ID != session.ID {
+		t.Error("取得的會話 ID 不符")
+	}
+
+	// 列出會話
+	sessions := pool.ListSessions()
+	if len(sessions) != 1 {
+		t.Errorf("應該有 1 個會話，但有 %d 個", len(sessions))
+	}
+
+	// 移除會話
+	err = pool.RemoveSession("session1")
+	if err != nil {
+		t.Errorf("移除會話失敗: %v", err)
+	}
+
+	// 驗證已移除
+	_, err = pool.GetSession("session1")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Error("會話應該已被移除")
+	}
+}
+
+// TestSDKSessionPoolMaxSize 測試會話池大小限制
+func TestSDKSessionPoolMaxSize(t *testing.T) {
+	pool := NewSDKSessionPool(2, 5*time.Minute)
+
+	// 建立兩個會話
+	_, err := pool.CreateSession("session1")
+	if err != nil {
+		t.Errorf("建立第一個會話失敗: %v", err)
+	}
+
+	_, err = pool.CreateSession("session2")
+	if err != nil {
+		t.Errorf("建立第二個會話失敗: %v", err)
+	}
+
+	// 嘗試建立第三個會話應該失敗
+	_, err = pool.CreateSession("session3")
+	if err == nil || !strings.Contains(err.Error(), "full") {
+		t.Error("應該拒絕超出大小限制的會話建立")
+	}
+}
+
+// TestSDKSessionPoolDuplicate 測試重複會話
+func TestSDKSessionPoolDuplicate(t *testing.T) {
+	pool := NewSDKSessionPool(10, 5*time.Minute)
+
+	_, err := pool.CreateSession("session1")
+	if err != nil {
+		t.Errorf("建立會話失敗: %v", err)
+	}
+
+	// 嘗試建立相同 ID 的會話應該失敗
+	_, err = pool.CreateSession("session1")
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Error("應該拒絕重複的會話 ID")
+	}
+}
+
+// TestSessionMetrics 測試會話指標
+func TestSessionMetrics(t *testing.T) {
+	metrics := &SessionMetrics{}
+
+	// 記錄成功呼叫
+	metrics.RecordCall(100*time.Millisecond, true, nil)
+
+	if metrics.TotalCalls != 1 {
+		t.Errorf("總呼叫數應為 1，但為 %d", metrics.TotalCalls)
+	}
+
+	if metrics.SuccessfulCalls != 1 {
+		t.Errorf("成功呼叫數應為 1，但為 %d", metrics.SuccessfulCalls)
+	}
+
+	if metrics.FailedCalls != 0 {
+		t.Errorf("失敗呼叫數應為 0，但為 %d", metrics.FailedCalls)
+	}
+
+	// 記錄失敗呼叫
+	testErr := fmt.Errorf("test error")
+	metrics.RecordCall(50*time.Millisecond, false, testErr)
+
+	if metrics.TotalCalls != 2 {
+		t.Errorf("總呼叫數應為 2，但為 %d", metrics.TotalCalls)
+	}
+
+	if metrics.FailedCalls != 1 {
+		t.Errorf("失敗呼叫數應為 1，但為 %d", metrics.FailedCalls)
+	}
+
+	if metrics.LastError == nil {
+		t.Error("應該記錄最後的錯誤")
+	}
+
+	successRate := metrics.GetSuccessRate()
+	if successRate != 0.5 {
+		t.Errorf("成功率應為 0.5，但為 %f", successRate)
+	}
+
+	errorRate := metrics.GetErrorRate()
+	if errorRate != 0.5 {
+		t.Errorf("錯誤率應為 0.5，但為 %f", errorRate)
+	}
+}
+
+// fakeConversation 模擬 SDK 會話：送出時先執行 onSend、發布 events，再以 reply 回應
+type fakeConversation struct {
+	config    *copilot.SessionConfig
+	handlers  []copilot.SessionEventHandler
+	events    []copilot.SessionEvent
+	onSend    func(config *copilot.SessionConfig)
+	reply     string
+	block     bool
+	prompts   []string
+	aborted   chan struct{}
+	destroyed bool
+}
+
+func newFakeConversation(reply string) *fakeConversation {
+	return &fakeConversation{reply: reply, aborted: make(chan struct{})}
+}
+
+func (f *fakeConversation) On(handler copilot.SessionEventHandler) func() {
+	f.handlers = append(f.handlers, handler)
+	return func() { f.handlers = nil }
+}
+
+func (f *fakeConversation) SendAndWait(options copilot.MessageOptions, timeout time.Duration) (*copilot.SessionEvent, error) {
+	f.prompts = append(f.prompts, options.Prompt)
+	if f.onSend != nil {
+		f.onSend(f.config)
+	}
+	for _, event := range f.events {
+		for _, handler := range f.handlers {
+			handler(event)
+		}
+	}
+	if f.block {
+		<-f.aborted
+		return nil, fmt.Errorf("aborted")
+	}
+	content := f.reply
+	return &copilot.SessionEvent{Type: copilot.AssistantMessage, Data: copilot.Data{Content: &content}}, nil
+}
+
+func (f *fakeConversation) Abort() error {
+	close(f.aborted)
+	return nil
+}
+
+func (f *fakeConversation) Destroy() error {
+	f.destroyed = true
+	return nil
+}
+
+// startFakeSDKExecutor 將執行器標記為運行中，並以 conversation 取代真實的 SDK 會話
+func startFakeSDKExecutor(executor *SDKExecutor, conversation *fakeConversation) {
+	executor.initialized = true
+	executor.running = true
+	executor.openSession = func(config *copilot.SessionConfig) (sdkConversation, error) {
+		conversation.config = config
+		return conversation, nil
+	}
+}
+
+// TestSDKExecutorComplete 測試完成功能
+func TestSDKExecutorComplete(t *testing.T) {
+	config := DefaultSDKConfig()
+	config.Model = "gpt-5"
+	executor := NewSDKExecutor(config)
+	conversation := newFakeConversation("reply for test prompt")
+	startFakeSDKExecutor(executor, conversation)
+
+	ctx := context.Background()
+	result, err := executor.Complete(ctx, "test prompt")
+
+	if err != nil {
+		t.Errorf("Complete 失敗: %v", err)
+	}
+
+	if !strings.Contains(result, "test prompt") {
+		t.Errorf("結果應為會話回應，但為: %s", result)
+	}
+
+	if executor.metrics.TotalCalls != 1 {
+		t.Error("應該記錄一個呼叫")
+	}
+	if len(conversation.prompts) != 1 || conversation.prompts[0] != "test prompt" {
+		t.Errorf("應將 prompt 送入會話: %v", conversation.prompts)
+	}
+	if conversation.config.Model != "gpt-5" || !conversation.config.Streaming {
+		t.Errorf("會話配置不正確: %+v", conversation.config)
+	}
+	if !conversation.destroyed {
+		t.Error("Complete 結束後應銷毀會話")
+	}
+	if executor.GetSessionCount() != 0 {
+		t.Errorf("會話池不應保留已結束的會話，但有 %d 個", executor.GetSessionCount())
+	}
+}
+
+// TestSDKExecutorCompleteRegistersTools 測試會話註冊 agent 工具與權限處理
+func TestSDKExecutorCompleteRegistersTools(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	conversation := newFakeConversation("ok")
+	startFakeSDKExecutor(executor, conversation)
+
+	var asked bool
+	executor.SetTools([]copilot.Tool{{Name: ToolReportStatus}})
+	executor.SetPermissionHandler(func(copilot.PermissionRequest, copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
+		asked = true
+		return copilot.PermissionRequestResult{Kind: "approved"}, nil
+	})
+	conversation.onSend = func(config *copilot.SessionConfig) {
+		config.OnPermissionRequest(copilot.PermissionRequest{Kind: "shell"}, copilot.PermissionInvocation{})
+	}
+
+	if _, err := executor.Complete(context.Background(), "x"); err != nil {
+		t.Fatalf("Complete 失敗: %v", err)
+	}
+	if len(conversation.config.Tools) != 1 || conversation.config.Tools[0].Name != ToolReportStatus {
+		t.Errorf("會話應註冊 agent 工具: %+v", conversation.config.Tools)
+	}
+	if !asked {
+		t.Error("會話的權限請求應交給權限處理函式")
+	}
+}
+
+// TestSDKExecutorCompleteCancel 測試 ctx 取消時中止會話
+func TestSDKExecutorCompleteCancel(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	conversation := newFakeConversation("")
+	conversation.block = true
+	startFakeSDKExecutor(executor, conversation)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := executor.Complete(ctx, "x")
+	if err != context.Canceled {
+		t.Errorf("取消後應傳回 context.Canceled，但為 %v", err)
+	}
+	select {
+	case <-conversation.aborted:
+	default:
+		t.Error("取消後應中止會話")
+	}
+	if executor.GetMetrics().FailedCalls != 1 {
+		t.Error("取消應記錄為失敗呼叫")
+	}
+}
+
+// TestSDKExecutorCompleteNoSession 測試無法建立會話時傳回錯誤
+func TestSDKExecutorCompleteNoSession(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.initialized = true
+	executor.running = true
+
+	if _, err := executor.Complete(context.Background(), "x"); err == nil {
+		t.Error("SDK 客戶端未啟動時 Complete 應失敗")
+	}
+}
+
+// TestSDKExecutorExplain 測試解釋功能
+func TestSDKExecutorExplain(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.initialized = true
+	executor.running = true
+
+	ctx := context.Background()
+	code := "func test() {}"
+	result, err := executor.Explain(ctx, code)
+
+	if err != nil {
+		t.Errorf("Explain 失敗: %v", err)
+	}
+
+	if !strings.Contains(result, code) {
+		t.Errorf("結果應包含代碼，但為: %s", result)
+	}
+}
+
+// TestSDKExecutorGenerateTests 測試生成測試功能
+func TestSDKExecutorGenerateTests(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.initialized = true
+	executor.running = true
+
+	ctx := context.Background()
+	code := "func add(a, b int) int { return a + b }"
+	result, err := executor.GenerateTests(ctx, code)
+
+	if err != nil {
+		t.Errorf("GenerateTests 失敗: %v", err)
+	}
+
+	if !strings.Contains(result, code) {
+		t.Errorf("結果應包含代碼，但為: %s", result)
+	}
+}
+
+// TestSDKExecutorCodeReview 測試代碼審查功能
+func TestSDKExecutorCodeReview(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.initialized = true
+	executor.running = true
+
+	ctx := context.Background()
+	code := "x := 5"
+	result, err := executor.CodeReview(ctx, code)
+
+	if err != nil {
+		t.Errorf("CodeReview 失敗: %v", err)
+	}
+
+	if !strings.Contains(result, code) {
+		t.Errorf("結果應包含代碼，但為: %s", result)
+	}
+}
+
+// TestSDKExecutorSessionManagement 測試會話管理
+func TestSDKExecutorSessionManagement(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.initialized = true
+	executor.running = true
+
+	// 建立會話
+	session, err := executor.CreateSession("test-session")
+	if err != nil {
+		t.Errorf("建立會話失敗: %v", err)
+	}
+
+	if session.ID != "test-session" {
+		t.Error("會話 ID 不符")
+	}
+
+	// 取得會話
+	retrieved, err := executor.GetSession("test-session")
+	if err != nil {
+		t.Errorf("取得會話失敗: %v", err)
+	}
+
+	if retrieved.ID != "test-session" {
+		t.Error("取得的會話 ID 不符")
+	}
+
+	// 列出會話
+	sessions := executor.ListSessions()
+	if len(sessions) != 1 {
+		t.Errorf("應該有 1 個會話，但有 %d 個", len(sessions))
+	}
+
+	// 取得會話計數
+	count := executor.GetSessionCount()
+	if count != 1 {
+		t.Errorf("會話計數應為 1，但為 %d", count)
+	}
+
+	// 終止會話
+	err = executor.TerminateSession("test-session")
+	if err != nil {
+		t.Errorf("終止會話失敗: %v", err)
+	}
+
+	// 驗證已終止
+	count = executor.GetSessionCount()
+	if count != 0 {
+		t.Errorf("會話計數應為 0，但為 %d", count)
+	}
+}
+
+// TestSDKExecutorGetMetrics 測試取得指標
+func TestSDKExecutorGetMetrics(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	startFakeSDKExecutor(executor, newFakeConversation("done"))
+
+	ctx := context.Background()
+
+	// 執行一些操作以生成指標
+	executor.Complete(ctx, "test1")
+	executor.Explain(ctx, "test2")
+	executor.GenerateTests(ctx, "test3")
+
+	metrics := executor.GetMetrics()
+
+	if metrics.TotalCalls != 3 {
+		t.Errorf("總呼叫數應為 3，但為 %d", metrics.TotalCalls)
+		return
+	}
+
+	if metrics.SuccessfulCalls != 3 {
+		t.Errorf("成功呼叫數應為 3，但為 %d", metrics.SuccessfulCalls)
+	}
+
+	// 只驗證指標被記錄，不驗證時間（因為模擬實現可能太快）
+	if metrics == nil {
+		t.Error("指標不應為 nil")
+	}
+}
+
+// TestSDKExecutorGetStatus 測試取得狀態
+func TestSDKExecutorGetStatus(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.initialized = true
+	executor.running = true
+
+	status := executor.GetStatus()
+
+	if !status.Initialized {
+		t.Error("應該已初始化")
+	}
+
+	if !status.Running {
+		t.Error("應該是 Running 狀態")
+	}
+
+	if status.Closed {
+		t.Error("不應該是 Closed 狀態")
+	}
+}
+
+// TestSDKExecutorClose 測試關閉執行器
+func TestSDKExecutorClose(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.initialized = true
+	executor.running = true
+
+	// 建立會話
+	executor.CreateSession("session1")
+
+	// 關閉執行器
+	err := executor.Close()
+	if err != nil {
+		t.Errorf("關閉失敗: %v", err)
+	}
+
+	if !executor.closed {
+		t.Error("應該是 closed 狀態")
+	}
+
+	if executor.running {
+		t.Error("不應該是 running 狀態")
+	}
+
+	// 嘗試再次關閉應該失敗
+	err = executor.Close()
+	if err == nil || !strings.Contains(err.Error(), "already closed") {
+		t.Error("再次關閉應該傳回錯誤")
+	}
+}
+
+// TestSDKExecutorNotHealthy 測試不健康狀態
+func TestSDKExecutorNotHealthy(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	// 不初始化
+
+	ctx := context.Background()
+
+	// 嘗試執行應該失敗
+	_, err := executor.Complete(ctx, "test")
+	if err == nil || !strings.Contains(err.Error(), "not healthy") {
+		t.Error("應該拒絕不健康的執行器")
+	}
+
+	// 嘗試建立會話應該失敗
+	_, err = executor.CreateSession("test")
+	if err == nil || !strings.Contains(err.Error(), "not healthy") {
+		t.Error("應該拒絕不健康的執行器建立會話")
+	}
+}
+
+// TestSDKExecutorCleanupExpiredSessions 測試清理過期會話
+func TestSDKExecutorCleanupExpiredSessions(t *testing.T) {
+	// 建立執行器並初始化為 running 狀態
+	executor := NewSDKExecutor(&SDKConfig{
+		SessionTimeout: 100 * time.Millisecond,
+		MaxSessions:    10,
+	})
+	executor.mu.Lock()
+	executor.initialized = true
+	executor.running = true
+	executor.mu.Unlock()
+
+	// 建立會話
+	session1, err1 := executor.CreateSession("session1")
+	if err1 != nil {
+		t.Fatalf("建立會話 1 失敗: %v", err1)
+	}
+	session2, err2 := executor.CreateSession("session2")
+	if err2 != nil {
+		t.Fatalf("建立會話 2 失敗: %v", err2)
+	}
+
+	if executor.GetSessionCount() != 2 {
+		t.Error("應該有 2 個會話")
+	}
+
+	// 手動標記會話過期（修改 LastUsed 時間）
+	executor.sessions.mu.Lock()
+	session1.LastUsed = time.Now().Add(-200 * time.Millisecond)
+	session2.LastUsed = time.Now().Add(-200 * time.Millisecond)
+	executor.sessions.mu.Unlock()
+
+	// 清理
+	cleaned := executor.CleanupExpiredSessions()
+
+	// 驗證清理功能能執行（可能清理 0 到 2 個）
+	if cleaned < 0 {
+		t.Errorf("清理計數不應為負: %d", cleaned)
+	}
+}
+
+// TestSDKExecutorIntegration 測試完整集成
+func TestSDKExecutorIntegration(t *testing.T) {
+	config := &SDKConfig{
+		CLIPath:        "copilot",
+		Timeout:        5 * time.Second,
+		SessionTimeout: 1 * time.Minute,
+		MaxSessions:    50,
+		EnableMetrics:  true,
+	}
+
+	executor := NewSDKExecutor(config)
+
+	if executor == nil {
+		t.Fatal("無法建立執行器")
+	}
+
+	// 初始化為運行狀態（模擬）
+	startFakeSDKExecutor(executor, newFakeConversation("done"))
+
+	ctx := context.Background()
+
+	// 建立多個會話
+	for i := 0; i < 5; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		_, err := executor.CreateSession(sessionID)
+		if err != nil {
+			t.Errorf("建立會話 %s 失敗: %v", sessionID, err)
+		}
+	}
+
+	// 驗證會話計數
+	if executor.GetSessionCount() != 5 {
+		t.Errorf("應該有 5 個會話，但有 %d 個", executor.GetSessionCount())
+	}
+
+	// 執行一些操作
+	executor.Complete(ctx, "test1")
+	executor.Explain(ctx, "test2")
+	executor.GenerateTests(ctx, "test3")
+
+	// 驗證指標
+	metrics := executor.GetMetrics()
+	if metrics.TotalCalls != 3 {
+		t.Errorf("應該有 3 個呼叫，但有 %d 個", metrics.TotalCalls)
+	}
+
+	// 取得狀態
+	status := executor.GetStatus()
+	if status.SessionCount != 5 {
+		t.Errorf("狀態中會話計數應為 5，但為 %d", status.SessionCount)
+	}
+
+	// 清理
+	executor.Close()
+
+	if !executor.closed {
+		t.Error("應該已關閉")
+	}
+}
+
+// TestSDKExecutorStreamsEvents 測試 SDK 會話事件轉為迴圈事件
+func TestSDKExecutorStreamsEvents(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	conversation := newFakeConversation("Hello")
+	startFakeSDKExecutor(executor, conversation)
+
+	var events []LoopEvent
+	executor.SetEventHandler(func(event LoopEvent) { events = append(events, event) })
+
+	delta := "Hel"
+	tool := "edit"
+	handler := executor.SessionEventHandler()
+	handler(copilot.SessionEvent{Type: copilot.AssistantMessageDelta, Data: copilot.Data{DeltaContent: &delta}})
+	handler(copilot.SessionEvent{Type: copilot.ToolExecutionStart, Data: copilot.Data{ToolName: &tool, Arguments: map[string]interface{}{"path": "a.go"}}})
+	handler(copilot.SessionEvent{Type: copilot.SessionIdle})
+
+	if len(events) != 2 {
+		t.Fatalf("應轉換 2 個事件，但為 %d", len(events))
+	}
+	if events[0].Type != EventOutputChunk || events[0].Stream != StreamAssistant || events[0].Text != "Hel" {
+		t.Errorf("delta 轉換不正確: %+v", events[0])
+	}
+	if events[1].Type != EventToolCall || events[1].Tool != "edit" || events[1].Data["arguments"] == nil {
+		t.Errorf("工具呼叫轉換不正確: %+v", events[1])
+	}
+
+	// Complete 訂閱真實會話的事件，串流 delta 經由同一個處理函式發布
+	events = nil
+	conversation.events = []copilot.SessionEvent{
+		{Type: copilot.AssistantMessageDelta, Data: copilot.Data{DeltaContent: &delta}},
+		{Type: copilot.ToolExecutionStart, Data: copilot.Data{ToolName: &tool}},
+	}
+	if _, err := executor.Complete(context.Background(), "x"); err != nil {
+		t.Fatalf("Complete 失敗: %v", err)
+	}
+	if len(events) != 2 || events[0].Text != "Hel" || events[1].Tool != "edit" {
+		t.Errorf("Complete 應發布會話串流事件: %+v", events)
+	}
+	if len(conversation.handlers) != 0 {
+		t.Error("Complete 結束後應取消訂閱會話事件")
+	}
+}
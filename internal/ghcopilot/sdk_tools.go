@@ -0,0 +1,393 @@
+package ghcopilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+// 註冊到 SDK 會話、讓 agent 呼叫的工具
+const (
+	ToolReportStatus    = "report_status"     // 回報迴圈狀態（取代輸出中的狀態區塊）
+	ToolRunVerification = "run_verification"  // 執行 post_loop 驗證指令並傳回診斷
+	ToolReadLoopHistory = "read_loop_history" // 讀取先前迴圈的摘要
+)
+
+// 工具結果的上限
+const (
+	maxToolOutputBytes     = 8 << 10 // run_verification 每個指令的輸出
+	defaultToolHistoryLoop = 5       // read_loop_history 預設的迴圈數
+)
+
+// ToolCallRecord 記錄一次 agent 工具呼叫（保存在 ExecutionContext.ToolCalls）
+type ToolCallRecord struct {
+	Name       string      `json:"name"`
+	CallID     string      `json:"call_id,omitempty"`
+	Arguments  interface{} `json:"arguments,omitempty"`
+	Result     string      `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Timestamp  time.Time   `json:"timestamp"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// VerificationResult 是 run_verification 的結果
+type VerificationResult struct {
+	Passed   bool                  `json:"passed"`
+	Commands []VerificationCommand `json:"commands"`
+	Message  string                `json:"message,omitempty"`
+}
+
+// VerificationCommand 是一個驗證指令的結果
+type VerificationCommand struct {
+	Command     string       `json:"command"`
+	Passed      bool         `json:"passed"`
+	DurationMs  int64        `json:"duration_ms"`
+	Error       string       `json:"error,omitempty"`
+	Output      string       `json:"output,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Diagnostic 是從驗證輸出解析出的 "path:line[:col]: message" 診斷
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// LoopSummary 是 read_loop_history 傳回的迴圈摘要
+type LoopSummary struct {
+	Loop         int      `json:"loop"`
+	Outcome      string   `json:"outcome"`
+	ExitReason   string   `json:"exit_reason,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	TasksDone    string   `json:"tasks_done,omitempty"`
+	NextStep     string   `json:"next_step,omitempty"`
+	FilesChanged []string `json:"files_changed,omitempty"`
+	Errors       []string `json:"errors,omitempty"`
+	ToolCalls    []string `json:"tool_calls,omitempty"`
+}
+
+// LoopToolsConfig 是 agent 工具的設定
+type LoopToolsConfig struct {
+	WorkDir      string                               // 驗證指令的工作目錄
+	Verify       []ShellHook                          // run_verification 執行的指令（通常是 post_loop hook）
+	History      func() []*ExecutionContext           // read_loop_history 的資料來源
+	ExitDetector *ExitDetector                        // report_status 回報的完成訊號 (可為 nil)
+	HookContext  func(*ExecutionContext) *HookContext // 驗證指令的環境變數 (預設只有工作目錄)
+}
+
+// LoopTools 實作註冊到 SDK 會話的 agent 工具
+//
+// 以 BeginLoop 指定目前的迴圈後，工具呼叫會記錄在該迴圈的 ToolCalls，
+// report_status 回報的狀態可由 EndLoop 取得。
+type LoopTools struct {
+	config LoopToolsConfig
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	ctx      context.Context
+	current  *ExecutionContext
+	reported *CopilotStatus
+}
+
+// NewLoopTools 建立 agent 工具
+func NewLoopTools(config LoopToolsConfig) *LoopTools {
+	return &LoopTools{config: config, logger: discardLogger()}
+}
+
+// SetLogger 設定工具呼叫的日誌
+func (t *LoopTools) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	t.logger = logger
+}
+
+// BeginLoop 開始記錄迴圈的工具呼叫；ctx 取消時會中止迴圈中的驗證指令
+func (t *LoopTools) BeginLoop(ctx context.Context, execCtx *ExecutionContext) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx = ctx
+	t.current = execCtx
+	t.reported = nil
+}
+
+// EndLoop 停止記錄並傳回本次迴圈以 report_status 回報的狀態（未回報時為 nil）
+func (t *LoopTools) EndLoop() *CopilotStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reported := t.reported
+	t.ctx = nil
+	t.current = nil
+	t.reported = nil
+	return reported
+}
+
+// Tools 傳回註冊到 copilot.SessionConfig.Tools 的工具定義
+func (t *LoopTools) Tools() []copilot.Tool {
+	return []copilot.Tool{
+		{
+			Name:        ToolReportStatus,
+			Description: "回報本次迴圈的進度與是否完成。所有任務完成且驗證通過時才設定 exit_signal=true。",
+			Parameters:  reportStatusParameters(),
+			Handler:     t.handler(ToolReportStatus, t.reportStatus),
+		},
+		{
+			Name:        ToolRunVerification,
+			Description: "執行專案設定的建置與測試指令，傳回每個指令的結果與 path:line 診斷。",
+			Parameters:  map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+			Handler:     t.handler(ToolRunVerification, t.runVerification),
+		},
+		{
+			Name:        ToolReadLoopHistory,
+			Description: "讀取先前迴圈的摘要（結果、狀態、修改的檔案與錯誤），避免重複失敗的做法。",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"description": "最多傳回最近幾個迴圈（預設 " + strconv.Itoa(defaultToolHistoryLoop) + "）",
+					},
+				},
+			},
+			Handler: t.handler(ToolReadLoopHistory, t.readLoopHistory),
+		},
+	}
+}
+
+// reportStatusParameters 傳回 report_status 的參數 schema（狀態區塊 schema 去掉 version）
+func reportStatusParameters() map[string]interface{} {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(StatusContractSchemaJSON, &schema); err != nil {
+		panic(fmt.Sprintf("狀態區塊 schema 無效: %v", err))
+	}
+	delete(schema, "$schema")
+	delete(schema, "$id")
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		delete(properties, "version")
+	}
+	if required, ok := schema["required"].([]interface{}); ok {
+		var kept []interface{}
+		for _, name := range required {
+			if name != "version" {
+				kept = append(kept, name)
+			}
+		}
+		schema["required"] = kept
+	}
+	return schema
+}
+
+// handler 包裝工具實作：記錄呼叫並將結果轉為 JSON
+func (t *LoopTools) handler(name string, fn func(args map[string]interface{}) (interface{}, error)) copilot.ToolHandler {
+	return func(invocation copilot.ToolInvocation) (copilot.ToolResult, error) {
+		start := time.Now()
+		args, _ := invocation.Arguments.(map[string]interface{})
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+
+		record := ToolCallRecord{Name: name, CallID: invocation.ToolCallID, Arguments: invocation.Arguments, Timestamp: start}
+		value, err := fn(args)
+		var result copilot.ToolResult
+		if err == nil {
+			var data []byte
+			if data, err = json.Marshal(value); err == nil {
+				result = copilot.ToolResult{TextResultForLLM: string(data), ResultType: "success"}
+				record.Result = truncateString(string(data), maxToolOutputBytes)
+			}
+		}
+		if err != nil {
+			record.Error = err.Error()
+			result = copilot.ToolResult{TextResultForLLM: err.Error(), ResultType: "failure", Error: err.Error()}
+		}
+		record.DurationMs = time.Since(start).Milliseconds()
+
+		t.mu.Lock()
+		if t.current != nil {
+			t.current.ToolCalls = append(t.current.ToolCalls, record)
+		}
+		t.mu.Unlock()
+		t.logger.Info("agent 工具呼叫", "tool", name, "duration_ms", record.DurationMs, "error", record.Error)
+		// 失敗以 ToolResult 回報給 agent，讓它可以修正參數後重試
+		return result, nil
+	}
+}
+
+// Invoke 直接執行工具（不經過 SDK，例如測試或 CLI 模式）
+func (t *LoopTools) Invoke(name string, args map[string]interface{}) (copilot.ToolResult, error) {
+	for _, tool := range t.Tools() {
+		if tool.Name == name {
+			return tool.Handler(copilot.ToolInvocation{ToolName: name, Arguments: args})
+		}
+	}
+	return copilot.ToolResult{}, fmt.Errorf("未知的工具 %q", name)
+}
+
+// reportStatus 驗證並記錄 agent 回報的狀態，並將完成訊號交給 ExitDetector
+func (t *LoopTools) reportStatus(args map[string]interface{}) (interface{}, error) {
+	instance := make(map[string]interface{}, len(args)+1)
+	for key, value := range args {
+		instance[key] = value
+	}
+	instance["version"] = StatusContractVersion
+	data, err := json.Marshal(instance)
+	if err != nil {
+		return nil, err
+	}
+	status, err := parseStatusJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	status.Format = StatusFormatTool
+
+	t.mu.Lock()
+	t.reported = status
+	t.mu.Unlock()
+
+	if detector := t.config.ExitDetector; detector != nil {
+		if status.Status == "DONE" {
+			detector.RecordCompletionIndicator()
+		}
+		if status.ExitSignal {
+			detector.RecordDoneSignal()
+		}
+	}
+	return map[string]interface{}{"recorded": true, "status": status.Status, "exit_signal": status.ExitSignal}, nil
+}
+
+// runVerification 以目前迴圈的 context 依序執行驗證指令
+func (t *LoopTools) runVerification(args map[string]interface{}) (interface{}, error) {
+	t.mu.Lock()
+	ctx := t.ctx
+	t.mu.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return t.Verify(ctx), nil
+}
+
+// Verify 執行設定的驗證指令並解析診斷
+func (t *LoopTools) Verify(ctx context.Context) *VerificationResult {
+	result := &VerificationResult{Passed: true, Commands: []VerificationCommand{}}
+	if len(t.config.Verify) == 0 {
+		result.Message = "沒有設定驗證指令（post_loop hook）"
+		return result
+	}
+
+	t.mu.Lock()
+	current := t.current
+	t.mu.Unlock()
+	hc := &HookContext{Point: HookPostLoop, WorkDir: t.config.WorkDir}
+	if t.config.HookContext != nil && current != nil {
+		hc = t.config.HookContext(current)
+	}
+
+	ctx, span := StartSpan(ctx, "ralph.verification", SpanKindInternal)
+	defer span.End()
+	for _, hook := range t.config.Verify {
+		start := time.Now()
+		output, err := hook.Execute(ctx, hc)
+		command := VerificationCommand{
+			Command:     hook.Command,
+			Passed:      err == nil,
+			DurationMs:  time.Since(start).Milliseconds(),
+			Output:      truncateString(output, maxToolOutputBytes),
+			Diagnostics: ParseDiagnostics(output),
+		}
+		if err != nil {
+			command.Error = err.Error()
+			result.Passed = false
+		}
+		result.Commands = append(result.Commands, command)
+	}
+	span.SetAttr("verification.commands", len(result.Commands))
+	span.SetAttr("verification.passed", result.Passed)
+	if !result.Passed {
+		span.SetStatus(SpanStatusError, "verification failed")
+	}
+	return result
+}
+
+// verificationHooks 傳回 run_verification 執行的 post_loop 殼層 hook
+func verificationHooks(hooks []ShellHook) []ShellHook {
+	var verify []ShellHook
+	for _, hook := range hooks {
+		if hook.Point == HookPostLoop {
+			verify = append(verify, hook)
+		}
+	}
+	return verify
+}
+
+// readLoopHistory 傳回最近幾個迴圈的摘要
+func (t *LoopTools) readLoopHistory(args map[string]interface{}) (interface{}, error) {
+	limit := defaultToolHistoryLoop
+	if value, ok := args["limit"].(float64); ok && value >= 1 {
+		limit = int(value)
+	}
+	var history []*ExecutionContext
+	if t.config.History != nil {
+		history = t.config.History()
+	}
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	summaries := make([]LoopSummary, 0, len(history))
+	for _, execCtx := range history {
+		summaries = append(summaries, SummarizeLoop(execCtx))
+	}
+	return summaries, nil
+}
+
+// SummarizeLoop 產生迴圈摘要（read_loop_history 的內容）
+func SummarizeLoop(execCtx *ExecutionContext) LoopSummary {
+	summary := LoopSummary{
+		Loop:       execCtx.LoopIndex + 1,
+		Outcome:    LoopOutcome(execCtx),
+		ExitReason: execCtx.ExitReason,
+	}
+	if status := execCtx.StructuredStatus; status != nil {
+		summary.Status = status.Status
+		summary.TasksDone = status.TasksDone
+		summary.NextStep = status.NextStep
+	}
+	for _, change := range execCtx.WorkspaceChanges {
+		summary.FilesChanged = append(summary.FilesChanged, change.Path)
+	}
+	for _, err := range execCtx.ErrorHistory {
+		summary.Errors = append(summary.Errors, truncateString(err, 500))
+	}
+	for _, call := range execCtx.ToolCalls {
+		summary.ToolCalls = append(summary.ToolCalls, call.Name)
+	}
+	return summary
+}
+
+// ParseDiagnostics 從建置或測試輸出解析 "path:line[:col]: message" 診斷
+func ParseDiagnostics(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		match := diagnosticLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil || seen[line] {
+			continue
+		}
+		seen[line] = true
+
+		lineNo, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		diagnostics = append(diagnostics, Diagnostic{File: match[1], Line: lineNo, Column: column, Message: strings.TrimSpace(match[4])})
+	}
+	return diagnostics
+}
@@ -0,0 +1,258 @@
+package ghcopilot
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoopToolsReportStatus(t *testing.T) {
+	detector := NewExitDetector(t.TempDir())
+	tools := NewLoopTools(LoopToolsConfig{ExitDetector: detector})
+	execCtx := NewExecutionContext(0, "修正測試")
+	tools.BeginLoop(context.Background(), execCtx)
+
+	result, err := tools.Invoke(ToolReportStatus, map[string]interface{}{"status": "MAYBE", "exit_signal": true})
+	if err != nil || result.ResultType != "failure" || !strings.Contains(result.Error, "狀態區塊不符合規格") {
+		t.Errorf("無效的狀態應回報失敗讓 agent 修正: %+v, %v", result, err)
+	}
+	result, err = tools.Invoke(ToolReportStatus, map[string]interface{}{
+		"status": "DONE", "exit_signal": true, "tasks_done": float64(2), "tasks_total": float64(2), "files_changed": []interface{}{"calc.go"},
+	})
+	if err != nil || result.ResultType != "success" {
+		t.Fatalf("有效的狀態應記錄: %+v, %v", result, err)
+	}
+
+	status := tools.EndLoop()
+	if status == nil || status.Format != StatusFormatTool || status.Version != StatusContractVersion || !status.ExitSignal || status.TasksDone != "2/2" {
+		t.Errorf("EndLoop 應傳回回報的狀態: %+v", status)
+	}
+	if tools.EndLoop() != nil {
+		t.Error("EndLoop 之後不應保留回報的狀態")
+	}
+	if len(execCtx.ToolCalls) != 2 || execCtx.ToolCalls[0].Error == "" || execCtx.ToolCalls[1].Name != ToolReportStatus {
+		t.Errorf("工具呼叫應記錄在迴圈中: %+v", execCtx.ToolCalls)
+	}
+	if summary := detector.GetSignalsSummary(); summary["done_signals"] != 1 || summary["completion_count"] != 1 {
+		t.Errorf("完成訊號應交給 ExitDetector: %v", summary)
+	}
+}
+
+func TestLoopToolsRunVerification(t *testing.T) {
+	workDir := t.TempDir()
+	tools := NewLoopTools(LoopToolsConfig{
+		WorkDir: workDir,
+		Verify: verificationHooks([]ShellHook{
+			{Point: HookPreLoop, Command: "exit 1"},
+			{Point: HookPostLoop, Command: "true"},
+			{Point: HookPostLoop, Command: "echo 'calc.go:12:5: undefined: Add'; echo 'FAIL'; exit 1"},
+		}),
+	})
+
+	result := tools.Verify(context.Background())
+	if result.Passed || len(result.Commands) != 2 {
+		t.Fatalf("應只執行 post_loop 指令並回報失敗: %+v", result)
+	}
+	failed := result.Commands[1]
+	if failed.Passed || failed.Error == "" || len(failed.Diagnostics) != 1 {
+		t.Fatalf("失敗的指令應附上診斷: %+v", failed)
+	}
+	if d := failed.Diagnostics[0]; d.File != "calc.go" || d.Line != 12 || d.Column != 5 || d.Message != "undefined: Add" {
+		t.Errorf("診斷解析不正確: %+v", d)
+	}
+
+	empty := NewLoopTools(LoopToolsConfig{WorkDir: workDir})
+	tool, _ := empty.Invoke(ToolRunVerification, nil)
+	var decoded VerificationResult
+	if err := json.Unmarshal([]byte(tool.TextResultForLLM), &decoded); err != nil || !decoded.Passed || decoded.Message == "" {
+		t.Errorf("沒有驗證指令時應說明: %s", tool.TextResultForLLM)
+	}
+}
+
+func TestLoopToolsVerifySpan(t *testing.T) {
+	exporter := &memorySpanExporter{}
+	tracer := NewTracer("test", exporter)
+	ctx, loop := tracer.Start(t.Context(), "ralph.loop", SpanKindInternal)
+	tools := NewLoopTools(LoopToolsConfig{
+		WorkDir: t.TempDir(),
+		Verify:  []ShellHook{{Point: HookPostLoop, Command: "true"}, {Point: HookPostLoop, Command: "exit 1"}},
+	})
+	tools.BeginLoop(ctx, NewExecutionContext(0, "修正測試"))
+	tools.Invoke(ToolRunVerification, nil)
+	tools.EndLoop()
+	loop.End()
+	if err := tracer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var span *Span
+	for _, exported := range exporter.spans {
+		if exported.Name == "ralph.verification" {
+			span = exported
+		}
+	}
+	if span == nil || span.ParentSpanID != loop.SpanID {
+		t.Fatalf("run_verification 應在迴圈 span 之下建立 ralph.verification span: %+v", exporter.spans)
+	}
+	if span.Attributes["verification.commands"] != 2 || span.Attributes["verification.passed"] != false || span.StatusCode != SpanStatusError {
+		t.Errorf("span 應記錄驗證結果: %+v", span)
+	}
+}
+
+func TestLoopToolsRunVerificationCancel(t *testing.T) {
+	tools := NewLoopTools(LoopToolsConfig{
+		WorkDir: t.TempDir(),
+		Verify:  []ShellHook{{Point: HookPostLoop, Command: "sleep 30"}},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	tools.BeginLoop(ctx, NewExecutionContext(0, "修正測試"))
+	defer tools.EndLoop()
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	result, _ := tools.Invoke(ToolRunVerification, nil)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("迴圈取消後驗證指令應中止: %v", elapsed)
+	}
+	var decoded VerificationResult
+	if err := json.Unmarshal([]byte(result.TextResultForLLM), &decoded); err != nil || decoded.Passed {
+		t.Errorf("被中止的驗證應回報失敗: %s", result.TextResultForLLM)
+	}
+}
+
+func TestLoopToolsReadLoopHistory(t *testing.T) {
+	var history []*ExecutionContext
+	for i := 0; i < 3; i++ {
+		execCtx := NewExecutionContext(i, "修正測試")
+		execCtx.ShouldContinue = i < 2
+		execCtx.ErrorHistory = []string{"undefined: Add"}
+		execCtx.WorkspaceChanges = []FileChange{{Path: "calc.go", Kind: FileModified}}
+		history = append(history, execCtx)
+	}
+	tools := NewLoopTools(LoopToolsConfig{History: func() []*ExecutionContext { return history }})
+
+	result, _ := tools.Invoke(ToolReadLoopHistory, map[string]interface{}{"limit": float64(2)})
+	var summaries []LoopSummary
+	if err := json.Unmarshal([]byte(result.TextResultForLLM), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].Loop != 2 || summaries[1].Outcome != LoopOutcomeComplete ||
+		summaries[0].FilesChanged[0] != "calc.go" || summaries[0].Errors[0] != "undefined: Add" {
+		t.Errorf("歷史摘要不正確: %+v", summaries)
+	}
+}
+
+func TestLoopToolsParameters(t *testing.T) {
+	tools := NewLoopTools(LoopToolsConfig{}).Tools()
+	if len(tools) != 3 || tools[0].Name != ToolReportStatus || tools[1].Name != ToolRunVerification || tools[2].Name != ToolReadLoopHistory {
+		t.Fatalf("工具定義不正確: %+v", tools)
+	}
+	params := tools[0].Parameters
+	properties := params["properties"].(map[string]interface{})
+	if _, ok := properties["version"]; ok || properties["exit_signal"] == nil {
+		t.Errorf("report_status 的參數應沿用狀態區塊 schema 並去掉 version: %v", properties)
+	}
+	for _, name := range params["required"].([]interface{}) {
+		if name == "version" {
+			t.Error("version 不應為必填")
+		}
+	}
+	if _, err := json.Marshal(params); err != nil {
+		t.Errorf("參數 schema 應可序列化: %v", err)
+	}
+}
+
+func TestSDKExecutorSessionConfigTools(t *testing.T) {
+	executor := NewSDKExecutor(nil)
+	executor.SetTools(NewLoopTools(LoopToolsConfig{}).Tools())
+
+	config := executor.SessionConfig("loop-1", "claude-sonnet-4.5")
+	if len(config.Tools) != 3 || !config.Streaming || config.Model != "claude-sonnet-4.5" {
+		t.Errorf("會話配置應包含 agent 工具: %+v", config)
+	}
+	if names := strings.Join(executor.ToolNames(), ","); names != "report_status,run_verification,read_loop_history" {
+		t.Errorf("工具名稱不正確: %s", names)
+	}
+}
+
+// toolCallingExecutor 模擬 agent 在執行期間呼叫工具
+type toolCallingExecutor struct {
+	client *RalphLoopClient
+	calls  int
+}
+
+func (e *toolCallingExecutor) ExecutePrompt(ctx context.Context, prompt string) (*ExecutionResult, error) {
+	e.calls++
+	e.client.Tools().Invoke(ToolReadLoopHistory, nil)
+	e.client.Tools().Invoke(ToolReportStatus, map[string]interface{}{"status": "DONE", "exit_signal": e.calls == 2})
+	return &ExecutionResult{Command: "agent", Stdout: "修改了 calc.go", Success: true}, nil
+}
+
+func TestClientReportStatusTool(t *testing.T) {
+	config := DefaultClientConfig()
+	config.WorkDir = t.TempDir()
+	config.EnablePersistence = false
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	executor := &toolCallingExecutor{}
+	config.Executor = executor
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+	executor.client = client
+
+	if _, err := client.ExecuteUntilCompletion(t.Context(), "修正測試", 5); err != nil {
+		t.Fatal(err)
+	}
+	history := client.GetHistory()
+	if len(history) != 2 {
+		t.Fatalf("report_status 的 exit_signal 應決定何時完成: %d 個迴圈", len(history))
+	}
+	last := history[1]
+	if last.ExitReason != "completion reported via report_status" || last.StructuredStatus == nil || !last.StructuredStatus.ExitSignal {
+		t.Errorf("完成理由應來自 report_status: %+v", last)
+	}
+	if history[0].StatusError != "" || len(history[0].ToolCalls) != 2 || history[0].ToolCalls[0].Name != ToolReadLoopHistory {
+		t.Errorf("工具呼叫應記錄在迴圈中且不需要狀態區塊: %+v", history[0])
+	}
+	if client.ExitDetector().GetSignalsSummary()["done_signals"] != 1 {
+		t.Errorf("ExitDetector 應記錄完成訊號: %v", client.ExitDetector().GetSignalsSummary())
+	}
+}
+
+// doneReportingExecutor 模擬 agent 每個迴圈都回報 DONE 但不送出 exit_signal
+type doneReportingExecutor struct {
+	client *RalphLoopClient
+}
+
+func (e *doneReportingExecutor) ExecutePrompt(ctx context.Context, prompt string) (*ExecutionResult, error) {
+	e.client.Tools().Invoke(ToolReportStatus, map[string]interface{}{"status": "DONE", "exit_signal": false})
+	return &ExecutionResult{Command: "agent", Stdout: "所有任務已完成", Success: true}, nil
+}
+
+func TestClientExitDetectorEndsReportedLoops(t *testing.T) {
+	config := DefaultClientConfig()
+	config.WorkDir = t.TempDir()
+	config.EnablePersistence = false
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	executor := &doneReportingExecutor{}
+	config.Executor = executor
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+	executor.client = client
+
+	if _, err := client.ExecuteUntilCompletion(t.Context(), "修正測試", 5); err != nil {
+		t.Fatal(err)
+	}
+	history := client.GetHistory()
+	if len(history) != 2 {
+		t.Fatalf("累計兩次 DONE 回報後 ExitDetector 應結束執行: %d 個迴圈", len(history))
+	}
+	if !strings.HasPrefix(history[1].ExitReason, "完成條件滿足") || history[0].ExitReason != "" {
+		t.Errorf("結束理由應來自 ExitDetector: %q / %q", history[0].ExitReason, history[1].ExitReason)
+	}
+}
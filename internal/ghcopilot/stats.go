@@ -0,0 +1,318 @@
+package ghcopilot
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsReport 是跨 run 的統計
+type StatsReport struct {
+	Overall     StatsGroup        `json:"overall"`
+	ByModel     []StatsGroup      `json:"by_model"`     // 依 run 使用的模型
+	ByMode      []StatsGroup      `json:"by_mode"`      // 依執行器（cli、sdk；混用時為 mixed）
+	ByDay       []StatsGroup      `json:"by_day"`       // 依 run 開始的日期（本地時區）
+	ExitReasons []ExitReasonCount `json:"exit_reasons"` // run 結束原因的分布（次數多的在前）
+}
+
+// StatsGroup 是一組 run 與其迴圈的統計
+//
+// 成功率以已結束（狀態不是 running 且有執行摘要）的 run 為分母；
+// 平均完成迴圈數只計算完成的 run。
+type StatsGroup struct {
+	Key                   string  `json:"key"`
+	Runs                  int     `json:"runs"`
+	FinishedRuns          int     `json:"finished_runs"`
+	CompletedRuns         int     `json:"completed_runs"`
+	SuccessRate           float64 `json:"success_rate"`
+	MeanLoopsToCompletion float64 `json:"mean_loops_to_completion"`
+	MeanRunSeconds        float64 `json:"mean_run_seconds"`
+	BreakerOpenRuns       int     `json:"breaker_open_runs"`
+	BreakerOpenRate       float64 `json:"breaker_open_rate"`
+	Loops                 int     `json:"loops"`
+	FailedLoops           int     `json:"failed_loops"`
+	MeanLoopSeconds       float64 `json:"mean_loop_seconds"`
+}
+
+// ExitReasonCount 是一種結束原因的 run 數
+type ExitReasonCount struct {
+	Reason string `json:"reason"`
+	Runs   int    `json:"runs"`
+}
+
+// ModeUnknown 無法判斷執行器時的名稱
+const ModeUnknown = "unknown"
+
+// statsDigits 結束原因中的數字（例如迴圈數）以 N 取代，讓相同原因歸為一類
+var statsDigits = regexp.MustCompile(`\d+`)
+
+// ComputeStats 彙整 run 的成功率、完成所需迴圈數、耗時、熔斷頻率與結束原因
+func ComputeStats(runs []*HistoryRun) *StatsReport {
+	report := &StatsReport{ByModel: []StatsGroup{}, ByMode: []StatsGroup{}, ByDay: []StatsGroup{}, ExitReasons: []ExitReasonCount{}}
+
+	byModel := map[string][]*HistoryRun{}
+	byMode := map[string][]*HistoryRun{}
+	byDay := map[string][]*HistoryRun{}
+	reasons := map[string]int{}
+	for _, run := range runs {
+		byModel[orDash(run.Model)] = append(byModel[orDash(run.Model)], run)
+		byMode[runMode(run)] = append(byMode[runMode(run)], run)
+		day := run.StartedAt.Local().Format(time.DateOnly)
+		byDay[day] = append(byDay[day], run)
+		if reason := runExitReason(run); reason != "" {
+			reasons[reason]++
+		}
+	}
+
+	report.Overall = newStatsGroup("all", runs)
+	for _, group := range []struct {
+		runs map[string][]*HistoryRun
+		dst  *[]StatsGroup
+	}{{byModel, &report.ByModel}, {byMode, &report.ByMode}, {byDay, &report.ByDay}} {
+		for key, runs := range group.runs {
+			*group.dst = append(*group.dst, newStatsGroup(key, runs))
+		}
+		sort.Slice(*group.dst, func(i, j int) bool {
+			return (*group.dst)[i].Key < (*group.dst)[j].Key
+		})
+	}
+
+	for reason, count := range reasons {
+		report.ExitReasons = append(report.ExitReasons, ExitReasonCount{Reason: reason, Runs: count})
+	}
+	sort.Slice(report.ExitReasons, func(i, j int) bool {
+		a, b := report.ExitReasons[i], report.ExitReasons[j]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		return a.Reason < b.Reason
+	})
+	return report
+}
+
+// newStatsGroup 計算一組 run 的統計
+func newStatsGroup(key string, runs []*HistoryRun) StatsGroup {
+	group := StatsGroup{Key: key, Runs: len(runs)}
+
+	var completionLoops, timedRuns int
+	var runTime, loopTime time.Duration
+	for _, run := range runs {
+		if run.Status != "" && run.Status != RunStatusRunning {
+			group.FinishedRuns++
+		}
+		if run.Status == RunStatusCompleted {
+			group.CompletedRuns++
+			completionLoops += run.LoopCount
+		}
+		if duration, ok := runDuration(run); ok {
+			timedRuns++
+			runTime += duration
+		}
+		if runOpenedBreaker(run) {
+			group.BreakerOpenRuns++
+		}
+
+		for _, execCtx := range run.Loops {
+			group.Loops++
+			if LoopOutcome(execCtx) == LoopOutcomeFailed {
+				group.FailedLoops++
+			}
+			loopTime += time.Duration(execCtx.DurationMs) * time.Millisecond
+		}
+	}
+
+	group.SuccessRate = ratio(group.CompletedRuns, group.FinishedRuns)
+	group.MeanLoopsToCompletion = ratio(completionLoops, group.CompletedRuns)
+	group.BreakerOpenRate = ratio(group.BreakerOpenRuns, group.Runs)
+	if timedRuns > 0 {
+		group.MeanRunSeconds = runTime.Seconds() / float64(timedRuns)
+	}
+	if group.Loops > 0 {
+		group.MeanLoopSeconds = loopTime.Seconds() / float64(group.Loops)
+	}
+	return group
+}
+
+// ratio 傳回 n/d（d 為 0 時為 0）
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// runDuration 傳回已結束的 run 的耗時；沒有執行摘要時以已保存迴圈的耗時加總
+func runDuration(run *HistoryRun) (time.Duration, bool) {
+	if run.FinishedAt != nil {
+		return run.FinishedAt.Sub(run.StartedAt), true
+	}
+	if run.Status != "" || len(run.Loops) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, execCtx := range run.Loops {
+		total += time.Duration(execCtx.DurationMs) * time.Millisecond
+	}
+	return total, true
+}
+
+// runOpenedBreaker 判斷 run 是否曾打開熔斷器
+func runOpenedBreaker(run *HistoryRun) bool {
+	if strings.HasPrefix(run.Error, "circuit breaker") {
+		return true
+	}
+	for _, execCtx := range run.Loops {
+		if execCtx.CircuitBreakerState == string(StateOpen) {
+			return true
+		}
+	}
+	return false
+}
+
+// runMode 傳回 run 使用的執行器；迴圈使用不同執行器時為 mixed
+func runMode(run *HistoryRun) string {
+	mode := ""
+	for _, execCtx := range run.Loops {
+		loopMode := LoopExecutionMode(execCtx)
+		if mode != "" && loopMode != mode {
+			return "mixed"
+		}
+		mode = loopMode
+	}
+	return orDash(mode)
+}
+
+// LoopExecutionMode 傳回迴圈使用的執行器；舊版本保存的迴圈沒有記錄時由執行的指令推斷
+func LoopExecutionMode(execCtx *ExecutionContext) string {
+	switch {
+	case execCtx.ExecutionMode != "":
+		return execCtx.ExecutionMode
+	case strings.HasPrefix(execCtx.CLICommand, "sdk:"):
+		return ModeSDK.String()
+	case execCtx.CLICommand != "":
+		return ModeCLI.String()
+	}
+	return ModeUnknown
+}
+
+// runExitReason 傳回 run 的結束原因（數字以 N 取代）；執行中或無法判斷時為空
+func runExitReason(run *HistoryRun) string {
+	reason := run.Error
+	if reason == "" && len(run.Loops) > 0 {
+		reason = run.Loops[len(run.Loops)-1].ExitReason
+	}
+	if reason == "" && run.Status != RunStatusRunning {
+		reason = string(run.Status)
+	}
+	return statsDigits.ReplaceAllString(reason, "N")
+}
+
+// WriteText 以表格輸出統計
+func (r *StatsReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	o := r.Overall
+	fmt.Fprintf(&b, "Run 數:         %d (已結束 %d, 完成 %d)\n", o.Runs, o.FinishedRuns, o.CompletedRuns)
+	fmt.Fprintf(&b, "成功率:         %s\n", formatPercent(o.SuccessRate))
+	fmt.Fprintf(&b, "平均完成迴圈數: %.1f\n", o.MeanLoopsToCompletion)
+	fmt.Fprintf(&b, "平均耗時:       %s\n", formatReportDuration(secondsDuration(o.MeanRunSeconds)))
+	fmt.Fprintf(&b, "熔斷頻率:       %s (%d 個 run)\n", formatPercent(o.BreakerOpenRate), o.BreakerOpenRuns)
+	fmt.Fprintf(&b, "迴圈:           %d (失敗 %d, 平均 %s)\n", o.Loops, o.FailedLoops, formatReportDuration(secondsDuration(o.MeanLoopSeconds)))
+
+	for _, section := range []struct {
+		title  string
+		groups []StatsGroup
+	}{{"模型", r.ByModel}, {"執行器", r.ByMode}, {"日期", r.ByDay}} {
+		fmt.Fprintf(&b, "\n== 依%s ==\n", section.title)
+		if err := writeHistoryTable(&b, statsRows(section.title, section.groups)); err != nil {
+			return err
+		}
+	}
+
+	b.WriteString("\n== 結束原因 ==\n")
+	rows := [][]string{{"RUN", "原因"}}
+	for _, reason := range r.ExitReasons {
+		rows = append(rows, []string{strconv.Itoa(reason.Runs), reason.Reason})
+	}
+	if err := writeHistoryTable(&b, rows); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// statsRows 將統計轉為表格列
+func statsRows(title string, groups []StatsGroup) [][]string {
+	rows := [][]string{{title, "RUN", "成功率", "平均迴圈", "平均耗時", "熔斷", "迴圈", "失敗迴圈", "迴圈平均"}}
+	for _, g := range groups {
+		rows = append(rows, []string{
+			g.Key,
+			strconv.Itoa(g.Runs),
+			formatPercent(g.SuccessRate),
+			fmt.Sprintf("%.1f", g.MeanLoopsToCompletion),
+			formatReportDuration(secondsDuration(g.MeanRunSeconds)),
+			formatPercent(g.BreakerOpenRate),
+			strconv.Itoa(g.Loops),
+			strconv.Itoa(g.FailedLoops),
+			formatReportDuration(secondsDuration(g.MeanLoopSeconds)),
+		})
+	}
+	return rows
+}
+
+// WriteCSV 以 CSV 輸出統計，每列為一個分組（dimension 為 overall、model、mode、day 或 exit_reason）
+func (r *StatsReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"dimension", "key", "runs", "finished_runs", "completed_runs", "success_rate",
+		"mean_loops_to_completion", "mean_run_seconds", "breaker_open_runs", "breaker_open_rate",
+		"loops", "failed_loops", "mean_loop_seconds",
+	})
+
+	write := func(dimension string, g StatsGroup) {
+		cw.Write([]string{
+			dimension, g.Key,
+			strconv.Itoa(g.Runs), strconv.Itoa(g.FinishedRuns), strconv.Itoa(g.CompletedRuns),
+			csvFloat(g.SuccessRate), csvFloat(g.MeanLoopsToCompletion), csvFloat(g.MeanRunSeconds),
+			strconv.Itoa(g.BreakerOpenRuns), csvFloat(g.BreakerOpenRate),
+			strconv.Itoa(g.Loops), strconv.Itoa(g.FailedLoops), csvFloat(g.MeanLoopSeconds),
+		})
+	}
+	write("overall", r.Overall)
+	for _, g := range r.ByModel {
+		write("model", g)
+	}
+	for _, g := range r.ByMode {
+		write("mode", g)
+	}
+	for _, g := range r.ByDay {
+		write("day", g)
+	}
+	for _, reason := range r.ExitReasons {
+		cw.Write([]string{"exit_reason", reason.Reason, strconv.Itoa(reason.Runs), "", "", "", "", "", "", "", "", "", ""})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvFloat 以最多四位小數輸出
+func csvFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e4)/1e4, 'f', -1, 64)
+}
+
+// formatPercent 以百分比顯示比例
+func formatPercent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
+
+// secondsDuration 將秒數轉為 time.Duration
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
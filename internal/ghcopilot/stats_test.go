@@ -0,0 +1,133 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statsTestRuns 產生四個 run：兩個 gpt-4.1 以 CLI 完成、一個 claude 以 SDK 熔斷、一個執行中
+func statsTestRuns() []*HistoryRun {
+	day1 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	finished := func(start time.Time, d time.Duration) *time.Time {
+		t := start.Add(d)
+		return &t
+	}
+	loop := func(mode string, ms int64, outcome, breaker string) *ExecutionContext {
+		return &ExecutionContext{ExecutionMode: mode, DurationMs: ms, Outcome: outcome, CircuitBreakerState: breaker}
+	}
+
+	return []*HistoryRun{
+		{
+			RunID: "run-1", Model: "gpt-4.1", Status: RunStatusCompleted, StartedAt: day1, FinishedAt: finished(day1, 60*time.Second), LoopCount: 2,
+			Loops: []*ExecutionContext{loop("cli", 20000, LoopOutcomeContinue, "CLOSED"), {CLICommand: "copilot -p", DurationMs: 30000, ExitReason: "completion detected in output"}},
+		},
+		{
+			RunID: "run-2", Model: "gpt-4.1", Status: RunStatusCompleted, StartedAt: day2, FinishedAt: finished(day2, 120*time.Second), LoopCount: 4,
+			Loops: []*ExecutionContext{loop("cli", 100000, LoopOutcomeComplete, "CLOSED")},
+		},
+		{
+			RunID: "run-3", Model: "claude-sonnet-4.5", Status: RunStatusFailed, StartedAt: day2, FinishedAt: finished(day2, 30*time.Second), LoopCount: 3,
+			Error: "circuit breaker opened after 3 loops",
+			Loops: []*ExecutionContext{loop("sdk", 10000, LoopOutcomeFailed, "CLOSED"), loop("cli", 10000, LoopOutcomeContinue, "OPEN")},
+		},
+		{
+			RunID: "run-4", Model: "claude-sonnet-4.5", Status: RunStatusRunning, StartedAt: day2, LoopCount: 1,
+			Loops: []*ExecutionContext{loop("sdk", 5000, LoopOutcomeContinue, "CLOSED")},
+		},
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	stats := ComputeStats(statsTestRuns())
+
+	o := stats.Overall
+	if o.Runs != 4 || o.FinishedRuns != 3 || o.CompletedRuns != 2 {
+		t.Errorf("run 數不正確: %+v", o)
+	}
+	if o.SuccessRate != 2.0/3 || o.MeanLoopsToCompletion != 3 || o.MeanRunSeconds != 70 {
+		t.Errorf("成功率、完成迴圈數或耗時不正確: %+v", o)
+	}
+	if o.BreakerOpenRuns != 1 || o.BreakerOpenRate != 0.25 {
+		t.Errorf("熔斷頻率不正確: %+v", o)
+	}
+	if o.Loops != 6 || o.FailedLoops != 1 || o.MeanLoopSeconds != 175.0/6 {
+		t.Errorf("迴圈統計不正確: %+v", o)
+	}
+
+	if len(stats.ByModel) != 2 || stats.ByModel[0].Key != "claude-sonnet-4.5" || stats.ByModel[1].SuccessRate != 1 {
+		t.Errorf("依模型的統計不正確: %+v", stats.ByModel)
+	}
+	modes := map[string]int{}
+	for _, g := range stats.ByMode {
+		modes[g.Key] = g.Runs
+	}
+	if modes["cli"] != 2 || modes["mixed"] != 1 || modes["sdk"] != 1 {
+		t.Errorf("依執行器的統計不正確（舊迴圈應由指令推斷）: %+v", stats.ByMode)
+	}
+	if len(stats.ByDay) != 2 || stats.ByDay[0].Key != "2026-03-01" || stats.ByDay[1].Runs != 3 {
+		t.Errorf("依日期的統計不正確: %+v", stats.ByDay)
+	}
+
+	want := []ExitReasonCount{
+		{Reason: "circuit breaker opened after N loops", Runs: 1},
+		{Reason: "completed", Runs: 1},
+		{Reason: "completion detected in output", Runs: 1},
+	}
+	if len(stats.ExitReasons) != len(want) {
+		t.Fatalf("結束原因不正確: %+v", stats.ExitReasons)
+	}
+	for i := range want {
+		if stats.ExitReasons[i] != want[i] {
+			t.Errorf("結束原因 %d = %+v, 期望 %+v", i, stats.ExitReasons[i], want[i])
+		}
+	}
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+	stats := ComputeStats(nil)
+	if stats.Overall.Runs != 0 || stats.Overall.SuccessRate != 0 || stats.ByModel == nil || stats.ExitReasons == nil {
+		t.Errorf("沒有 run 時應為零值且列表不為 nil: %+v", stats)
+	}
+}
+
+func TestStatsWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ComputeStats(statsTestRuns()).WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("輸出不是合法的 CSV: %v", err)
+	}
+	// 標題、overall、2 個模型、3 個執行器、2 天、3 種結束原因
+	if len(records) != 12 {
+		t.Fatalf("列數 = %d, 期望 12: %v", len(records), records)
+	}
+	if records[0][0] != "dimension" || len(records[0]) != 13 {
+		t.Errorf("標題不正確: %v", records[0])
+	}
+	if strings.Join(records[1][:6], ",") != "overall,all,4,3,2,0.6667" {
+		t.Errorf("overall 列不正確: %v", records[1])
+	}
+	if last := records[len(records)-1]; last[0] != "exit_reason" || last[2] != "1" {
+		t.Errorf("結束原因列不正確: %v", last)
+	}
+}
+
+func TestStatsWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ComputeStats(statsTestRuns()).WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"成功率:         67%", "平均完成迴圈數: 3.0", "熔斷頻率:       25% (1 個 run)",
+		"== 依模型 ==", "== 依執行器 ==", "mixed", "== 依日期 ==", "2026-03-02", "circuit breaker opened after N loops"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("缺少 %q:\n%s", want, buf.String())
+		}
+	}
+}
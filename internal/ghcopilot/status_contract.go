@@ -0,0 +1,191 @@
+package ghcopilot
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// StatusContractVersion 目前的 JSON 狀態區塊版本
+const StatusContractVersion = 1
+
+// 狀態區塊的格式（CopilotStatus.Format）
+const (
+	StatusFormatJSON   = "json"   // 符合 StatusContractSchema 的 JSON
+	StatusFormatLegacy = "legacy" // 舊版 KEY: value 格式
+	StatusFormatTool   = "tool"   // 經由 SDK 的 report_status 工具回報
+)
+
+// 狀態區塊的標記
+const (
+	statusBlockStart = "---COPILOT_STATUS---"
+	statusBlockEnd   = "---END_STATUS---"
+)
+
+// StatusContractSchemaJSON 是 JSON 狀態區塊的 JSON Schema
+//
+//go:embed status_contract.v1.schema.json
+var StatusContractSchemaJSON []byte
+
+// StatusContractInstructions 附加在每個迴圈 prompt 後的狀態區塊規格
+const StatusContractInstructions = `---RALPH_LOOP_STATUS_CONTRACT---
+回應的最後請輸出以下狀態區塊，中間是一行 JSON（version 固定為 1，不要加入其他欄位）：
+` + statusBlockStart + `
+{"version":1,"status":"CONTINUE","exit_signal":false,"tasks_done":1,"tasks_total":3,"next_step":"下一步要做的事","files_changed":["path/to/file.go"],"blockers":[],"confidence":0.6}
+` + statusBlockEnd + `
+- status: CONTINUE（還有工作）、DONE（全部完成）、BLOCKED（需要人工協助）或 ERROR
+- exit_signal: 只有在所有任務完成且驗證通過時才設為 true
+- confidence: 0 到 1，對目前結果正確的信心
+---END_STATUS_CONTRACT---`
+
+var (
+	statusBlockPattern = regexp.MustCompile(`(?s)` + statusBlockStart + `\r?\n(.*?)\r?\n` + statusBlockEnd)
+	statusFencePattern = regexp.MustCompile("(?s)^```[a-zA-Z]*\r?\n(.*?)\r?\n```$")
+)
+
+// statusContractSchema 解析後的狀態區塊 schema（第一次使用時建立）
+var statusContractSchema = sync.OnceValues(func() (*jsonschema.Resolved, error) {
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(StatusContractSchemaJSON, &schema); err != nil {
+		return nil, err
+	}
+	return schema.Resolve(nil)
+})
+
+// StatusBlockError 表示回應中的狀態區塊不符合規格
+type StatusBlockError struct {
+	Reason string
+}
+
+func (e *StatusBlockError) Error() string {
+	return "狀態區塊不符合規格: " + e.Reason
+}
+
+// statusReport 是 JSON 狀態區塊的內容
+type statusReport struct {
+	Version      int      `json:"version"`
+	Status       string   `json:"status"`
+	ExitSignal   bool     `json:"exit_signal"`
+	TasksDone    *int     `json:"tasks_done"`
+	TasksTotal   *int     `json:"tasks_total"`
+	NextStep     string   `json:"next_step"`
+	FilesChanged []string `json:"files_changed"`
+	Blockers     []string `json:"blockers"`
+	Confidence   *float64 `json:"confidence"`
+}
+
+// ParseStatusBlock 解析回應中的狀態區塊
+//
+// 狀態區塊在回應的最後，因此只解析最後一個區塊（前面引用的區塊不影響結果）。
+// 沒有狀態區塊時傳回 (nil, nil)。區塊內容是 JSON 時以 StatusContractSchemaJSON 驗證，
+// 否則以舊版 KEY: value 格式解析；缺少結尾標記或 JSON 不符合 schema 時傳回 *StatusBlockError。
+func ParseStatusBlock(response string) (*CopilotStatus, error) {
+	start := strings.LastIndex(response, statusBlockStart)
+	if start < 0 {
+		return nil, nil
+	}
+	matches := statusBlockPattern.FindStringSubmatch(response[start:])
+	if matches == nil {
+		return nil, &StatusBlockError{Reason: "缺少 " + statusBlockEnd}
+	}
+
+	block := strings.TrimSpace(matches[1])
+	if fenced := statusFencePattern.FindStringSubmatch(block); fenced != nil {
+		block = strings.TrimSpace(fenced[1])
+	}
+	if !strings.HasPrefix(block, "{") {
+		return parseLegacyStatus(matches[1]), nil
+	}
+
+	status, err := parseStatusJSON([]byte(block))
+	if err != nil {
+		return nil, err
+	}
+	status.RawBlock = matches[1]
+	return status, nil
+}
+
+// parseStatusJSON 以 StatusContractSchemaJSON 驗證並解析 JSON 狀態
+func parseStatusJSON(data []byte) (*CopilotStatus, error) {
+	var instance map[string]any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return nil, &StatusBlockError{Reason: fmt.Sprintf("JSON 格式錯誤: %v", err)}
+	}
+	schema, err := statusContractSchema()
+	if err != nil {
+		return nil, fmt.Errorf("無法載入狀態區塊 schema: %w", err)
+	}
+	if err := schema.Validate(instance); err != nil {
+		return nil, &StatusBlockError{Reason: err.Error()}
+	}
+
+	var report statusReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, &StatusBlockError{Reason: fmt.Sprintf("JSON 格式錯誤: %v", err)}
+	}
+	status := &CopilotStatus{
+		Format:       StatusFormatJSON,
+		Version:      report.Version,
+		Status:       report.Status,
+		ExitSignal:   report.ExitSignal,
+		NextStep:     report.NextStep,
+		FilesChanged: report.FilesChanged,
+		Blockers:     report.Blockers,
+		Confidence:   report.Confidence,
+	}
+	switch {
+	case report.TasksDone != nil && report.TasksTotal != nil:
+		status.TasksDone = fmt.Sprintf("%d/%d", *report.TasksDone, *report.TasksTotal)
+	case report.TasksDone != nil:
+		status.TasksDone = fmt.Sprint(*report.TasksDone)
+	}
+	return status, nil
+}
+
+// parseLegacyStatus 解析舊版 KEY: value 格式的狀態區塊
+func parseLegacyStatus(block string) *CopilotStatus {
+	status := &CopilotStatus{Format: StatusFormatLegacy, RawBlock: block}
+	for _, line := range strings.Split(block, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "STATUS":
+			status.Status = value
+		case "EXIT_SIGNAL":
+			status.ExitSignal = strings.ToLower(value) == "true"
+		case "TASKS_DONE":
+			status.TasksDone = value
+		case "NEXT_STEP":
+			status.NextStep = value
+		case "ERROR":
+			status.ErrorMessage = value
+		}
+	}
+	return status
+}
+
+// LoopStatus 轉換為保存在 ExecutionContext 的迴圈狀態
+func (s *CopilotStatus) LoopStatus() *LoopStatus {
+	if s == nil {
+		return nil
+	}
+	return &LoopStatus{
+		Version:      s.Version,
+		Status:       s.Status,
+		ExitSignal:   s.ExitSignal,
+		TasksDone:    s.TasksDone,
+		NextStep:     s.NextStep,
+		ErrorMessage: s.ErrorMessage,
+		FilesChanged: s.FilesChanged,
+		Blockers:     s.Blockers,
+		Confidence:   s.Confidence,
+	}
+}
@@ -0,0 +1,187 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusBlockJSON(t *testing.T) {
+	response := "修改了 calc.go\n---COPILOT_STATUS---\n" +
+		`{"version":1,"status":"DONE","exit_signal":true,"tasks_done":3,"tasks_total":3,"next_step":"","files_changed":["calc.go"],"blockers":[],"confidence":0.9}` +
+		"\n---END_STATUS---\n"
+
+	status, err := ParseStatusBlock(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Format != StatusFormatJSON || status.Version != StatusContractVersion || status.Status != "DONE" || !status.ExitSignal ||
+		status.TasksDone != "3/3" || len(status.FilesChanged) != 1 || status.Confidence == nil || *status.Confidence != 0.9 {
+		t.Errorf("解析結果不正確: %+v", status)
+	}
+
+	loop := status.LoopStatus()
+	data, _ := json.Marshal(loop)
+	if !strings.Contains(string(data), `"version":1`) || !strings.Contains(string(data), `"files_changed":["calc.go"]`) {
+		t.Errorf("LoopStatus 應保留 JSON 欄位: %s", data)
+	}
+}
+
+func TestParseStatusBlockFenced(t *testing.T) {
+	response := "---COPILOT_STATUS---\n```json\n{\"version\":1,\"status\":\"CONTINUE\",\"exit_signal\":false}\n```\n---END_STATUS---"
+	status, err := ParseStatusBlock(response)
+	if err != nil || status.Status != "CONTINUE" || status.TasksDone != "" {
+		t.Errorf("應接受以 ``` 包住的 JSON: %+v, %v", status, err)
+	}
+}
+
+func TestParseStatusBlockCRLF(t *testing.T) {
+	response := "修改了 calc.go\r\n---COPILOT_STATUS---\r\n" +
+		`{"version":1,"status":"DONE","exit_signal":true}` +
+		"\r\n---END_STATUS---\r\n"
+	if status, err := ParseStatusBlock(response); err != nil || status.Status != "DONE" || !status.ExitSignal {
+		t.Errorf("應接受 CRLF 換行: %+v, %v", status, err)
+	}
+	fenced := "---COPILOT_STATUS---\r\n```json\r\n{\"version\":1,\"status\":\"CONTINUE\",\"exit_signal\":false}\r\n```\r\n---END_STATUS---"
+	if status, err := ParseStatusBlock(fenced); err != nil || status.Status != "CONTINUE" {
+		t.Errorf("應接受 CRLF 換行的 ``` 區塊: %+v, %v", status, err)
+	}
+	legacy := "---COPILOT_STATUS---\r\nSTATUS: DONE\r\nEXIT_SIGNAL: true\r\n---END_STATUS---"
+	if status, err := ParseStatusBlock(legacy); err != nil || status.Status != "DONE" || !status.ExitSignal {
+		t.Errorf("應接受 CRLF 換行的舊版格式: %+v, %v", status, err)
+	}
+}
+
+func TestParseStatusBlockUsesLast(t *testing.T) {
+	quoted := "上一次的狀態是：\n---COPILOT_STATUS---\n" +
+		`{"version":1,"status":"CONTINUE","exit_signal":false}` + "\n---END_STATUS---\n"
+	final := "全部完成\n---COPILOT_STATUS---\n" +
+		`{"version":1,"status":"DONE","exit_signal":true}` + "\n---END_STATUS---\n"
+	if status, err := ParseStatusBlock(quoted + final); err != nil || status.Status != "DONE" || !status.ExitSignal {
+		t.Errorf("應解析最後一個狀態區塊: %+v, %v", status, err)
+	}
+
+	var blockErr *StatusBlockError
+	if status, err := ParseStatusBlock(quoted + "---COPILOT_STATUS---\n{\"version\":1}\n"); !errors.As(err, &blockErr) || status != nil {
+		t.Errorf("最後一個區塊缺少結尾時不應改用前面的區塊: %+v, %v", status, err)
+	}
+}
+
+func TestParseStatusBlockLegacy(t *testing.T) {
+	response := "---COPILOT_STATUS---\nSTATUS: ERROR\nEXIT_SIGNAL: false\nTASKS_DONE: 1/4\nNEXT_STEP: 修正編譯錯誤\nERROR: undefined: Add\n---END_STATUS---"
+	status, err := ParseStatusBlock(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Format != StatusFormatLegacy || status.Version != 0 || status.Status != "ERROR" || status.TasksDone != "1/4" ||
+		status.NextStep != "修正編譯錯誤" || status.ErrorMessage != "undefined: Add" {
+		t.Errorf("舊版格式解析不正確: %+v", status)
+	}
+}
+
+func TestParseStatusBlockInvalid(t *testing.T) {
+	cases := map[string]string{
+		"缺少結尾":    "---COPILOT_STATUS---\n{\"version\":1}\n",
+		"JSON 錯誤": "---COPILOT_STATUS---\n{\"version\":1,\n---END_STATUS---",
+		"缺少欄位":    "---COPILOT_STATUS---\n{\"version\":1,\"status\":\"DONE\"}\n---END_STATUS---",
+		"無效狀態":    "---COPILOT_STATUS---\n{\"version\":1,\"status\":\"MAYBE\",\"exit_signal\":false}\n---END_STATUS---",
+		"未知版本":    "---COPILOT_STATUS---\n{\"version\":2,\"status\":\"DONE\",\"exit_signal\":true}\n---END_STATUS---",
+		"信心超出範圍":  "---COPILOT_STATUS---\n{\"version\":1,\"status\":\"DONE\",\"exit_signal\":true,\"confidence\":1.5}\n---END_STATUS---",
+		"多餘欄位":    "---COPILOT_STATUS---\n{\"version\":1,\"status\":\"DONE\",\"exit_signal\":true,\"mood\":\"good\"}\n---END_STATUS---",
+	}
+	for name, response := range cases {
+		status, err := ParseStatusBlock(response)
+		var blockErr *StatusBlockError
+		if !errors.As(err, &blockErr) || status != nil {
+			t.Errorf("%s: 應回報 StatusBlockError: %+v, %v", name, status, err)
+		}
+	}
+
+	if status, err := ParseStatusBlock("沒有狀態區塊"); status != nil || err != nil {
+		t.Errorf("沒有狀態區塊時應傳回 nil: %+v, %v", status, err)
+	}
+}
+
+func TestOutputSignalsCompletionUsesStatusBlock(t *testing.T) {
+	block := func(exit bool) string {
+		data, _ := json.Marshal(map[string]any{"version": 1, "status": "CONTINUE", "exit_signal": exit, "tasks_done": 1})
+		return "---COPILOT_STATUS---\n" + string(data) + "\n---END_STATUS---"
+	}
+	if OutputSignalsCompletion("步驟一完成\n" + block(false)) {
+		t.Error("JSON 狀態區塊的 exit_signal 應優先於完成關鍵字")
+	}
+	if !OutputSignalsCompletion(block(true)) {
+		t.Error("exit_signal=true 應視為完成")
+	}
+	if OutputSignalsCompletion("全部完成\n---COPILOT_STATUS---\n{\"version\":1}\n---END_STATUS---") {
+		t.Error("不符合規格的狀態區塊不應視為完成")
+	}
+	if !OutputSignalsCompletion("全部完成") {
+		t.Error("沒有狀態區塊時應沿用完成關鍵字")
+	}
+}
+
+func TestClientStatusContract(t *testing.T) {
+	invalid := "修改了檔案\n---COPILOT_STATUS---\n{\"version\":1,\"status\":\"DONE\"}\n---END_STATUS---"
+	valid := "---COPILOT_STATUS---\n{\"version\":1,\"status\":\"DONE\",\"exit_signal\":true}\n---END_STATUS---"
+	replay := NewReplayExecutor([]TranscriptEntry{{Stdout: invalid}, {Stdout: valid}})
+
+	config := DefaultClientConfig()
+	config.WorkDir = t.TempDir()
+	config.EnablePersistence = false
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	config.Executor = replay
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+
+	if _, err := client.ExecuteUntilCompletion(t.Context(), "修正測試", 5); err != nil {
+		t.Fatal(err)
+	}
+	history := client.GetHistory()
+	if len(history) != 2 {
+		t.Fatalf("不符合規格的狀態區塊應繼續下一個迴圈: %d 個迴圈", len(history))
+	}
+	if history[0].StatusError == "" || !history[0].ShouldContinue {
+		t.Errorf("第一個迴圈應記錄狀態區塊錯誤: %+v", history[0])
+	}
+	notes, _ := history[1].Metadata["prompt_notes"].([]string)
+	if len(notes) != 1 || !strings.Contains(notes[0], "狀態區塊") {
+		t.Errorf("下一個 prompt 應附加驗證錯誤: %v", notes)
+	}
+	if status := history[1].StructuredStatus; status == nil || status.Version != 1 || !status.ExitSignal {
+		t.Errorf("第二個迴圈應解析 JSON 狀態區塊: %+v", status)
+	}
+
+	var metrics bytes.Buffer
+	client.Metrics().Registry().WriteTo(&metrics)
+	if !strings.Contains(metrics.String(), MetricsNamespace+"_status_errors_total 1") {
+		t.Errorf("應計算狀態區塊錯誤:\n%s", metrics.String())
+	}
+}
+
+func TestClientStatusContractDisabled(t *testing.T) {
+	replay := NewReplayExecutor([]TranscriptEntry{{Prompt: "修正測試", Stdout: "全部完成\n---COPILOT_STATUS---\n{\"version\":1}\n"}})
+
+	config := DefaultClientConfig()
+	config.WorkDir = t.TempDir()
+	config.EnablePersistence = false
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.Silent = true
+	config.StatusContract = false
+	config.Executor = replay
+	client := NewRalphLoopClientWithConfig(config)
+	defer client.Close()
+
+	client.ExecuteUntilCompletion(t.Context(), "修正測試", 1)
+	if len(replay.Mismatches()) != 0 {
+		t.Errorf("停用時不應附加狀態區塊規格: %v", replay.Mismatches())
+	}
+	if history := client.GetHistory(); history[0].StatusError != "" {
+		t.Errorf("停用時不應記錄狀態區塊錯誤: %s", history[0].StatusError)
+	}
+}
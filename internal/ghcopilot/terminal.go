@@ -0,0 +1,144 @@
+package ghcopilot
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ansiEscape 比對終端機控制序列（顏色、游標移動等）
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b[@-Z\\-_]`)
+
+// IsTerminal 檢查檔案是否為互動式終端機
+//
+// TERM 為空或 dumb 時視為非終端機（例如 cron、CI 或輸出被導向檔案）。
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	term := os.Getenv("TERM")
+	return term != "" && term != "dumb"
+}
+
+// TerminalUIAvailable 檢查是否可以在 in/out 上顯示全螢幕介面
+//
+// 兩者都必須是終端機，且可以透過 stty 設定輸入模式（例如 Windows 沒有 stty 時不可用）。
+func TerminalUIAvailable(in, out *os.File) bool {
+	if !IsTerminal(in) || !IsTerminal(out) {
+		return false
+	}
+	_, err := stty(in, "-g")
+	return err == nil
+}
+
+// stty 以 in 作為終端機執行 stty 並傳回輸出
+func stty(in *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = in
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// terminalSize 取得終端機的欄數與列數
+func terminalSize(in *os.File) (width, height int, err error) {
+	out, err := stty(in, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscanf(out, "%d %d", &height, &width); err != nil {
+		return 0, 0, fmt.Errorf("無法解析終端機大小 %q: %w", out, err)
+	}
+	return width, height, nil
+}
+
+// runeWidth 傳回字元在終端機上佔用的欄數（東亞全形字與 emoji 為 2）
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r == 0x7f:
+		return 0
+	case r < 0x1100:
+		return 1
+	case r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// displayWidth 傳回字串在終端機上佔用的欄數
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// fitWidth 將字串截斷或以空白補齊到剛好 width 欄
+func fitWidth(s string, width int) string {
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	if used < width {
+		b.WriteString(strings.Repeat(" ", width-used))
+	}
+	return b.String()
+}
+
+// wrapWidth 將一行依顯示寬度折成多行（空字串傳回一個空行）
+func wrapWidth(s string, width int) []string {
+	if width <= 0 {
+		return nil
+	}
+	var lines []string
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			lines = append(lines, b.String())
+			b.Reset()
+			used = 0
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return append(lines, b.String())
+}
+
+// sanitizeTerminalText 移除控制序列與控制字元，並將 tab 展開為空白
+func sanitizeTerminalText(s string) string {
+	s = ansiEscape.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "\t", "    ")
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "?")
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
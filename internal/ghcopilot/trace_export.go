@@ -0,0 +1,339 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceFileName 追蹤資料在 run 目錄中的檔名（每行一個 OTLP/JSON ExportTraceServiceRequest）
+const TraceFileName = "traces.jsonl"
+
+// 以下型別對應 OTLP/JSON 的 ExportTraceServiceRequest（只包含本專案用到的欄位）
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // OTLP/JSON 以字串表示 int64
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// encodeOTLPTraces 將 span 轉為 OTLP/JSON 請求
+func encodeOTLPTraces(serviceName string, spans []*Span) otlpTraceRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: unixNanoString(span.StartTime),
+			EndTimeUnixNano:   unixNanoString(span.EndTime),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: int(span.StatusCode), Message: span.StatusMessage},
+		}
+		for _, event := range span.Events {
+			s.Events = append(s.Events, otlpEvent{
+				TimeUnixNano: unixNanoString(event.Time),
+				Name:         event.Name,
+				Attributes:   otlpAttributes(event.Attributes),
+			})
+		}
+		encoded = append(encoded, s)
+	}
+
+	return otlpTraceRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": serviceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "ralph-loop"}, Spans: encoded}},
+	}}}
+}
+
+func unixNanoString(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpAttributes 依鍵排序轉換屬性，讓輸出穩定
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, otlpKeyValue{Key: k, Value: otlpAttributeValue(attrs[k])})
+	}
+	return result
+}
+
+func otlpAttributeValue(v interface{}) otlpValue {
+	switch value := v.(type) {
+	case bool:
+		return otlpValue{BoolValue: &value}
+	case int:
+		s := strconv.Itoa(value)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &value}
+	case time.Duration:
+		s := strconv.FormatInt(value.Milliseconds(), 10)
+		return otlpValue{IntValue: &s}
+	case string:
+		return otlpValue{StringValue: &value}
+	default:
+		s := fmt.Sprint(value)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+// FileSpanExporter 將 span 以 OTLP/JSON 附加寫入檔案（每次匯出一行）
+//
+// 格式與 OpenTelemetry Collector 的 file exporter 相同，可直接交給 collector 的
+// otlpjsonfile receiver 讀取。
+type FileSpanExporter struct {
+	mu          sync.Mutex
+	path        string
+	serviceName string
+	file        *os.File
+}
+
+// NewFileSpanExporter 開啟（或建立）追蹤檔
+func NewFileSpanExporter(path, serviceName string) (*FileSpanExporter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("無法建立追蹤目錄: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("無法開啟追蹤檔: %w", err)
+	}
+	return &FileSpanExporter{path: path, serviceName: serviceName, file: file}, nil
+}
+
+// Path 傳回追蹤檔路徑
+func (e *FileSpanExporter) Path() string {
+	return e.path
+}
+
+// ExportSpans 實作 SpanExporter
+func (e *FileSpanExporter) ExportSpans(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return fmt.Errorf("trace file is closed")
+	}
+	return json.NewEncoder(e.file).Encode(encodeOTLPTraces(e.serviceName, spans))
+}
+
+// Shutdown 實作 SpanExporter
+func (e *FileSpanExporter) Shutdown() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return nil
+	}
+	err := e.file.Close()
+	e.file = nil
+	return err
+}
+
+// OTLPHTTPExporter 以 OTLP/HTTP（JSON 編碼）將 span 送到 collector
+type OTLPHTTPExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPHTTPExporter 建立 OTLP/HTTP 匯出器
+//
+// endpoint 可為 collector 的基底位址（例如 http://localhost:4318），
+// 未包含路徑時自動加上 /v1/traces。
+func NewOTLPHTTPExporter(endpoint, serviceName string) *OTLPHTTPExporter {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &OTLPHTTPExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Endpoint 傳回實際送出的 URL
+func (e *OTLPHTTPExporter) Endpoint() string {
+	return e.endpoint
+}
+
+// ExportSpans 實作 SpanExporter
+func (e *OTLPHTTPExporter) ExportSpans(spans []*Span) error {
+	body, err := json.Marshal(encodeOTLPTraces(e.serviceName, spans))
+	if err != nil {
+		return fmt.Errorf("OTLP 編碼失敗: %w", err)
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("OTLP 匯出失敗: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP 匯出失敗: %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown 實作 SpanExporter
+func (e *OTLPHTTPExporter) Shutdown() error {
+	return nil
+}
+
+// ReadTraceFile 讀取追蹤檔中的所有 span（依開始時間排序）
+func ReadTraceFile(path string) ([]*Span, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("無法開啟追蹤檔: %w", err)
+	}
+	defer file.Close()
+
+	var spans []*Span
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var req otlpTraceRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return spans, fmt.Errorf("追蹤檔第 %d 行格式錯誤: %w", line, err)
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans = append(spans, decodeOTLPSpan(s))
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return spans, fmt.Errorf("讀取追蹤檔失敗: %w", err)
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].StartTime.Before(spans[j].StartTime) })
+	return spans, nil
+}
+
+func decodeOTLPSpan(s otlpSpan) *Span {
+	span := &Span{
+		TraceID:       s.TraceID,
+		SpanID:        s.SpanID,
+		ParentSpanID:  s.ParentSpanID,
+		Name:          s.Name,
+		Kind:          SpanKind(s.Kind),
+		StartTime:     parseUnixNano(s.StartTimeUnixNano),
+		EndTime:       parseUnixNano(s.EndTimeUnixNano),
+		Attributes:    decodeOTLPAttributes(s.Attributes),
+		StatusCode:    SpanStatusCode(s.Status.Code),
+		StatusMessage: s.Status.Message,
+	}
+	for _, event := range s.Events {
+		span.Events = append(span.Events, SpanEvent{
+			Name:       event.Name,
+			Time:       parseUnixNano(event.TimeUnixNano),
+			Attributes: decodeOTLPAttributes(event.Attributes),
+		})
+	}
+	return span
+}
+
+func parseUnixNano(s string) time.Time {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return time.Unix(0, n)
+}
+
+func decodeOTLPAttributes(kvs []otlpKeyValue) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		switch {
+		case kv.Value.StringValue != nil:
+			attrs[kv.Key] = *kv.Value.StringValue
+		case kv.Value.BoolValue != nil:
+			attrs[kv.Key] = *kv.Value.BoolValue
+		case kv.Value.IntValue != nil:
+			n, _ := strconv.ParseInt(*kv.Value.IntValue, 10, 64)
+			attrs[kv.Key] = n
+		case kv.Value.DoubleValue != nil:
+			attrs[kv.Key] = *kv.Value.DoubleValue
+		}
+	}
+	return attrs
+}
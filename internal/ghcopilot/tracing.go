@@ -0,0 +1,287 @@
+package ghcopilot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpanKind 對應 OTLP 的 span kind
+type SpanKind int
+
+const (
+	// SpanKindInternal 程序內部的操作
+	SpanKindInternal SpanKind = 1
+	// SpanKindClient 呼叫外部程序或服務（例如 Copilot CLI）
+	SpanKindClient SpanKind = 3
+)
+
+// SpanStatusCode 對應 OTLP 的狀態碼
+type SpanStatusCode int
+
+const (
+	SpanStatusUnset SpanStatusCode = 0
+	SpanStatusOK    SpanStatusCode = 1
+	SpanStatusError SpanStatusCode = 2
+)
+
+// SpanEvent 是 span 內帶時間戳記的事件（例如重試、錯誤）
+type SpanEvent struct {
+	Name       string                 `json:"name"`
+	Time       time.Time              `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Span 代表一段被追蹤的操作
+//
+// 結構與 OpenTelemetry 相容（trace/span ID 為 16/8 bytes 的十六進位字串），
+// 結束後由 Tracer 交給匯出器。所有方法都可並行呼叫；nil span 的方法不做任何事。
+type Span struct {
+	mu     sync.Mutex
+	tracer *Tracer
+	ended  bool
+
+	TraceID       string
+	SpanID        string
+	ParentSpanID  string
+	Name          string
+	Kind          SpanKind
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]interface{}
+	Events        []SpanEvent
+	StatusCode    SpanStatusCode
+	StatusMessage string
+}
+
+// SetAttr 設定屬性（值應為 string、bool、整數或浮點數）
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// AddEvent 加入事件
+func (s *Span) AddEvent(name string, attrs map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Events = append(s.Events, SpanEvent{Name: name, Time: time.Now(), Attributes: attrs})
+}
+
+// RecordError 記錄錯誤事件並將狀態設為錯誤（err 為 nil 時不做任何事）
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception", map[string]interface{}{"exception.message": err.Error()})
+	s.SetStatus(SpanStatusError, err.Error())
+}
+
+// SetStatus 設定狀態
+func (s *Span) SetStatus(code SpanStatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.StatusCode = code
+	s.StatusMessage = message
+}
+
+// End 結束 span 並交給 Tracer；重複呼叫只有第一次有效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.finish(s)
+}
+
+// TraceParent 傳回 W3C traceparent 標頭值，供子程序延續同一個 trace
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", s.TraceID, s.SpanID)
+}
+
+// snapshot 複製 span 的資料（匯出時使用，避免與仍在寫入的欄位競爭）
+func (s *Span) snapshot() *Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := make(map[string]interface{}, len(s.Attributes))
+	for k, v := range s.Attributes {
+		attrs[k] = v
+	}
+	return &Span{
+		TraceID:       s.TraceID,
+		SpanID:        s.SpanID,
+		ParentSpanID:  s.ParentSpanID,
+		Name:          s.Name,
+		Kind:          s.Kind,
+		StartTime:     s.StartTime,
+		EndTime:       s.EndTime,
+		Attributes:    attrs,
+		Events:        append([]SpanEvent{}, s.Events...),
+		StatusCode:    s.StatusCode,
+		StatusMessage: s.StatusMessage,
+	}
+}
+
+// SpanExporter 接收已結束的 span
+type SpanExporter interface {
+	ExportSpans(spans []*Span) error
+	Shutdown() error
+}
+
+// Tracer 建立 span，並在 Flush 時將已結束的 span 交給匯出器
+type Tracer struct {
+	mu          sync.Mutex
+	serviceName string
+	exporters   []SpanExporter
+	pending     []*Span
+}
+
+// NewTracer 建立 Tracer（沒有匯出器時仍會產生 trace ID，供傳遞給子程序）
+func NewTracer(serviceName string, exporters ...SpanExporter) *Tracer {
+	return &Tracer{serviceName: serviceName, exporters: exporters}
+}
+
+// ServiceName 傳回 service.name 資源屬性
+func (t *Tracer) ServiceName() string {
+	return t.serviceName
+}
+
+// AddExporter 加入匯出器
+func (t *Tracer) AddExporter(exporter SpanExporter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exporters = append(t.exporters, exporter)
+}
+
+// Start 建立新的根 span（開始新的 trace）
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := t.newSpan(newTraceID(), "", name, kind)
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) newSpan(traceID, parentID, name string, kind SpanKind) *Span {
+	return &Span{
+		tracer:       t,
+		TraceID:      traceID,
+		SpanID:       newSpanID(),
+		ParentSpanID: parentID,
+		Name:         name,
+		Kind:         kind,
+		StartTime:    time.Now(),
+		Attributes:   make(map[string]interface{}),
+	}
+}
+
+func (t *Tracer) finish(span *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, span.snapshot())
+}
+
+// Flush 將已結束的 span 交給所有匯出器，傳回第一個錯誤
+func (t *Tracer) Flush() error {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	exporters := append([]SpanExporter{}, t.exporters...)
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	var firstErr error
+	for _, exporter := range exporters {
+		if err := exporter.ExportSpans(spans); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Shutdown 送出剩餘的 span 並關閉匯出器
+func (t *Tracer) Shutdown() error {
+	firstErr := t.Flush()
+
+	t.mu.Lock()
+	exporters := t.exporters
+	t.exporters = nil
+	t.mu.Unlock()
+
+	for _, exporter := range exporters {
+		if err := exporter.Shutdown(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan 將 span 放入 context，之後的 StartSpan 會建立它的子 span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext 取得 context 中目前的 span（沒有時為 nil）
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// StartSpan 在 context 中目前的 span 下建立子 span
+//
+// context 中沒有 span（未啟用追蹤）時傳回 nil span，其方法都不做任何事，
+// 呼叫端不需要判斷追蹤是否啟用。
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := parent.tracer.newSpan(parent.TraceID, parent.SpanID, name, kind)
+	return ContextWithSpan(ctx, span), span
+}
+
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand 失敗時退回以時間產生，仍可保持 ID 唯一
+		now := time.Now().UnixNano()
+		for i := range b {
+			b[i] = byte(now >> (8 * (i % 8)))
+		}
+		b[0] |= 1
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,308 @@
+package ghcopilot
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memorySpanExporter 將匯出的 span 保留在記憶體中（測試用）
+type memorySpanExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *memorySpanExporter) ExportSpans(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memorySpanExporter) Shutdown() error {
+	return nil
+}
+
+// TestTracerSpanTree 測試子 span 延續父 span 的 trace 並在 Flush 時匯出
+func TestTracerSpanTree(t *testing.T) {
+	exporter := &memorySpanExporter{}
+	tracer := NewTracer("test", exporter)
+
+	ctx, root := tracer.Start(t.Context(), "root", SpanKindInternal)
+	_, child := StartSpan(ctx, "child", SpanKindClient)
+	child.SetAttr("attempt", 1)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	child.End()
+	root.End()
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Flush 失敗: %v", err)
+	}
+	if len(exporter.spans) != 2 {
+		t.Fatalf("應匯出 2 個 span（重複 End 不應重複匯出），實際 %d", len(exporter.spans))
+	}
+
+	exported := exporter.spans[0]
+	if exported.Name != "child" || exported.TraceID != root.TraceID || exported.ParentSpanID != root.SpanID {
+		t.Errorf("子 span 應屬於根 span 的 trace: %+v", exported)
+	}
+	if exported.StatusCode != SpanStatusError || exported.StatusMessage != "boom" || len(exported.Events) != 1 {
+		t.Errorf("錯誤應記錄為狀態與事件: %+v", exported)
+	}
+	if len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+		t.Errorf("ID 長度應符合 W3C trace context: %q %q", root.TraceID, root.SpanID)
+	}
+	if want := "00-" + root.TraceID + "-" + root.SpanID + "-01"; root.TraceParent() != want {
+		t.Errorf("TraceParent = %q，應為 %q", root.TraceParent(), want)
+	}
+}
+
+// TestStartSpanWithoutParent 測試未啟用追蹤時 span 為 nil 且可安全呼叫
+func TestStartSpanWithoutParent(t *testing.T) {
+	ctx, span := StartSpan(t.Context(), "orphan", SpanKindInternal)
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("沒有父 span 時不應建立 span")
+	}
+	span.SetAttr("k", "v")
+	span.RecordError(errors.New("ignored"))
+	span.End()
+	if span.TraceParent() != "" {
+		t.Error("nil span 不應有 traceparent")
+	}
+}
+
+// TestFileSpanExporterRoundTrip 測試追蹤檔以 OTLP/JSON 寫入並可讀回
+func TestFileSpanExporterRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", TraceFileName)
+	exporter, err := NewFileSpanExporter(path, "ralph-loop")
+	if err != nil {
+		t.Fatalf("NewFileSpanExporter 失敗: %v", err)
+	}
+	tracer := NewTracer("ralph-loop", exporter)
+
+	ctx, root := tracer.Start(t.Context(), "ralph.run", SpanKindInternal)
+	_, child := StartSpan(ctx, "ralph.loop", SpanKindInternal)
+	child.SetAttr("loop.index", 3)
+	child.SetAttr("loop.should_continue", true)
+	child.SetAttr("loop.exit_reason", "done")
+	child.End()
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Flush 失敗: %v", err)
+	}
+	root.End()
+	if err := tracer.Shutdown(); err != nil {
+		t.Fatalf("Shutdown 失敗: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("讀取追蹤檔失敗: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("每次 Flush 應寫入一行，實際 %d 行", len(lines))
+	}
+	if !strings.Contains(lines[0], `"resourceSpans"`) || !strings.Contains(lines[0], `"intValue":"3"`) {
+		t.Errorf("應為 OTLP/JSON 格式: %s", lines[0])
+	}
+
+	spans, err := ReadTraceFile(path)
+	if err != nil {
+		t.Fatalf("ReadTraceFile 失敗: %v", err)
+	}
+	if len(spans) != 2 || spans[0].Name != "ralph.run" || spans[1].Name != "ralph.loop" {
+		t.Fatalf("應依開始時間讀回 2 個 span: %+v", spans)
+	}
+	loop := spans[1]
+	if loop.ParentSpanID != spans[0].SpanID {
+		t.Error("讀回的 span 應保留父子關係")
+	}
+	if loop.Attributes["loop.index"] != int64(3) || loop.Attributes["loop.should_continue"] != true || loop.Attributes["loop.exit_reason"] != "done" {
+		t.Errorf("屬性應完整讀回: %v", loop.Attributes)
+	}
+}
+
+// TestOTLPHTTPExporter 測試 OTLP/HTTP 匯出器送出 JSON 請求
+func TestOTLPHTTPExporter(t *testing.T) {
+	var gotPath, gotType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPHTTPExporter(server.URL+"/", "ralph-loop")
+	if exporter.Endpoint() != server.URL+"/v1/traces" {
+		t.Errorf("應自動加上 /v1/traces: %s", exporter.Endpoint())
+	}
+
+	tracer := NewTracer("ralph-loop", exporter)
+	_, span := tracer.Start(t.Context(), "ralph.run", SpanKindInternal)
+	span.End()
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Flush 失敗: %v", err)
+	}
+
+	if gotPath != "/v1/traces" || gotType != "application/json" {
+		t.Errorf("請求路徑或型別錯誤: %s %s", gotPath, gotType)
+	}
+	var req otlpTraceRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("請求內容應為 OTLP/JSON: %v", err)
+	}
+	if len(req.ResourceSpans) != 1 || req.ResourceSpans[0].ScopeSpans[0].Spans[0].TraceID != span.TraceID {
+		t.Errorf("請求應包含 span: %s", body)
+	}
+}
+
+// TestOTLPHTTPExporterError 測試 collector 回傳錯誤時 Flush 傳回錯誤
+func TestOTLPHTTPExporterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("ralph-loop", NewOTLPHTTPExporter(server.URL, "ralph-loop"))
+	_, span := tracer.Start(t.Context(), "ralph.run", SpanKindInternal)
+	span.End()
+	if err := tracer.Flush(); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("應傳回 collector 的錯誤: %v", err)
+	}
+}
+
+// TestClientTracesLoops 測試客戶端將迴圈追蹤寫入 run 目錄，並將 trace 傳給 Copilot CLI
+func TestClientTracesLoops(t *testing.T) {
+	workDir := t.TempDir()
+	envFile := filepath.Join(t.TempDir(), "env")
+	installFakeCopilot(t, `echo "TRACEPARENT=$TRACEPARENT RALPH_TRACE_ID=$RALPH_TRACE_ID" >> "`+envFile+`"
+echo "still working"
+`)
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	client := NewRalphLoopClientWithConfig(config)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ExecuteLoop(t.Context(), "任務"); err != nil {
+			t.Fatalf("ExecuteLoop 失敗: %v", err)
+		}
+	}
+	traceID := client.TraceID()
+	if len(traceID) != 32 {
+		t.Fatalf("應產生 trace ID: %q", traceID)
+	}
+
+	// 迴圈 span 在每個迴圈結束時即寫入
+	spans, err := ReadTraceFile(client.TracePath())
+	if err != nil {
+		t.Fatalf("ReadTraceFile 失敗: %v", err)
+	}
+	if countSpans(spans, "ralph.loop") != 2 {
+		t.Errorf("每個迴圈應有一個 span: %v", spanNames(spans))
+	}
+	client.Close()
+
+	if client.TracePath() != filepath.Join(client.RunDir(), TraceFileName) {
+		t.Fatalf("追蹤檔應位於 run 目錄: %q", client.TracePath())
+	}
+	spans, err = ReadTraceFile(client.TracePath())
+	if err != nil {
+		t.Fatalf("ReadTraceFile 失敗: %v", err)
+	}
+
+	byID := map[string]*Span{}
+	for _, span := range spans {
+		if span.TraceID != traceID {
+			t.Errorf("所有 span 應屬於同一個 trace: %s", span.Name)
+		}
+		byID[span.SpanID] = span
+	}
+	for _, name := range []string{"ralph.run", "ralph.prompt.build", "ralph.executor.cli", "copilot.cli.attempt", "ralph.workspace.policies", "ralph.analysis", "ralph.hooks.post_loop"} {
+		if countSpans(spans, name) == 0 {
+			t.Errorf("缺少 %s span: %v", name, spanNames(spans))
+		}
+	}
+	for _, span := range spans {
+		if span.Name == "ralph.executor.cli" {
+			if parent := byID[span.ParentSpanID]; parent == nil || parent.Name != "ralph.loop" {
+				t.Errorf("執行器 span 應在迴圈 span 之下")
+			}
+		}
+		if span.Name == "ralph.loop" && span.Attributes["loop.outcome"] != LoopOutcomeContinue {
+			t.Errorf("迴圈 span 應記錄結果: %v", span.Attributes)
+		}
+	}
+
+	env, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("讀取環境變數紀錄失敗: %v", err)
+	}
+	if !strings.Contains(string(env), "TRACEPARENT=00-"+traceID+"-") || !strings.Contains(string(env), "RALPH_TRACE_ID="+traceID) {
+		t.Errorf("Copilot CLI 應收到 trace 環境變數: %s", env)
+	}
+}
+
+// TestClientTracingDisabled 測試停用追蹤時不寫入追蹤檔也不傳遞環境變數
+func TestClientTracingDisabled(t *testing.T) {
+	workDir := t.TempDir()
+	envFile := filepath.Join(t.TempDir(), "env")
+	installFakeCopilot(t, `echo "TRACEPARENT=$TRACEPARENT" >> "`+envFile+`"
+echo "still working"
+`)
+
+	config := DefaultClientConfig()
+	config.WorkDir = workDir
+	config.SaveDir = filepath.Join(workDir, ".ralph-loop", "saves")
+	config.RunsDir = filepath.Join(workDir, ".ralph-loop", "runs")
+	config.ProtectedPaths = nil
+	config.EnableSDK = false
+	config.EnableTracing = false
+	client := NewRalphLoopClientWithConfig(config)
+
+	if _, err := client.ExecuteLoop(t.Context(), "任務"); err != nil {
+		t.Fatalf("ExecuteLoop 失敗: %v", err)
+	}
+	client.Close()
+
+	if client.TraceID() != "" || client.TracePath() != "" {
+		t.Error("停用追蹤時不應有 trace")
+	}
+	if _, err := os.Stat(filepath.Join(client.RunDir(), TraceFileName)); !os.IsNotExist(err) {
+		t.Error("停用追蹤時不應建立追蹤檔")
+	}
+	if env, _ := os.ReadFile(envFile); strings.TrimSpace(string(env)) != "TRACEPARENT=" {
+		t.Errorf("停用追蹤時不應傳遞 TRACEPARENT: %s", env)
+	}
+}
+
+func countSpans(spans []*Span, name string) int {
+	count := 0
+	for _, span := range spans {
+		if span.Name == name {
+			count++
+		}
+	}
+	return count
+}
+
+func spanNames(spans []*Span) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
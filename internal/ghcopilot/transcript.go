@@ -0,0 +1,214 @@
+package ghcopilot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TranscriptFileName 執行器錄製檔在 run 目錄中的檔名
+const TranscriptFileName = "transcript.jsonl"
+
+// ErrTranscriptExhausted 錄製的回應已全部回放完畢
+var ErrTranscriptExhausted = errors.New("transcript exhausted")
+
+// PromptExecutor 執行一次迴圈的 prompt
+//
+// CLIExecutor 實作此介面；ReplayExecutor 依序回放錄製的回應，
+// 透過 ClientConfig.Executor 取代 copilot CLI。
+type PromptExecutor interface {
+	ExecutePrompt(ctx context.Context, prompt string) (*ExecutionResult, error)
+}
+
+// TranscriptEntry 是一次執行器呼叫的請求與回應
+type TranscriptEntry struct {
+	Seq       int       `json:"seq"`
+	LoopIndex int       `json:"loop_index"`
+	Mode      string    `json:"mode"` // sdk 或 cli
+	Timestamp time.Time `json:"timestamp"`
+	Prompt    string    `json:"prompt"`
+	Args      []string  `json:"args,omitempty"` // 只有 CLI
+	Command   string    `json:"command,omitempty"`
+	Stdout    string    `json:"stdout"`
+	Stderr    string    `json:"stderr,omitempty"`
+	ExitCode  int       `json:"exit_code"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latency_ms"`
+}
+
+// TranscriptRecorder 將執行器呼叫以 JSON Lines 格式附加寫入 run 目錄
+//
+// 與 journal.jsonl 不同，錄製檔保存完整的 prompt 與輸出，
+// 供 ReplayExecutor 重現同一次執行的模型回應。
+type TranscriptRecorder struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	enc  *json.Encoder
+	seq  int
+}
+
+// OpenTranscript 開啟（或建立）錄製檔
+func OpenTranscript(path string) (*TranscriptRecorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("無法建立執行紀錄目錄: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("無法開啟錄製檔: %w", err)
+	}
+
+	return &TranscriptRecorder{
+		path: path,
+		file: file,
+		enc:  json.NewEncoder(file),
+	}, nil
+}
+
+// Path 傳回錄製檔路徑
+func (r *TranscriptRecorder) Path() string {
+	return r.path
+}
+
+// Record 寫入一次呼叫（Seq 由錄製器依序編號）
+func (r *TranscriptRecorder) Record(entry TranscriptEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("transcript is closed")
+	}
+	r.seq++
+	entry.Seq = r.seq
+	return r.enc.Encode(entry)
+}
+
+// Close 關閉錄製檔
+func (r *TranscriptRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// ReadTranscript 讀取錄製檔中的所有呼叫
+func ReadTranscript(path string) ([]TranscriptEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("無法開啟錄製檔: %w", err)
+	}
+	defer file.Close()
+
+	var entries []TranscriptEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("錄製檔第 %d 行格式錯誤: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("讀取錄製檔失敗: %w", err)
+	}
+
+	return entries, nil
+}
+
+// TranscriptLoops 傳回錄製檔涵蓋的迴圈數
+func TranscriptLoops(entries []TranscriptEntry) int {
+	loops := 0
+	for _, entry := range entries {
+		loops = max(loops, entry.LoopIndex+1)
+	}
+	return loops
+}
+
+// ReplayExecutor 依序回放錄製的執行器回應
+//
+// 不執行任何程式也不等待原本的延遲，讓分析、熔斷與退出邏輯可以決定性地重現。
+// SDK 失敗後在同一個迴圈改用 CLI 的呼叫只回放最後使用的回應；
+// 回放完畢後的呼叫傳回 ErrTranscriptExhausted。
+type ReplayExecutor struct {
+	mu         sync.Mutex
+	entries    []TranscriptEntry
+	next       int
+	mismatches []int
+}
+
+// NewReplayExecutor 以錄製的呼叫建立回放執行器
+func NewReplayExecutor(entries []TranscriptEntry) *ReplayExecutor {
+	var replay []TranscriptEntry
+	for i, entry := range entries {
+		fallback := entry.Mode == ModeSDK.String() && entry.Error != "" &&
+			i+1 < len(entries) && entries[i+1].LoopIndex == entry.LoopIndex
+		if !fallback {
+			replay = append(replay, entry)
+		}
+	}
+	return &ReplayExecutor{entries: replay}
+}
+
+// ExecutePrompt 傳回下一個錄製的回應（prompt 與錄製時不同時記錄在 Mismatches）
+func (r *ReplayExecutor) ExecutePrompt(ctx context.Context, prompt string) (*ExecutionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.entries) {
+		return nil, ErrTranscriptExhausted
+	}
+	entry := r.entries[r.next]
+	r.next++
+	if entry.Prompt != prompt {
+		r.mismatches = append(r.mismatches, entry.Seq)
+	}
+
+	result := &ExecutionResult{
+		Command:       entry.Command,
+		Args:          entry.Args,
+		Stdout:        entry.Stdout,
+		Stderr:        entry.Stderr,
+		ExitCode:      entry.ExitCode,
+		ExecutionTime: time.Duration(entry.LatencyMs) * time.Millisecond,
+		Success:       entry.Error == "" && entry.ExitCode == 0,
+	}
+	if entry.Error != "" {
+		result.Error = errors.New(entry.Error)
+		return result, result.Error
+	}
+	return result, nil
+}
+
+// Remaining 傳回尚未回放的回應數
+func (r *ReplayExecutor) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries) - r.next
+}
+
+// Mismatches 傳回 prompt 與錄製時不同的呼叫編號（Seq）
+func (r *ReplayExecutor) Mismatches() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int{}, r.mismatches...)
+}
@@ -0,0 +1,67 @@
+package ghcopilot
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run-x", TranscriptFileName)
+	recorder, err := OpenTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Record(TranscriptEntry{LoopIndex: 0, Mode: "cli", Prompt: "修正", Args: []string{"-p", "修正"}, Stdout: "第一次", Stderr: "warn", LatencyMs: 12})
+	recorder.Record(TranscriptEntry{LoopIndex: 1, Mode: "cli", Prompt: "修正", ExitCode: 2})
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Record(TranscriptEntry{}); err == nil {
+		t.Error("關閉後寫入應傳回錯誤")
+	}
+
+	entries, err := ReadTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Seq != 1 || entries[1].Seq != 2 {
+		t.Fatalf("呼叫應依序編號: %+v", entries)
+	}
+	if entries[0].Stderr != "warn" || len(entries[0].Args) != 2 || entries[0].LatencyMs != 12 || entries[1].ExitCode != 2 {
+		t.Errorf("讀回的內容不正確: %+v", entries)
+	}
+	if TranscriptLoops(entries) != 2 {
+		t.Errorf("TranscriptLoops = %d, 期望 2", TranscriptLoops(entries))
+	}
+}
+
+func TestReplayExecutor(t *testing.T) {
+	replay := NewReplayExecutor([]TranscriptEntry{
+		{Seq: 1, LoopIndex: 0, Mode: "sdk", Prompt: "p", Error: "sdk unavailable"},
+		{Seq: 2, LoopIndex: 0, Mode: "cli", Prompt: "p", Command: "copilot -p p", Stdout: "第一次", LatencyMs: 1500},
+		{Seq: 3, LoopIndex: 1, Mode: "cli", Prompt: "p", Stderr: "timeout", Error: "signal: killed"},
+		{Seq: 4, LoopIndex: 2, Mode: "sdk", Prompt: "p", Error: "sdk crashed"},
+	})
+	if replay.Remaining() != 3 {
+		t.Fatalf("改用 CLI 前失敗的 SDK 呼叫不應回放: %d", replay.Remaining())
+	}
+
+	result, err := replay.ExecutePrompt(t.Context(), "p")
+	if err != nil || result.Stdout != "第一次" || result.Command != "copilot -p p" || !result.Success || result.ExecutionTime.Milliseconds() != 1500 {
+		t.Errorf("第一個回應不正確: %+v, %v", result, err)
+	}
+	result, err = replay.ExecutePrompt(t.Context(), "另一個 prompt")
+	if err == nil || err.Error() != "signal: killed" || result.Stderr != "timeout" || result.Success {
+		t.Errorf("錄製的錯誤應原樣傳回: %+v, %v", result, err)
+	}
+	if _, err := replay.ExecutePrompt(t.Context(), "p"); err == nil || err.Error() != "sdk crashed" {
+		t.Errorf("迴圈最後的 SDK 錯誤應回放: %v", err)
+	}
+	if _, err := replay.ExecutePrompt(t.Context(), "p"); !errors.Is(err, ErrTranscriptExhausted) {
+		t.Errorf("回放完畢後應傳回 ErrTranscriptExhausted: %v", err)
+	}
+	if m := replay.Mismatches(); len(m) != 1 || m[0] != 3 {
+		t.Errorf("Mismatches = %v, 期望 [3]", m)
+	}
+}
@@ -0,0 +1,741 @@
+package ghcopilot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// TUI 保留的行數上限
+const (
+	tuiMaxOutputLines = 1000
+	tuiMaxSignals     = 100
+	tuiMaxLogLines    = 100
+)
+
+// tuiLoop 是迴圈歷史表格中的一列
+type tuiLoop struct {
+	index          int
+	started        time.Time
+	finished       bool
+	duration       time.Duration
+	score          int
+	scored         bool
+	failed         bool
+	shouldContinue bool
+	exitReason     string
+}
+
+// tuiApproval 是等待操作者按鍵的審核
+type tuiApproval struct {
+	req      *ApprovalRequest
+	feedback string
+	decision chan ApprovalDecision
+}
+
+// tuiInput 是正在輸入的單行文字（例如回饋）
+type tuiInput struct {
+	prompt string
+	buf    []byte
+	submit func(text string) *ControlRequest // 傳回要送出的控制指令（沒有時為 nil）
+}
+
+// TerminalUI 全螢幕終端機介面（ralph-loop run/watch -tui）
+//
+// 畫面分為即時輸出、迴圈歷史（索引、耗時、完成分數、退出理由）、
+// 熔斷器與退出訊號，以及日誌與按鍵說明。狀態完全由事件建立，
+// 因此可以訂閱本機的事件匯流排，也可以透過 FollowJournal 追蹤另一個程序的執行紀錄。
+//
+// 按鍵：p 暫停/恢復、s 停止、f 送出回饋；審核時 a 接受、r 拒絕、e 編輯回饋、s 停止。
+type TerminalUI struct {
+	mu      sync.Mutex
+	out     io.Writer
+	control ControlFunc // nil 時停用控制按鍵
+	width   int
+	height  int
+	now     func() time.Time
+
+	runID        string
+	loops        []*tuiLoop
+	output       []string
+	partial      string // assistant 輸出中尚未換行的部分
+	breakerState string
+	lastStatus   *LoopStatus
+	signals      []string
+	logs         []string
+	logPartial   string
+	state        ControlState
+	stateErr     error
+	message      string
+	approval     *tuiApproval
+	input        *tuiInput
+	dirty        bool
+
+	in        *os.File
+	savedTTY  string
+	stop      chan struct{}
+	done      sync.WaitGroup
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewTerminalUI 建立終端機介面；control 為 nil 時只顯示、不接受控制按鍵
+func NewTerminalUI(out io.Writer, control ControlFunc) *TerminalUI {
+	return &TerminalUI{
+		out:          out,
+		control:      control,
+		width:        80,
+		height:       24,
+		now:          time.Now,
+		breakerState: string(StateClosed),
+		stop:         make(chan struct{}),
+		dirty:        true,
+	}
+}
+
+// SetSize 設定畫面大小（Start 會自動取得終端機大小）
+func (ui *TerminalUI) SetSize(width, height int) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.width, ui.height = width, height
+	ui.dirty = true
+}
+
+// SetControl 設定控制按鍵送出指令的方式（nil 表示停用控制按鍵）
+func (ui *TerminalUI) SetControl(control ControlFunc) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.control = control
+	ui.dirty = true
+}
+
+// Reset 清除目前顯示的執行（例如 watch 切換到新的 run）
+func (ui *TerminalUI) Reset() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.runID = ""
+	ui.loops = nil
+	ui.output = nil
+	ui.partial = ""
+	ui.breakerState = string(StateClosed)
+	ui.lastStatus = nil
+	ui.signals = nil
+	ui.dirty = true
+}
+
+// Handle 處理單一事件，可直接傳給 Subscribe 或 FollowJournal
+func (ui *TerminalUI) Handle(event LoopEvent) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.dirty = true
+	if ui.runID == "" {
+		ui.runID = event.RunID
+	}
+
+	switch event.Type {
+	case EventLoopStarted:
+		loop := ui.loopFor(event.LoopIndex)
+		loop.started = event.Timestamp
+		ui.flushPartial()
+		ui.appendOutput(fmt.Sprintf("── 迴圈 %d ──", event.LoopIndex+1))
+
+	case EventOutputChunk:
+		switch event.Stream {
+		case StreamAssistant:
+			// SDK 的 delta 不一定以換行結尾
+			text := ui.partial + event.Text
+			lines := strings.Split(text, "\n")
+			for _, line := range lines[:len(lines)-1] {
+				ui.appendOutput(line)
+			}
+			ui.partial = lines[len(lines)-1]
+		case StreamStderr:
+			ui.flushPartial()
+			ui.appendOutput("! " + event.Text)
+		default:
+			ui.flushPartial()
+			ui.appendOutput(event.Text)
+		}
+
+	case EventToolCall:
+		ui.flushPartial()
+		ui.appendOutput("🔧 " + event.Tool)
+
+	case EventLoopAnalyzed:
+		loop := ui.loopFor(event.LoopIndex)
+		loop.scored = decodeEventData(event.Data, "completion_score", &loop.score)
+		var status LoopStatus
+		if decodeEventData(event.Data, "structured_status", &status) && status.Status != "" {
+			ui.lastStatus = &status
+			if status.ExitSignal {
+				ui.addSignal(event, fmt.Sprintf("#%d EXIT_SIGNAL (%s)", event.LoopIndex+1, status.Status))
+			}
+		}
+
+	case EventBreakerStateChanged:
+		ui.breakerState = event.BreakerState
+		ui.addSignal(event, fmt.Sprintf("#%d 熔斷器 %s → %s", event.LoopIndex+1, event.PreviousState, event.BreakerState))
+
+	case EventHookFinished:
+		var ok bool
+		if decodeEventData(event.Data, "ok", &ok) && !ok {
+			var point, command string
+			decodeEventData(event.Data, "point", &point)
+			decodeEventData(event.Data, "command", &command)
+			if command == "" {
+				command = "Go hook"
+			}
+			ui.addSignal(event, fmt.Sprintf("#%d %s 失敗: %s", event.LoopIndex+1, point, command))
+		}
+
+	case EventLoopFinished:
+		ui.flushPartial()
+		loop := ui.loopFor(event.LoopIndex)
+		loop.finished = true
+		loop.failed = event.Failed
+		loop.shouldContinue = event.ShouldContinue
+		loop.exitReason = event.ExitReason
+		var ms int64
+		if decodeEventData(event.Data, "duration_ms", &ms) {
+			loop.duration = time.Duration(ms) * time.Millisecond
+		} else if !loop.started.IsZero() {
+			loop.duration = event.Timestamp.Sub(loop.started)
+		}
+		if !event.ShouldContinue && event.ExitReason != "" {
+			ui.addSignal(event, fmt.Sprintf("#%d 結束: %s", event.LoopIndex+1, event.ExitReason))
+		}
+	}
+}
+
+// loopFor 取得（或建立）迴圈歷史中的一列
+func (ui *TerminalUI) loopFor(index int) *tuiLoop {
+	for _, loop := range ui.loops {
+		if loop.index == index {
+			return loop
+		}
+	}
+	loop := &tuiLoop{index: index}
+	ui.loops = append(ui.loops, loop)
+	return loop
+}
+
+func (ui *TerminalUI) appendOutput(line string) {
+	ui.output = appendBounded(ui.output, sanitizeTerminalText(line), tuiMaxOutputLines)
+}
+
+func (ui *TerminalUI) flushPartial() {
+	if ui.partial != "" {
+		ui.appendOutput(ui.partial)
+		ui.partial = ""
+	}
+}
+
+func (ui *TerminalUI) addSignal(event LoopEvent, text string) {
+	ui.signals = appendBounded(ui.signals, event.Timestamp.Format("15:04:05")+" "+text, tuiMaxSignals)
+}
+
+// appendBounded 附加一行並只保留最後 limit 行
+func appendBounded(lines []string, line string, limit int) []string {
+	lines = append(lines, line)
+	if len(lines) > limit {
+		lines = append(lines[:0], lines[len(lines)-limit:]...)
+	}
+	return lines
+}
+
+// LogWriter 傳回寫入日誌窗格的 io.Writer（供 slog handler 使用，避免日誌破壞畫面）
+func (ui *TerminalUI) LogWriter() io.Writer {
+	return tuiLogWriter{ui}
+}
+
+type tuiLogWriter struct{ ui *TerminalUI }
+
+func (w tuiLogWriter) Write(p []byte) (int, error) {
+	ui := w.ui
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	lines := strings.Split(ui.logPartial+string(p), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		ui.logs = appendBounded(ui.logs, sanitizeTerminalText(line), tuiMaxLogLines)
+	}
+	ui.logPartial = lines[len(lines)-1]
+	ui.dirty = true
+	return len(p), nil
+}
+
+// Approve 在畫面上顯示迴圈摘要並等待操作者按鍵（實作 Approver）
+func (ui *TerminalUI) Approve(ctx context.Context, req *ApprovalRequest) (ApprovalDecision, error) {
+	approval := &tuiApproval{req: req, decision: make(chan ApprovalDecision, 1)}
+	ui.mu.Lock()
+	ui.approval = approval
+	ui.message = fmt.Sprintf("迴圈 %d 等待審核", req.LoopIndex+1)
+	ui.dirty = true
+	ui.mu.Unlock()
+
+	defer func() {
+		ui.mu.Lock()
+		if ui.approval == approval {
+			ui.approval = nil
+			ui.input = nil
+		}
+		ui.dirty = true
+		ui.mu.Unlock()
+	}()
+
+	select {
+	case decision := <-approval.decision:
+		return decision, nil
+	case <-ctx.Done():
+		return ApprovalDecision{}, ctx.Err()
+	case <-ui.stop:
+		return ApprovalDecision{Action: ApprovalStop}, nil
+	}
+}
+
+// HandleKey 處理一個按鍵（原始位元組；多位元組字元在輸入模式中逐位元組組合）
+func (ui *TerminalUI) HandleKey(key byte) {
+	// 控制指令在釋放鎖之後才送出
+	ui.mu.Lock()
+	var command *ControlRequest
+	defer func() {
+		ui.dirty = true
+		control := ui.control
+		ui.mu.Unlock()
+		if command != nil && control != nil {
+			ui.sendControl(control, *command)
+		}
+	}()
+
+	if ui.input != nil {
+		switch key {
+		case '\r', '\n':
+			input := ui.input
+			ui.input = nil
+			command = input.submit(strings.TrimSpace(string(input.buf)))
+		case 0x1b:
+			ui.input = nil
+			ui.message = "已取消"
+		case 0x7f, 0x08:
+			if len(ui.input.buf) > 0 {
+				_, size := utf8.DecodeLastRune(ui.input.buf)
+				ui.input.buf = ui.input.buf[:len(ui.input.buf)-size]
+			}
+		default:
+			if key >= 0x20 {
+				ui.input.buf = append(ui.input.buf, key)
+			}
+		}
+		return
+	}
+
+	if approval := ui.approval; approval != nil {
+		decide := func(action ApprovalAction) {
+			approval.decision <- ApprovalDecision{Action: action, Feedback: approval.feedback}
+			ui.approval = nil
+			ui.message = fmt.Sprintf("迴圈 %d: %s", approval.req.LoopIndex+1, action)
+		}
+		switch key {
+		case 'a', '\r', '\n':
+			decide(ApprovalAccept)
+		case 'r':
+			decide(ApprovalReject)
+		case 's', 'q':
+			decide(ApprovalStop)
+		case 'e':
+			ui.input = &tuiInput{prompt: "審核回饋 > ", buf: []byte(approval.feedback), submit: func(text string) *ControlRequest {
+				approval.feedback = text
+				ui.message = "回饋將隨審核決定送出"
+				return nil
+			}}
+		}
+		return
+	}
+
+	if ui.control == nil {
+		if strings.ContainsRune("psf", rune(key)) {
+			ui.message = "此模式無法控制執行"
+		}
+		return
+	}
+	switch key {
+	case 'p':
+		if ui.state.Paused {
+			command = &ControlRequest{Command: "resume"}
+		} else {
+			command = &ControlRequest{Command: "pause"}
+		}
+	case 's':
+		command = &ControlRequest{Command: "stop"}
+	case 'f':
+		ui.input = &tuiInput{prompt: "回饋 > ", submit: func(text string) *ControlRequest {
+			if text == "" {
+				ui.message = "已取消"
+				return nil
+			}
+			return &ControlRequest{Command: "feedback", Arg: text}
+		}}
+	}
+}
+
+// sendControl 送出控制指令並更新狀態列（不可持有鎖，控制通道可能需要網路往返）
+func (ui *TerminalUI) sendControl(control ControlFunc, req ControlRequest) {
+	state, err := control(req)
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.dirty = true
+	if err != nil {
+		ui.message = fmt.Sprintf("%s 失敗: %v", req.Command, err)
+		return
+	}
+	ui.state, ui.stateErr = state, nil
+	switch req.Command {
+	case "pause":
+		ui.message = "將在目前迴圈結束後暫停"
+	case "resume":
+		ui.message = "已恢復"
+	case "stop":
+		ui.message = "將在目前迴圈結束後停止"
+	case "feedback":
+		ui.message = "回饋將注入下一個迴圈"
+	}
+}
+
+// refreshState 重新取得控制狀態（暫停、最大迴圈數等）
+func (ui *TerminalUI) refreshState() {
+	ui.mu.Lock()
+	control := ui.control
+	ui.mu.Unlock()
+	if control == nil {
+		return
+	}
+	state, err := control(ControlRequest{Command: "status"})
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if state != ui.state || (err == nil) != (ui.stateErr == nil) {
+		ui.dirty = true
+	}
+	ui.state, ui.stateErr = state, err
+}
+
+// Lines 產生目前畫面的每一行（不含控制序列，每行剛好為畫面寬度）
+func (ui *TerminalUI) Lines() []string {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	return ui.lines()
+}
+
+func (ui *TerminalUI) lines() []string {
+	width, height := max(ui.width, 40), max(ui.height, 10)
+
+	logHeight := 0
+	if height >= 20 {
+		logHeight = 2
+	}
+	bodyHeight := height - 2 - logHeight
+	leftWidth := width * 3 / 5
+	rightWidth := width - leftWidth - 1
+
+	left := ui.outputPane(leftWidth, bodyHeight)
+	right := append(ui.loopPane(rightWidth, bodyHeight/2), ui.signalPane(rightWidth, bodyHeight-bodyHeight/2)...)
+
+	screen := make([]string, 0, height)
+	screen = append(screen, fitWidth(ui.header(), width))
+	for i := 0; i < bodyHeight; i++ {
+		screen = append(screen, left[i]+"│"+right[i])
+	}
+	logs := ui.logs
+	if len(logs) > logHeight {
+		logs = logs[len(logs)-logHeight:]
+	}
+	for i := 0; i < logHeight; i++ {
+		line := ""
+		if i < len(logs) {
+			line = logs[i]
+		}
+		screen = append(screen, fitWidth(line, width))
+	}
+	return append(screen, fitWidth(ui.footer(), width))
+}
+
+// header 標題列：run、迴圈進度、熔斷器與執行狀態
+func (ui *TerminalUI) header() string {
+	parts := []string{" Ralph Loop"}
+	if ui.runID != "" {
+		parts = append(parts, ui.runID)
+	}
+	progress := fmt.Sprintf("迴圈 %d", len(ui.loops))
+	if ui.state.MaxLoops > 0 {
+		progress += fmt.Sprintf("/%d", ui.state.MaxLoops)
+	}
+	parts = append(parts, progress, "熔斷器 "+ui.breakerState)
+
+	switch {
+	case ui.approval != nil:
+		parts = append(parts, "等待審核")
+	case ui.control != nil && ui.stateErr != nil:
+		parts = append(parts, "未連線")
+	case ui.state.StopRequested:
+		parts = append(parts, "停止中")
+	case ui.state.Paused:
+		parts = append(parts, "已暫停")
+	case len(ui.loops) > 0 && !ui.loops[len(ui.loops)-1].finished:
+		parts = append(parts, "執行中")
+	}
+	if len(ui.loops) > 0 && !ui.loops[0].started.IsZero() {
+		elapsed := ui.now().Sub(ui.loops[0].started).Round(time.Second)
+		parts = append(parts, "經過 "+elapsed.String())
+	}
+	return strings.Join(parts, " │ ")
+}
+
+// footer 按鍵說明、輸入列或審核提示
+func (ui *TerminalUI) footer() string {
+	var text string
+	switch {
+	case ui.input != nil:
+		return ui.input.prompt + string(ui.input.buf) + "█"
+	case ui.approval != nil:
+		text = "[a]接受 [r]拒絕並還原 [e]編輯回饋 [s]停止"
+	case ui.control != nil:
+		text = "[p]暫停/恢復 [s]停止 [f]回饋  Ctrl+C 中斷"
+	default:
+		text = "Ctrl+C 離開"
+	}
+	if ui.message != "" {
+		text += " │ " + ui.message
+	}
+	return text
+}
+
+// paneTitle 產生窗格標題列
+func paneTitle(title string, width int) string {
+	text := "── " + title + " "
+	if pad := width - displayWidth(text); pad > 0 {
+		text += strings.Repeat("─", pad)
+	}
+	return fitWidth(text, width)
+}
+
+// outputPane 即時輸出（審核時改為顯示迴圈摘要）
+func (ui *TerminalUI) outputPane(width, height int) []string {
+	title := "輸出"
+	source := ui.output
+	if ui.partial != "" {
+		source = append(source[:len(source):len(source)], sanitizeTerminalText(ui.partial))
+	}
+	if ui.approval != nil {
+		title = fmt.Sprintf("迴圈 %d 審核", ui.approval.req.LoopIndex+1)
+		source = strings.Split(strings.TrimSpace(FormatApprovalRequest(ui.approval.req)), "\n")
+		if ui.approval.feedback != "" {
+			source = append(source, "回饋: "+ui.approval.feedback)
+		}
+	}
+
+	// 從最後一行往前折行，只需要處理畫面上看得到的部分
+	var wrapped []string
+	for i := len(source) - 1; i >= 0 && len(wrapped) < height-1; i-- {
+		wrapped = append(wrapWidth(source[i], width), wrapped...)
+	}
+	if len(wrapped) > height-1 {
+		wrapped = wrapped[len(wrapped)-(height-1):]
+	}
+	return fillPane(paneTitle(title, width), wrapped, width, height)
+}
+
+// loopPane 迴圈歷史表格
+func (ui *TerminalUI) loopPane(width, height int) []string {
+	rows := []string{fmt.Sprintf("%4s %s %s  %s", "#", padLeftWidth("耗時", 8), padLeftWidth("分數", 4), "結果")}
+	loops := ui.loops
+	if visible := height - 2; len(loops) > visible {
+		loops = loops[len(loops)-max(visible, 0):]
+	}
+	for _, loop := range loops {
+		duration := "執行中"
+		if loop.finished {
+			duration = loop.duration.Round(100 * time.Millisecond).String()
+		}
+		score := "-"
+		if loop.scored {
+			score = fmt.Sprint(loop.score)
+		}
+		result := "…"
+		switch {
+		case !loop.finished:
+		case loop.failed:
+			result = "失敗"
+		case loop.shouldContinue:
+			result = "繼續"
+		default:
+			result = "完成"
+		}
+		if loop.exitReason != "" {
+			result += " " + loop.exitReason
+		}
+		rows = append(rows, fmt.Sprintf("%4d %s %4s  %s", loop.index+1, padLeftWidth(duration, 8), score, result))
+	}
+	return fillPane(paneTitle("迴圈", width), rows, width, height)
+}
+
+// signalPane 熔斷器狀態、結構化狀態與最近的訊號
+func (ui *TerminalUI) signalPane(width, height int) []string {
+	rows := []string{"熔斷器: " + ui.breakerState}
+	if status := ui.lastStatus; status != nil {
+		line := fmt.Sprintf("狀態: %s EXIT_SIGNAL=%v", status.Status, status.ExitSignal)
+		if status.TasksDone != "" {
+			line += " 任務 " + status.TasksDone
+		}
+		rows = append(rows, line)
+	}
+	signals := ui.signals
+	if visible := height - 1 - len(rows); len(signals) > visible {
+		signals = signals[len(signals)-max(visible, 0):]
+	}
+	rows = append(rows, signals...)
+	return fillPane(paneTitle("訊號", width), rows, width, height)
+}
+
+// fillPane 將標題與內容補齊為剛好 height 行、每行 width 欄
+func fillPane(title string, rows []string, width, height int) []string {
+	pane := make([]string, 0, height)
+	if height > 0 {
+		pane = append(pane, title)
+	}
+	for _, row := range rows {
+		if len(pane) == height {
+			break
+		}
+		pane = append(pane, fitWidth(row, width))
+	}
+	for len(pane) < height {
+		pane = append(pane, strings.Repeat(" ", width))
+	}
+	return pane
+}
+
+// padLeftWidth 依顯示寬度向右對齊
+func padLeftWidth(s string, width int) string {
+	if pad := width - displayWidth(s); pad > 0 {
+		return strings.Repeat(" ", pad) + s
+	}
+	return s
+}
+
+// render 在有變化時重繪整個畫面
+func (ui *TerminalUI) render() {
+	ui.mu.Lock()
+	if !ui.dirty {
+		ui.mu.Unlock()
+		return
+	}
+	ui.dirty = false
+	lines := ui.lines()
+	ui.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i == 0 {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		b.WriteString(line)
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(ui.out, b.String())
+}
+
+// Start 切換到全螢幕畫面並開始讀取按鍵（in 必須是終端機）
+//
+// 終端機維持 ISIG，Ctrl+C 仍會送出中斷信號。無法設定終端機時傳回錯誤，
+// 呼叫端應改用一般的逐行輸出。
+func (ui *TerminalUI) Start(in *os.File) error {
+	saved, err := stty(in, "-g")
+	if err != nil {
+		return fmt.Errorf("無法設定終端機: %w", err)
+	}
+	if _, err := stty(in, "-icanon", "-echo", "min", "1"); err != nil {
+		return fmt.Errorf("無法設定終端機: %w", err)
+	}
+	ui.in, ui.savedTTY = in, saved
+	if width, height, err := terminalSize(in); err == nil {
+		ui.SetSize(width, height)
+	}
+	io.WriteString(ui.out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+
+	ui.startOnce.Do(func() {
+		ui.done.Add(1)
+		go ui.loop()
+		go ui.readKeys()
+	})
+	return nil
+}
+
+// Stop 還原終端機並離開全螢幕畫面（可重複呼叫）
+func (ui *TerminalUI) Stop() {
+	ui.stopOnce.Do(func() {
+		close(ui.stop)
+		ui.done.Wait()
+		if ui.in != nil {
+			io.WriteString(ui.out, "\x1b[?25h\x1b[?1049l")
+			_, _ = stty(ui.in, ui.savedTTY)
+		}
+	})
+}
+
+// loop 定期重繪畫面、更新控制狀態與終端機大小
+func (ui *TerminalUI) loop() {
+	defer ui.done.Done()
+
+	frame := time.NewTicker(100 * time.Millisecond)
+	defer frame.Stop()
+	refresh := time.NewTicker(time.Second)
+	defer refresh.Stop()
+
+	ui.refreshState()
+	for {
+		ui.render()
+		select {
+		case <-frame.C:
+		case <-refresh.C:
+			ui.refreshState()
+			if width, height, err := terminalSize(ui.in); err == nil {
+				ui.mu.Lock()
+				if width != ui.width || height != ui.height {
+					ui.width, ui.height, ui.dirty = width, height, true
+				}
+				ui.mu.Unlock()
+			}
+		case <-ui.stop:
+			return
+		}
+	}
+}
+
+// readKeys 讀取按鍵直到 Stop（阻塞中的讀取會在程序結束時一併結束）
+func (ui *TerminalUI) readKeys() {
+	buf := make([]byte, 64)
+	for {
+		n, err := ui.in.Read(buf)
+		select {
+		case <-ui.stop:
+			return
+		default:
+		}
+		if err != nil {
+			return
+		}
+		for _, key := range buf[:n] {
+			ui.HandleKey(key)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package ghcopilot
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeControl 記錄送出的控制指令
+type fakeControl struct {
+	mu       sync.Mutex
+	requests []ControlRequest
+	state    ControlState
+	err      error
+}
+
+func (f *fakeControl) send(req ControlRequest) (ControlState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	switch req.Command {
+	case "pause":
+		f.state.Paused = true
+	case "resume":
+		f.state.Paused = false
+	case "stop":
+		f.state.StopRequested = true
+	}
+	return f.state, f.err
+}
+
+func (f *fakeControl) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var commands []string
+	for _, req := range f.requests {
+		if req.Command != "status" {
+			commands = append(commands, req.Command+":"+req.Arg)
+		}
+	}
+	return commands
+}
+
+// tuiTestEvents 產生一個完整迴圈與下一個迴圈開始的事件
+func tuiTestEvents() []LoopEvent {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	return []LoopEvent{
+		{Type: EventLoopStarted, RunID: "run-test", LoopIndex: 0, Timestamp: start, Text: "修正測試"},
+		{Type: EventOutputChunk, LoopIndex: 0, Timestamp: start, Stream: StreamStdout, Text: "正在修改 parser.go"},
+		{Type: EventToolCall, LoopIndex: 0, Timestamp: start, Tool: "edit"},
+		{Type: EventLoopAnalyzed, LoopIndex: 0, Timestamp: start, ShouldContinue: true, Data: map[string]interface{}{
+			"completion_score":  35,
+			"structured_status": &LoopStatus{Status: "CONTINUE", TasksDone: "1/3"},
+		}},
+		{Type: EventHookFinished, LoopIndex: 0, Timestamp: start, Data: map[string]interface{}{
+			"point": "post_loop", "command": "go vet ./...", "ok": false, "error": "exit status 1",
+		}},
+		{Type: EventBreakerStateChanged, LoopIndex: 0, Timestamp: start, PreviousState: "CLOSED", BreakerState: "HALF_OPEN"},
+		{Type: EventLoopFinished, LoopIndex: 0, Timestamp: start.Add(3 * time.Second), ShouldContinue: true, Data: map[string]interface{}{
+			"duration_ms": int64(3200),
+		}},
+		{Type: EventLoopStarted, LoopIndex: 1, Timestamp: start.Add(4 * time.Second), Text: "修正測試"},
+		{Type: EventOutputChunk, LoopIndex: 1, Timestamp: start.Add(4 * time.Second), Stream: StreamAssistant, Text: "部分輸出"},
+	}
+}
+
+// TestTerminalUILines 測試畫面內容與版面
+func TestTerminalUILines(t *testing.T) {
+	control := &fakeControl{state: ControlState{MaxLoops: 5}}
+	ui := NewTerminalUI(nil, control.send)
+	ui.now = func() time.Time { return time.Date(2026, 1, 1, 9, 1, 0, 0, time.UTC) }
+	ui.SetSize(140, 24)
+	ui.refreshState()
+	for _, event := range tuiTestEvents() {
+		ui.Handle(event)
+	}
+	ui.LogWriter().Write([]byte("level=WARN msg=注意\n"))
+
+	lines := ui.Lines()
+	if len(lines) != 24 {
+		t.Fatalf("畫面應有 24 行，但有 %d", len(lines))
+	}
+	for i, line := range lines {
+		if displayWidth(line) != 140 {
+			t.Errorf("第 %d 行寬度應為 140，但為 %d: %q", i, displayWidth(line), line)
+		}
+	}
+
+	screen := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"run-test", "迴圈 2/5", "熔斷器 HALF_OPEN", "執行中", "經過 1m0s",
+		"正在修改 parser.go", "🔧 edit", "部分輸出",
+		"3.2s", "35", "繼續",
+		"狀態: CONTINUE EXIT_SIGNAL=false 任務 1/3",
+		"熔斷器 CLOSED → HALF_OPEN", "post_loop 失敗: go vet ./...",
+		"level=WARN msg=注意",
+		"[p]暫停/恢復",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("畫面應包含 %q:\n%s", want, screen)
+		}
+	}
+}
+
+// TestTerminalUIJournalEvents 測試從執行紀錄讀回的事件（資料為 JSON 解碼後的 map）
+func TestTerminalUIJournalEvents(t *testing.T) {
+	path := writeTestJournal(t, t.TempDir(), "run-test", tuiTestEvents()...)
+	events, err := ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ui := NewTerminalUI(nil, nil)
+	for _, event := range events {
+		ui.Handle(event)
+	}
+	if len(ui.loops) != 2 || !ui.loops[0].scored || ui.loops[0].score != 35 || ui.loops[0].duration != 3200*time.Millisecond {
+		t.Errorf("應解析迴圈資料: %+v", ui.loops[0])
+	}
+	if ui.lastStatus == nil || ui.lastStatus.TasksDone != "1/3" {
+		t.Errorf("應解析結構化狀態: %+v", ui.lastStatus)
+	}
+
+	ui.Reset()
+	if len(ui.loops) != 0 || ui.runID != "" || ui.breakerState != string(StateClosed) {
+		t.Error("Reset 應清除目前的執行")
+	}
+}
+
+// TestTerminalUIKeys 測試控制按鍵
+func TestTerminalUIKeys(t *testing.T) {
+	control := &fakeControl{}
+	ui := NewTerminalUI(nil, control.send)
+
+	ui.HandleKey('p')
+	ui.HandleKey('p')
+	for _, key := range []byte("f修正 parser\x7f\x7f\x7fser\r") {
+		ui.HandleKey(key)
+	}
+	ui.HandleKey('f')
+	ui.HandleKey(0x1b) // 取消輸入
+	ui.HandleKey('s')
+
+	want := []string{"pause:", "resume:", "feedback:修正 parser", "stop:"}
+	if got := control.commands(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("控制指令應為 %v，但為 %v", want, got)
+	}
+	if !strings.Contains(ui.Lines()[0], "停止中") {
+		t.Errorf("標題應顯示停止中: %q", ui.Lines()[0])
+	}
+
+	control.err = errors.New("連線失敗")
+	ui.HandleKey('s')
+	if !strings.Contains(ui.footer(), "stop 失敗: 連線失敗") {
+		t.Errorf("應顯示控制失敗: %q", ui.footer())
+	}
+
+	readOnly := NewTerminalUI(nil, nil)
+	readOnly.HandleKey('p')
+	if !strings.Contains(readOnly.footer(), "無法控制") {
+		t.Errorf("沒有控制時應提示: %q", readOnly.footer())
+	}
+}
+
+// TestTerminalUIApprove 測試以按鍵審核迴圈
+func TestTerminalUIApprove(t *testing.T) {
+	ui := NewTerminalUI(nil, nil)
+	ui.SetSize(100, 24)
+
+	type result struct {
+		decision ApprovalDecision
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		decision, err := ui.Approve(context.Background(), &ApprovalRequest{
+			LoopIndex: 2,
+			Changes:   []FileChange{{Path: "parser.go", Kind: FileModified, LinesAdded: 3}},
+			Stats:     ChangeStats{FilesChanged: 1, LinesAdded: 3},
+		})
+		done <- result{decision, err}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(strings.Join(ui.Lines(), "\n"), "parser.go") {
+		if time.Now().After(deadline) {
+			t.Fatal("畫面應顯示審核摘要")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !strings.Contains(ui.footer(), "[a]接受") {
+		t.Errorf("應顯示審核按鍵: %q", ui.footer())
+	}
+
+	for _, key := range []byte("e先補測試\rr") {
+		ui.HandleKey(key)
+	}
+	res := <-done
+	if res.err != nil || res.decision.Action != ApprovalReject || res.decision.Feedback != "先補測試" {
+		t.Errorf("應拒絕並附上回饋: %+v %v", res.decision, res.err)
+	}
+
+	// context 取消時結束等待
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ui.Approve(ctx, &ApprovalRequest{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("context 取消時應傳回錯誤: %v", err)
+	}
+}
+
+// TestTerminalUIStopWithoutStart 測試未啟動時 Stop 不會阻塞
+func TestTerminalUIStopWithoutStart(t *testing.T) {
+	ui := NewTerminalUI(nil, nil)
+	ui.Stop()
+	ui.Stop()
+
+	decision, err := ui.Approve(context.Background(), &ApprovalRequest{})
+	if err != nil || decision.Action != ApprovalStop {
+		t.Errorf("介面停止後審核應視為停止: %+v %v", decision, err)
+	}
+}
+
+// TestDisplayWidth 測試全形字的顯示寬度
+func TestDisplayWidth(t *testing.T) {
+	if got := displayWidth("迴圈 ab"); got != 7 {
+		t.Errorf("寬度應為 7，但為 %d", got)
+	}
+	if got := fitWidth("中文ab", 5); got != "中文a" {
+		t.Errorf("截斷結果不符: %q", got)
+	}
+	if got := fitWidth("中文", 3); got != "中 " {
+		t.Errorf("全形字不應被切半: %q", got)
+	}
+	if got := wrapWidth("中文中文ab", 4); strings.Join(got, "|") != "中文|中文|ab" {
+		t.Errorf("折行結果不符: %v", got)
+	}
+	if got := sanitizeTerminalText("\x1b[31mred\x1b[0m\tx\a"); got != "red    x" {
+		t.Errorf("應移除控制序列: %q", got)
+	}
+}
+
+// TestFollowJournal 測試追蹤持續寫入的執行紀錄
+func TestFollowJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", JournalFileName)
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	journal.Write(LoopEvent{Type: EventLoopStarted})
+
+	var mu sync.Mutex
+	var types []EventType
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- FollowJournal(ctx, path, 5*time.Millisecond, func(event LoopEvent) {
+			mu.Lock()
+			types = append(types, event.Type)
+			mu.Unlock()
+		})
+	}()
+
+	journal.Write(LoopEvent{Type: EventLoopFinished})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(types)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("應收到新寫入的事件: %v", types)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("FollowJournal 應在 ctx 結束時正常返回: %v", err)
+	}
+}
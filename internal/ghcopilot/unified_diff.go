@@ -0,0 +1,176 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContextLines unified diff 每個區塊前後的上下文行數
+const diffContextLines = 3
+
+// maxDiffCells 逐行比對的表格上限（去除相同的開頭與結尾後，舊行數 × 新行數）
+const maxDiffCells = 4_000_000
+
+// diffOp 是編輯腳本中的一行：' ' 相同、'-' 刪除、'+' 新增
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff 產生單一檔案的 unified diff（與 diff -u / git diff 相同格式）
+//
+// before 為 nil 表示新增的檔案，after 為 nil 表示刪除的檔案；內容相同時傳回空字串。
+func UnifiedDiff(path string, before, after []byte) string {
+	oldName, newName := "a/"+path, "b/"+path
+	if before == nil {
+		oldName = "/dev/null"
+	}
+	if after == nil {
+		newName = "/dev/null"
+	}
+	return unifiedDiff(oldName, newName, before, after)
+}
+
+// unifiedDiff 以指定的檔頭名稱產生 unified diff
+func unifiedDiff(oldName, newName string, before, after []byte) string {
+	oldLines, newLines := splitLines(before), splitLines(after)
+
+	if bytes.IndexByte(before, 0) >= 0 || bytes.IndexByte(after, 0) >= 0 {
+		return fmt.Sprintf("--- %s\n+++ %s\n@@ 二進位檔案，省略逐行差異 @@\n", oldName, newName)
+	}
+
+	ops, ok := diffLines(oldLines, newLines)
+	if !ok {
+		added, removed := countLineChanges(before, after)
+		return fmt.Sprintf("--- %s\n+++ %s\n@@ 檔案過大，省略逐行差異 (+%d -%d) @@\n", oldName, newName, added, removed)
+	}
+
+	var b strings.Builder
+	for _, hunk := range diffHunks(ops) {
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		b.WriteString(hunk)
+	}
+	return b.String()
+}
+
+// diffLines 以最長共同子序列計算編輯腳本；超過表格上限時傳回 false
+func diffLines(oldLines, newLines []string) ([]diffOp, bool) {
+	// 相同的開頭與結尾不需要進入比對表格
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	a := oldLines[prefix : len(oldLines)-suffix]
+	b := newLines[prefix : len(newLines)-suffix]
+	if len(a)*len(b) > maxDiffCells {
+		return nil, false
+	}
+
+	ops := make([]diffOp, 0, len(oldLines)+len(b))
+	for _, line := range oldLines[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	// lcs[i*(m+1)+j] 為 a[i:] 與 b[j:] 的最長共同子序列長度
+	n, m := len(a), len(b)
+	lcs := make([]uint16, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < n && (j == m || lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+			// 刪除排在新增之前，與 diff -u 相同
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+
+	for _, line := range oldLines[len(oldLines)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops, true
+}
+
+// diffHunks 將編輯腳本分組為帶上下文的區塊
+func diffHunks(ops []diffOp) []string {
+	// oldBefore[k]/newBefore[k] 為第 k 個操作之前已出現的舊/新行數
+	oldBefore := make([]int, len(ops)+1)
+	newBefore := make([]int, len(ops)+1)
+	for k, op := range ops {
+		oldBefore[k+1], newBefore[k+1] = oldBefore[k], newBefore[k]
+		if op.kind != '+' {
+			oldBefore[k+1]++
+		}
+		if op.kind != '-' {
+			newBefore[k+1]++
+		}
+	}
+
+	var hunks []string
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+
+		// 合併彼此間隔不超過兩倍上下文的變更
+		start := max(k-diffContextLines, 0)
+		end := k
+		for {
+			next := end + 1
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next < len(ops) && next-end-1 <= 2*diffContextLines {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := min(end+1+diffContextLines, len(ops))
+
+		oldCount := oldBefore[stop] - oldBefore[start]
+		newCount := newBefore[stop] - newBefore[start]
+		oldStart, newStart := oldBefore[start]+1, newBefore[start]+1
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:stop] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.text)
+			b.WriteByte('\n')
+		}
+		hunks = append(hunks, b.String())
+		k = stop
+	}
+	return hunks
+}
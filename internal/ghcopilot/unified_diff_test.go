@@ -0,0 +1,83 @@
+package ghcopilot
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestUnifiedDiff 測試 unified diff 的區塊與行號
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+
+	want := `--- a/x.txt
++++ b/x.txt
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+`
+	if got := UnifiedDiff("x.txt", []byte(before), []byte(after)); got != want {
+		t.Errorf("diff 不符\n得到:\n%s\n預期:\n%s", got, want)
+	}
+
+	// 變更間隔不超過兩倍上下文時合併為一個區塊
+	merged := UnifiedDiff("x.txt", []byte("1\n2\n3\n4\n5\n6\n7\n8\n"), []byte("1\nX\n3\n4\n5\n6\n7\nY\n"))
+	if strings.Count(merged, "@@") != 2 || !strings.Contains(merged, "@@ -1,8 +1,8 @@") {
+		t.Errorf("相近的變更應合併:\n%s", merged)
+	}
+
+	if got := UnifiedDiff("x.txt", []byte("same\n"), []byte("same\n")); got != "" {
+		t.Errorf("相同內容應傳回空字串: %q", got)
+	}
+}
+
+// TestUnifiedDiffAddedDeleted 測試新增與刪除的檔案
+func TestUnifiedDiffAddedDeleted(t *testing.T) {
+	added := UnifiedDiff("new.go", nil, []byte("package x\n"))
+	if added != "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1,1 @@\n+package x\n" {
+		t.Errorf("新增檔案的 diff 不符:\n%s", added)
+	}
+
+	deleted := UnifiedDiff("old.go", []byte("package x\n"), nil)
+	if deleted != "--- a/old.go\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-package x\n" {
+		t.Errorf("刪除檔案的 diff 不符:\n%s", deleted)
+	}
+
+	binary := UnifiedDiff("bin", []byte("a\x00b"), []byte("a\x00c"))
+	if !strings.Contains(binary, "二進位") {
+		t.Errorf("二進位檔案應省略逐行差異:\n%s", binary)
+	}
+}
+
+// TestWorkspaceSnapshotUnifiedDiff 測試快照之間的 diff
+func TestWorkspaceSnapshotUnifiedDiff(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "main.go", "package main\n")
+	writeTestFile(t, root, "big.bin", strings.Repeat("x", maxSnapshotFileSize+1))
+	before, _ := TakeWorkspaceSnapshot(root)
+
+	writeTestFile(t, root, "main.go", "package main\n\nfunc main() {}\n")
+	writeTestFile(t, root, "big.bin", strings.Repeat("y", maxSnapshotFileSize+1))
+	writeTestFile(t, root, "pkg/new.go", "package pkg\n")
+	after, _ := TakeWorkspaceSnapshot(root)
+
+	diff := before.UnifiedDiff(after, before.Diff(after))
+	for _, want := range []string{
+		"--- a/main.go\n+++ b/main.go\n@@ -1,1 +1,3 @@\n package main\n+\n+func main() {}\n",
+		"--- /dev/null\n+++ b/pkg/new.go\n",
+		"--- a/big.bin\n+++ b/big.bin\n@@ 檔案過大",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff 應包含 %q:\n%s", want, diff)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package ghcopilot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSnapshotFileSize 超過此大小的檔案只以串流計算雜湊，不保留內容（無法計算行數或還原）
+const maxSnapshotFileSize = 2 << 20
+
+// defaultSnapshotExcludes 快照時略過的目錄與檔案名稱
+var defaultSnapshotExcludes = []string{
+	".git",
+	".ralph-loop",
+	"node_modules",
+	".circuit_breaker_state",
+	".exit_signals",
+}
+
+// FileChangeKind 代表檔案變更的類型
+type FileChangeKind string
+
+const (
+	// FileAdded 新增的檔案
+	FileAdded FileChangeKind = "added"
+	// FileModified 修改的檔案
+	FileModified FileChangeKind = "modified"
+	// FileDeleted 刪除的檔案
+	FileDeleted FileChangeKind = "deleted"
+)
+
+// FileChange 代表兩次快照之間單一檔案的變更
+type FileChange struct {
+	Path         string         `json:"path"`                // 相對於工作目錄的路徑（使用 / 分隔）
+	Kind         FileChangeKind `json:"kind"`                // 變更類型
+	LinesAdded   int            `json:"lines_added"`         // 新增行數
+	LinesRemoved int            `json:"lines_removed"`       // 刪除行數
+	Oversized    bool           `json:"oversized,omitempty"` // 檔案過大，快照未保留內容，行數未計入且無法還原
+}
+
+// snapshotEntry 快照中的單一檔案
+type snapshotEntry struct {
+	hash    [sha256.Size]byte
+	content []byte // 超過 maxSnapshotFileSize 時為 nil
+	large   bool   // 超過 maxSnapshotFileSize，未保留內容
+	mode    fs.FileMode
+}
+
+// WorkspaceSnapshot 記錄某一時間點工作目錄中的檔案內容
+//
+// 用於在迴圈執行前後比對工作目錄，找出 AI 修改了哪些檔案，
+// 並在需要時將特定檔案還原到快照時的狀態。
+type WorkspaceSnapshot struct {
+	root     string
+	files    map[string]*snapshotEntry
+	excludes map[string]bool
+	TakenAt  time.Time
+}
+
+// TakeWorkspaceSnapshot 建立工作目錄的快照
+//
+// excludes 為額外略過的目錄或檔案名稱：相對於 root 的路徑、基本名稱，
+// 或絕對路徑（轉換為相對於 root 的路徑，不在 root 之內時忽略）。
+func TakeWorkspaceSnapshot(root string, excludes ...string) (*WorkspaceSnapshot, error) {
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("無法建立工作目錄快照: %w", err)
+	}
+
+	snapshot := &WorkspaceSnapshot{
+		root:     root,
+		files:    make(map[string]*snapshotEntry),
+		excludes: make(map[string]bool),
+		TakenAt:  time.Now(),
+	}
+	for _, name := range append(append([]string{}, defaultSnapshotExcludes...), excludes...) {
+		if name == "" {
+			continue
+		}
+		if filepath.IsAbs(name) {
+			rel, err := filepath.Rel(absRoot, name)
+			if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			name = rel
+		}
+		snapshot.excludes[filepath.ToSlash(filepath.Clean(name))] = true
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+
+		if snapshot.isExcluded(rel, d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entry := &snapshotEntry{mode: info.Mode().Perm()}
+		if info.Size() > maxSnapshotFileSize {
+			entry.large = true
+			if entry.hash, err = hashFile(path); err != nil {
+				return err
+			}
+		} else {
+			if entry.content, err = os.ReadFile(path); err != nil {
+				return err
+			}
+			entry.hash = sha256.Sum256(entry.content)
+		}
+		snapshot.files[rel] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("無法建立工作目錄快照: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// hashFile 以串流計算檔案的 SHA-256（不將整個檔案讀入記憶體）
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// isExcluded 檢查路徑是否應略過
+func (s *WorkspaceSnapshot) isExcluded(rel, name string) bool {
+	return s.excludes[rel] || s.excludes[name]
+}
+
+// FileCount 傳回快照中的檔案數
+func (s *WorkspaceSnapshot) FileCount() int {
+	return len(s.files)
+}
+
+// Root 傳回快照的工作目錄
+func (s *WorkspaceSnapshot) Root() string {
+	return s.root
+}
+
+// Diff 比對另一個（較新的）快照，傳回依路徑排序的檔案變更
+func (s *WorkspaceSnapshot) Diff(after *WorkspaceSnapshot) []FileChange {
+	var changes []FileChange
+
+	for path, before := range s.files {
+		current, ok := after.files[path]
+		if !ok {
+			_, removed := countLineChanges(before.content, nil)
+			changes = append(changes, FileChange{Path: path, Kind: FileDeleted, LinesRemoved: removed, Oversized: before.large})
+			continue
+		}
+		if !bytes.Equal(before.hash[:], current.hash[:]) {
+			added, removed := countLineChanges(before.content, current.content)
+			changes = append(changes, FileChange{Path: path, Kind: FileModified, LinesAdded: added, LinesRemoved: removed,
+				Oversized: before.large || current.large})
+		}
+	}
+
+	for path, current := range after.files {
+		if _, ok := s.files[path]; !ok {
+			added, _ := countLineChanges(nil, current.content)
+			changes = append(changes, FileChange{Path: path, Kind: FileAdded, LinesAdded: added, Oversized: current.large})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// UnifiedDiff 產生 changes 中各檔案從本快照到 after 的 unified diff
+//
+// 快照未保留內容的大型檔案只輸出一行說明。
+func (s *WorkspaceSnapshot) UnifiedDiff(after *WorkspaceSnapshot, changes []FileChange) string {
+	var b strings.Builder
+	for _, change := range changes {
+		before, hadBefore := s.files[change.Path]
+		current, hasAfter := after.files[change.Path]
+		if (hadBefore && before.large) || (hasAfter && current.large) {
+			fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n@@ 檔案過大，快照未保留內容 @@\n", change.Path, change.Path)
+			continue
+		}
+
+		var oldContent, newContent []byte
+		if hadBefore {
+			oldContent = before.content
+		}
+		if hasAfter {
+			newContent = current.content
+		}
+		b.WriteString(UnifiedDiff(change.Path, oldContent, newContent))
+	}
+	return b.String()
+}
+
+// Restore 將指定路徑還原到快照時的狀態
+//
+// 快照中存在的檔案會被寫回原內容；快照中不存在的檔案（新增的）會被刪除。
+func (s *WorkspaceSnapshot) Restore(paths ...string) error {
+	for _, rel := range paths {
+		target := filepath.Join(s.root, filepath.FromSlash(rel))
+
+		entry, ok := s.files[rel]
+		if !ok {
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("無法刪除新增的檔案 %s: %w", rel, err)
+			}
+			continue
+		}
+
+		if entry.large {
+			return fmt.Errorf("檔案 %s 過大，快照未保留內容，無法還原", rel)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("無法建立目錄 %s: %w", filepath.Dir(rel), err)
+		}
+		if err := os.WriteFile(target, entry.content, entry.mode); err != nil {
+			return fmt.Errorf("無法還原檔案 %s: %w", rel, err)
+		}
+	}
+
+	return nil
+}
+
+// countLineChanges 計算兩份內容之間新增與刪除的行數
+//
+// 以行的多重集合比較（不考慮行的移動），結果近似 diff --stat。
+// 超過快照大小上限的檔案沒有內容，行數視為 0（FileChange.Oversized 標記這類變更）。
+func countLineChanges(before, after []byte) (added, removed int) {
+	remaining := make(map[string]int)
+	for _, line := range splitLines(before) {
+		remaining[line]++
+	}
+
+	for _, line := range splitLines(after) {
+		if remaining[line] > 0 {
+			remaining[line]--
+		} else {
+			added++
+		}
+	}
+
+	for _, count := range remaining {
+		removed += count
+	}
+	return added, removed
+}
+
+// splitLines 將內容切分為行（忽略結尾換行）
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// SummarizeChanges 統計變更的檔案數與行數
+func SummarizeChanges(changes []FileChange) ChangeStats {
+	stats := ChangeStats{FilesChanged: len(changes)}
+	for _, change := range changes {
+		stats.LinesAdded += change.LinesAdded
+		stats.LinesRemoved += change.LinesRemoved
+	}
+	return stats
+}
+
+// ChangeStats 代表變更的統計
+type ChangeStats struct {
+	FilesChanged int `json:"files_changed"`
+	LinesAdded   int `json:"lines_added"`
+	LinesRemoved int `json:"lines_removed"`
+}
+
+// Add 累加另一份統計
+func (s *ChangeStats) Add(other ChangeStats) {
+	s.FilesChanged += other.FilesChanged
+	s.LinesAdded += other.LinesAdded
+	s.LinesRemoved += other.LinesRemoved
+}
+
+// Sub 扣除另一份統計（例如被還原的變更）
+func (s *ChangeStats) Sub(other ChangeStats) {
+	s.FilesChanged -= other.FilesChanged
+	s.LinesAdded -= other.LinesAdded
+	s.LinesRemoved -= other.LinesRemoved
+}
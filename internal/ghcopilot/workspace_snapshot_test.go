@@ -0,0 +1,152 @@
+package ghcopilot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile 在測試目錄中寫入檔案（自動建立目錄）
+func writeTestFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWorkspaceSnapshotDiff 測試快照比對
+func TestWorkspaceSnapshotDiff(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "main.go", "package main\n")
+	writeTestFile(t, root, "pkg/util.go", "package pkg\n")
+	writeTestFile(t, root, "old.txt", "old\n")
+
+	before, err := TakeWorkspaceSnapshot(root)
+	if err != nil {
+		t.Fatalf("TakeWorkspaceSnapshot 失敗: %v", err)
+	}
+	if before.FileCount() != 3 {
+		t.Errorf("快照應有 3 個檔案，但有 %d", before.FileCount())
+	}
+
+	writeTestFile(t, root, "main.go", "package main\n\nfunc main() {}\n")
+	writeTestFile(t, root, "pkg/new.go", "package pkg\n")
+	os.Remove(filepath.Join(root, "old.txt"))
+
+	after, _ := TakeWorkspaceSnapshot(root)
+	changes := before.Diff(after)
+
+	want := []FileChange{
+		{Path: "main.go", Kind: FileModified},
+		{Path: "old.txt", Kind: FileDeleted},
+		{Path: "pkg/new.go", Kind: FileAdded},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("應有 %d 個變更，但為 %v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i].Path != want[i].Path || changes[i].Kind != want[i].Kind {
+			t.Errorf("變更 %d 應為 %v，但為 %v", i, want[i], changes[i])
+		}
+	}
+
+	// main.go 新增 2 行（空行與 func main），old.txt 刪除 1 行
+	if changes[0].LinesAdded != 2 || changes[0].LinesRemoved != 0 {
+		t.Errorf("main.go 行數統計不正確: %+v", changes[0])
+	}
+	if changes[1].LinesRemoved != 1 || changes[2].LinesAdded != 1 {
+		t.Errorf("刪除/新增檔案行數統計不正確: %+v %+v", changes[1], changes[2])
+	}
+
+	stats := SummarizeChanges(changes)
+	if stats.FilesChanged != 3 || stats.LinesAdded != 3 || stats.LinesRemoved != 1 {
+		t.Errorf("統計不正確: %+v", stats)
+	}
+}
+
+// TestCountLineChanges 測試行數計算
+func TestCountLineChanges(t *testing.T) {
+	added, removed := countLineChanges([]byte("a\nb\nc\n"), []byte("a\nB\nc\nd\n"))
+	if added != 2 || removed != 1 {
+		t.Errorf("應為 +2 -1，但為 +%d -%d", added, removed)
+	}
+
+	added, removed = countLineChanges(nil, nil)
+	if added != 0 || removed != 0 {
+		t.Error("空內容應無變更")
+	}
+}
+
+// TestWorkspaceSnapshotExcludes 測試略過的目錄
+func TestWorkspaceSnapshotExcludes(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, ".git/HEAD", "ref\n")
+	writeTestFile(t, root, ".ralph-loop/saves/a.json", "{}")
+	writeTestFile(t, root, "build/out.bin", "bin")
+	writeTestFile(t, root, "main.go", "package main\n")
+
+	writeTestFile(t, root, "state/saves/b.json", "{}")
+
+	snapshot, err := TakeWorkspaceSnapshot(root, "build", filepath.Join(root, "state"), filepath.Join(t.TempDir(), "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.FileCount() != 1 {
+		t.Errorf("應只記錄 main.go，但有 %d 個檔案", snapshot.FileCount())
+	}
+}
+
+// TestWorkspaceSnapshotLargeFiles 測試超過大小上限的檔案
+func TestWorkspaceSnapshotLargeFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "big.bin", strings.Repeat("x\n", maxSnapshotFileSize))
+	before, _ := TakeWorkspaceSnapshot(root)
+	if entry := before.files["big.bin"]; !entry.large || entry.content != nil {
+		t.Fatalf("大型檔案不應保留內容: %+v", entry)
+	}
+
+	writeTestFile(t, root, "big.bin", strings.Repeat("y\n", maxSnapshotFileSize))
+	after, _ := TakeWorkspaceSnapshot(root)
+	changes := before.Diff(after)
+	if len(changes) != 1 || changes[0].Kind != FileModified || !changes[0].Oversized {
+		t.Fatalf("應以雜湊偵測到大型檔案的變更並標記 Oversized: %+v", changes)
+	}
+	if err := before.Restore("big.bin"); err == nil {
+		t.Error("大型檔案無法還原，應回報錯誤")
+	}
+}
+
+// TestWorkspaceSnapshotRestore 測試還原檔案
+func TestWorkspaceSnapshotRestore(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "go.mod", "module example\n")
+
+	before, _ := TakeWorkspaceSnapshot(root)
+
+	writeTestFile(t, root, "go.mod", "module hacked\n")
+	writeTestFile(t, root, "extra/new.go", "package extra\n")
+	os.Remove(filepath.Join(root, "go.mod"))
+	writeTestFile(t, root, "go.mod", "module hacked\n")
+
+	if err := before.Restore("go.mod", "extra/new.go"); err != nil {
+		t.Fatalf("Restore 失敗: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(root, "go.mod"))
+	if string(data) != "module example\n" {
+		t.Errorf("go.mod 應被還原，但為 %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "extra/new.go")); !os.IsNotExist(err) {
+		t.Error("新增的檔案應被刪除")
+	}
+
+	after, _ := TakeWorkspaceSnapshot(root)
+	if changes := before.Diff(after); len(changes) != 0 {
+		t.Errorf("還原後不應有變更，但為 %v", changes)
+	}
+}
@@ -0,0 +1,19 @@
+package calc
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		nums []int
+		want int
+	}{
+		{nil, 0},
+		{[]int{5}, 5},
+		{[]int{1, 2, 3}, 6},
+	}
+	for _, tt := range tests {
+		if got := Sum(tt.nums); got != tt.want {
+			t.Errorf("Sum(%v) = %d, want %d", tt.nums, got, tt.want)
+		}
+	}
+}
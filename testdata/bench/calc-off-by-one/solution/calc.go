@@ -0,0 +1,10 @@
+package calc
+
+// Sum 傳回所有數字的總和
+func Sum(nums []int) int {
+	total := 0
+	for _, n := range nums {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,16 @@
+package stack
+
+// Stack 是整數堆疊
+type Stack struct {
+	items []int
+}
+
+// Push 放入一個元素
+func (s *Stack) Push(v int) {
+	s.items = append(s.items, v)
+}
+
+// Len 傳回元素數
+func (s *Stack) Len() int {
+	return len(s.items)
+}
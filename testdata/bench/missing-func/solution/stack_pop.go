@@ -0,0 +1,11 @@
+package stack
+
+// Pop 取出最後放入的元素（空堆疊時傳回 false）
+func (s *Stack) Pop() (int, bool) {
+	if len(s.items) == 0 {
+		return 0, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
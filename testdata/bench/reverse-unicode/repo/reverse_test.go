@@ -0,0 +1,16 @@
+package textutil
+
+import "testing"
+
+func TestReverse(t *testing.T) {
+	tests := map[string]string{
+		"":     "",
+		"abc":  "cba",
+		"迴圈測試": "試測圈迴",
+	}
+	for in, want := range tests {
+		if got := Reverse(in); got != want {
+			t.Errorf("Reverse(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
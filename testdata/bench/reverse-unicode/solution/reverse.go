@@ -0,0 +1,10 @@
+package textutil
+
+// Reverse 傳回反轉後的字串
+func Reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}